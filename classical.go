@@ -0,0 +1,86 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ClassicalWork is the work and movement of a track of classical music.
+type ClassicalWork struct {
+	Work          string
+	Movement      string // name of the movement
+	MovementIndex int    // number of the movement in the work, from 1
+	MovementCount int    // number of movements in the work
+	ShowMovement  bool   // display the work and movement in place of the title
+}
+
+// Classical returns the work and movement of the metadata: the iTunes atoms (©wrk, ©mvn,
+// ©mvi, ©mvc and shwm) of MP4 files, the iTunes MVNM and MVIN frames of ID3v2 tags (with
+// the work in a WORK user text frame, or in TIT1 when iTunes uses GRP1 for the grouping),
+// and the WORK, MOVEMENTNAME, MOVEMENT, MOVEMENTTOTAL and SHOWMOVEMENT fields of Vorbis
+// comments.
+func Classical(m Metadata) ClassicalWork {
+	str := func(k string) string { return rawText(m, k) }
+
+	switch m.Format() {
+	case MP4:
+		mp4, ok := m.(*metadataMP4)
+		if !ok {
+			return ClassicalWork{}
+		}
+		num := func(k string) int { return mp4.getInt([]string{k}) }
+		return ClassicalWork{
+			Work:          str(AtomWork),
+			Movement:      str(AtomMovement),
+			MovementIndex: num(AtomMovementIndex),
+			MovementCount: num(AtomMovementCount),
+			ShowMovement:  num(AtomShowMovement) != 0,
+		}
+
+	case ID3v2_3, ID3v2_4:
+		frames := id3v2FramesOf(m)
+		c := ClassicalWork{
+			Work:         userText(frames, "WORK"),
+			Movement:     str("MVNM"),
+			ShowMovement: userText(frames, "SHOWMOVEMENT") == "1",
+		}
+		if _, ok := frames["GRP1"]; ok && c.Work == "" {
+			c.Work = str("TIT1")
+		}
+		c.MovementIndex, c.MovementCount = parseXofN(str("MVIN"))
+		return c
+
+	case VORBIS:
+		num := func(k string) int {
+			x, _ := strconv.Atoi(strings.TrimSpace(str(k)))
+			return x
+		}
+		return ClassicalWork{
+			Work:          str("work"),
+			Movement:      str("movementname"),
+			MovementIndex: num("movement"),
+			MovementCount: num("movementtotal"),
+			ShowMovement:  num("showmovement") != 0,
+		}
+	}
+	return ClassicalWork{}
+}
+
+// userText returns the text of the ID3v2 user text frame (TXXX) with the description,
+// ignoring case, from the frames.
+func userText(frames map[string]interface{}, description string) string {
+	if s, ok := frames[UserTextPrefix+description].(string); ok {
+		return s
+	}
+	for k, v := range frames {
+		if strings.HasPrefix(k, UserTextPrefix) && strings.EqualFold(k[len(UserTextPrefix):], description) {
+			s, _ := v.(string)
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestClassical(t *testing.T) {
+	// testID3 returns an ID3v2.3 tag with the frames.
+	testID3 := func(frames ...[]byte) []byte {
+		b := bytes.Join(frames, nil)
+		return append(testID3v23(len(b)+10, b), make([]byte, 10)...)
+	}
+
+	mp4 := testM4A([][]byte{
+		testTextItem(AtomWork, "Symphony No. 5"),
+		testTextItem(AtomMovement, "Allegro con brio"),
+		testIntItem(AtomMovementIndex, 0, 1),
+		testIntItem(AtomMovementCount, 0, 4),
+		testIntItem(AtomShowMovement, 1),
+	})
+
+	id3 := testID3(
+		testID3v23Frame("TXXX", []byte("\x00WORK\x00Symphony No. 5")),
+		testID3v23Frame("MVNM", []byte("\x00Andante con moto")),
+		testID3v23Frame("MVIN", []byte("\x002/4")),
+		testID3v23Frame("TXXX", []byte("\x00SHOWMOVEMENT\x001")),
+	)
+	itunes := testID3(
+		testID3v23Frame("TIT1", []byte("\x00Symphony No. 5")),
+		testID3v23Frame("GRP1", []byte("\x00Beethoven")),
+		testID3v23Frame("MVIN", []byte("\x003")),
+	)
+	grouping := testID3(testID3v23Frame("TIT1", []byte("\x00Grouping")))
+
+	flac := testFLAC(t, []string{
+		"WORK=Symphony No. 5", "MOVEMENTNAME=Allegro", "MOVEMENT=4", "MOVEMENTTOTAL=4", "SHOWMOVEMENT=0",
+	}, 0)
+
+	tests := []struct {
+		input []byte
+		read  func([]byte) (Metadata, error)
+		want  ClassicalWork
+	}{
+		{mp4, readAtomsBytes, ClassicalWork{"Symphony No. 5", "Allegro con brio", 1, 4, true}},
+		{id3, readID3v2Bytes, ClassicalWork{"Symphony No. 5", "Andante con moto", 2, 4, true}},
+		{itunes, readID3v2Bytes, ClassicalWork{Work: "Symphony No. 5", MovementIndex: 3}},
+		{grouping, readID3v2Bytes, ClassicalWork{}},
+		{flac, readFLACBytes, ClassicalWork{"Symphony No. 5", "Allegro", 4, 4, false}},
+		{testM4A(nil), readAtomsBytes, ClassicalWork{}},
+	}
+
+	for ii, tt := range tests {
+		m, err := tt.read(tt.input)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := Classical(m); got != tt.want {
+			t.Errorf("[%d] Classical() = %+v, expected %+v", ii, got, tt.want)
+		}
+	}
+
+	if got := Classical(metadataID3v1{}); got != (ClassicalWork{}) {
+		t.Errorf("Classical() = %+v for ID3v1 metadata, expected nothing", got)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chaolong-1995/audiotag"
+)
+
+var artCmd = &command{
+	name:  "art",
+	usage: "extract [-o output] file | embed image file...",
+	short: "extract or embed cover artwork",
+	run:   runArt,
+}
+
+func runArt(c *command, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "extract":
+			return runArtExtract(c, args[1:])
+		case "embed":
+			return runArtEmbed(c, args[1:])
+		}
+	}
+	fs := newFlagSet(c)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	fs.Usage()
+	return errUsage
+}
+
+// pictureExt returns the file extension (without the leading dot) for the picture.
+func pictureExt(p *audiotag.Picture) string {
+	switch p.MIMEType {
+	case "image/jpeg", "image/jpg":
+		return "jpg"
+	case "image/png":
+		return "png"
+	case "image/gif":
+		return "gif"
+	case "image/bmp":
+		return "bmp"
+	}
+	if p.Ext != "" {
+		return strings.ToLower(p.Ext)
+	}
+	return "bin"
+}
+
+func runArtExtract(c *command, args []string) error {
+	fs := newFlagSet(c)
+	out := fs.String("o", "", "write the image to `file` (\"-\" for stdout), defaults to \"cover\" with the extension of the image type")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	m, err := readFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	p := m.Picture()
+	if p == nil {
+		return errors.New("no artwork found")
+	}
+
+	switch *out {
+	case "-":
+		_, err = os.Stdout.Write(p.Data)
+		return err
+	case "":
+		*out = "cover." + pictureExt(p)
+	}
+	if err := ioutil.WriteFile(*out, p.Data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%s: wrote %v (%d bytes) to %s\n", fs.Arg(0), p.MIMEType, len(p.Data), *out)
+	return nil
+}
+
+// readPicture reads the image file at path as a front cover picture.
+func readPicture(path string) (*audiotag.Picture, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType := http.DetectContentType(b)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, fmt.Errorf("%s: unsupported image type %q", path, mimeType)
+	}
+	p := &audiotag.Picture{
+		MIMEType: mimeType,
+		Type:     "Cover (front)",
+		Data:     b,
+	}
+	p.Ext = pictureExt(p)
+	return p, nil
+}
+
+func runArtEmbed(c *command, args []string) error {
+	fs := newFlagSet(c)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	p, err := readPicture(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	paths := fs.Args()[1:]
+	for _, path := range paths {
+		if err := editFile(path, &audiotag.Edit{Pictures: []*audiotag.Picture{p}}); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: embedded %s\n", path, filepath.Base(fs.Arg(0)))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not update %d of %d files", failed, len(paths))
+	}
+	return nil
+}
@@ -0,0 +1,435 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaolong-1995/audiotag"
+)
+
+var chaptersCmd = &command{
+	name:  "chapters",
+	usage: "export [-format format] [-o output] file | import [-format format] chapters file...",
+	short: "export or import chapter markers (WebVTT, FFmetadata or text)",
+	run:   runChapters,
+}
+
+// chapter is a chapter marker with parsed times.
+type chapter struct {
+	start, end time.Duration // end is zero if unknown
+	title      string
+}
+
+// chapterFormats are the supported chapter file formats.
+var chapterFormats = map[string]struct {
+	write func(w io.Writer, cs []chapter) error
+	read  func(r io.Reader) ([]chapter, error)
+}{
+	"vtt":        {writeVTT, readVTT},
+	"ffmetadata": {writeFFMetadata, readFFMetadata},
+	"txt":        {writeChapterText, readChapterText},
+}
+
+// chapterFormat returns the chapter format given by the flag value, or by the extension
+// of the file name if the flag is empty.
+func chapterFormat(format, name string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".vtt":
+			format = "vtt"
+		case ".ffmetadata", ".ffmeta", ".ffm", ".ini":
+			format = "ffmetadata"
+		default:
+			format = "txt"
+		}
+	}
+	if _, ok := chapterFormats[format]; !ok {
+		return "", fmt.Errorf("unknown chapter format %q", format)
+	}
+	return format, nil
+}
+
+func runChapters(c *command, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return runChaptersExport(c, args[1:])
+		case "import":
+			return runChaptersImport(c, args[1:])
+		}
+	}
+	fs := newFlagSet(c)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	fs.Usage()
+	return errUsage
+}
+
+func runChaptersExport(c *command, args []string) error {
+	fs := newFlagSet(c)
+	format := fs.String("format", "", "chapter `format`: vtt, ffmetadata or txt (defaults to the extension of the output file, or txt)")
+	out := fs.String("o", "-", "write the chapters to `file`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	f, err := chapterFormat(*format, *out)
+	if err != nil {
+		return err
+	}
+
+	m, err := readFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cs, err := fromChapters(audiotag.Chapters(m), m.Duration())
+	if err != nil {
+		return err
+	}
+	if len(cs) == 0 {
+		return errors.New("no chapters found")
+	}
+
+	if *out == "-" {
+		return chapterFormats[f].write(os.Stdout, cs)
+	}
+	w, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := chapterFormats[f].write(w, cs); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func runChaptersImport(c *command, args []string) error {
+	fs := newFlagSet(c)
+	format := fs.String("format", "", "chapter `format`: vtt, ffmetadata or txt (defaults to the header or extension of the chapters file, or txt)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errUsage
+	}
+	b, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		// Prefer the format given by the file header to the extension.
+		switch {
+		case bytes.HasPrefix(bytes.TrimPrefix(b, []byte("\ufeff")), []byte("WEBVTT")):
+			*format = "vtt"
+		case bytes.HasPrefix(b, []byte(";FFMETADATA")):
+			*format = "ffmetadata"
+		}
+	}
+	f, err := chapterFormat(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cs, err := chapterFormats[f].read(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("%s: %v", fs.Arg(0), err)
+	}
+	if len(cs) == 0 {
+		return fmt.Errorf("%s: no chapters found", fs.Arg(0))
+	}
+
+	var failed int
+	paths := fs.Args()[1:]
+	for _, path := range paths {
+		if err := editFile(path, &audiotag.Edit{Chapters: toChapters(cs)}); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: imported %d chapters\n", path, len(cs))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not update %d of %d files", failed, len(paths))
+	}
+	return nil
+}
+
+// fromChapters converts library chapters, using the duration (in seconds) as the end of
+// the last chapter if it is not set.
+func fromChapters(in []audiotag.Chapter, duration int) ([]chapter, error) {
+	cs := make([]chapter, 0, len(in))
+	for _, c := range in {
+		start, err := parseSeconds(c.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		var end time.Duration
+		if c.EndTime != "" {
+			if end, err = parseSeconds(c.EndTime); err != nil {
+				return nil, err
+			}
+		}
+		cs = append(cs, chapter{start: start, end: end, title: c.Title})
+	}
+	for i := range cs {
+		if cs[i].end == 0 {
+			if i+1 < len(cs) {
+				cs[i].end = cs[i+1].start
+			} else if d := time.Duration(duration) * time.Second; d > cs[i].start {
+				cs[i].end = d
+			}
+		}
+	}
+	return cs, nil
+}
+
+// toChapters converts chapters to library chapters.
+func toChapters(cs []chapter) []audiotag.Chapter {
+	out := make([]audiotag.Chapter, 0, len(cs))
+	for _, c := range cs {
+		ch := audiotag.Chapter{
+			StartTime: fmt.Sprintf("%.3f", c.start.Seconds()),
+			Title:     c.title,
+		}
+		if c.end > 0 {
+			ch.EndTime = fmt.Sprintf("%.3f", c.end.Seconds())
+		}
+		out = append(out, ch)
+	}
+	return out
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	x, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chapter time %q", s)
+	}
+	return time.Duration(x*float64(time.Second) + 0.5), nil
+}
+
+// formatTimestamp formats d as HH:MM:SS.mmm.
+func formatTimestamp(d time.Duration) string {
+	ms := d.Round(time.Millisecond) / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// parseTimestamp parses timestamps of the form [HH:]MM:SS[.mmm].
+func parseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	var secs float64
+	for _, p := range parts {
+		x, err := strconv.ParseFloat(strings.Replace(p, ",", ".", 1), 64)
+		if err != nil || x < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		secs = secs*60 + x
+	}
+	return time.Duration(secs*float64(time.Second) + 0.5), nil
+}
+
+func writeVTT(w io.Writer, cs []chapter) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, "WEBVTT\n")
+	for i, c := range cs {
+		end := c.end
+		if end < c.start {
+			end = c.start
+		}
+		fmt.Fprintf(bw, "\n%d\n%s --> %s\n%s\n", i+1, formatTimestamp(c.start), formatTimestamp(end), c.title)
+	}
+	return bw.Flush()
+}
+
+func readVTT(r io.Reader) ([]chapter, error) {
+	var cs []chapter
+	var cur *chapter
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		t := strings.TrimSpace(s.Text())
+		switch {
+		case line == 1:
+			if !strings.HasPrefix(strings.TrimPrefix(t, "\ufeff"), "WEBVTT") {
+				return nil, errors.New("missing WEBVTT header")
+			}
+		case t == "":
+			cur = nil
+		case strings.Contains(t, "-->"):
+			times := strings.SplitN(t, "-->", 2)
+			start, err := parseTimestamp(times[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			// Cue settings may follow the end time.
+			end, err := parseTimestamp(strings.Fields(times[1] + " ")[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			cs = append(cs, chapter{start: start, end: end})
+			cur = &cs[len(cs)-1]
+		case cur != nil:
+			if cur.title != "" {
+				cur.title += " "
+			}
+			cur.title += t
+		}
+	}
+	return cs, s.Err()
+}
+
+// ffmetadataEscaper escapes special characters in FFmetadata values.
+var ffmetadataEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+
+func writeFFMetadata(w io.Writer, cs []chapter) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, ";FFMETADATA1\n")
+	for _, c := range cs {
+		end := c.end
+		if end < c.start {
+			end = c.start
+		}
+		fmt.Fprintf(bw, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			c.start/time.Millisecond, end/time.Millisecond, ffmetadataEscaper.Replace(c.title))
+	}
+	return bw.Flush()
+}
+
+var timebaseRe = regexp.MustCompile(`^([0-9]+)/([0-9]+)$`)
+
+func readFFMetadata(r io.Reader) ([]chapter, error) {
+	var cs []chapter
+	var cur *chapter
+	var start, end int64
+	num, den := int64(1), int64(1000000000)
+
+	flush := func() {
+		if cur != nil {
+			cur.start = time.Duration(start * num * int64(time.Second) / den)
+			cur.end = time.Duration(end * num * int64(time.Second) / den)
+			cs = append(cs, *cur)
+		}
+		cur = nil
+	}
+
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		t := s.Text()
+		// Lines ending in a backslash continue onto the next line.
+		for strings.HasSuffix(t, `\`) && !strings.HasSuffix(t, `\\`) && s.Scan() {
+			t = t[:len(t)-1] + "\n" + s.Text()
+			line++
+		}
+
+		switch {
+		case line == 1:
+			if !strings.HasPrefix(t, ";FFMETADATA") {
+				return nil, errors.New("missing ;FFMETADATA header")
+			}
+		case strings.HasPrefix(t, ";"), strings.HasPrefix(t, "#"), strings.TrimSpace(t) == "":
+		case strings.HasPrefix(t, "["):
+			flush()
+			if strings.TrimSpace(t) == "[CHAPTER]" {
+				cur = &chapter{}
+				start, end = 0, 0
+				num, den = 1, 1000000000
+			}
+		case cur != nil:
+			kv := strings.SplitN(t, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("line %d: expected key=value", line)
+			}
+			var err error
+			switch strings.ToLower(kv[0]) {
+			case "timebase":
+				m := timebaseRe.FindStringSubmatch(kv[1])
+				if m == nil {
+					return nil, fmt.Errorf("line %d: invalid TIMEBASE %q", line, kv[1])
+				}
+				num, _ = strconv.ParseInt(m[1], 10, 64)
+				den, _ = strconv.ParseInt(m[2], 10, 64)
+				if den == 0 {
+					return nil, fmt.Errorf("line %d: invalid TIMEBASE %q", line, kv[1])
+				}
+			case "start":
+				start, err = strconv.ParseInt(kv[1], 10, 64)
+			case "end":
+				end, err = strconv.ParseInt(kv[1], 10, 64)
+			case "title":
+				cur.title = unescapeFFMetadata(kv[1])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+	}
+	flush()
+	return cs, s.Err()
+}
+
+func unescapeFFMetadata(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// writeChapterText writes chapters as lines of the form "HH:MM:SS.mmm Title".
+func writeChapterText(w io.Writer, cs []chapter) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range cs {
+		fmt.Fprintf(bw, "%s %s\n", formatTimestamp(c.start), c.title)
+	}
+	return bw.Flush()
+}
+
+func readChapterText(r io.Reader) ([]chapter, error) {
+	var cs []chapter
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		t := strings.TrimSpace(s.Text())
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		parts := strings.SplitN(t, " ", 2)
+		start, err := parseTimestamp(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		c := chapter{start: start}
+		if len(parts) == 2 {
+			c.title = strings.TrimSpace(parts[1])
+		}
+		if len(cs) > 0 {
+			cs[len(cs)-1].end = start
+		}
+		cs = append(cs, c)
+	}
+	return cs, s.Err()
+}
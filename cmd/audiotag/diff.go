@@ -0,0 +1,78 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/chaolong-1995/audiotag"
+)
+
+var diffCmd = &command{
+	name:  "diff",
+	usage: "[-q] file1 file2",
+	short: "compare the metadata of two audio files",
+	run:   runDiff,
+}
+
+// errDiffer is returned by the diff command when the metadata of the files differs.
+var errDiffer = errors.New("metadata differs")
+
+// maxDiffValue is the maximum length of values printed by the diff command.
+const maxDiffValue = 80
+
+func runDiff(c *command, args []string) error {
+	fs := newFlagSet(c)
+	quiet := fs.Bool("q", false, "only report whether the metadata differs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	a, err := readFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%s: %v", fs.Arg(0), err)
+	}
+	b, err := readFile(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("%s: %v", fs.Arg(1), err)
+	}
+
+	diffs := audiotag.Diff(a, b)
+	if len(diffs) == 0 {
+		return nil
+	}
+	if *quiet {
+		return errDiffer
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "\t%s (%s %s)\t%s (%s %s)\n", fs.Arg(0), a.FileType(), a.Format(), fs.Arg(1), b.FileType(), b.Format())
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Field, diffValue(d.A), diffValue(d.B))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return errDiffer
+}
+
+// diffValue quotes and truncates a value for printing.
+func diffValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	if r := []rune(s); len(r) > maxDiffValue {
+		s = string(r[:maxDiffValue]) + "…"
+	}
+	return strconv.Quote(s)
+}
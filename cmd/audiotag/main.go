@@ -0,0 +1,109 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The audiotag tool reads metadata from media files (as supported by the audiotag library).
+//
+// Usage:
+//
+//	audiotag <command> [flags] [arguments]
+//
+// Run "audiotag help <command>" for more information about a command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// command is a subcommand of the audiotag tool.
+type command struct {
+	name  string
+	usage string // one-line argument summary
+	short string // short description shown in the command list
+	run   func(c *command, args []string) error
+}
+
+// commands are the supported subcommands, in the order they are listed in the usage.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		readCmd,
+		setCmd,
+		artCmd,
+		renameCmd,
+		scanCmd,
+		stripCmd,
+		verifyCmd,
+		diffCmd,
+		chaptersCmd,
+		serveCmd,
+	}
+}
+
+// errUsage is returned by commands when they were invoked with invalid arguments.
+var errUsage = fmt.Errorf("invalid usage")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags] [arguments]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "commands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.short)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s help <command>' for more information about a command.\n", os.Args[0])
+}
+
+func lookup(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// newFlagSet returns a flag.FlagSet for the command c which prints the command usage on error.
+func newFlagSet(c *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s %s\n", os.Args[0], c.name, c.usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		if len(args) == 1 {
+			if c := lookup(args[0]); c != nil {
+				c.run(c, []string{"-h"})
+				return
+			}
+		}
+		usage()
+		return
+	}
+
+	c := lookup(name)
+	if c == nil {
+		fmt.Fprintf(os.Stderr, "%s: unknown command %q\n", os.Args[0], name)
+		usage()
+		os.Exit(2)
+	}
+
+	if err := c.run(c, args); err != nil {
+		if err == errUsage || err == flag.ErrHelp {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", os.Args[0], c.name, err)
+		os.Exit(1)
+	}
+}
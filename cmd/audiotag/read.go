@@ -0,0 +1,280 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/chaolong-1995/audiotag"
+)
+
+var readCmd = &command{
+	name:  "read",
+	usage: "[-json] [-raw] [-unknown] [-salvage] file...",
+	short: "print the metadata of audio files",
+	run:   runRead,
+}
+
+// pictureInfo is a summary of an attached picture (the image data is not included).
+type pictureInfo struct {
+	MIMEType    string `json:"mime_type,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Size        int    `json:"size"`
+}
+
+func newPictureInfo(p *audiotag.Picture) *pictureInfo {
+	if p == nil {
+		return nil
+	}
+	return &pictureInfo{
+		MIMEType:    p.MIMEType,
+		Type:        p.Type,
+		Description: p.Description,
+		Size:        len(p.Data),
+	}
+}
+
+// fileInfo is the metadata of a single file, as printed by the read command.
+type fileInfo struct {
+	Path        string                 `json:"path"`
+	Error       string                 `json:"error,omitempty"`
+	Truncated   bool                   `json:"truncated,omitempty"` // the file ends part way through the tags
+	Format      audiotag.Format        `json:"format,omitempty"`
+	FileType    audiotag.FileType      `json:"file_type,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Album       string                 `json:"album,omitempty"`
+	Artist      string                 `json:"artist,omitempty"`
+	AlbumArtist string                 `json:"album_artist,omitempty"`
+	Composer    string                 `json:"composer,omitempty"`
+	Genre       string                 `json:"genre,omitempty"`
+	Year        int                    `json:"year,omitempty"`
+	Track       int                    `json:"track,omitempty"`
+	TrackTotal  int                    `json:"track_total,omitempty"`
+	Disc        int                    `json:"disc,omitempty"`
+	DiscTotal   int                    `json:"disc_total,omitempty"`
+	Duration    int                    `json:"duration,omitempty"`
+	BPM         float64                `json:"bpm,omitempty"`
+	Key         audiotag.Key           `json:"key,omitempty"`
+	Comment     string                 `json:"comment,omitempty"`
+	Lyrics      string                 `json:"lyrics,omitempty"`
+	Picture     *pictureInfo           `json:"picture,omitempty"`
+	Warnings    []string               `json:"warnings,omitempty"`
+	Conflicts   []string               `json:"conflicts,omitempty"`
+	Unknown     []string               `json:"unknown,omitempty"`
+	Raw         map[string]interface{} `json:"raw,omitempty"`
+}
+
+func newFileInfo(path string, m audiotag.Metadata, raw bool) *fileInfo {
+	fi := &fileInfo{
+		Path:        path,
+		Format:      m.Format(),
+		FileType:    m.FileType(),
+		Title:       m.Title(),
+		Album:       m.Album(),
+		Artist:      m.Artist(),
+		AlbumArtist: m.AlbumArtist(),
+		Composer:    m.Composer(),
+		Genre:       m.Genre(),
+		Year:        m.Year(),
+		Duration:    m.Duration(),
+		BPM:         audiotag.BPM(m),
+		Key:         audiotag.InitialKey(m),
+		Comment:     m.Comment(),
+		Lyrics:      m.Lyrics(),
+		Picture:     newPictureInfo(m.Picture()),
+	}
+	fi.Track, fi.TrackTotal = m.Track()
+	fi.Disc, fi.DiscTotal = m.Disc()
+	for _, w := range audiotag.Warnings(m) {
+		fi.Warnings = append(fi.Warnings, w.Error())
+	}
+	for _, c := range audiotag.Conflicts(m) {
+		fi.Conflicts = append(fi.Conflicts, c.String())
+	}
+	for _, u := range audiotag.UnknownTags(m) {
+		fi.Unknown = append(fi.Unknown, u.String())
+	}
+
+	if raw {
+		fi.Raw = make(map[string]interface{})
+		for k, v := range m.Raw() {
+			fi.Raw[k] = rawValue(v)
+		}
+	}
+	return fi
+}
+
+// rawValue converts a raw tag value into a value which is suitable for printing.
+func rawValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string, int, bool, float64:
+		return v
+	case *audiotag.Picture:
+		return newPictureInfo(v)
+	case []byte:
+		return fmt.Sprintf("<%d bytes>", len(v))
+	}
+	return fmt.Sprint(v)
+}
+
+// readFile opens and reads the metadata of the file at path, with the duration of MP3 files.
+func readFile(path string) (audiotag.Metadata, error) {
+	o := audiotag.NewReadOptions()
+	o.MP3Audio = true
+	return readFileWithOptions(path, o)
+}
+
+// readFileWithOptions opens and reads the metadata of the file at path with the options o.
+func readFileWithOptions(path string, o audiotag.ReadOptions) (audiotag.Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return audiotag.ReadFromWithOptions(f, o)
+}
+
+// salvageFile opens and reads the metadata of the file at path with the options o,
+// scanning for tags if the file is damaged (see audiotag.Salvage).
+func salvageFile(path string, o audiotag.ReadOptions) (audiotag.Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return audiotag.SalvageWithOptions(f, o)
+}
+
+func runRead(c *command, args []string) error {
+	fs := newFlagSet(c)
+	jsonOut := fs.Bool("json", false, "print metadata as JSON")
+	raw := fs.Bool("raw", false, "include the raw tags")
+	unknown := fs.Bool("unknown", false, "list the tags which are not recognized")
+	salvage := fs.Bool("salvage", false, "scan damaged files for tags")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	o := audiotag.NewReadOptions()
+	o.MP3Audio = true
+	if *unknown {
+		o.UnknownTagPolicy = audiotag.ListUnknown
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	read := readFileWithOptions
+	if *salvage {
+		read = salvageFile
+	}
+
+	var failed int
+	infos := make([]*fileInfo, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		m, err := read(path, o)
+		if err == audiotag.ErrTruncated && m != nil {
+			fi := newFileInfo(path, m, *raw)
+			fi.Truncated = true
+			infos = append(infos, fi)
+			continue
+		}
+		if err != nil {
+			failed++
+			infos = append(infos, &fileInfo{Path: path, Error: err.Error()})
+			continue
+		}
+		infos = append(infos, newFileInfo(path, m, *raw))
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(infos); err != nil {
+			return err
+		}
+	} else {
+		for i, fi := range infos {
+			if i > 0 {
+				fmt.Println()
+			}
+			printFileInfo(os.Stdout, fi, len(infos) > 1)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not read %d of %d files", failed, len(infos))
+	}
+	return nil
+}
+
+func printFileInfo(w io.Writer, fi *fileInfo, header bool) {
+	if header {
+		fmt.Fprintf(w, "==> %s <==\n", fi.Path)
+	}
+	if fi.Error != "" {
+		fmt.Fprintf(w, "Error: %v\n", fi.Error)
+		return
+	}
+
+	if fi.Truncated {
+		fmt.Fprintf(w, "Warning: the file is truncated, some tags may be missing\n")
+	}
+	for _, x := range fi.Warnings {
+		fmt.Fprintf(w, "Warning: %v\n", x)
+	}
+	for _, x := range fi.Conflicts {
+		fmt.Fprintf(w, "Conflict: %v\n", x)
+	}
+	for _, x := range fi.Unknown {
+		fmt.Fprintf(w, "Unknown: %v\n", x)
+	}
+	fmt.Fprintf(w, "Metadata Format: %v\n", fi.Format)
+	fmt.Fprintf(w, "File Type: %v\n", fi.FileType)
+
+	fmt.Fprintf(w, " Title: %v\n", fi.Title)
+	fmt.Fprintf(w, " Album: %v\n", fi.Album)
+	fmt.Fprintf(w, " Artist: %v\n", fi.Artist)
+	fmt.Fprintf(w, " Composer: %v\n", fi.Composer)
+	fmt.Fprintf(w, " Genre: %v\n", fi.Genre)
+	fmt.Fprintf(w, " Year: %v\n", fi.Year)
+	fmt.Fprintf(w, " Track: %v of %v\n", fi.Track, fi.TrackTotal)
+	fmt.Fprintf(w, " Disc: %v of %v\n", fi.Disc, fi.DiscTotal)
+	fmt.Fprintf(w, " Duration: %v\n", fi.Duration)
+	if fi.BPM != 0 {
+		fmt.Fprintf(w, " BPM: %v\n", fi.BPM)
+	}
+	if fi.Key != audiotag.UnknownKey {
+		fmt.Fprintf(w, " Key: %v (%v)\n", fi.Key, fi.Key.Camelot())
+	}
+	if fi.Picture != nil {
+		fmt.Fprintf(w, " Picture: %v, %v, %d bytes\n", fi.Picture.MIMEType, fi.Picture.Type, fi.Picture.Size)
+	}
+	if fi.Comment != "" {
+		fmt.Fprintf(w, " Comment: %v\n", fi.Comment)
+	}
+	if fi.Lyrics != "" {
+		fmt.Fprintf(w, " Lyrics: %v\n", fi.Lyrics)
+	}
+
+	if len(fi.Raw) > 0 {
+		fmt.Fprintln(w)
+		keys := make([]string, 0, len(fi.Raw))
+		for k := range fi.Raw {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "%q: %v\n", k, fi.Raw[k])
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chaolong-1995/audiotag"
+)
+
+var renameCmd = &command{
+	name:  "rename",
+	usage: "[-template template] [-dir dir] [-n] file...",
+	short: "rename audio files using their metadata",
+	run:   runRename,
+}
+
+const defaultTemplate = "{album_artist|artist}/{album}/{track:02d} {title}"
+
+// templateFieldRe matches template fields: {name}, {name|fallback} and {name:02d}.
+var templateFieldRe = regexp.MustCompile(`\{([a-z_|]+)(?::([0-9]*[ds]?))?\}`)
+
+// templateFields are the names of the fields which can be used in rename templates.
+var templateFields = []string{
+	"title", "album", "artist", "album_artist", "composer", "genre", "year", "track",
+	"track_total", "disc", "disc_total", "bpm", "key", "format", "filetype",
+}
+
+// templateValues returns the values of the metadata fields which can be used in rename
+// templates, keyed by field name.
+func templateValues(m audiotag.Metadata) map[string]string {
+	v := map[string]string{
+		"title":        m.Title(),
+		"album":        m.Album(),
+		"artist":       m.Artist(),
+		"album_artist": m.AlbumArtist(),
+		"composer":     m.Composer(),
+		"genre":        m.Genre(),
+		"key":          string(audiotag.InitialKey(m)),
+		"format":       string(m.Format()),
+		"filetype":     string(m.FileType()),
+	}
+
+	ints := map[string]int{"year": m.Year()}
+	ints["track"], ints["track_total"] = m.Track()
+	ints["disc"], ints["disc_total"] = m.Disc()
+	for k, n := range ints {
+		if n != 0 {
+			v[k] = strconv.Itoa(n)
+		}
+	}
+	if bpm := audiotag.BPM(m); bpm != 0 {
+		v["bpm"] = strconv.FormatFloat(bpm, 'f', -1, 64)
+	}
+	return v
+}
+
+// unsafePathChars are replaced in field values so that each value is a valid file name
+// on all common file systems.
+var unsafePathChars = strings.NewReplacer(
+	"/", "-", "\\", "-", ":", "-", "*", "-", "?", "", "\"", "'", "<", "", ">", "", "|", "-", "\x00", "",
+)
+
+// formatField formats the value according to a (Python-like) format spec such as "02d" or "3s".
+func formatField(value, spec string) string {
+	if spec == "" {
+		return value
+	}
+	verb := spec[len(spec)-1]
+	if verb == 'd' || verb == 's' {
+		spec = spec[:len(spec)-1]
+	} else {
+		verb = 's'
+	}
+
+	if verb == 'd' {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return value
+		}
+		return fmt.Sprintf("%"+spec+"d", n)
+	}
+	return fmt.Sprintf("%"+spec+"s", value)
+}
+
+// expandTemplate expands the fields in the template using the values.  Path separators
+// in the template separate directories, those in values are replaced.
+func expandTemplate(template string, values map[string]string) (string, error) {
+	s := templateFieldRe.ReplaceAllStringFunc(template, func(f string) string {
+		match := templateFieldRe.FindStringSubmatch(f)
+		for _, name := range strings.Split(match[1], "|") {
+			if v := strings.TrimSpace(values[name]); v != "" {
+				return formatField(unsafePathChars.Replace(v), match[2])
+			}
+		}
+		return ""
+	})
+
+	// Clean up each path element, dropping elements left empty by missing values.
+	var parts []string
+	for _, p := range strings.Split(s, "/") {
+		p = strings.Trim(strings.TrimSpace(p), ".")
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return "", errors.New("template expands to an empty path")
+	}
+	return filepath.Join(parts...), nil
+}
+
+// validateTemplate returns an error if the template uses unknown fields.
+func validateTemplate(template string) error {
+	known := make(map[string]bool, len(templateFields))
+	for _, f := range templateFields {
+		known[f] = true
+	}
+	for _, match := range templateFieldRe.FindAllStringSubmatch(template, -1) {
+		for _, name := range strings.Split(match[1], "|") {
+			if !known[name] {
+				return fmt.Errorf("unknown template field %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+func runRename(c *command, args []string) error {
+	fs := newFlagSet(c)
+	template := fs.String("template", defaultTemplate, "file name `template`, fields are given as {name}, {name|fallback} or {name:02d} and the file extension is added automatically (fields: "+strings.Join(templateFields, ", ")+")")
+	dir := fs.String("dir", ".", "move files into this destination `directory`")
+	dryRun := fs.Bool("n", false, "print the new names without renaming any files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	// Validate the template before touching any files.
+	if err := validateTemplate(*template); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, path := range fs.Args() {
+		dst, err := renameFile(path, *template, *dir, *dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s -> %s\n", path, dst)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not rename %d of %d files", failed, fs.NArg())
+	}
+	return nil
+}
+
+// renameFile moves the file at path to the location given by the template (relative to
+// dir), returning the new path.  Existing files are never overwritten.
+func renameFile(path, template, dir string, dryRun bool) (string, error) {
+	m, err := readFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := expandTemplate(template, templateValues(m))
+	if err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, name+strings.ToLower(filepath.Ext(path)))
+
+	if filepath.Clean(path) == dst {
+		return dst, nil
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf("%s already exists", dst)
+	}
+	if dryRun {
+		return dst, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	return dst, os.Rename(path, dst)
+}
@@ -0,0 +1,194 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var scanCmd = &command{
+	name:  "scan",
+	usage: "[-format csv|json] [-j workers] [-all] dir...",
+	short: "report the metadata of all audio files in directories",
+	run:   runScan,
+}
+
+// audioExts are the file extensions which are scanned by default.
+var audioExts = map[string]bool{
+	".mp3": true, ".m4a": true, ".m4b": true, ".m4p": true, ".mp4": true, ".aac": true,
+	".alac": true, ".flac": true, ".ogg": true, ".oga": true, ".dsf": true,
+}
+
+// scanResult is the inventory entry for a single file.
+type scanResult struct {
+	*fileInfo
+	Size    int64 `json:"size"`
+	Bitrate int   `json:"bitrate,omitempty"` // kbit/s
+}
+
+// scanColumns are the CSV columns written by the scan command.
+var scanColumns = []string{
+	"path", "format", "file_type", "title", "artist", "album", "album_artist", "composer", "genre",
+	"year", "track", "track_total", "disc", "disc_total", "duration", "bitrate", "size", "picture", "error",
+}
+
+func (r *scanResult) record() []string {
+	itoa := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	picture := ""
+	if r.Picture != nil {
+		picture = r.Picture.MIMEType
+	}
+	return []string{
+		r.Path, string(r.Format), string(r.FileType), r.Title, r.Artist, r.Album, r.AlbumArtist,
+		r.Composer, r.Genre, itoa(r.Year), itoa(r.Track), itoa(r.TrackTotal), itoa(r.Disc),
+		itoa(r.DiscTotal), itoa(r.Duration), itoa(r.Bitrate), strconv.FormatInt(r.Size, 10),
+		picture, r.Error,
+	}
+}
+
+// scanFile reads the metadata of the file at path into a scanResult.
+func scanFile(path string, size int64) *scanResult {
+	m, err := readFile(path)
+	if err != nil {
+		return &scanResult{fileInfo: &fileInfo{Path: path, Error: err.Error()}, Size: size}
+	}
+
+	r := &scanResult{fileInfo: newFileInfo(path, m, false), Size: size}
+	if r.Duration > 0 {
+		// Average bitrate of the whole file (including the tags).
+		r.Bitrate = int(size * 8 / int64(r.Duration) / 1000)
+	}
+	return r
+}
+
+// scanDirs walks the directories and reads the metadata of the files found using the
+// given number of workers, returning the results sorted by path.
+func scanDirs(dirs []string, workers int, all bool) ([]*scanResult, error) {
+	type job struct {
+		path string
+		size int64
+	}
+	jobs := make(chan job)
+	results := make(chan *scanResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results <- scanFile(j.path, j.size)
+			}
+		}()
+	}
+
+	var walkErr error
+	go func() {
+		defer close(jobs)
+		for _, dir := range dirs {
+			err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !fi.Mode().IsRegular() {
+					return nil
+				}
+				if !all && !audioExts[strings.ToLower(filepath.Ext(path))] {
+					return nil
+				}
+				jobs <- job{path: path, size: fi.Size()}
+				return nil
+			})
+			if err != nil {
+				walkErr = err
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var rs []*scanResult
+	for r := range results {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Path < rs[j].Path })
+	return rs, walkErr
+}
+
+func runScan(c *command, args []string) error {
+	fs := newFlagSet(c)
+	format := fs.String("format", "csv", "output `format`: csv, or json (one object per line)")
+	workers := fs.Int("j", runtime.NumCPU(), "number of files to read in parallel")
+	all := fs.Bool("all", false, "read all files, not just those with audio file extensions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || *workers < 1 || (*format != "csv" && *format != "json") {
+		fs.Usage()
+		return errUsage
+	}
+
+	rs, err := scanDirs(fs.Args(), *workers, *all)
+	if err != nil {
+		return err
+	}
+	if err := writeScanResults(os.Stdout, *format, rs); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, r := range rs {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d files could not be read\n", failed, len(rs))
+	}
+	return nil
+}
+
+func writeScanResults(w io.Writer, format string, rs []*scanResult) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		for _, r := range rs {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(scanColumns); err != nil {
+		return err
+	}
+	for _, r := range rs {
+		if err := cw.Write(r.record()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,48 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/chaolong-1995/audiotag/httptag"
+)
+
+var serveCmd = &command{
+	name:  "serve",
+	usage: "[-addr address] [-root dir] [-max-upload bytes]",
+	short: "serve file metadata and artwork over HTTP",
+	run:   runServe,
+}
+
+func runServe(c *command, args []string) error {
+	fs := newFlagSet(c)
+	addr := fs.String("addr", "localhost:8080", "listen on `address`")
+	root := fs.String("root", "", "serve files under `dir` using ?path= queries (by default only uploads are accepted)")
+	maxUpload := fs.Int64("max-upload", httptag.DefaultMaxUploadSize, "maximum size of uploaded files in `bytes`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	if *root != "" {
+		fi, err := os.Stat(*root)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("%s: not a directory", *root)
+		}
+	}
+
+	h := &httptag.Handler{Root: *root, MaxUploadSize: *maxUpload}
+	fmt.Fprintf(os.Stderr, "listening on %s\n", *addr)
+	return http.ListenAndServe(*addr, h)
+}
@@ -0,0 +1,264 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/chaolong-1995/audiotag"
+)
+
+var setCmd = &command{
+	name:  "set",
+	usage: "[-title title] [-artist artist] ... [-from-json file] [-scrub] [-id3v2 version] file...",
+	short: "set metadata fields of audio files",
+	run:   runSet,
+}
+
+// setFields are the fields which can be set by the set command, using the names
+// of the JSON fields printed by the read command.
+var setFields = []struct {
+	name, usage string
+}{
+	{"title", "track title"},
+	{"album", "album name"},
+	{"artist", "artist name"},
+	{"album_artist", "album artist name"},
+	{"composer", "composer"},
+	{"genre", "genre"},
+	{"year", "year"},
+	{"track", "track number"},
+	{"track_total", "total number of tracks"},
+	{"disc", "disc number"},
+	{"disc_total", "total number of discs"},
+	{"bpm", "tempo in beats per minute"},
+	{"key", "initial musical key"},
+	{"comment", "comment"},
+	{"lyrics", "lyrics"},
+}
+
+func runSet(c *command, args []string) error {
+	fs := newFlagSet(c)
+	fromJSON := fs.String("from-json", "", "read fields from a JSON `file` (\"-\" for stdin), either an object applied to all files or an array of objects with a \"path\" field, as printed by \"read -json\"")
+	dryRun := fs.Bool("n", false, "print the changes without writing them")
+	scrub := fs.Bool("scrub", false, "remove the account of the buyer of files bought from the iTunes Store")
+	id3v2 := fs.String("id3v2", "", "write the ID3v2 tags of MP3 files as `version` 2.3 or 2.4, converting existing tags")
+	values := make(map[string]*string, len(setFields))
+	for _, f := range setFields {
+		values[f.name] = fs.String(f.name, "", "set the "+f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var version audiotag.Format
+	switch *id3v2 {
+	case "":
+	case "2.3":
+		version = audiotag.ID3v2_3
+	case "2.4":
+		version = audiotag.ID3v2_4
+	default:
+		return fmt.Errorf("invalid ID3v2 version %q, expected 2.3 or 2.4", *id3v2)
+	}
+
+	// Only fields which were given on the command line are set (so that they can
+	// be set to an empty value).
+	fields := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if v, ok := values[f.Name]; ok {
+			fields[f.Name] = *v
+		}
+	})
+
+	edits := make(map[string]map[string]string)
+	var paths []string
+	if *fromJSON != "" {
+		var err error
+		edits, paths, err = readJSONEdits(*fromJSON, fs.Args())
+		if err != nil {
+			return err
+		}
+	} else {
+		paths = fs.Args()
+		for _, path := range paths {
+			edits[path] = make(map[string]string)
+		}
+	}
+
+	if len(paths) == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var failed int
+	for _, path := range paths {
+		// Command-line flags take precedence over fields from JSON.
+		for k, v := range fields {
+			edits[path][k] = v
+		}
+		if len(edits[path]) == 0 && !*scrub && version == "" {
+			continue
+		}
+
+		if *dryRun {
+			for _, f := range setFields {
+				if v, ok := edits[path][f.name]; ok {
+					fmt.Printf("%s: %s = %q\n", path, f.name, v)
+				}
+			}
+			if *scrub {
+				fmt.Printf("%s: scrub account\n", path)
+			}
+			if version != "" {
+				fmt.Printf("%s: convert to %v\n", path, version)
+			}
+			continue
+		}
+
+		if err := editFile(path, &audiotag.Edit{Fields: edits[path], Scrub: *scrub, ID3v2Version: version}); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not update %d of %d files", failed, len(paths))
+	}
+	return nil
+}
+
+// readJSONEdits reads the fields to set from the JSON file at name.  A JSON object is applied
+// to all of the paths, a JSON array of objects is applied to the paths given in each object.
+func readJSONEdits(name string, paths []string) (map[string]map[string]string, []string, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var objs []map[string]interface{}
+	if err := json.Unmarshal(b, &objs); err != nil {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(b, &obj); err != nil {
+			return nil, nil, fmt.Errorf("invalid JSON fields (expected an object or an array of objects): %v", err)
+		}
+		edits := make(map[string]map[string]string, len(paths))
+		for _, path := range paths {
+			fields, err := jsonFields(obj)
+			if err != nil {
+				return nil, nil, err
+			}
+			edits[path] = fields
+		}
+		return edits, paths, nil
+	}
+
+	// Restrict the edits to the given paths, if any.
+	include := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		include[path] = true
+	}
+
+	edits := make(map[string]map[string]string, len(objs))
+	var editPaths []string
+	for i, obj := range objs {
+		path, ok := obj["path"].(string)
+		if !ok || path == "" {
+			return nil, nil, fmt.Errorf("invalid JSON fields: object %d has no \"path\"", i)
+		}
+		if len(include) > 0 && !include[path] {
+			continue
+		}
+		fields, err := jsonFields(obj)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if _, ok := edits[path]; !ok {
+			editPaths = append(editPaths, path)
+		}
+		edits[path] = fields
+	}
+	return edits, editPaths, nil
+}
+
+// jsonFields converts the settable fields in the JSON object into strings.  Fields which
+// can't be set (such as "path", "format" and "raw") are ignored.
+func jsonFields(obj map[string]interface{}) (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, f := range setFields {
+		v, ok := obj[f.name]
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			fields[f.name] = v
+		case float64:
+			fields[f.name] = strconv.FormatFloat(v, 'f', -1, 64)
+		case nil:
+			fields[f.name] = ""
+		default:
+			return nil, fmt.Errorf("invalid value for %q: %v", f.name, v)
+		}
+	}
+	return fields, nil
+}
+
+// editFile applies e to the file at path, replacing the file only once the
+// updated copy has been completely written.
+func editFile(path string, e *audiotag.Edit) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return replaceFile(path, func(w io.Writer) error {
+		return audiotag.WriteTags(w, f, e)
+	})
+}
+
+// replaceFile atomically replaces the file at path with the output of write.
+func replaceFile(path string, write func(w io.Writer) error) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(fi.Mode()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,137 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chaolong-1995/audiotag"
+)
+
+var stripCmd = &command{
+	name:  "strip",
+	usage: "[-keep field,...] [-legacy] file...",
+	short: "remove tags from audio files",
+	run:   runStrip,
+}
+
+func runStrip(c *command, args []string) error {
+	fs := newFlagSet(c)
+	keep := fs.String("keep", "", "comma-separated `fields` to keep (\"picture\" keeps the artwork)")
+	legacy := fs.Bool("legacy", false, "only remove redundant tags: duplicate ID3v2 tags, APE tags, and ID3v1 tags when there is an ID3v2 tag")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var keepFields []string
+	if *keep != "" {
+		known := make(map[string]bool, len(setFields)+1)
+		known["picture"] = true
+		for _, f := range setFields {
+			known[f.name] = true
+		}
+		for _, f := range strings.Split(*keep, ",") {
+			f = strings.TrimSpace(f)
+			if !known[f] {
+				return fmt.Errorf("unknown field %q", f)
+			}
+			keepFields = append(keepFields, f)
+		}
+	}
+
+	var failed int
+	for _, path := range fs.Args() {
+		if err := stripFile(path, keepFields, *legacy); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not strip %d of %d files", failed, fs.NArg())
+	}
+	return nil
+}
+
+func stripFile(path string, keep []string, legacy bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	format, _, err := audiotag.Identify(f)
+	if err != nil && err != audiotag.ErrNoTagsFound {
+		return err
+	}
+	mp3 := err == audiotag.ErrNoTagsFound
+	switch format {
+	case audiotag.ID3v1, audiotag.ID3v2_2, audiotag.ID3v2_3, audiotag.ID3v2_4:
+		mp3 = true
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if legacy {
+		if !mp3 {
+			return nil
+		}
+		ok, err := audiotag.HasLegacyTags(f)
+		if err != nil || !ok {
+			return err
+		}
+		return replaceFile(path, func(w io.Writer) error {
+			return audiotag.StripLegacyTags(w, f)
+		})
+	}
+
+	if len(keep) == 0 && mp3 {
+		return replaceFile(path, func(w io.Writer) error {
+			return audiotag.StripID3Tags(w, f)
+		})
+	}
+	if format == audiotag.UnknownFormat {
+		return nil // nothing to strip
+	}
+
+	e := &audiotag.Edit{
+		Fields:   make(map[string]string),
+		Pictures: []*audiotag.Picture{},
+		Clear:    true,
+	}
+	if len(keep) > 0 {
+		m, err := audiotag.ReadFrom(f)
+		if err != nil {
+			return err
+		}
+		values := audiotag.FieldValues(m)
+		for _, k := range keep {
+			if k == "picture" {
+				if p := m.Picture(); p != nil {
+					e.Pictures = append(e.Pictures, p)
+				}
+				continue
+			}
+			if values[k] != "" {
+				e.Fields[k] = values[k]
+			}
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	return replaceFile(path, func(w io.Writer) error {
+		return audiotag.WriteTags(w, f, e)
+	})
+}
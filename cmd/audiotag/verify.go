@@ -0,0 +1,126 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chaolong-1995/audiotag"
+)
+
+var verifyCmd = &command{
+	name:  "verify",
+	usage: "[-q [-w]] [-strict] [-lint] file...",
+	short: "check that audio files can be parsed, validated and checksummed",
+	run:   runVerify,
+}
+
+// verifyResult is the outcome of verifying a single file.
+type verifyResult struct {
+	sum      string
+	problems []string
+	warnings []string // problems which do not fail verification
+}
+
+// verifyFile parses (with the options o) and validates the tags of the file at path
+// (checking the values read with audiotag.Lint if lint is set), and computes the checksum
+// of its audio data.
+func verifyFile(path string, o audiotag.ReadOptions, lint bool) (*verifyResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := &verifyResult{}
+	m, err := audiotag.ReadFromWithOptions(f, o)
+	if err != nil && err != audiotag.ErrNoTagsFound {
+		res.problems = append(res.problems, fmt.Sprintf("error reading tags: %v", err))
+	}
+	if lint && m != nil {
+		for _, x := range audiotag.Lint(m) {
+			res.warnings = append(res.warnings, "lint: "+x.String())
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	findings, err := audiotag.Validate(f)
+	if err != nil && err != audiotag.ErrValidateNotSupported {
+		res.problems = append(res.problems, fmt.Sprintf("error validating tags: %v", err))
+	}
+	for _, x := range findings {
+		if x.Severity == audiotag.Error {
+			res.problems = append(res.problems, x.String())
+		} else {
+			res.warnings = append(res.warnings, x.String())
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	res.sum, err = audiotag.Sum(f)
+	if err != nil {
+		res.problems = append(res.problems, fmt.Sprintf("error computing audio checksum: %v", err))
+	}
+	return res, nil
+}
+
+func runVerify(c *command, args []string) error {
+	fs := newFlagSet(c)
+	quiet := fs.Bool("q", false, "only report files with problems")
+	warn := fs.Bool("w", false, "with -q, also report files with warnings")
+	strict := fs.Bool("strict", false, "report any tag spec violation (by default malformed tag data is skipped)")
+	lint := fs.Bool("lint", false, "warn about suspicious tag values (such as an empty title)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	o := audiotag.NewReadOptions()
+	if *strict {
+		o.ParseMode = audiotag.Strict
+	}
+
+	var failed int
+	for _, path := range fs.Args() {
+		res, err := verifyFile(path, o, *lint)
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		if len(res.problems) > 0 {
+			fmt.Printf("FAIL %s\n", path)
+			for _, p := range append(res.problems, res.warnings...) {
+				fmt.Printf("  %s\n", p)
+			}
+			failed++
+			continue
+		}
+		if !*quiet {
+			fmt.Printf("OK   %s %s\n", path, res.sum)
+		} else if *warn && len(res.warnings) > 0 {
+			// The OK line is not printed, so name the file the warnings are for.
+			fmt.Printf("WARN %s\n", path)
+		}
+		if !*quiet || *warn {
+			for _, w := range res.warnings {
+				fmt.Printf("  %s\n", w)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed verification", failed, fs.NArg())
+	}
+	return nil
+}
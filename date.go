@@ -0,0 +1,72 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseYear returns the year of a date in any of the formats found in tags: a bare year,
+// ISO 8601 ("2019-03-01", "2019-03-01T07:00:00Z", "20190301"), or with the year last or
+// separated by dots ("03/2019", "01.03.2019", "2019.03.01").  Returns 0 if there is no year.
+func parseYear(s string) int {
+	s = strings.TrimSpace(s)
+	for i := 0; i < len(s); {
+		j := i
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		if n := j - i; n == 4 || (n == 8 && i == 0) {
+			year, _ := strconv.Atoi(s[i : i+4])
+			return year
+		}
+		if j == i {
+			j++
+		}
+		i = j
+	}
+	return 0
+}
+
+// dateLayouts are the ISO 8601 layouts of full dates accepted by parseDate, most precise
+// first.  Dates without a time zone are taken to be UTC.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"20060102",
+	"2006-01",
+	"2006",
+}
+
+// parseDate parses an ISO 8601 date (and optional time), such as "2023-05-14",
+// "2023-05-14T07:00:00Z" or "2023-05".  Missing months and days are taken to be the first.
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ReleaseDate returns the full release date of an MP4 file (from the ©day atom), if it
+// has one in ISO 8601 format, or false if it does not or m is not the metadata of an MP4
+// file.  Year returns the year of dates in other formats.
+func ReleaseDate(m Metadata) (time.Time, bool) {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return time.Time{}, false
+	}
+	return parseDate(mp4.getString(Registry.LookupByField(MP4, "year")))
+}
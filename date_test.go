@@ -0,0 +1,102 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseYear(t *testing.T) {
+	tests := []struct {
+		input string
+		year  int
+	}{
+		{"", 0},
+		{"2019", 2019},
+		{" 2019 \n", 2019},
+		{"2019-03", 2019},
+		{"2019-03-01", 2019},
+		{"2019-03-01T07:00:00Z", 2019},
+		{"20190301", 2019},
+		{"03/2019", 2019},
+		{"01/03/2019", 2019},
+		{"2019.03.01", 2019},
+		{"01.03.2019", 2019},
+		{"(P) 2019 Label", 2019},
+		{"19", 0},
+		{"201", 0},
+		{"Unknown", 0},
+	}
+
+	for ii, tt := range tests {
+		if got := parseYear(tt.input); got != tt.year {
+			t.Errorf("[%d] parseYear(%q) = %d, expected %d", ii, tt.input, got, tt.year)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		input string
+		date  time.Time
+		ok    bool
+	}{
+		{"2023-05-14T07:00:00Z", time.Date(2023, 5, 14, 7, 0, 0, 0, time.UTC), true},
+		{" 2023-05-14T07:00:00.5Z\n", time.Date(2023, 5, 14, 7, 0, 0, 5e8, time.UTC), true},
+		{"2023-05-14T07:00:00+02:00", time.Date(2023, 5, 14, 5, 0, 0, 0, time.UTC), true},
+		{"2023-05-14T07:00:00+0200", time.Date(2023, 5, 14, 5, 0, 0, 0, time.UTC), true},
+		{"2023-05-14T07:00Z", time.Date(2023, 5, 14, 7, 0, 0, 0, time.UTC), true},
+		{"2023-05-14T07:00:00", time.Date(2023, 5, 14, 7, 0, 0, 0, time.UTC), true},
+		{"2023-05-14 07:00:00", time.Date(2023, 5, 14, 7, 0, 0, 0, time.UTC), true},
+		{"2023-05-14T07:00", time.Date(2023, 5, 14, 7, 0, 0, 0, time.UTC), true},
+		{"2023-05-14", time.Date(2023, 5, 14, 0, 0, 0, 0, time.UTC), true},
+		{"20230514", time.Date(2023, 5, 14, 0, 0, 0, 0, time.UTC), true},
+		{"2023-05", time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2023", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2023-13-01", time.Time{}, false},
+		{"14/05/2023", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for ii, tt := range tests {
+		date, ok := parseDate(tt.input)
+		if ok != tt.ok || !date.Equal(tt.date) {
+			t.Errorf("[%d] parseDate(%q) = %v, %v, expected %v, %v", ii, tt.input, date, ok, tt.date, tt.ok)
+		}
+	}
+}
+
+func TestReleaseDate(t *testing.T) {
+	tests := []struct {
+		day  string
+		year int
+		date time.Time
+		ok   bool
+	}{
+		{"2023-05-14T07:00:00Z", 2023, time.Date(2023, 5, 14, 7, 0, 0, 0, time.UTC), true},
+		{"2023", 2023, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"14.05.2023", 2023, time.Time{}, false},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{testTextItem(AtomYear, tt.day)})))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if m.Year() != tt.year {
+			t.Errorf("[%d] Year() = %d, expected %d", ii, m.Year(), tt.year)
+		}
+		if date, ok := ReleaseDate(m); ok != tt.ok || !date.Equal(tt.date) {
+			t.Errorf("[%d] ReleaseDate() = %v, %v, expected %v, %v", ii, date, ok, tt.date, tt.ok)
+		}
+	}
+
+	if _, ok := ReleaseDate(metadataID3v1{}); ok {
+		t.Errorf("ReleaseDate() = true for ID3v1 metadata, expected false")
+	}
+}
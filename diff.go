@@ -0,0 +1,101 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldDiff is a difference between the value of a field in two sets of metadata.
+type FieldDiff struct {
+	Field string // field name, as used by Edit.Fields, or "picture" or "chapters"
+	A, B  string // values of the field, empty if unset
+}
+
+// Conflict is a field which has different values in two tags of the same file.
+type Conflict struct {
+	Field string // field name, as in FieldDiff
+
+	Format Format // format of the tag whose value is returned by Metadata
+	Value  string
+
+	OtherFormat Format // format of the tag whose value is ignored
+	OtherValue  string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%v: %q (%v) conflicts with %q (%v)", c.Field, c.Value, c.Format, c.OtherValue, c.OtherFormat)
+}
+
+// diffFields are the fields compared by Diff, in order.
+var diffFields = []string{"title", "album", "artist", "album_artist", "composer", "genre", "year",
+	"track", "track_total", "disc", "disc_total", "bpm", "key", "comment", "lyrics", "chapters", "picture"}
+
+// Diff returns the fields whose values differ between a and b.  Pictures are compared
+// using a SHA-1 hash of the image data, so that re-encoded artwork is reported.
+func Diff(a, b Metadata) []FieldDiff {
+	va, vb := diffValues(a), diffValues(b)
+	var diffs []FieldDiff
+	for _, f := range diffFields {
+		if va[f] != vb[f] {
+			diffs = append(diffs, FieldDiff{Field: f, A: va[f], B: vb[f]})
+		}
+	}
+	return diffs
+}
+
+// FieldValues returns the values of the fields of m which can be set using Edit.Fields,
+// keyed by field name.  Numeric fields are formatted as decimal numbers, and fields
+// which are unset (or zero) are empty.
+func FieldValues(m Metadata) map[string]string {
+	itoa := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	track, trackTotal := m.Track()
+	disc, discTotal := m.Disc()
+	v := map[string]string{
+		"title":        m.Title(),
+		"album":        m.Album(),
+		"artist":       m.Artist(),
+		"album_artist": m.AlbumArtist(),
+		"composer":     m.Composer(),
+		"genre":        m.Genre(),
+		"year":         itoa(m.Year()),
+		"track":        itoa(track),
+		"track_total":  itoa(trackTotal),
+		"disc":         itoa(disc),
+		"disc_total":   itoa(discTotal),
+		"bpm":          "",
+		"key":          string(InitialKey(m)),
+		"comment":      m.Comment(),
+		"lyrics":       m.Lyrics(),
+	}
+	if bpm := BPM(m); bpm != 0 {
+		v["bpm"] = strconv.FormatFloat(bpm, 'f', -1, 64)
+	}
+	return v
+}
+
+// diffValues returns the values of the fields compared by Diff.
+func diffValues(m Metadata) map[string]string {
+	v := FieldValues(m)
+
+	var chapters []string
+	for _, c := range Chapters(m) {
+		chapters = append(chapters, c.StartTime+" "+c.Title)
+	}
+	v["chapters"] = strings.Join(chapters, "; ")
+
+	if p := m.Picture(); p != nil {
+		v["picture"] = fmt.Sprintf("%s, %d bytes, sha1:%x", p.MIMEType, len(p.Data), sha1.Sum(p.Data))
+	}
+	return v
+}
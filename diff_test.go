@@ -0,0 +1,76 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b     []string
+		expected []FieldDiff
+	}{
+		{
+			[]string{"TITLE=Title", "ARTIST=Artist"},
+			[]string{"ARTIST=Artist", "TITLE=Title"},
+			nil,
+		},
+		{
+			[]string{"TITLE=Title", "TRACKNUMBER=1", "TRACKTOTAL=10", "BPM=120"},
+			[]string{"TITLE=Other", "TRACKNUMBER=1", "BPM=120.5"},
+			[]FieldDiff{
+				{Field: "title", A: "Title", B: "Other"},
+				{Field: "track_total", A: "10", B: ""},
+				{Field: "bpm", A: "120", B: "120.5"},
+			},
+		},
+		{
+			[]string{"CHAPTER001=00:00:00.000", "CHAPTER001NAME=Intro"},
+			nil,
+			[]FieldDiff{
+				{Field: "chapters", A: "0.000 Intro", B: ""},
+			},
+		},
+	}
+
+	for ii, tt := range tests {
+		a, err := ReadFLACTags(bytes.NewReader(testFLAC(t, tt.a, 0)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		b, err := ReadFLACTags(bytes.NewReader(testFLAC(t, tt.b, 0)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := Diff(a, b); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("[%d] Diff = %v, expected %v", ii, got, tt.expected)
+		}
+	}
+}
+
+func TestFieldValues(t *testing.T) {
+	m, err := ReadFLACTags(bytes.NewReader(testFLAC(t, []string{"TITLE=Title", "TRACKNUMBER=3", "BPM=120.5",
+		"CHAPTER001=00:00:00.000"}, 0)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := FieldValues(m)
+	for k, want := range map[string]string{"title": "Title", "track": "3", "track_total": "", "bpm": "120.5"} {
+		if got[k] != want {
+			t.Errorf("FieldValues()[%q] = %q, expected %q", k, got[k], want)
+		}
+	}
+	for _, k := range []string{"chapters", "picture"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("FieldValues() has %q, expected only fields of Edit.Fields", k)
+		}
+	}
+}
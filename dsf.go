@@ -13,35 +13,41 @@ import (
 // metadata in a Metadata implementation, or non-nil error if there was a problem.
 // samples: http://www.2l.no/hires/index.html
 func ReadDSFTags(r io.ReadSeeker) (Metadata, error) {
+	rc := defaultReadContext()
+	return readDSFTags(rc.reader(r), rc)
+}
+
+func readDSFTags(r io.ReadSeeker, rc *readContext) (Metadata, error) {
+	start := tell(r)
 	dsd, err := readString(r, 4)
 	if err != nil {
-		return nil, err
+		return nil, parseError(UnknownFormat, "DSD chunk", start, err)
 	}
 	if dsd != "DSD " {
-		return nil, errors.New("expected 'DSD '")
+		return nil, parseError(UnknownFormat, "DSD chunk", start, errors.New("expected 'DSD '"))
 	}
 
 	_, err = r.Seek(int64(16), io.SeekCurrent)
 	if err != nil {
-		return nil, err
+		return nil, parseError(UnknownFormat, "DSD chunk", start, err)
 	}
 
 	id3Pointer, err := readUint64LittleEndian(r)
 	if err != nil {
-		return nil, err
+		return nil, parseError(UnknownFormat, "DSD chunk", start, err)
 	}
 
 	_, err = r.Seek(int64(id3Pointer), io.SeekStart)
 	if err != nil {
-		return nil, err
+		return nil, parseError(UnknownFormat, "DSD chunk", start, err)
 	}
 
-	id3, err := ReadID3v2Tags(r)
-	if err != nil {
+	id3, err := readID3v2Tags(r, rc)
+	if err != nil && err != ErrTruncated {
 		return nil, err
 	}
 
-	return metadataDSF{id3}, nil
+	return metadataDSF{id3}, err
 }
 
 type metadataDSF struct {
@@ -111,3 +117,27 @@ func (m metadataDSF) Raw() map[string]interface{} {
 func (m metadataDSF) Duration() int {
 	return 0
 }
+
+func (m metadataDSF) BPM() float64 {
+	return BPM(m.id3)
+}
+
+func (m metadataDSF) Key() Key {
+	return InitialKey(m.id3)
+}
+
+func (m metadataDSF) Chapters() []Chapter {
+	return Chapters(m.id3)
+}
+
+func (m metadataDSF) Warnings() []error {
+	return Warnings(m.id3)
+}
+
+func (m metadataDSF) Conflicts() []Conflict {
+	return Conflicts(m.id3)
+}
+
+func (m metadataDSF) UnknownTags() []UnknownTag {
+	return UnknownTags(m.id3)
+}
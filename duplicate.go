@@ -0,0 +1,82 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import "fmt"
+
+// DuplicatePolicy determines the value used when a tag which should only appear once (an
+// MP4 item atom, an ID3v2 text frame or a Vorbis comment field) appears more than once.
+type DuplicatePolicy int
+
+// Duplicate policies.
+const (
+	// FormatDefault is the behaviour of each format: the last value of MP4 atoms and
+	// Vorbis comment fields is used, and the first of ID3v2 frames, with each later frame
+	// kept in Raw under its name with a number appended (such as TIT2_0).  This is the
+	// default.
+	FormatDefault DuplicatePolicy = iota
+
+	// LastWins uses the last value.
+	LastWins
+
+	// FirstWins uses the first value.
+	FirstWins
+
+	// MultiValue joins text values with ";" (see Genres), combines the values of MP4
+	// atoms with several data atoms (see Artists), and uses the last of other values.
+	MultiValue
+)
+
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case FormatDefault:
+		return "format-default"
+	case LastWins:
+		return "last-wins"
+	case FirstWins:
+		return "first-wins"
+	case MultiValue:
+		return "multi-value"
+	}
+	return fmt.Sprintf("DuplicatePolicy(%d)", int(p))
+}
+
+// DefaultDuplicatePolicy is the DuplicatePolicy used when reading tags, unless another is
+// given by ReadOptions.
+var DefaultDuplicatePolicy = FormatDefault
+
+// mergeDuplicate returns the value of a tag with the value old which appears again with
+// the value v, according to the DuplicatePolicy of rc.  FormatDefault uses v: ID3v2 frames
+// are not merged with that policy.
+func (rc *readContext) mergeDuplicate(old, v interface{}) interface{} {
+	switch rc.DuplicatePolicy {
+	case FirstWins:
+		return old
+	case MultiValue:
+		a, ok := old.(string)
+		b, ok2 := v.(string)
+		if ok && ok2 {
+			return a + ";" + b
+		}
+		as, ok := stringValues(old)
+		bs, ok2 := stringValues(v)
+		if ok && ok2 {
+			return append(as, bs...)
+		}
+	}
+	return v
+}
+
+// stringValues returns the text value v (a string, or the []string of an MP4 atom with
+// several data atoms) as a new slice.
+func stringValues(v interface{}) ([]string, bool) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, true
+	case []string:
+		return append([]string(nil), v...), true
+	}
+	return nil, false
+}
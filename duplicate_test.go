@@ -0,0 +1,84 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDuplicatePolicy(t *testing.T) {
+	defer func(p DuplicatePolicy) { DefaultDuplicatePolicy = p }(DefaultDuplicatePolicy)
+
+	first := testID3v23Frame("TIT2", []byte("\x00First"))
+	second := testID3v23Frame("TIT2", []byte("\x00Second"))
+	id3 := testID3v23(len(first)+len(second)+10, first, second, make([]byte, 10))
+	m4a := testM4A([][]byte{testTextItem("\xa9nam", "First"), testTextItem("\xa9nam", "Second")})
+	flac := testFLAC(t, []string{"TITLE=First", "title=Second"}, 0)
+
+	tests := []struct {
+		policy    DuplicatePolicy
+		id3Title  string // the title of the ID3v2 tag
+		title     string // the title of the MP4 and FLAC files
+		duplicate bool   // the second ID3v2 frame is kept as TIT2_0
+	}{
+		{FormatDefault, "First", "Second", true},
+		{LastWins, "Second", "Second", false},
+		{FirstWins, "First", "First", false},
+		{MultiValue, "First;Second", "First;Second", false},
+	}
+
+	for ii, tt := range tests {
+		DefaultDuplicatePolicy = tt.policy
+		for _, input := range []struct {
+			b     []byte
+			read  func([]byte) (Metadata, error)
+			title string
+		}{
+			{id3, readID3v2Bytes, tt.id3Title},
+			{m4a, readAtomsBytes, tt.title},
+			{flac, readFLACBytes, tt.title},
+		} {
+			m, err := input.read(input.b)
+			if err != nil {
+				t.Errorf("[%d] %v: unexpected error: %v", ii, tt.policy, err)
+				continue
+			}
+			if m.Title() != input.title {
+				t.Errorf("[%d] %v: %v Title() = %q, expected %q", ii, tt.policy, m.Format(), m.Title(), input.title)
+			}
+			if m.FileType() != MP3 {
+				continue
+			}
+			if v, ok := m.Raw()["TIT2_0"]; ok != tt.duplicate || (ok && v != "Second") {
+				t.Errorf("[%d] %v: TIT2_0 = %v, expected it to be kept: %v", ii, tt.policy, v, tt.duplicate)
+			}
+		}
+	}
+}
+
+func TestDuplicatePolicyMultipleValues(t *testing.T) {
+	text := func(v string) []byte { return testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(v)) }
+	m4a := testM4A([][]byte{
+		testAtom(AtomArtist, text("Artist 1"), text("Artist 2")),
+		testAtom(AtomArtist, text("Artist 3")),
+		testAtom(AtomComposer, text("Composer 1")),
+		testAtom(AtomComposer, text("Composer 2"), text("Composer 3")),
+	})
+
+	o := NewReadOptions()
+	o.DuplicatePolicy = MultiValue
+	m, err := ReadFromWithOptions(bytes.NewReader(m4a), o)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := Artists(m), []string{"Artist 1", "Artist 2", "Artist 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Artists() = %q, expected %q", got, want)
+	}
+	if got, want := Composers(m), []string{"Composer 1", "Composer 2", "Composer 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Composers() = %q, expected %q", got, want)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Field is a metadata field, whose name (see String) is used by Edit.Fields, Diff, Lint
+// and Registry.
+type Field int
+
+// Fields.
+const (
+	UnknownField Field = iota
+	FieldTitle
+	FieldAlbum
+	FieldArtist
+	FieldAlbumArtist
+	FieldComposer
+	FieldGenre
+	FieldYear
+	FieldTrack
+	FieldTrackTotal
+	FieldDisc
+	FieldDiscTotal
+	FieldBPM
+	FieldKey
+	FieldComment
+	FieldLyrics
+	FieldPicture
+	FieldChapters
+)
+
+var fieldNames = [...]string{
+	UnknownField:     "",
+	FieldTitle:       "title",
+	FieldAlbum:       "album",
+	FieldArtist:      "artist",
+	FieldAlbumArtist: "album_artist",
+	FieldComposer:    "composer",
+	FieldGenre:       "genre",
+	FieldYear:        "year",
+	FieldTrack:       "track",
+	FieldTrackTotal:  "track_total",
+	FieldDisc:        "disc",
+	FieldDiscTotal:   "disc_total",
+	FieldBPM:         "bpm",
+	FieldKey:         "key",
+	FieldComment:     "comment",
+	FieldLyrics:      "lyrics",
+	FieldPicture:     "picture",
+	FieldChapters:    "chapters",
+}
+
+// String returns the name of the field, such as "album_artist".
+func (f Field) String() string {
+	if f > UnknownField && int(f) < len(fieldNames) {
+		return fieldNames[f]
+	}
+	return fmt.Sprintf("Field(%d)", int(f))
+}
+
+// ParseField returns the Field with the given name (see Field.String), ignoring case.
+func ParseField(s string) (Field, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for f, name := range fieldNames {
+		if name != "" && name == s {
+			return Field(f), nil
+		}
+	}
+	return UnknownField, fmt.Errorf("unknown field %q", s)
+}
+
+// Names of MP4 atoms, as used in Metadata.Raw.
+const (
+	AtomTitle         = "\xa9nam"
+	AtomAlbum         = "\xa9alb"
+	AtomArtist        = "\xa9ART"
+	AtomArtistAlt     = "\xa9art" // used by some taggers in place of AtomArtist
+	AtomAlbumArtist   = "aART"
+	AtomComposer      = "\xa9wrt"
+	AtomYear          = "\xa9day"
+	AtomGenre         = "\xa9gen"
+	AtomGenreID       = "gnre" // ID3v1 genre index + 1, used by older taggers
+	AtomTrack         = "trkn"
+	AtomTrackTotal    = "trkn_count" // the total from the trkn atom
+	AtomDisc          = "disk"
+	AtomDiscTotal     = "disk_count" // the total from the disk atom
+	AtomTempo         = "tmpo"
+	AtomComment       = "\xa9cmt"
+	AtomLyrics        = "\xa9lyr"
+	AtomPicture       = "covr"
+	AtomChapters      = "chpl"
+	AtomEncoder       = "\xa9too"
+	AtomCopyright     = "cprt"
+	AtomGrouping      = "\xa9grp"
+	AtomKeywords      = "keyw"
+	AtomMovement      = "\xa9mvn"
+	AtomMovementCount = "\xa9mvc"
+	AtomMovementIndex = "\xa9mvi"
+	AtomShowMovement  = "shwm"
+	AtomWork          = "\xa9wrk"
+	AtomCompilation   = "cpil"
+	AtomCategory      = "catg"
+	AtomAdvisory      = "rtng"
+	AtomMediaKind     = "stik"
+	AtomGapless       = "pgap"
+	AtomHDVideo       = "hdvd"
+	AtomPodcast       = "pcst"
+	AtomPodcastURL    = "purl"
+	AtomEpisodeGUID   = "egid"
+	AtomDescription   = "desc"
+	AtomLongDesc      = "ldes"
+	AtomDescAlt       = "\xa9des" // used by some taggers (such as Audible) in place of AtomDescription
+	AtomNarrator      = "\xa9nrt"
+	AtomTVShow        = "tvsh"
+	AtomTVEpisodeID   = "tven"
+	AtomTVSeason      = "tvsn"
+	AtomTVEpisode     = "tves"
+	AtomTVNetwork     = "tvnn"
+	AtomSortTitle     = "sonm"
+	AtomSortArtist    = "soar"
+	AtomSortAlbum     = "soal"
+	AtomSortAlbumArt  = "soaa"
+	AtomSortComposer  = "soco"
+	AtomSortShow      = "sosn"
+	AtomCatalogID     = "cnID" // iTunes Store identifiers of the track, artist, album (playlist) and genre
+	AtomArtistID      = "atID"
+	AtomPlaylistID    = "plID"
+	AtomGenreStoreID  = "geID"
+	AtomStorefrontID  = "sfID" // iTunes Store country (storefront) the track was bought from
+	AtomAppleID       = "apID" // Apple ID of the account which bought the track
+	AtomOwner         = "ownr" // name of the owner of that account
+	AtomPurchaseDate  = "purd"
+	AtomCustom        = "----" // iTunes custom atoms are read using their name
+)
+
+// Names of ID3v2.3 and ID3v2.4 frames, as used in Metadata.Raw.
+const (
+	FrameTitle              = "TIT2"
+	FrameAlbum              = "TALB"
+	FrameArtist             = "TPE1"
+	FrameAlbumArtist        = "TPE2"
+	FrameComposer           = "TCOM"
+	FrameYear               = "TYER" // ID3v2.3
+	FrameRecordingTime      = "TDRC" // ID3v2.4, in place of FrameYear
+	FrameDate               = "TDAT" // ID3v2.3, DDMM
+	FrameTime               = "TIME" // ID3v2.3, HHMM
+	FrameOriginalYear       = "TORY" // ID3v2.3
+	FrameOriginalTime       = "TDOR" // ID3v2.4, in place of FrameOriginalYear
+	FrameInvolvedPeople     = "IPLS" // ID3v2.3
+	FrameInvolvedPeopleList = "TIPL" // ID3v2.4, in place of FrameInvolvedPeople
+	FrameTrack              = "TRCK"
+	FrameDisc               = "TPOS"
+	FrameGenre              = "TCON"
+	FrameBPM                = "TBPM"
+	FrameKey                = "TKEY"
+	FrameComment            = "COMM"
+	FrameLyrics             = "USLT"
+	FramePicture            = "APIC"
+	FrameUserText           = "TXXX"
+	FrameUserURL            = "WXXX"
+	FrameUniqueID           = "UFID"
+)
+
+// UserTextPrefix is the prefix of the names in Metadata.Raw of ID3v2 user defined text
+// (TXXX) frames, which is followed by the description of the frame (such as
+// "txxx:REPLAYGAIN_TRACK_GAIN").  Their values are the text of the frames.
+const UserTextPrefix = "txxx:"
+
+// Names of Vorbis comment fields (see https://wiki.xiph.org/Field_names).  Metadata.Raw
+// uses their lower case forms.
+const (
+	CommentTitle       = "TITLE"
+	CommentAlbum       = "ALBUM"
+	CommentArtist      = "ARTIST"
+	CommentAlbumArtist = "ALBUMARTIST"
+	CommentComposer    = "COMPOSER"
+	CommentGenre       = "GENRE"
+	CommentDate        = "DATE"
+	CommentTrack       = "TRACKNUMBER"
+	CommentTrackTotal  = "TRACKTOTAL"
+	CommentDisc        = "DISCNUMBER"
+	CommentDiscTotal   = "DISCTOTAL"
+	CommentBPM         = "BPM"
+	CommentKey         = "INITIALKEY"
+	CommentComment     = "COMMENT"
+	CommentLyrics      = "LYRICS"
+)
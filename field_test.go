@@ -0,0 +1,85 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import "testing"
+
+func TestFieldString(t *testing.T) {
+	tests := []struct {
+		f    Field
+		want string
+	}{
+		{FieldTitle, "title"},
+		{FieldAlbumArtist, "album_artist"},
+		{FieldTrackTotal, "track_total"},
+		{FieldChapters, "chapters"},
+		{UnknownField, "Field(0)"},
+		{Field(100), "Field(100)"},
+	}
+
+	for ii, tt := range tests {
+		if got := tt.f.String(); got != tt.want {
+			t.Errorf("[%d] String() = %q, expected %q", ii, got, tt.want)
+		}
+	}
+}
+
+func TestParseField(t *testing.T) {
+	for f := FieldTitle; f <= FieldChapters; f++ {
+		got, err := ParseField(f.String())
+		if err != nil {
+			t.Errorf("[%v] unexpected error: %v", f, err)
+			continue
+		}
+		if got != f {
+			t.Errorf("[%v] ParseField() = %v, expected %v", f, got, f)
+		}
+	}
+
+	tests := []struct {
+		s       string
+		want    Field
+		wantErr bool
+	}{
+		{" Album_Artist ", FieldAlbumArtist, false},
+		{"BPM", FieldBPM, false},
+		{"", UnknownField, true},
+		{"encoder", UnknownField, true},
+	}
+
+	for ii, tt := range tests {
+		got, err := ParseField(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("[%d] ParseField(%q) error = %v, expected error: %v", ii, tt.s, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("[%d] ParseField(%q) = %v, expected %v", ii, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestFieldKeys(t *testing.T) {
+	tests := []struct {
+		f    Format
+		name string
+		want string
+	}{
+		{MP4, AtomTitle, "title"},
+		{MP4, AtomAlbumArtist, "album_artist"},
+		{MP4, AtomTrack, "track"},
+		{ID3v2_3, FrameTitle, "title"},
+		{ID3v2_3, FrameYear, "year"},
+		{ID3v2_4, FrameRecordingTime, "year"},
+		{VORBIS, CommentAlbumArtist, "album_artist"},
+		{VORBIS, CommentDate, "year"},
+	}
+
+	for ii, tt := range tests {
+		got, ok := Registry.LookupByAtom(tt.f, tt.name)
+		if !ok || got != tt.want {
+			t.Errorf("[%d] LookupByAtom(%v, %q) = %q, %v, expected %q", ii, tt.f, tt.name, got, ok, tt.want)
+		}
+	}
+}
@@ -5,7 +5,9 @@
 package audiotag
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -14,33 +16,64 @@ type blockType byte
 
 // FLAC block types.
 const (
-	// Stream Info Block           0
-	// Padding Block               1
-	// Application Block           2
-	// Seektable Block             3
-	// Cue Sheet Block             5
+	streamInfoBlock    blockType = 0
+	paddingBlock       blockType = 1
+	applicationBlock   blockType = 2
+	seekTableBlock     blockType = 3
 	vorbisCommentBlock blockType = 4
+	cueSheetBlock      blockType = 5
 	pictureBlock       blockType = 6
 )
 
+func (t blockType) String() string {
+	switch t {
+	case streamInfoBlock:
+		return "STREAMINFO"
+	case paddingBlock:
+		return "PADDING"
+	case applicationBlock:
+		return "APPLICATION"
+	case seekTableBlock:
+		return "SEEKTABLE"
+	case vorbisCommentBlock:
+		return "VORBIS_COMMENT"
+	case cueSheetBlock:
+		return "CUESHEET"
+	case pictureBlock:
+		return "PICTURE"
+	}
+	return fmt.Sprintf("type %d", byte(t))
+}
+
 // ReadFLACTags reads FLAC metadata from the io.ReadSeeker, returning the resulting
-// metadata in a Metadata implementation, or non-nil error if there was a problem.
+// metadata in a Metadata implementation, or non-nil error if there was a problem.  If the
+// data ends part way through a metadata block then the blocks read so far are returned
+// with ErrTruncated (in Lenient mode).
 func ReadFLACTags(r io.ReadSeeker) (Metadata, error) {
+	rc := defaultReadContext()
+	return readFLACTags(rc.reader(r), rc)
+}
+
+func readFLACTags(r io.ReadSeeker, rc *readContext) (Metadata, error) {
+	start := tell(r)
 	flac, err := readString(r, 4)
 	if err != nil {
-		return nil, err
+		return nil, parseError(VORBIS, "stream marker", start, err)
 	}
 	if flac != "fLaC" {
-		return nil, errors.New("expected 'fLaC'")
+		return nil, parseError(VORBIS, "stream marker", start, errors.New("expected 'fLaC'"))
 	}
 
 	m := &metadataFLAC{
-		newMetadataVorbis(),
+		newMetadataVorbis(rc),
 	}
 
 	for {
 		last, err := m.readFLACMetadataBlock(r)
 		if err != nil {
+			if err == ErrTruncated {
+				return m, err
+			}
 			return nil, err
 		}
 
@@ -56,8 +89,12 @@ type metadataFLAC struct {
 }
 
 func (m *metadataFLAC) readFLACMetadataBlock(r io.ReadSeeker) (last bool, err error) {
-	blockHeader, err := readBytes(r, 1)
+	start := tell(r)
+	blockHeader, err := readBytes(r, 4)
 	if err != nil {
+		if isTruncation(err) {
+			err = m.rc.truncated(VORBIS, start)
+		}
 		return
 	}
 
@@ -65,21 +102,51 @@ func (m *metadataFLAC) readFLACMetadataBlock(r io.ReadSeeker) (last bool, err er
 		blockHeader[0] ^= (1 << 7)
 		last = true
 	}
+	blockLen := uint(getInt(blockHeader[1:]))
 
-	blockLen, err := readInt(r, 3)
-	if err != nil {
-		return
-	}
-
+	var read func(io.Reader) error
 	switch blockType(blockHeader[0]) {
 	case vorbisCommentBlock:
-		err = m.readVorbisComment(r)
+		read = func(r io.Reader) error { return m.readVorbisComment(r, start+4) }
 
 	case pictureBlock:
-		err = m.readPictureBlock(r)
+		read = m.readPictureBlock
 
-	default:
+	case streamInfoBlock, paddingBlock:
 		_, err = r.Seek(int64(blockLen), io.SeekCurrent)
+		return
+
+	default:
+		// Blocks which are not read (such as APPLICATION and CUESHEET) are unknown tags.
+		var b []byte
+		if m.rc.UnknownTagPolicy == CaptureUnknown {
+			b, err = readBytes(r, blockLen)
+		} else {
+			_, err = r.Seek(int64(blockLen), io.SeekCurrent)
+		}
+		if err != nil {
+			if isTruncation(err) {
+				err = m.rc.truncated(VORBIS, start)
+			}
+			return
+		}
+		m.unknownTags.add(m.rc.UnknownTagPolicy, VORBIS, blockType(blockHeader[0]).String(), start, int64(blockLen), b)
+		return
+	}
+
+	b, err := readBytes(r, blockLen)
+	if err != nil {
+		if isTruncation(err) {
+			err = m.rc.truncated(VORBIS, start)
+		}
+		return
+	}
+	if err = read(bytes.NewReader(b)); err != nil {
+		if _, ok := err.(*ParseError); ok {
+			return
+		}
+		// Lenient: skip the malformed block.
+		err = m.rc.structureViolation(&m.warnings, VORBIS, fmt.Sprintf("%v block", blockType(blockHeader[0])), start, err)
 	}
 	return
 }
@@ -0,0 +1,156 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// maxFLACBlockLen is the largest length of a FLAC metadata block (24 bits).
+	maxFLACBlockLen = 1<<24 - 1
+
+	// defaultFLACPadding is the padding added when there is no existing padding to use.
+	defaultFLACPadding = 1024
+)
+
+// flacBlock is a FLAC metadata block.
+type flacBlock struct {
+	t    blockType
+	data []byte
+}
+
+// readFLACBlocks reads the FLAC metadata blocks from r, which must be positioned after
+// the "fLaC" marker.
+func readFLACBlocks(r io.Reader) ([]flacBlock, error) {
+	var blocks []flacBlock
+	for {
+		blockHeader, err := readBytes(r, 1)
+		if err != nil {
+			return nil, err
+		}
+		last := getBit(blockHeader[0], 7)
+		t := blockType(blockHeader[0] &^ (1 << 7))
+
+		blockLen, err := readUint(r, 3)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readBytes(r, blockLen)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, flacBlock{t: t, data: data})
+
+		if last {
+			return blocks, nil
+		}
+	}
+}
+
+// WriteFLACTags copies the FLAC data from r to w, updating the Vorbis comment and picture
+// metadata blocks with the Edit.  Existing padding is resized where possible so that the
+// audio data remains at the same offset.
+func WriteFLACTags(w io.Writer, r io.ReadSeeker, e *Edit) error {
+	flac, err := readString(r, 4)
+	if err != nil {
+		return err
+	}
+	if flac != "fLaC" {
+		return errors.New("expected 'fLaC'")
+	}
+
+	blocks, err := readFLACBlocks(r)
+	if err != nil {
+		return err
+	}
+
+	var origLen, padding int
+	var vendor string
+	var comments []string
+	var out []flacBlock
+	for _, b := range blocks {
+		origLen += 4 + len(b.data)
+		switch b.t {
+		case vorbisCommentBlock:
+			vendor, comments, err = readVorbisCommentList(bytes.NewReader(b.data))
+			if err != nil {
+				return fmt.Errorf("error reading vorbis comment block: %v", err)
+			}
+			continue
+
+		case pictureBlock:
+			if e.Pictures != nil || e.Clear {
+				continue
+			}
+
+		case paddingBlock:
+			padding += 4 + len(b.data)
+			continue
+		}
+		out = append(out, b)
+	}
+	if len(out) == 0 || out[0].t != 0 {
+		return errors.New("expected FLAC STREAMINFO block")
+	}
+
+	comments, err = editVorbisComments(comments, e)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	if err := writeVorbisComment(buf, vendor, comments); err != nil {
+		return err
+	}
+	// The comment block goes after STREAMINFO (and any other leading blocks).
+	out = append(out, flacBlock{t: vorbisCommentBlock, data: buf.Bytes()})
+
+	for _, p := range e.Pictures {
+		buf := &bytes.Buffer{}
+		if err := writePictureBlock(buf, p); err != nil {
+			return err
+		}
+		out = append(out, flacBlock{t: pictureBlock, data: buf.Bytes()})
+	}
+
+	var n int
+	for _, b := range out {
+		if len(b.data) > maxFLACBlockLen {
+			return fmt.Errorf("FLAC metadata block too large: %d bytes", len(b.data))
+		}
+		n += 4 + len(b.data)
+	}
+
+	// Keep the audio data at the same offset if the existing padding allows, otherwise
+	// add fresh padding so that future edits can be done in place.
+	padLen := defaultFLACPadding
+	if padding > 0 && n+4 <= origLen {
+		padLen = origLen - n - 4
+	}
+	out = append(out, flacBlock{t: paddingBlock, data: make([]byte, padLen)})
+
+	if _, err := io.WriteString(w, "fLaC"); err != nil {
+		return err
+	}
+	for i, b := range out {
+		h := byte(b.t)
+		if i == len(out)-1 {
+			h |= 1 << 7
+		}
+		l := len(b.data)
+		if _, err := w.Write([]byte{h, byte(l >> 16), byte(l >> 8), byte(l)}); err != nil {
+			return err
+		}
+		if _, err := w.Write(b.data); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
@@ -0,0 +1,138 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testFLAC returns a minimal FLAC file with the given Vorbis comments and padding.
+func testFLAC(t *testing.T, comments []string, padding int) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("fLaC")
+
+	streamInfo := make([]byte, 34)
+	vc := &bytes.Buffer{}
+	if err := writeVorbisComment(vc, "test", comments); err != nil {
+		t.Fatal(err)
+	}
+	blocks := []flacBlock{
+		{t: 0, data: streamInfo},
+		{t: vorbisCommentBlock, data: vc.Bytes()},
+		{t: paddingBlock, data: make([]byte, padding)},
+	}
+	for i, b := range blocks {
+		h := byte(b.t)
+		if i == len(blocks)-1 {
+			h |= 1 << 7
+		}
+		l := len(b.data)
+		buf.Write([]byte{h, byte(l >> 16), byte(l >> 8), byte(l)})
+		buf.Write(b.data)
+	}
+	buf.WriteString("audio data")
+	return buf.Bytes()
+}
+
+func TestWriteFLACTags(t *testing.T) {
+	in := testFLAC(t, []string{"TITLE=Title", "ARTIST=Artist", "BPM=120", "CUSTOM=x"}, 512)
+
+	out := &bytes.Buffer{}
+	err := WriteTags(out, bytes.NewReader(in), &Edit{
+		Fields: map[string]string{
+			"title":        "New Title",
+			"album_artist": "Album Artist",
+			"bpm":          "",
+		},
+		Pictures: []*Picture{{MIMEType: "image/png", Type: "Cover (back)", Data: []byte("png")}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out.Len() != len(in) {
+		t.Errorf("expected padding to be reused: got %d bytes, expected %d", out.Len(), len(in))
+	}
+	if !bytes.HasSuffix(out.Bytes(), []byte("audio data")) {
+		t.Errorf("audio data not preserved")
+	}
+
+	m, err := ReadFLACTags(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error reading written tags: %v", err)
+	}
+	testValue(t, "New Title", m.Title())
+	testValue(t, "Artist", m.Artist())
+	testValue(t, "Album Artist", m.AlbumArtist())
+	testValue(t, 0.0, BPM(m))
+	testValue(t, "x", m.Raw()["custom"])
+
+	p := m.Picture()
+	if p == nil {
+		t.Fatalf("expected picture")
+	}
+	testValue(t, "image/png", p.MIMEType)
+	testValue(t, "Cover (back)", p.Type)
+	testValue(t, "png", string(p.Data))
+}
+
+func TestWriteFLACTagsClear(t *testing.T) {
+	in := testFLAC(t, []string{"TITLE=Title", "ARTIST=Artist"}, 0)
+
+	out := &bytes.Buffer{}
+	err := WriteFLACTags(out, bytes.NewReader(in), &Edit{
+		Fields: map[string]string{"artist": "Artist"},
+		Clear:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m, err := ReadFLACTags(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error reading written tags: %v", err)
+	}
+	testValue(t, "", m.Title())
+	testValue(t, "Artist", m.Artist())
+}
+
+func TestWriteFLACChapters(t *testing.T) {
+	in := testFLAC(t, []string{"TITLE=Title", "CHAPTER001=00:00:00.000", "CHAPTER001NAME=Old"}, 512)
+
+	out := &bytes.Buffer{}
+	err := WriteTags(out, bytes.NewReader(in), &Edit{
+		Chapters: []Chapter{
+			{StartTime: "0", Title: "Intro"},
+			{StartTime: "75.5", Title: "Part 2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m, err := ReadFLACTags(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error reading written tags: %v", err)
+	}
+	testValue(t, "Title", m.Title())
+	testValue(t, "00:01:15.500", m.Raw()["chapter002"])
+
+	chapters := Chapters(m)
+	if len(chapters) != 2 {
+		t.Fatalf("expected 2 chapters, got %d", len(chapters))
+	}
+	testValue(t, "Intro", chapters[0].Title)
+	testValue(t, "75.500", chapters[0].EndTime)
+	testValue(t, "75.500", chapters[1].StartTime)
+	testValue(t, "Part 2", chapters[1].Title)
+
+	err = WriteTags(&bytes.Buffer{}, bytes.NewReader(in), &Edit{
+		Chapters: []Chapter{{StartTime: "0"}, {StartTime: "x"}, {StartTime: "90"}},
+	})
+	if err == nil {
+		t.Errorf("expected error for invalid chapter start time")
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import "strings"
+
+// GenreSynonyms maps genre names to the names returned by Genres in their place, so that
+// variant spellings (such as "Psych Rock" and "Psychedelic Rock") can be merged.  Keys are
+// matched case-insensitively.  It is empty by default.
+var GenreSynonyms = map[string]string{}
+
+// Genres returns the genres of the track, splitting the genre field into its parts (which
+// can be separated by ";", "/" or NULs), mapping any GenreSynonyms and removing duplicates.
+// Returns nil if there is no genre.
+func Genres(m Metadata) []string {
+	return splitGenres(m.Genre())
+}
+
+func splitGenres(genre string) []string {
+	synonyms := make(map[string]string, len(GenreSynonyms))
+	for k, v := range GenreSynonyms {
+		synonyms[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+
+	var genres []string
+	seen := make(map[string]bool)
+	for _, g := range strings.FieldsFunc(genre, func(r rune) bool { return r == ';' || r == '/' || r == 0 }) {
+		g = strings.TrimSpace(g)
+		if s, ok := synonyms[strings.ToLower(g)]; ok {
+			g = s
+		}
+		if g == "" || seen[strings.ToLower(g)] {
+			continue
+		}
+		seen[strings.ToLower(g)] = true
+		genres = append(genres, g)
+	}
+	return genres
+}
@@ -0,0 +1,55 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSplitGenres(t *testing.T) {
+	defer func(s map[string]string) { GenreSynonyms = s }(GenreSynonyms)
+	GenreSynonyms = map[string]string{
+		"Psych Rock":   "Psychedelic Rock",
+		"psych-rock ":  "Psychedelic Rock",
+		"Hip Hop":      "Hip-Hop",
+		"Unknown":      "",
+		"Electronica ": "Electronic",
+	}
+
+	tests := []struct {
+		input  string
+		genres []string
+	}{
+		{"", nil},
+		{"Jazz", []string{"Jazz"}},
+		{" Jazz \x00\x00", []string{"Jazz"}},
+		{"Rock;Pop", []string{"Rock", "Pop"}},
+		{"Rock / Pop ; Jazz", []string{"Rock", "Pop", "Jazz"}},
+		{"Rock\x00Pop", []string{"Rock", "Pop"}},
+		{"Rock;;rock;ROCK", []string{"Rock"}},
+		{"psych rock;Psych-Rock;Psychedelic Rock", []string{"Psychedelic Rock"}},
+		{"hip hop/Unknown", []string{"Hip-Hop"}},
+	}
+
+	for ii, tt := range tests {
+		if got := splitGenres(tt.input); !reflect.DeepEqual(got, tt.genres) {
+			t.Errorf("[%d] splitGenres(%q) = %q, expected %q", ii, tt.input, got, tt.genres)
+		}
+	}
+}
+
+func TestReadID3v2MultipleValues(t *testing.T) {
+	genre := testID3v23Frame("TCON", []byte("\x00Rock\x00Pop\x00"))
+	m, err := ReadID3v2Tags(bytes.NewReader(testID3v23(len(genre)+10, genre, make([]byte, 10))))
+	if err != nil {
+		t.Fatalf("ReadID3v2Tags() error = %v", err)
+	}
+	testValue(t, "Rock;Pop", m.Genre())
+	if got := Genres(m); !reflect.DeepEqual(got, []string{"Rock", "Pop"}) {
+		t.Errorf("Genres() = %q, expected %q", got, []string{"Rock", "Pop"})
+	}
+}
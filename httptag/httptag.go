@@ -0,0 +1,305 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package httptag provides an http.Handler which serves the metadata and artwork of
+// audio files, either uploaded in the request or read from a directory on the server.
+//
+// Requests are handled as follows:
+//
+//	GET  /?path=dir/file.mp3      metadata of a file under Handler.Root, as JSON
+//	POST /                        metadata of the uploaded file (the request body, or
+//	                              the "file" field of a multipart form), as JSON
+//
+// Adding the query parameter art=1 to either request returns the attached picture
+// instead of the metadata (404 if there is none).
+package httptag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/chaolong-1995/audiotag"
+)
+
+// DefaultMaxUploadSize is the maximum size of uploaded files used when
+// Handler.MaxUploadSize is zero.
+const DefaultMaxUploadSize = 256 << 20
+
+// Picture is a summary of an attached picture.
+type Picture struct {
+	MIMEType    string `json:"mime_type,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Size        int    `json:"size"`
+}
+
+// Metadata is the JSON representation of audio file metadata.
+type Metadata struct {
+	Format      audiotag.Format   `json:"format,omitempty"`
+	FileType    audiotag.FileType `json:"file_type,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Album       string            `json:"album,omitempty"`
+	Artist      string            `json:"artist,omitempty"`
+	AlbumArtist string            `json:"album_artist,omitempty"`
+	Composer    string            `json:"composer,omitempty"`
+	Genre       string            `json:"genre,omitempty"`
+	Year        int               `json:"year,omitempty"`
+	Track       int               `json:"track,omitempty"`
+	TrackTotal  int               `json:"track_total,omitempty"`
+	Disc        int               `json:"disc,omitempty"`
+	DiscTotal   int               `json:"disc_total,omitempty"`
+	Duration    int               `json:"duration,omitempty"`
+	BPM         float64           `json:"bpm,omitempty"`
+	Key         audiotag.Key      `json:"key,omitempty"`
+	Comment     string            `json:"comment,omitempty"`
+	Lyrics      string            `json:"lyrics,omitempty"`
+	Chapters    []Chapter         `json:"chapters,omitempty"`
+	Picture     *Picture          `json:"picture,omitempty"`
+	Warnings    []string          `json:"warnings,omitempty"`
+	Conflicts   []string          `json:"conflicts,omitempty"`
+	Truncated   bool              `json:"truncated,omitempty"` // the file ends part way through the tags
+}
+
+// Chapter is the JSON representation of a chapter marker.
+type Chapter struct {
+	StartTime string   `json:"start_time"`
+	EndTime   string   `json:"end_time,omitempty"`
+	Title     string   `json:"title,omitempty"`
+	Picture   *Picture `json:"picture,omitempty"`
+	URL       string   `json:"url,omitempty"`
+}
+
+// NewMetadata returns the JSON representation of m.
+func NewMetadata(m audiotag.Metadata) *Metadata {
+	x := &Metadata{
+		Format:      m.Format(),
+		FileType:    m.FileType(),
+		Title:       m.Title(),
+		Album:       m.Album(),
+		Artist:      m.Artist(),
+		AlbumArtist: m.AlbumArtist(),
+		Composer:    m.Composer(),
+		Genre:       m.Genre(),
+		Year:        m.Year(),
+		Duration:    m.Duration(),
+		BPM:         audiotag.BPM(m),
+		Key:         audiotag.InitialKey(m),
+		Comment:     m.Comment(),
+		Lyrics:      m.Lyrics(),
+	}
+	x.Track, x.TrackTotal = m.Track()
+	x.Disc, x.DiscTotal = m.Disc()
+	for _, w := range audiotag.Warnings(m) {
+		x.Warnings = append(x.Warnings, w.Error())
+	}
+	for _, c := range audiotag.Conflicts(m) {
+		x.Conflicts = append(x.Conflicts, c.String())
+	}
+	for _, c := range audiotag.Chapters(m) {
+		x.Chapters = append(x.Chapters, Chapter{StartTime: c.StartTime, EndTime: c.EndTime, Title: c.Title, Picture: newPicture(c.Picture), URL: c.URL})
+	}
+	x.Picture = newPicture(m.Picture())
+	return x
+}
+
+// newPicture returns the summary of p, or nil if p is nil.
+func newPicture(p *audiotag.Picture) *Picture {
+	if p == nil {
+		return nil
+	}
+	return &Picture{
+		MIMEType:    p.MIMEType,
+		Type:        p.Type,
+		Description: p.Description,
+		Size:        len(p.Data),
+	}
+}
+
+// Handler is an http.Handler which serves the metadata of audio files.
+type Handler struct {
+	// Root is the directory from which files can be read using the path query
+	// parameter.  If empty, only uploaded files are read.  Symbolic links under Root
+	// are followed only if they lead to files under Root.
+	Root string
+
+	// MaxUploadSize is the maximum size of an uploaded file in bytes.  If zero,
+	// DefaultMaxUploadSize is used.
+	MaxUploadSize int64
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var rs io.ReadSeeker
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		f, err := h.open(r.URL.Query().Get("path"))
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		defer f.Close()
+		rs = f
+
+	case http.MethodPost, http.MethodPut:
+		b, err := h.upload(w, r)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		rs = bytes.NewReader(b)
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, PUT")
+		httpError(w, &statusError{http.StatusMethodNotAllowed, "method not allowed"})
+		return
+	}
+
+	o := audiotag.NewReadOptions()
+	o.MP3Audio = true
+	m, err := audiotag.ReadFromWithOptions(rs, o)
+	truncated := err == audiotag.ErrTruncated && m != nil
+	if err != nil && !truncated {
+		httpError(w, &statusError{http.StatusUnprocessableEntity, err.Error()})
+		return
+	}
+
+	if art := r.URL.Query().Get("art"); art != "" && art != "0" && art != "false" {
+		p := m.Picture()
+		if p == nil {
+			httpError(w, &statusError{http.StatusNotFound, "no picture found"})
+			return
+		}
+		if p.MIMEType != "" {
+			w.Header().Set("Content-Type", p.MIMEType)
+		} else {
+			w.Header().Set("Content-Type", http.DetectContentType(p.Data))
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(p.Data)))
+		w.Write(p.Data)
+		return
+	}
+
+	x := NewMetadata(m)
+	x.Truncated = truncated
+	writeJSON(w, http.StatusOK, x)
+}
+
+// open opens the file with the slash-separated name relative to h.Root.
+func (h *Handler) open(name string) (*os.File, error) {
+	if h.Root == "" {
+		return nil, &statusError{http.StatusForbidden, "reading files by path is disabled"}
+	}
+	if name == "" {
+		return nil, &statusError{http.StatusBadRequest, "missing path"}
+	}
+	if strings.Contains(name, "\x00") || (filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator)) {
+		return nil, &statusError{http.StatusBadRequest, "invalid path"}
+	}
+
+	root, err := filepath.EvalSymlinks(h.Root)
+	if err != nil {
+		return nil, &statusError{http.StatusInternalServerError, "could not read root directory"}
+	}
+	// Cleaning the path as an absolute path removes any leading "..".
+	p, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(path.Clean("/"+name))))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &statusError{http.StatusNotFound, "file not found"}
+		}
+		return nil, &statusError{http.StatusForbidden, "could not open file"}
+	}
+	// Symbolic links must not lead outside the root.
+	if rel, err := filepath.Rel(root, p); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, &statusError{http.StatusForbidden, "file outside root"}
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &statusError{http.StatusNotFound, "file not found"}
+		}
+		return nil, &statusError{http.StatusForbidden, "could not open file"}
+	}
+	if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, &statusError{http.StatusBadRequest, "not a regular file"}
+	}
+	return f, nil
+}
+
+// upload returns the contents of the file uploaded in the request.
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	max := h.MaxUploadSize
+	if max <= 0 {
+		max = DefaultMaxUploadSize
+	}
+	tooLarge := &statusError{http.StatusRequestEntityTooLarge, fmt.Sprintf("file larger than %d bytes", max)}
+	if r.ContentLength > max {
+		return nil, tooLarge
+	}
+	// Allow for the multipart framing around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, max+1<<20)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return nil, &statusError{http.StatusBadRequest, err.Error()}
+		}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil, &statusError{http.StatusBadRequest, `missing "file" field`}
+			}
+			if err != nil {
+				return nil, &statusError{http.StatusBadRequest, err.Error()}
+			}
+			if part.FormName() == "file" {
+				body = part
+				break
+			}
+		}
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(body, max+1))
+	if err != nil {
+		return nil, &statusError{http.StatusBadRequest, err.Error()}
+	}
+	if int64(len(b)) > max {
+		return nil, tooLarge
+	}
+	return b, nil
+}
+
+// statusError is an error with an associated HTTP status code.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+func httpError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if e, ok := err.(*statusError); ok {
+		code = e.code
+	}
+	writeJSON(w, code, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
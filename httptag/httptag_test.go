@@ -0,0 +1,236 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httptag
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFrame returns an ID3v2.3 frame with the given name and data.
+func testFrame(name string, data []byte) []byte {
+	l := len(data)
+	return append([]byte{name[0], name[1], name[2], name[3], byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l), 0, 0}, data...)
+}
+
+// testMP3 returns an ID3v2.3 tag with the given declared size containing the frames.
+func testMP3(size int, frames ...[]byte) []byte {
+	b := []byte{'I', 'D', '3', 3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	for _, f := range frames {
+		b = append(b, f...)
+	}
+	return b
+}
+
+var (
+	testTitle = testFrame("TIT2", []byte("\x00Title"))
+	testAPIC  = testFrame("APIC", []byte("\x00image/png\x00\x03\x00\x89PNG"))
+	testSong  = testMP3(len(testTitle)+len(testAPIC), testTitle, testAPIC)
+)
+
+// serve returns the response of h to the request.
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// decodeMetadata decodes the JSON metadata of the response.
+func decodeMetadata(t *testing.T, w *httptest.ResponseRecorder) *Metadata {
+	t.Helper()
+	x := &Metadata{}
+	if err := json.Unmarshal(w.Body.Bytes(), x); err != nil {
+		t.Fatalf("unexpected error decoding %q: %v", w.Body.String(), err)
+	}
+	return x
+}
+
+func TestHandlerPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httptag")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{filepath.Join(root, "song.mp3"), testSong},
+		{filepath.Join(root, "sub", "song.mp3"), testSong},
+		{filepath.Join(dir, "secret.mp3"), testSong},
+	} {
+		if err := os.MkdirAll(filepath.Dir(f.name), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(f.name, f.data, 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"song.mp3", http.StatusOK},
+		{"sub/song.mp3", http.StatusOK},
+		{"sub/../song.mp3", http.StatusOK},
+		{"../secret.mp3", http.StatusNotFound},
+		{"sub/../../secret.mp3", http.StatusNotFound},
+		{"/../../secret.mp3", http.StatusNotFound},
+		{"missing.mp3", http.StatusNotFound},
+		{"sub", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+	}
+
+	h := &Handler{Root: root}
+	for ii, tt := range tests {
+		w := serve(h, httptest.NewRequest("GET", "/?path="+tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("[%d] %q: status = %d, expected %d", ii, tt.path, w.Code, tt.code)
+			continue
+		}
+		if tt.code == http.StatusOK {
+			if x := decodeMetadata(t, w); x.Title != "Title" {
+				t.Errorf("[%d] %q: Title = %q, expected %q", ii, tt.path, x.Title, "Title")
+			}
+		}
+	}
+
+	w := serve(&Handler{}, httptest.NewRequest("GET", "/?path=song.mp3", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d without Root, expected %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestHandlerSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httptag")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{filepath.Join(root, "song.mp3"), filepath.Join(dir, "secret.mp3")} {
+		if err := ioutil.WriteFile(name, testSong, 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "song.mp3"), filepath.Join(root, "inside.mp3")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.mp3"), filepath.Join(root, "outside.mp3")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Symlink(dir, filepath.Join(root, "parent")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"inside.mp3", http.StatusOK},
+		{"outside.mp3", http.StatusForbidden},
+		{"parent/secret.mp3", http.StatusForbidden},
+		{"parent/root/song.mp3", http.StatusOK},
+	}
+
+	h := &Handler{Root: root}
+	for ii, tt := range tests {
+		if w := serve(h, httptest.NewRequest("GET", "/?path="+tt.path, nil)); w.Code != tt.code {
+			t.Errorf("[%d] %q: status = %d, expected %d", ii, tt.path, w.Code, tt.code)
+		}
+	}
+}
+
+func TestHandlerUpload(t *testing.T) {
+	tests := []struct {
+		max           int64
+		contentLength bool // the request gives the length of the body
+		code          int
+	}{
+		{0, true, http.StatusOK},
+		{int64(len(testSong)), true, http.StatusOK},
+		{int64(len(testSong)) - 1, true, http.StatusRequestEntityTooLarge},
+		{int64(len(testSong)) - 1, false, http.StatusRequestEntityTooLarge},
+	}
+
+	for ii, tt := range tests {
+		r := httptest.NewRequest("POST", "/", bytes.NewReader(testSong))
+		if !tt.contentLength {
+			r.ContentLength = -1
+		}
+		w := serve(&Handler{MaxUploadSize: tt.max}, r)
+		if w.Code != tt.code {
+			t.Errorf("[%d] status = %d, expected %d", ii, w.Code, tt.code)
+			continue
+		}
+		if tt.code == http.StatusOK {
+			if x := decodeMetadata(t, w); x.Title != "Title" || x.Picture == nil || x.Picture.Size != 4 {
+				t.Errorf("[%d] Title, Picture = %q, %+v, expected %q and a 4 byte picture", ii, x.Title, x.Picture, "Title")
+			}
+		}
+	}
+}
+
+func TestHandlerArt(t *testing.T) {
+	w := serve(&Handler{}, httptest.NewRequest("POST", "/?art=1", bytes.NewReader(testSong)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, expected %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %q, expected %q", ct, "image/png")
+	}
+	if b := w.Body.String(); b != "\x89PNG" {
+		t.Errorf("body = %q, expected %q", b, "\x89PNG")
+	}
+
+	noArt := testMP3(len(testTitle), testTitle)
+	w = serve(&Handler{}, httptest.NewRequest("POST", "/?art=1", bytes.NewReader(noArt)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d without picture, expected %d", w.Code, http.StatusNotFound)
+	}
+
+	w = serve(&Handler{}, httptest.NewRequest("POST", "/?art=0", bytes.NewReader(testSong)))
+	if x := decodeMetadata(t, w); x.Title != "Title" {
+		t.Errorf("Title = %q with art=0, expected %q", x.Title, "Title")
+	}
+}
+
+func TestHandlerTruncated(t *testing.T) {
+	tests := []struct {
+		input     []byte
+		truncated bool
+	}{
+		{testSong, false},
+		{testMP3(100, testTitle, testAPIC[:8]), true},
+	}
+
+	for ii, tt := range tests {
+		w := serve(&Handler{}, httptest.NewRequest("POST", "/", bytes.NewReader(tt.input)))
+		if w.Code != http.StatusOK {
+			t.Errorf("[%d] status = %d, expected %d", ii, w.Code, http.StatusOK)
+			continue
+		}
+		x := decodeMetadata(t, w)
+		if x.Truncated != tt.truncated {
+			t.Errorf("[%d] Truncated = %v, expected %v", ii, x.Truncated, tt.truncated)
+		}
+		if x.Title != "Title" {
+			t.Errorf("[%d] Title = %q, expected %q", ii, x.Title, "Title")
+		}
+	}
+}
@@ -26,19 +26,7 @@ func Identify(r io.ReadSeeker) (format Format, fileType FileType, err error) {
 		return VORBIS, OGG, nil
 
 	case string(b[4:8]) == "ftyp":
-		b = b[8:11]
-		fileType = UnknownFileType
-		switch string(b) {
-		case "M4A":
-			fileType = M4A
-
-		case "M4B":
-			fileType = M4B
-
-		case "M4P":
-			fileType = M4P
-		}
-		return MP4, fileType, nil
+		return MP4, brandFileType([]string{string(b[8:11])}), nil
 
 	case string(b[0:3]) == "ID3":
 		b := b[3:]
@@ -58,6 +46,14 @@ func Identify(r io.ReadSeeker) (format Format, fileType FileType, err error) {
 		return format, MP3, nil
 	}
 
+	mp4, err := isMP4(r)
+	if err != nil {
+		return
+	}
+	if mp4 {
+		return MP4, UnknownFileType, nil
+	}
+
 	n, err := r.Seek(-128, io.SeekEnd)
 	if err != nil {
 		return
@@ -7,8 +7,8 @@ package audiotag
 import (
 	"errors"
 	"io"
-	"strconv"
 	"strings"
+	"time"
 )
 
 // id3v1Genres is a list of genres as given in the ID3v1 specification.
@@ -40,106 +40,138 @@ var id3v1Genres = [...]string{
 // ErrNotID3v1 is an error which is returned when no ID3v1 header is found.
 var ErrNotID3v1 = errors.New("invalid ID3v1 header")
 
-// ReadID3v1Tags reads ID3v1 tags from the io.ReadSeeker.  Returns ErrNotID3v1
-// if there are no ID3v1 tags, otherwise non-nil error if there was a problem.
+// ReadID3v1Tags reads ID3v1 tags from the io.ReadSeeker.  The tag is expected at the end
+// of the file, or directly before a trailing APEv2 tag.  Returns ErrNotID3v1 if there are
+// no ID3v1 tags, otherwise non-nil error if there was a problem.
 func ReadID3v1Tags(r io.ReadSeeker) (Metadata, error) {
-	_, err := r.Seek(-128, io.SeekEnd)
+	rc := defaultReadContext()
+	return readID3v1Tags(rc.reader(r), rc)
+}
+
+// readID3v1Tags reads the ID3v1 tag of an MP3 file which has no ID3v2 tag, and the MPEG
+// audio which precedes it (see DefaultMP3Audio).
+func readID3v1Tags(r io.ReadSeeker, rc *readContext) (Metadata, error) {
+	m, l, err := readID3v1Tag(r, rc)
 	if err != nil {
 		return nil, err
 	}
+	m.audio = rc.readMP3Audio(r, l.audioStart)
+	return m, nil
+}
 
-	if tag, err := readString(r, 3); err != nil {
-		return nil, err
-	} else if tag != "TAG" {
-		return nil, ErrNotID3v1
+// readID3v1Tag reads the ID3v1 tag of r, returning it with the layout of the file.
+func readID3v1Tag(r io.ReadSeeker, rc *readContext) (metadataID3v1, *mp3Layout, error) {
+	var m metadataID3v1
+	l, err := readMP3Layout(r)
+	if err != nil {
+		return m, nil, err
+	}
+	if !l.id3v1 {
+		return m, nil, ErrNotID3v1
+	}
+
+	_, err = r.Seek(l.id3v1Start+3, io.SeekStart)
+	if err != nil {
+		return m, nil, err
 	}
 
 	title, err := readString(r, 30)
 	if err != nil {
-		return nil, err
+		return m, nil, err
 	}
 
 	artist, err := readString(r, 30)
 	if err != nil {
-		return nil, err
+		return m, nil, err
 	}
 
 	album, err := readString(r, 30)
 	if err != nil {
-		return nil, err
+		return m, nil, err
 	}
 
 	year, err := readString(r, 4)
 	if err != nil {
-		return nil, err
+		return m, nil, err
 	}
 
 	commentBytes, err := readBytes(r, 30)
 	if err != nil {
-		return nil, err
+		return m, nil, err
 	}
 
+	trim := func(x string) string { return trimString(rc.sanitizeText(x)) }
 	var comment string
 	var track int
 	if commentBytes[28] == 0 {
-		comment = trimString(string(commentBytes[:28]))
+		comment = trim(string(commentBytes[:28]))
 		track = int(commentBytes[29])
 	} else {
-		comment = trimString(string(commentBytes))
+		comment = trim(string(commentBytes))
 	}
 
 	var genre string
 	genreID, err := readBytes(r, 1)
 	if err != nil {
-		return nil, err
+		return m, nil, err
 	}
 	if int(genreID[0]) < len(id3v1Genres) {
 		genre = id3v1Genres[int(genreID[0])]
 	}
 
-	m := make(map[string]interface{})
-	m["title"] = trimString(title)
-	m["artist"] = trimString(artist)
-	m["album"] = trimString(album)
-	m["year"] = trimString(year)
-	m["comment"] = trimString(comment)
-	m["track"] = track
-	m["genre"] = genre
-
-	return metadataID3v1(m), nil
+	m.tags = map[string]interface{}{
+		"title":   trim(title),
+		"artist":  trim(artist),
+		"album":   trim(album),
+		"year":    trim(year),
+		"comment": comment,
+		"track":   track,
+		"genre":   genre,
+	}
+	return m, l, nil
 }
 
+// trimString returns x without NUL padding and surrounding whitespace.
 func trimString(x string) string {
 	return strings.TrimSpace(strings.Trim(x, "\x00"))
 }
 
 // metadataID3v1 is the implementation of Metadata used for ID3v1 tags.
-type metadataID3v1 map[string]interface{}
+type metadataID3v1 struct {
+	tags  map[string]interface{}
+	audio *mpegAudio // the MPEG audio preceding the tag, or nil if unknown
+}
 
 func (metadataID3v1) Format() Format                { return ID3v1 }
 func (metadataID3v1) FileType() FileType            { return MP3 }
-func (m metadataID3v1) Raw() map[string]interface{} { return m }
+func (m metadataID3v1) Raw() map[string]interface{} { return copyRaw(m.tags) }
 
-func (m metadataID3v1) Title() string  { return m["title"].(string) }
-func (m metadataID3v1) Album() string  { return m["album"].(string) }
-func (m metadataID3v1) Artist() string { return m["artist"].(string) }
-func (m metadataID3v1) Genre() string  { return m["genre"].(string) }
+func (m metadataID3v1) Title() string  { return m.tags["title"].(string) }
+func (m metadataID3v1) Album() string  { return m.tags["album"].(string) }
+func (m metadataID3v1) Artist() string { return m.tags["artist"].(string) }
+func (m metadataID3v1) Genre() string  { return m.tags["genre"].(string) }
 
 func (m metadataID3v1) Year() int {
-	y := m["year"].(string)
-	n, err := strconv.Atoi(y)
-	if err != nil {
-		return 0
-	}
-	return n
+	return parseYear(m.tags["year"].(string))
 }
 
-func (m metadataID3v1) Track() (int, int) { return m["track"].(int), 0 }
-
-func (m metadataID3v1) AlbumArtist() string { return "" }
-func (m metadataID3v1) Composer() string    { return "" }
-func (metadataID3v1) Disc() (int, int)      { return 0, 0 }
-func (m metadataID3v1) Picture() *Picture   { return nil }
-func (m metadataID3v1) Lyrics() string      { return "" }
-func (m metadataID3v1) Comment() string     { return m["comment"].(string) }
-func (m metadataID3v1) Duration() int       { return 0 }
+func (m metadataID3v1) Track() (int, int) { return m.tags["track"].(int), 0 }
+
+func (m metadataID3v1) AlbumArtist() string       { return "" }
+func (m metadataID3v1) Composer() string          { return "" }
+func (metadataID3v1) Disc() (int, int)            { return 0, 0 }
+func (m metadataID3v1) Picture() *Picture         { return nil }
+func (m metadataID3v1) Lyrics() string            { return "" }
+func (m metadataID3v1) Comment() string           { return m.tags["comment"].(string) }
+func (m metadataID3v1) BPM() float64              { return 0 }
+func (m metadataID3v1) Key() Key                  { return UnknownKey }
+func (m metadataID3v1) Chapters() []Chapter       { return nil }
+func (m metadataID3v1) Warnings() []error         { return nil }
+func (m metadataID3v1) Conflicts() []Conflict     { return nil }
+func (m metadataID3v1) UnknownTags() []UnknownTag { return nil }
+
+// Duration returns the duration of the MPEG audio preceding the tag (see DurationInfo).
+func (m metadataID3v1) Duration() int {
+	d, _ := m.audio.duration()
+	return int(d / time.Second)
+}
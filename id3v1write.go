@@ -0,0 +1,113 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteID3v1Tags copies the MP3 data from r to w, updating its ID3v1 tag with the Edit (or
+// appending one to the end of the file).  The tag is written as ID3v1.1 (unless the Edit
+// sets neither the comment nor the track number of an ID3v1.0 tag): the title, artist,
+// album and comment are truncated to fit (30 bytes, and 28 for the comment), the track
+// number must be at most 255 and the genre is stored as the index of the ID3v1
+// genre with the same name (ignoring case), or 255 if there is none.  Other fields and
+// pictures are ignored.  All other data, including the ID3v2 and APE tags, is copied
+// unchanged.
+//
+// ID3v1 tags are only read by old hardware and software, so WriteID3v1Tags is usually
+// used after WriteID3v2Tags to keep the ID3v1 tag in step.
+func WriteID3v1Tags(w io.Writer, r io.ReadSeeker, e *Edit) error {
+	l, err := readMP3Layout(r)
+	if err != nil {
+		return err
+	}
+
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	tag[127] = 255
+	end := l.size
+	if l.id3v1 {
+		end = l.id3v1Start
+		if !e.Clear {
+			if _, err := r.Seek(l.id3v1Start, io.SeekStart); err != nil {
+				return err
+			}
+			if _, err := io.ReadFull(r, tag); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range []struct {
+		name        string
+		offset, len int
+	}{
+		{"title", 3, 30},
+		{"artist", 33, 30},
+		{"album", 63, 30},
+		{"year", 93, 4},
+		{"comment", 97, 28},
+	} {
+		if v, ok := e.Fields[f.name]; ok {
+			putID3v1String(tag[f.offset:f.offset+f.len], v)
+		}
+	}
+	// ID3v1.0 comments use the bytes of the ID3v1.1 track number, so are truncated when
+	// the comment or track number is set, and otherwise kept.
+	_, setComment := e.Fields["comment"]
+	v, setTrack := e.Fields["track"]
+	if (setComment || setTrack) && tag[125] != 0 {
+		tag[125], tag[126] = 0, 0
+	}
+	if setTrack {
+		track, err := parseMP4Int("track", v, 255)
+		if err != nil {
+			return err
+		}
+		tag[125], tag[126] = 0, byte(track)
+	}
+	if v, ok := e.Fields["genre"]; ok {
+		tag[127] = 255
+		for i, g := range id3v1Genres {
+			if strings.EqualFold(g, strings.TrimSpace(v)) {
+				tag[127] = byte(i)
+				break
+			}
+		}
+	}
+
+	if err := copyRange(w, r, 0, end); err != nil {
+		return err
+	}
+	if _, err := w.Write(tag); err != nil {
+		return fmt.Errorf("could not write ID3v1 tag: %v", err)
+	}
+	if l.id3v1 {
+		return copyRange(w, r, l.id3v1Start+128, l.size)
+	}
+	return nil
+}
+
+// putID3v1String writes s to b as ISO-8859-1 (with characters which cannot be represented
+// replaced by '?'), truncated to the length of b and padded with zero bytes.
+func putID3v1String(b []byte, s string) {
+	for i := range b {
+		b[i] = 0
+	}
+	i := 0
+	for _, r := range strings.TrimSpace(s) {
+		if i == len(b) {
+			break
+		}
+		if r > 0xff {
+			r = '?'
+		}
+		b[i] = byte(r)
+		i++
+	}
+}
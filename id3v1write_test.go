@@ -0,0 +1,104 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteID3v1Tags(t *testing.T) {
+	audio := []byte("\xff\xfbaudio data")
+	v1 := testID3v1Tag()
+	copy(v1[3:], "Old Title")
+	copy(v1[33:], "Old Artist")
+	copy(v1[97:], "A comment which is thirty byte")
+	v1[127] = 17 // Rock
+	join := func(bs ...[]byte) []byte { return bytes.Join(bs, nil) }
+
+	edit := &Edit{Fields: map[string]string{
+		"title": "Title",
+		"album": "An album title which is longer than thirty bytes",
+		"year":  "2001",
+		"track": "7",
+		"genre": "jazz",
+	}}
+
+	tests := []struct {
+		in      []byte
+		prefix  []byte // the data expected before the ID3v1 tag
+		suffix  []byte // the data expected after the ID3v1 tag
+		artist  string
+		comment string
+	}{
+		{audio, audio, nil, "", ""},
+		{join(testID3v2Tag(10), audio), join(testID3v2Tag(10), audio), nil, "", ""},
+		{join(audio, v1), audio, nil, "Old Artist", "A comment which is thirty by"},
+		{join(audio, testAPETag(), v1), join(audio, testAPETag()), nil, "Old Artist", "A comment which is thirty by"},
+		{join(audio, v1, testAPETag()), audio, testAPETag(), "Old Artist", "A comment which is thirty by"},
+	}
+
+	for ii, tt := range tests {
+		out := &bytes.Buffer{}
+		if err := WriteID3v1Tags(out, bytes.NewReader(tt.in), edit); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		b := out.Bytes()
+		if len(b) != len(tt.prefix)+128+len(tt.suffix) || !bytes.HasPrefix(b, tt.prefix) || !bytes.HasSuffix(b, tt.suffix) {
+			t.Errorf("[%d] unexpected output: %q", ii, b)
+			continue
+		}
+
+		m, err := ReadID3v1Tags(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("[%d] unexpected error reading written file: %v", ii, err)
+			continue
+		}
+		if m.Title() != "Title" || m.Artist() != tt.artist || m.Album() != "An album title which is longer" {
+			t.Errorf("[%d] Title(), Artist(), Album() = %q, %q, %q", ii, m.Title(), m.Artist(), m.Album())
+		}
+		if x, _ := m.Track(); x != 7 || m.Year() != 2001 || m.Genre() != "Jazz" || m.Comment() != tt.comment {
+			t.Errorf("[%d] Track(), Year(), Genre(), Comment() = %d, %d, %q, %q", ii, x, m.Year(), m.Genre(), m.Comment())
+		}
+	}
+
+	// Invalid track numbers are rejected.
+	if err := WriteID3v1Tags(&bytes.Buffer{}, bytes.NewReader(audio), &Edit{Fields: map[string]string{"track": "256"}}); err == nil {
+		t.Errorf("expected error for track number 256")
+	}
+}
+
+func TestWriteID3v1TagsKeepsComment(t *testing.T) {
+	audio := []byte("\xff\xfbaudio data")
+	v1 := testID3v1Tag()
+	copy(v1[97:], "A comment which is thirty byte") // ID3v1.0
+
+	tests := []struct {
+		fields  map[string]string
+		comment string
+		track   int
+	}{
+		{map[string]string{"title": "Title"}, "A comment which is thirty byte", 0},
+		{map[string]string{"track": "3"}, "A comment which is thirty by", 3},
+		{map[string]string{"comment": "New comment"}, "New comment", 0},
+	}
+
+	for ii, tt := range tests {
+		out := &bytes.Buffer{}
+		if err := WriteID3v1Tags(out, bytes.NewReader(append(append([]byte(nil), audio...), v1...)), &Edit{Fields: tt.fields}); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		m, err := ReadID3v1Tags(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Errorf("[%d] unexpected error reading written file: %v", ii, err)
+			continue
+		}
+		if x, _ := m.Track(); m.Comment() != tt.comment || x != tt.track {
+			t.Errorf("[%d] Comment(), Track() = %q, %d, expected %q, %d", ii, m.Comment(), x, tt.comment, tt.track)
+		}
+	}
+}
@@ -5,8 +5,13 @@
 package audiotag
 
 import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"regexp"
 	"strconv"
 	"strings"
@@ -48,15 +53,21 @@ type id3v2Header struct {
 	Unsynchronisation bool
 	ExtendedHeader    bool
 	Experimental      bool
+	Footer            bool // ID3v2.4 only
 	Size              uint
+
+	offset int64 // position of the tag in the input
 }
 
 // readID3v2Header reads the ID3v2 header from the given io.Reader.
 // offset it number of bytes of header that was read
-func readID3v2Header(r io.Reader) (h *id3v2Header, offset uint, err error) {
+func readID3v2Header(r io.Reader, rc *readContext) (h *id3v2Header, offset uint, err error) {
 	offset = 10
 	b, err := readBytes(r, offset)
 	if err != nil {
+		if isTruncation(err) {
+			return nil, 0, rc.truncated(UnknownFormat, 0)
+		}
 		return nil, 0, fmt.Errorf("expected to read 10 bytes (ID3v2Header): %v", err)
 	}
 
@@ -85,6 +96,7 @@ func readID3v2Header(r io.Reader) (h *id3v2Header, offset uint, err error) {
 		Unsynchronisation: getBit(b[2], 7),
 		ExtendedHeader:    getBit(b[2], 6),
 		Experimental:      getBit(b[2], 5),
+		Footer:            vers == ID3v2_4 && getBit(b[2], 4),
 		Size:              uint(get7BitChunkedInt(b[3:7])),
 	}
 
@@ -93,24 +105,36 @@ func readID3v2Header(r io.Reader) (h *id3v2Header, offset uint, err error) {
 		case ID3v2_3:
 			b, err := readBytes(r, 4)
 			if err != nil {
+				if isTruncation(err) {
+					return nil, 0, rc.truncated(vers, 10)
+				}
 				return nil, 0, fmt.Errorf("expected to read 4 bytes (ID3v23 extended header len): %v", err)
 			}
 			// skip header, size is excluding len bytes
 			extendedHeaderSize := uint(getInt(b))
 			_, err = readBytes(r, extendedHeaderSize)
 			if err != nil {
+				if isTruncation(err) {
+					return nil, 0, rc.truncated(vers, 10)
+				}
 				return nil, 0, fmt.Errorf("expected to read %d bytes (ID3v23 skip extended header): %v", extendedHeaderSize, err)
 			}
 			offset += extendedHeaderSize
 		case ID3v2_4:
 			b, err := readBytes(r, 4)
 			if err != nil {
+				if isTruncation(err) {
+					return nil, 0, rc.truncated(vers, 10)
+				}
 				return nil, 0, fmt.Errorf("expected to read 4 bytes (ID3v24 extended header len): %v", err)
 			}
 			// skip header, size is synchsafe int including len bytes
 			extendedHeaderSize := uint(get7BitChunkedInt(b)) - 4
 			_, err = readBytes(r, extendedHeaderSize)
 			if err != nil {
+				if isTruncation(err) {
+					return nil, 0, rc.truncated(vers, 10)
+				}
 				return nil, 0, fmt.Errorf("expected to read %d bytes (ID3v24 skip extended header): %v", extendedHeaderSize, err)
 			}
 			offset += extendedHeaderSize
@@ -218,16 +242,35 @@ func readID3v2_4FrameHeader(r io.Reader) (name string, size uint, headerSize uin
 	return
 }
 
-// readID3v2Frames reads ID3v2 frames from the given reader using the ID3v2Header.
-func readID3v2Frames(r io.Reader, offset uint, h *id3v2Header) (map[string]interface{}, error) {
+// Errors describing ID3v2 frames which cannot be decoded, and so are skipped with a
+// warning (in both Strict and Lenient mode).
+var (
+	ErrEncryptedFrame = errors.New("frame is encrypted")
+	ErrGroupedFrame   = errors.New("frame belongs to a group")
+)
+
+// readID3v2Frames reads ID3v2 frames from the given reader using the ID3v2Header.  Spec
+// violations which are skipped in Lenient mode are added to w.
+func readID3v2Frames(r io.Reader, offset uint, h *id3v2Header, w *warnings, u *unknownTags, rc *readContext) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
+	// partial handles an error reading the frame at start.  If the tag ends before its
+	// declared size then in Lenient mode the frames read so far are returned with
+	// ErrTruncated.
+	partial := func(start uint, err error) (map[string]interface{}, error) {
+		if !isTruncation(err) {
+			return nil, parseError(h.Version, "frame", h.offset+int64(start), err)
+		}
+		return result, rc.truncated(h.Version, h.offset+int64(start))
+	}
+
 	for offset < h.Size {
 		var err error
 		var name string
 		var size, headerSize uint
 		var flags *id3v2FrameFlags
 
+		start := offset
 		switch h.Version {
 		case ID3v2_2:
 			name, size, headerSize, err = readID3v2_2FrameHeader(r)
@@ -235,7 +278,7 @@ func readID3v2Frames(r io.Reader, offset uint, h *id3v2Header) (map[string]inter
 		case ID3v2_3:
 			name, size, headerSize, err = readID3v2_3FrameHeader(r)
 			if err != nil {
-				return nil, err
+				return partial(start, err)
 			}
 			flags, err = readID3v23FrameFlags(r)
 			headerSize += 2
@@ -243,127 +286,239 @@ func readID3v2Frames(r io.Reader, offset uint, h *id3v2Header) (map[string]inter
 		case ID3v2_4:
 			name, size, headerSize, err = readID3v2_4FrameHeader(r)
 			if err != nil {
-				return nil, err
+				return partial(start, err)
 			}
 			flags, err = readID3v24FrameFlags(r)
 			headerSize += 2
 		}
 
 		if err != nil {
-			return nil, err
+			return partial(start, err)
 		}
 
 		// FIXME: Do we still need this?
 		// if size=0, we certainly are in a padding zone. ignore the rest of
 		// the tags
 		if size == 0 {
+			if strings.Trim(name, "\x00") != "" {
+				if err := rc.structureViolation(w, h.Version, fmt.Sprintf("frame %q", name), h.offset+int64(start), errors.New("empty frame")); err != nil {
+					return nil, err
+				}
+			}
 			break
 		}
 
 		offset += headerSize + size
 
-		// Avoid corrupted padding (see http://id3.org/Compliance%20Issues).
-		if !validID3Frame(h.Version, name) && offset > h.Size {
+		if !wellFormedID3FrameName(name) {
+			// Garbage in place of a frame header, most likely corrupted padding
+			// (see http://id3.org/Compliance%20Issues).
+			if err := rc.violation(w, h.Version, h.offset+int64(start), fmt.Errorf("invalid frame ID %q", name)); err != nil {
+				return nil, err
+			}
 			break
 		}
 
-		if flags != nil {
-			if flags.Compression {
-				_, err = read7BitChunkedUint(r, 4) // read 4
-				if err != nil {
-					return nil, err
-				}
-				size -= 4
+		if offset > h.Size {
+			// Avoid corrupted padding (see http://id3.org/Compliance%20Issues).
+			if !validID3Frame(h.Version, name) {
+				break
 			}
-
-			if flags.Encryption {
-				_, err = readBytes(r, 1) // read 1 byte of encryption method
-				if err != nil {
-					return nil, err
-				}
-				size--
+			if err := rc.structureViolation(w, h.Version, fmt.Sprintf("frame %q", name), h.offset+int64(start), errors.New("extends beyond the end of the tag")); err != nil {
+				return nil, err
 			}
 		}
 
 		b, err := readBytes(r, size)
 		if err != nil {
-			return nil, err
+			return partial(start, err)
 		}
 
-		// There can be multiple tag with the same name. Append a number to the
-		// name if there is more than one.
-		rawName := name
-		if _, ok := result[rawName]; ok {
-			for i := 0; ok; i++ {
-				rawName = name + "_" + strconv.Itoa(i)
-				_, ok = result[rawName]
+		if flags != nil {
+			// ID3v2.4 frames are unsynchronised individually (including the information
+			// which follows their headers), and their sizes include the inserted bytes.
+			if h.Version == ID3v2_4 && (h.Unsynchronisation || flags.Unsynchronisation) {
+				b = removeUnsynchronisation(b)
+			}
+			b, err = id3v2FrameData(b, h.Version, flags, rc)
+			if err == ErrMetadataTooLarge {
+				return partial(start, err)
 			}
 		}
 
+		var v interface{}
 		switch {
+		case err != nil:
+
 		case name == "TXXX" || name == "TXX":
-			t, err := readTextWithDescrFrame(b, false, true) // no lang, but enc
-			if err != nil {
-				return nil, err
-			}
-			result[rawName] = t
+			v, err = readTextWithDescrFrame(b, false, true, rc) // no lang, but enc
 
-		case name[0] == 'T':
-			txt, err := readTFrame(b)
-			if err != nil {
-				return nil, err
-			}
-			result[rawName] = txt
+		case name[0] == 'T' || name == "MVNM" || name == "MVIN": // iTunes movement name and number
+			v, err = readTFrame(b, rc)
 
 		case name == "UFID" || name == "UFI":
-			t, err := readUFID(b)
-			if err != nil {
-				return nil, err
-			}
-			result[rawName] = t
+			v, err = readUFID(b)
 
 		case name == "WXXX" || name == "WXX":
-			t, err := readTextWithDescrFrame(b, false, false) // no lang, no enc
-			if err != nil {
-				return nil, err
-			}
-			result[rawName] = t
+			v, err = readTextWithDescrFrame(b, false, false, rc) // no lang, no enc
 
 		case name[0] == 'W':
-			txt, err := readWFrame(b)
-			if err != nil {
-				return nil, err
-			}
-			result[rawName] = txt
+			v, err = readWFrame(b, rc)
 
 		case name == "COMM" || name == "COM" || name == "USLT" || name == "ULT":
-			t, err := readTextWithDescrFrame(b, true, true) // both lang and enc
-			if err != nil {
-				return nil, err
-			}
-			result[rawName] = t
+			v, err = readTextWithDescrFrame(b, true, true, rc) // both lang and enc
+
+		case name == "CHAP":
+			v, err = readCHAPFrame(b, h, h.offset+int64(start+headerSize), w, u, rc)
+
+		case name == "CTOC":
+			v, err = readCTOCFrame(b, h, h.offset+int64(start+headerSize), w, u, rc)
+
+		case name == "GEOB" || name == "GEO":
+			v, err = readGEOBFrame(b, rc)
+
+		case name == "PRIV":
+			v, err = readPRIVFrame(b)
+
+		case name == "RVA2":
+			v, err = readRVA2Frame(b)
+
+		case name == "POPM" || name == "POP":
+			v, err = readPOPMFrame(b)
+
+		case name == "PCNT" || name == "CNT":
+			v, err = readID3v2Counter(b)
+
+		case name == "SYLT" || name == "SLT":
+			v, err = readSYLTFrame(b, rc)
 
 		case name == "APIC":
-			p, err := readAPICFrame(b)
-			if err != nil {
-				return nil, err
-			}
-			result[rawName] = p
+			v, err = readAPICFrame(b, rc)
 
 		case name == "PIC":
-			p, err := readPICFrame(b)
-			if err != nil {
+			v, err = readPICFrame(b, rc)
+
+		default:
+			v = b
+			u.add(rc.UnknownTagPolicy, h.Version, name, h.offset+int64(start), int64(len(b)), b)
+		}
+
+		if errors.Is(err, ErrEncryptedFrame) || errors.Is(err, ErrGroupedFrame) {
+			skipped(w, h.Version, fmt.Sprintf("frame %q", name), h.offset+int64(start), err)
+			continue
+		}
+		if err != nil {
+			// Skip malformed frames in Lenient mode.
+			if err := rc.structureViolation(w, h.Version, fmt.Sprintf("frame %q", name), h.offset+int64(start), err); err != nil {
 				return nil, err
 			}
-			result[rawName] = p
+			continue
+		}
 
-		default:
-			result[rawName] = b
+		// User defined text frames are stored by their description.
+		if c, ok := v.(*Comm); ok && (name == "TXXX" || name == "TXX") {
+			name, v = UserTextPrefix+c.Description, c.Text
+		}
+
+		// There should only be one text frame with each name (see DuplicatePolicy).
+		isText := name[0] == 'T' || strings.HasPrefix(name, UserTextPrefix)
+		if old, ok := result[name]; ok && isText && rc.DuplicatePolicy != FormatDefault {
+			result[name] = rc.mergeDuplicate(old, v)
+			continue
+		}
+
+		// There can be multiple tag with the same name. Append a number to the
+		// name if there is more than one.
+		rawName := name
+		if _, ok := result[rawName]; ok {
+			for i := 0; ok; i++ {
+				rawName = name + "_" + strconv.Itoa(i)
+				_, ok = result[rawName]
+			}
 		}
+		result[rawName] = v
 	}
 	return result, nil
 }
 
+// id3v2FrameData returns the data of a frame with the flags, removing the information
+// which precedes it (the decompressed size of compressed ID3v2.3 frames or the data length
+// indicator of ID3v2.4 frames) and inflating zlib compressed data, whose decompressed size
+// counts towards the metadata limit of rc.  Returns an error wrapping ErrEncryptedFrame or
+// ErrGroupedFrame for encrypted and grouped frames.
+func id3v2FrameData(b []byte, version Format, flags *id3v2FrameFlags, rc *readContext) ([]byte, error) {
+	var group, method, length []byte
+	take := func(p *[]byte, n int) bool {
+		if len(b) < n {
+			return false
+		}
+		*p, b = b[:n], b[n:]
+		return true
+	}
+	// The information is in the order of the flags.
+	var ok bool
+	if version == ID3v2_3 {
+		ok = (!flags.Compression || take(&length, 4)) &&
+			(!flags.Encryption || take(&method, 1)) &&
+			(!flags.GroupIdentity || take(&group, 1))
+	} else {
+		ok = (!flags.GroupIdentity || take(&group, 1)) &&
+			(!flags.Encryption || take(&method, 1)) &&
+			(!flags.DataLengthIndicator || take(&length, 4))
+	}
+	if !ok {
+		return nil, errors.New("missing information indicated by the frame flags")
+	}
+
+	switch {
+	case method != nil:
+		return nil, fmt.Errorf("%w (method %d)", ErrEncryptedFrame, method[0])
+	case group != nil:
+		return nil, fmt.Errorf("%w (group %d)", ErrGroupedFrame, group[0])
+	case !flags.Compression:
+		return b, nil
+	case length == nil:
+		return nil, errors.New("compressed frame without data length indicator")
+	}
+
+	dataLength := binary.BigEndian.Uint32(length)
+	if version == ID3v2_4 {
+		dataLength = uint32(get7BitChunkedInt(length))
+	}
+	if dataLength > maxID3v2Size {
+		return nil, fmt.Errorf("decompressed size %d too large", dataLength)
+	}
+	if err := rc.consume(int64(dataLength)); err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed data: %v", err)
+	}
+	d, err := ioutil.ReadAll(io.LimitReader(zr, int64(dataLength)+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed data: %v", err)
+	}
+	if len(d) != int(dataLength) {
+		return nil, fmt.Errorf("decompressed size %d, expected %d", len(d), dataLength)
+	}
+	return d, nil
+}
+
+// wellFormedID3FrameName returns true if the frame name consists of upper case letters
+// and digits (as required by all ID3v2 versions).
+func wellFormedID3FrameName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
 type unsynchroniser struct {
 	io.Reader
 	ff bool
@@ -389,23 +544,198 @@ func (r *unsynchroniser) Read(p []byte) (int, error) {
 }
 
 // ReadID3v2Tags parses ID3v2.{2,3,4} tags from the io.ReadSeeker into a Metadata, returning
-// non-nil error on failure.
+// non-nil error on failure.  If the data ends part way through the tag then the frames read
+// so far are returned with ErrTruncated (in Lenient mode).
+//
+// Junk bytes before the ID3v2 header are skipped (with a warning in Lenient mode).  If the
+// tag is directly followed by further ID3v2 tags then their frames are also read: a frame
+// in an earlier tag takes precedence over the same frame in a later one.
+//
+// If there is no tag at the start then an ID3v2.4 tag appended to the end of the audio
+// (before any APE and ID3v1 tags), which has a footer, is read.
 func ReadID3v2Tags(r io.ReadSeeker) (Metadata, error) {
-	h, offset, err := readID3v2Header(r)
+	rc := defaultReadContext()
+	return readID3v2Tags(rc.reader(r), rc)
+}
+
+func readID3v2Tags(r io.ReadSeeker, rc *readContext) (Metadata, error) {
+	var w warnings
+	var u unknownTags
+	start, err := skipToID3v2(r, &w, rc)
 	if err != nil {
 		return nil, err
 	}
+	appended := false
+	if b, err := readBytes(r, 3); err != nil || string(b) != "ID3" {
+		// An ID3v2.4 tag can be appended to the audio, with a footer.
+		l, err := readMP3Layout(r)
+		if err != nil {
+			return nil, err
+		}
+		if l.appendedEnd > l.appendedStart {
+			start, appended = l.appendedStart, true
+		}
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	h, f, end, err := readID3v2Tag(r, start, &w, &u, rc)
+	if h == nil || (err != nil && err != ErrTruncated) {
+		return nil, err
+	}
+	m := metadataID3v2{header: h, frames: f}
+
+	// Some taggers prepend a new tag instead of updating the existing one, so read any
+	// tags which directly follow this one.  Frames in earlier tags take precedence.
+	for err == nil {
+		if _, err = r.Seek(end, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if b, err := readBytes(r, 10); err != nil || !isID3v2Header(b) {
+			break
+		}
+		if _, err = r.Seek(end, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		var next map[string]interface{}
+		_, next, end, err = readID3v2Tag(r, end, &w, &u, rc)
+		if err != nil && err != ErrTruncated {
+			return nil, err
+		}
+		for k, v := range next {
+			if _, ok := m.frames[k]; !ok {
+				m.frames[k] = v
+			}
+		}
+	}
+
+	if err == nil {
+		// The audio follows the tags, unless the tag was appended to it.
+		if appended {
+			end = 0
+		}
+		m.audio = rc.readMP3Audio(r, end)
+	}
+	m.warnings, m.unknownTags = w, u
+	return m, err
+}
+
+// readID3v2Tag reads the ID3v2 tag at offset start of r, returning its header and frames
+// and the offset of the end of the tag.
+func readID3v2Tag(r io.ReadSeeker, start int64, w *warnings, u *unknownTags, rc *readContext) (*id3v2Header, map[string]interface{}, int64, error) {
+	h, offset, err := readID3v2Header(r, rc)
+	if err != nil {
+		if perr, ok := err.(*ParseError); ok {
+			perr.Offset += start
+		}
+		return nil, nil, 0, parseError(UnknownFormat, "ID3v2 header", start, err)
+	}
+	h.offset = start
 
 	var ur io.Reader = r
-	if h.Unsynchronisation {
-		ur = &unsynchroniser{Reader: r}
+	fh := h
+	if h.Unsynchronisation && h.Version != ID3v2_4 && offset-10 <= h.Size {
+		// The sizes of ID3v2.2 and ID3v2.3 frames exclude the bytes inserted by
+		// unsynchronisation, so it is removed from the whole tag before reading them.
+		lr := &io.LimitedReader{R: r, N: int64(h.Size - (offset - 10))}
+		b, err := ioutil.ReadAll(&unsynchroniser{Reader: lr})
+		if err != nil {
+			return nil, nil, 0, parseError(h.Version, "frame", start+int64(offset), err)
+		}
+		read := int64(h.Size-(offset-10)) - lr.N
+		x := *h
+		x.Size -= uint(read - int64(len(b)))
+		fh, ur = &x, bytes.NewReader(b)
+	}
+
+	f, err := readID3v2Frames(ur, offset, fh, w, u, rc)
+	end := start + 10 + int64(h.Size)
+	if h.Footer {
+		end += 10
+	}
+	return h, f, end, err
+}
+
+// id3v2SearchLimit is the number of bytes searched for an ID3v2 header when the data
+// does not start with one.
+const id3v2SearchLimit = 64 << 10
+
+// isID3v2Header returns true if b starts with a plausible ID3v2 header.
+func isID3v2Header(b []byte) bool {
+	if len(b) < 10 || string(b[:3]) != "ID3" {
+		return false
 	}
+	if b[3] < 2 || b[3] > 4 || b[4] == 0xff {
+		return false
+	}
+	for _, x := range b[6:10] {
+		if x >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
 
-	f, err := readID3v2Frames(ur, offset, h)
+// isID3v2Footer returns true if b starts with a plausible ID3v2.4 footer.
+func isID3v2Footer(b []byte) bool {
+	if len(b) < 10 || string(b[:3]) != "3DI" || b[3] != 4 || b[4] == 0xff {
+		return false
+	}
+	for _, x := range b[6:10] {
+		if x >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// findID3v2 returns the offset of the first plausible ID3v2 header in the id3v2SearchLimit
+// bytes from the current position of r, or -1 if there is none.  The position of r is
+// left unspecified.
+func findID3v2(r io.ReadSeeker) (int64, error) {
+	start := tell(r)
+	b, err := ioutil.ReadAll(io.LimitReader(r, id3v2SearchLimit+10))
 	if err != nil {
-		return nil, err
+		return -1, err
+	}
+	for i := 0; i < len(b); i++ {
+		n := bytes.Index(b[i:], []byte("ID3"))
+		if n < 0 {
+			break
+		}
+		i += n
+		if isID3v2Header(b[i:]) {
+			return start + int64(i), nil
+		}
+	}
+	return -1, nil
+}
+
+// skipToID3v2 positions r at the start of the ID3v2 tag, skipping any junk bytes before
+// its header, and returns its offset.  If there is no ID3v2 header then r is left at its
+// original position.
+func skipToID3v2(r io.ReadSeeker, w *warnings, rc *readContext) (int64, error) {
+	start := tell(r)
+	b, err := readBytes(r, 3)
+	if err == nil && string(b) != "ID3" {
+		if _, err := r.Seek(start, io.SeekStart); err != nil {
+			return 0, err
+		}
+		n, err := findID3v2(r)
+		if err != nil {
+			return 0, err
+		}
+		if n > start {
+			if err := rc.violation(w, UnknownFormat, start, fmt.Errorf("%d bytes of junk before ID3v2 header", n-start)); err != nil {
+				return 0, err
+			}
+			start = n
+		}
 	}
-	return metadataID3v2{header: h, frames: f}, nil
+	_, err = r.Seek(start, io.SeekStart)
+	return start, err
 }
 
 var id3v2genreRe = regexp.MustCompile(`(.*[^(]|.* |^)\(([0-9]+)\) *(.*)$`)
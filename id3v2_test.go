@@ -6,6 +6,9 @@ package audiotag
 
 import (
 	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
 	"reflect"
 	"testing"
 )
@@ -150,3 +153,561 @@ func TestGenreExpension(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeISO8859(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output string
+	}{
+		{[]byte("Test"), "Test"},
+		{[]byte("Caf\xe9"), "Café"},
+		{[]byte("\x93Quoted\x94 \x96 It\x92s \x80"), "“Quoted” – It’s €"},
+		{[]byte("\x81\x8d\x8f\x90\x9d"), "\u0081\u008d\u008f\u0090\u009d"}, // undefined in Windows-1252
+	}
+
+	for ii, tt := range tests {
+		if got := decodeISO8859(tt.input); got != tt.output {
+			t.Errorf("[%d] decodeISO8859(%q) = %+q, expected %+q", ii, tt.input, got, tt.output)
+		}
+	}
+}
+
+func TestReadSYLTFrame(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output *SyncedLyrics
+		err    bool
+	}{
+		{
+			[]byte("\x00eng\x02\x01Desc\x00Hello\x00\x00\x00\x03\xe8\nWorld\x00\x00\x00\x07\xd0"),
+			&SyncedLyrics{
+				Language:        "eng",
+				Description:     "Desc",
+				ContentType:     SyncedLyricsContent,
+				TimestampFormat: TimestampMilliseconds,
+				Lines:           []SyncedLine{{1000, "Hello"}, {2000, "\nWorld"}},
+			},
+			false,
+		},
+		{
+			[]byte("\x01fra\x01\x05\xff\xfe\x00\x00\xff\xfeB\x00b\x00\x00\x00\x00\x00\x00\x10\xfe\xff\x00F\x00\x00\x00\x00\x00\x20"),
+			&SyncedLyrics{
+				Language:        "fra",
+				ContentType:     SyncedChords,
+				TimestampFormat: TimestampMPEGFrames,
+				Lines:           []SyncedLine{{16, "Bb"}, {32, "F"}},
+			},
+			false,
+		},
+		{[]byte("\x00eng\x02\x01"), nil, true},
+		{[]byte("\x00eng\x02\x01\x00Hello\x00\x00\x00"), nil, true},
+	}
+
+	for ii, tt := range tests {
+		got, err := readSYLTFrame(tt.input, defaultReadContext())
+		if (err != nil) != tt.err {
+			t.Errorf("[%d] readSYLTFrame() error = %v, expected error: %v", ii, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("[%d] readSYLTFrame() = %+v, expected %+v", ii, got, tt.output)
+		}
+	}
+}
+
+func TestSyncedLyricsInfo(t *testing.T) {
+	sylt := testID3v23Frame("SYLT", []byte("\x00eng\x02\x01\x00One\x00\x00\x00\x00\x00\ntwo\x00\x00\x00\x01\xf4"))
+	b := testID3v23(2*len(sylt), sylt, sylt)
+	m, err := ReadID3v2Tags(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := SyncedLyricsInfo(m)
+	if len(got) != 2 {
+		t.Fatalf("SyncedLyricsInfo() returned %d frames, expected 2", len(got))
+	}
+	if s := got[0].String(); s != "One\ntwo" {
+		t.Errorf("String() = %q, expected %q", s, "One\ntwo")
+	}
+	if d, ok := got[0].Time(got[0].Lines[1]); !ok || d.Seconds() != 0.5 {
+		t.Errorf("Time() = %v, %v, expected 500ms, true", d, ok)
+	}
+	if SyncedLyricsInfo(metadataID3v1{}) != nil {
+		t.Errorf("expected nil for ID3v1 metadata")
+	}
+
+	// MP3 file which also has an ID3v1 tag.
+	m, err = ReadFrom(bytes.NewReader(append(b, testID3v1Tag()...)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := SyncedLyricsInfo(m); len(got) != 2 {
+		t.Errorf("SyncedLyricsInfo() returned %d frames for MP3 file with ID3v1 tag, expected 2", len(got))
+	}
+}
+
+func TestReadPOPMFrame(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output *Popularimeter
+		err    bool
+	}{
+		{[]byte("user@example.com\x00\xff\x00\x00\x01\x02"), &Popularimeter{"user@example.com", 255, 258}, false},
+		{[]byte("\x00\x80"), &Popularimeter{"", 128, 0}, false},
+		{[]byte("a\x00\x01\x01\x00\x00\x00\x00"), &Popularimeter{"a", 1, 1 << 32}, false},
+		{[]byte("a\x00\x01\x00\x01"), nil, true},
+		{[]byte("a\x00"), nil, true},
+		{[]byte("a"), nil, true},
+	}
+
+	for ii, tt := range tests {
+		got, err := readPOPMFrame(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("[%d] readPOPMFrame() error = %v, expected error: %v", ii, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("[%d] readPOPMFrame() = %v, expected %v", ii, got, tt.output)
+		}
+	}
+}
+
+func TestPopularimeterInfo(t *testing.T) {
+	frames := [][]byte{
+		testID3v23Frame("POPM", []byte("b@example.com\x00\x40\x00\x00\x00\x05")),
+		testID3v23Frame("POPM", []byte("a@example.com\x00\xc4")),
+		testID3v23Frame("PCNT", []byte("\x00\x00\x00\x2a")),
+	}
+	b := testID3v23(len(bytes.Join(frames, nil)), frames...)
+	m, err := ReadID3v2Tags(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []*Popularimeter{{"a@example.com", 196, 0}, {"b@example.com", 64, 5}}
+	if got := PopularimeterInfo(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("PopularimeterInfo() = %v, expected %v", got, want)
+	}
+	if got := PlayCount(m); got != 42 {
+		t.Errorf("PlayCount() = %d, expected 42", got)
+	}
+
+	// MP3 file which also has an ID3v1 tag.
+	m, err = ReadFrom(bytes.NewReader(append(b, testID3v1Tag()...)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := PlayCount(m); got != 42 {
+		t.Errorf("PlayCount() = %d for MP3 file with ID3v1 tag, expected 42", got)
+	}
+}
+
+func TestUniqueFileIDs(t *testing.T) {
+	frames := [][]byte{
+		testID3v23Frame("UFID", []byte("http://musicbrainz.org\x00b1a9c0e9-d987-4042-ae91-78d6a3267d69")),
+		testID3v23Frame("UFID", []byte("http://www.cddb.com/id3/taginfo1.html\x00\x01\x02\x03")),
+	}
+	m, err := ReadID3v2Tags(bytes.NewReader(testID3v23(len(bytes.Join(frames, nil)), frames...)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []*UFID{
+		{"http://musicbrainz.org", []byte("b1a9c0e9-d987-4042-ae91-78d6a3267d69")},
+		{"http://www.cddb.com/id3/taginfo1.html", []byte{1, 2, 3}},
+	}
+	if got := UniqueFileIDs(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("UniqueFileIDs() = %v, expected %v", got, want)
+	}
+	if got := UniqueFileID(m, "http://musicbrainz.org"); string(got) != "b1a9c0e9-d987-4042-ae91-78d6a3267d69" {
+		t.Errorf("UniqueFileID() = %q, expected MusicBrainz recording ID", got)
+	}
+	if got := UniqueFileID(m, "unknown"); got != nil {
+		t.Errorf("UniqueFileID() = %q, expected nil", got)
+	}
+	// MP3 file which also has an ID3v1 tag.
+	m, err = ReadFrom(bytes.NewReader(append(testID3v23(len(bytes.Join(frames, nil)), frames...), testID3v1Tag()...)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := UniqueFileIDs(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("UniqueFileIDs() = %v for MP3 file with ID3v1 tag, expected %v", got, want)
+	}
+}
+
+func TestURLLinks(t *testing.T) {
+	frames := [][]byte{
+		testID3v23Frame("WOAR", []byte("http://example.com/artist")),
+		testID3v23Frame("WCOM", []byte("http://example.com/buy\x00")),
+		testID3v23Frame("WCOM", []byte("http://example.org/buy")),
+		testID3v23Frame("WXXX", []byte("\x00Podcast\x00http://example.com/feed")),
+		testID3v23Frame("TIT2", []byte("\x00Title")),
+	}
+	m, err := ReadID3v2Tags(bytes.NewReader(testID3v23(len(bytes.Join(frames, nil)), frames...)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []URLLink{
+		{ID: "WCOM", URL: "http://example.com/buy"},
+		{ID: "WCOM", URL: "http://example.org/buy"},
+		{ID: "WOAR", URL: "http://example.com/artist"},
+		{ID: "WXXX", Description: "Podcast", URL: "http://example.com/feed"},
+	}
+	if got := URLLinks(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("URLLinks() = %v, expected %v", got, want)
+	}
+	if got := URLLinks(metadataID3v1{}); got != nil {
+		t.Errorf("URLLinks() = %v, expected nil for ID3v1 metadata", got)
+	}
+}
+
+func TestReadGEOBFrame(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output *EncapsulatedObject
+		err    bool
+	}{
+		{
+			[]byte("\x00application/octet-stream\x00\x00Serato Markers2\x00\x01\x01data"),
+			&EncapsulatedObject{"application/octet-stream", "", "Serato Markers2", []byte("\x01\x01data")},
+			false,
+		},
+		{
+			[]byte("\x01text/plain\x00\xff\xfea\x00.\x00t\x00\x00\x00\xff\xfeD\x00\x00\x00"),
+			&EncapsulatedObject{"text/plain", "a.t", "D", []byte{}},
+			false,
+		},
+		{[]byte("\x00text/plain"), nil, true},
+		{[]byte("\x00text/plain\x00file\x00desc"), nil, true},
+		{[]byte{}, nil, true},
+	}
+
+	for ii, tt := range tests {
+		got, err := readGEOBFrame(tt.input, defaultReadContext())
+		if (err != nil) != tt.err {
+			t.Errorf("[%d] readGEOBFrame() error = %v, expected error: %v", ii, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("[%d] readGEOBFrame() = %v, expected %v", ii, got, tt.output)
+		}
+	}
+}
+
+func TestEncapsulatedObjects(t *testing.T) {
+	frames := [][]byte{
+		testID3v23Frame("GEOB", []byte("\x00application/octet-stream\x00\x00Serato Overview\x00\x01")),
+		testID3v23Frame("GEOB", []byte("\x00application/octet-stream\x00\x00Serato Markers2\x00\x02")),
+	}
+	m, err := ReadID3v2Tags(bytes.NewReader(testID3v23(len(bytes.Join(frames, nil)), frames...)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := EncapsulatedObjects(m)
+	if len(got) != 2 || got[0].Description != "Serato Overview" || got[1].Description != "Serato Markers2" {
+		t.Errorf("EncapsulatedObjects() = %v, expected the objects in the order of the tag", got)
+	}
+}
+
+func TestReadPRIVFrame(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output *PrivateFrame
+		err    bool
+	}{
+		{[]byte("WM/MediaClassPrimaryID\x00\xbc\x7d"), &PrivateFrame{Owner: "WM/MediaClassPrimaryID", Data: []byte{0xbc, 0x7d}}, false},
+		{[]byte("www.amazon.com\x00"), &PrivateFrame{Owner: "www.amazon.com", Data: []byte{}}, false},
+		{[]byte("owner"), nil, true},
+	}
+
+	for ii, tt := range tests {
+		got, err := readPRIVFrame(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("[%d] readPRIVFrame() error = %v, expected error: %v", ii, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("[%d] readPRIVFrame() = %#v, expected %#v", ii, got, tt.output)
+		}
+	}
+}
+
+func TestPrivateFrames(t *testing.T) {
+	frames := [][]byte{
+		testID3v23Frame("PRIV", []byte("WM/MediaClassPrimaryID\x00\x01")),
+		testID3v23Frame("PRIV", []byte("www.amazon.com\x00\x02")),
+	}
+	m, err := ReadID3v2Tags(bytes.NewReader(testID3v23(len(bytes.Join(frames, nil)), frames...)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := PrivateFrames(m)
+	if len(got) != 2 || got[0].Owner != "WM/MediaClassPrimaryID" || got[1].Owner != "www.amazon.com" {
+		t.Errorf("PrivateFrames() = %v, expected the frames in the order of the tag", got)
+	}
+	if len(UnknownTags(m)) != 0 {
+		t.Errorf("UnknownTags() = %v, expected none", UnknownTags(m))
+	}
+}
+
+func TestReadRVA2Frame(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output *VolumeAdjustment
+		err    bool
+	}{
+		{[]byte("track\x00\x01\xf3\x00\x10\x40\x00"), &VolumeAdjustment{Identification: "track", Channels: []ChannelAdjustment{
+			{Channel: ChannelMasterVolume, Gain: -6.5, Peak: 0.5},
+		}}, false},
+		{[]byte("album\x00\x03\x02\x00\x00\x02\xfe\x00\x08\x80"), &VolumeAdjustment{Identification: "album", Channels: []ChannelAdjustment{
+			{Channel: ChannelFrontLeft, Gain: 1},
+			{Channel: ChannelFrontRight, Gain: -1, Peak: 1},
+		}}, false},
+		{[]byte("\x00"), &VolumeAdjustment{}, false},
+		{[]byte("track"), nil, true},
+		{[]byte("track\x00\x01\xf3"), nil, true},
+		{[]byte("track\x00\x01\xf3\x00\x10\x40"), nil, true},
+	}
+
+	for ii, tt := range tests {
+		got, err := readRVA2Frame(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("[%d] readRVA2Frame() error = %v, expected error: %v", ii, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("[%d] readRVA2Frame() = %+v, expected %+v", ii, got, tt.output)
+		}
+	}
+}
+
+func TestID3v2Pictures(t *testing.T) {
+	frames := [][]byte{
+		testID3v23Frame("TIT2", []byte("\x00Title")),
+		testID3v23Frame("APIC", []byte("\x00image/jpeg\x00\x03\x00front")),
+		testID3v23Frame("APIC", []byte("\x00image/png\x00\x04Back\x00back")),
+		testID3v23Frame("APIC", []byte("\x00image/jpeg\x00\x08\x00artist")),
+	}
+	m, err := ReadID3v2Tags(bytes.NewReader(testID3v23(len(bytes.Join(frames, nil)), frames...)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []*Picture{
+		{Ext: "jpg", MIMEType: "image/jpeg", Type: "Cover (front)", Data: []byte("front")},
+		{Ext: "png", MIMEType: "image/png", Type: "Cover (back)", Description: "Back", Data: []byte("back")},
+		{Ext: "jpg", MIMEType: "image/jpeg", Type: "Artist/performer", Data: []byte("artist")},
+	}
+	got := Pictures(m)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Pictures() = %v, expected %v", got, want)
+	}
+	if len(got) > 0 {
+		got[0].Data[0] = 'x'
+		if p := m.Picture(); p == nil || string(p.Data) != "front" {
+			t.Errorf("Picture() = %v, expected the front cover", p)
+		}
+	}
+}
+
+// testUnsynchronise inserts a zero byte after each 0xff byte of b.
+func testUnsynchronise(b []byte) []byte {
+	return bytes.Replace(b, []byte{0xff}, []byte{0xff, 0x00}, -1)
+}
+
+func TestReadID3v2Unsynchronisation(t *testing.T) {
+	title := []byte("\x01\xff\xfeT\x00i\x00t\x00l\x00e\x00") // UTF-16 with BOM
+	artist := []byte("\x00Artist")
+	padding := make([]byte, 10)
+
+	v23 := testUnsynchronise(bytes.Join([][]byte{testID3v23Frame("TIT2", title), testID3v23Frame("TPE1", artist), padding}, nil))
+	v23 = append(testID3v23(len(v23)), v23...)
+	v23[5] = 0x80
+
+	v24 := testID3v24(testID3v24Frame("TIT2", testUnsynchronise(title)), testID3v24Frame("TPE1", artist), padding)
+	v24[5] = 0x80
+
+	// Frame unsynchronisation and data length indicator flags.
+	frame := testID3v24Frame("TIT2", append([]byte{0, 0, 0, byte(len(title))}, testUnsynchronise(title)...))
+	frame[9] = 0x03
+	v24Frame := testID3v24(frame, testID3v24Frame("TPE1", artist), padding)
+
+	for ii, input := range [][]byte{v23, v24, v24Frame} {
+		m, err := ReadID3v2Tags(bytes.NewReader(append(input, "\xff\xfbaudio"...)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if m.Title() != "Title" || m.Artist() != "Artist" {
+			t.Errorf("[%d] Title(), Artist() = %q, %q, expected %q, %q", ii, m.Title(), m.Artist(), "Title", "Artist")
+		}
+		if w := Warnings(m); len(w) != 0 {
+			t.Errorf("[%d] unexpected warnings: %v", ii, w)
+		}
+	}
+}
+
+func TestReadID3v2CompressedFrames(t *testing.T) {
+	title := []byte("\x00Compressed title")
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(title)
+	zw.Close()
+
+	size := []byte{0, 0, 0, byte(len(title))}
+	v23 := testID3v23Frame("TIT2", append(size, z.Bytes()...))
+	v23[9] = 0x80
+	v24 := testID3v24Frame("TIT2", append(size, z.Bytes()...))
+	v24[9] = 0x09 // compression and data length indicator
+	v24NoLength := testID3v24Frame("TIT2", z.Bytes())
+	v24NoLength[9] = 0x08
+	badLength := testID3v23Frame("TIT2", append([]byte{0, 0, 0, 5}, z.Bytes()...))
+	badLength[9] = 0x80
+	badData := testID3v23Frame("TIT2", append(size, "not zlib"...))
+	badData[9] = 0x80
+	padding := make([]byte, 10)
+
+	tests := []struct {
+		input []byte
+		title string
+	}{
+		{testID3v23(len(v23)+len(padding), v23, padding), "Compressed title"},
+		{testID3v24(v24, padding), "Compressed title"},
+		{testID3v24(v24NoLength, padding), ""},
+		{testID3v23(len(badLength)+len(padding), badLength, padding), ""},
+		{testID3v23(len(badData)+len(padding), badData, padding), ""},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadID3v2Tags(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if m.Title() != tt.title {
+			t.Errorf("[%d] Title() = %q, expected %q", ii, m.Title(), tt.title)
+		}
+		if w := Warnings(m); (len(w) != 0) != (tt.title == "") {
+			t.Errorf("[%d] Warnings() = %v", ii, w)
+		}
+	}
+}
+
+func TestReadID3v2CompressedFrameLimit(t *testing.T) {
+	// 1MB of text compresses to about 1KB.
+	text := append([]byte{0}, bytes.Repeat([]byte("x"), 1<<20)...)
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(text)
+	zw.Close()
+
+	n := len(text)
+	frame := testID3v23Frame("TIT2", append([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}, z.Bytes()...))
+	frame[9] = 0x80
+	padding := make([]byte, 10)
+	input := testID3v23(len(frame)+len(padding), frame, padding)
+
+	o := NewReadOptions()
+	o.MetadataLimit = 64 << 10
+	if _, err := ReadFromWithOptions(bytes.NewReader(input), o); !errors.Is(err, ErrMetadataTooLarge) {
+		t.Errorf("limit %d: error = %v, expected ErrMetadataTooLarge", o.MetadataLimit, err)
+	}
+
+	o.MetadataLimit = 2 << 20
+	m, err := ReadFromWithOptions(bytes.NewReader(input), o)
+	if err != nil {
+		t.Fatalf("limit %d: unexpected error: %v", o.MetadataLimit, err)
+	}
+	if len(m.Title()) != n-1 {
+		t.Errorf("limit %d: len(Title()) = %d, expected %d", o.MetadataLimit, len(m.Title()), n-1)
+	}
+}
+
+func TestReadID3v2EncryptedAndGroupedFrames(t *testing.T) {
+	defer func(m ParseMode) { DefaultParseMode = m }(DefaultParseMode)
+
+	artist := []byte("\x00Artist")
+	padding := make([]byte, 10)
+
+	v23Encrypted := testID3v23Frame("TIT2", []byte("\x80\x00Encrypted"))
+	v23Encrypted[9] = 0x40
+	v23Grouped := testID3v23Frame("TIT2", []byte("\x01\x00Grouped"))
+	v23Grouped[9] = 0x20
+	v24Encrypted := testID3v24Frame("TIT2", []byte("\x01\x80\x00\x00\x00\x09\x00Encrypted")) // group, method, data length
+	v24Encrypted[9] = 0x45
+	v24Grouped := testID3v24Frame("TIT2", []byte("\x01\x00Grouped"))
+	v24Grouped[9] = 0x40
+
+	tests := []struct {
+		input []byte
+		err   error
+	}{
+		{testID3v23(len(v23Encrypted)+17+len(padding), v23Encrypted, testID3v23Frame("TPE1", artist), padding), ErrEncryptedFrame},
+		{testID3v23(len(v23Grouped)+17+len(padding), v23Grouped, testID3v23Frame("TPE1", artist), padding), ErrGroupedFrame},
+		{testID3v24(v24Encrypted, testID3v24Frame("TPE1", artist), padding), ErrEncryptedFrame},
+		{testID3v24(v24Grouped, testID3v24Frame("TPE1", artist), padding), ErrGroupedFrame},
+	}
+
+	for ii, tt := range tests {
+		for _, mode := range []ParseMode{Lenient, Strict} {
+			DefaultParseMode = mode
+			m, err := ReadID3v2Tags(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Errorf("[%d] %v: unexpected error: %v", ii, mode, err)
+				continue
+			}
+			if m.Title() != "" || m.Artist() != "Artist" {
+				t.Errorf("[%d] %v: Title(), Artist() = %q, %q, expected %q, %q", ii, mode, m.Title(), m.Artist(), "", "Artist")
+			}
+			if w := Warnings(m); len(w) != 1 || !errors.Is(w[0], tt.err) {
+				t.Errorf("[%d] %v: Warnings() = %v, expected %v", ii, mode, w, tt.err)
+			}
+		}
+	}
+}
+
+// testAppendedID3v24 returns an ID3v2.4 tag with a footer containing the frames.
+func testAppendedID3v24(frames ...[]byte) []byte {
+	b := testID3v24(frames...)
+	b[5] |= 0x10
+	return append(b, append([]byte("3DI"), b[3:10]...)...)
+}
+
+func TestReadAppendedID3v2Tags(t *testing.T) {
+	// Longer than id3v2SearchLimit, so that the appended tag is found by its footer.
+	audio := append([]byte("\xff\xfb"), make([]byte, id3v2SearchLimit+10)...)
+	appended := testAppendedID3v24(testID3v24Frame("TIT2", []byte("\x03Appended")))
+	leading := testID3v23(16, testID3v23Frame("TIT2", []byte("\x00Leading")))
+	join := func(bs ...[]byte) []byte { return bytes.Join(bs, nil) }
+
+	tests := []struct {
+		input []byte
+		title string
+	}{
+		{join(audio, appended), "Appended"},
+		{join(audio, appended, testID3v1Tag()), "Appended"},
+		{join(audio, appended, testAPETag(), testID3v1Tag()), "Appended"},
+		{join(leading, audio, appended), "Leading"},
+	}
+
+	for ii, tt := range tests {
+		for _, read := range []func(io.ReadSeeker) (Metadata, error){ReadID3v2Tags, ReadFrom} {
+			m, err := read(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Errorf("[%d] unexpected error: %v", ii, err)
+				continue
+			}
+			if m.Title() != tt.title {
+				t.Errorf("[%d] Title() = %q, expected %q", ii, m.Title(), tt.title)
+			}
+		}
+	}
+
+	if _, err := ReadFrom(bytes.NewReader(join(audio, appended[:len(appended)-10]))); err != ErrNoTagsFound {
+		t.Errorf("ReadFrom() without footer: error = %v, expected %v", err, ErrNoTagsFound)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ChapterFrame is the content of a chapter (CHAP) frame of an ID3v2.3 or ID3v2.4 tag (see
+// the ID3v2 Chapter Frame Addendum).
+type ChapterFrame struct {
+	ElementID   string
+	StartTime   uint32 // milliseconds
+	EndTime     uint32 // milliseconds
+	StartOffset uint32 // byte offset of the start of the chapter, or 0xffffffff if unused
+	EndOffset   uint32 // byte offset of the end of the chapter, or 0xffffffff if unused
+
+	// Frames are the sub-frames of the chapter (such as TIT2, APIC and WXXX), as returned
+	// by Metadata.Raw.
+	Frames map[string]interface{}
+}
+
+// TableOfContents is the content of a table of contents (CTOC) frame of an ID3v2.3 or
+// ID3v2.4 tag, which lists chapters or other tables of contents.
+type TableOfContents struct {
+	ElementID string
+	TopLevel  bool     // the root of the tables of contents
+	Ordered   bool     // the entries are in order
+	Children  []string // element IDs of the CHAP and CTOC frames in the table
+
+	// Frames are the sub-frames of the table of contents (such as TIT2).
+	Frames map[string]interface{}
+}
+
+// id3v2ChapterPrefix returns the length of the data of a CHAP or CTOC frame which precedes
+// its sub-frames.
+func id3v2ChapterPrefix(id string, b []byte) (int, error) {
+	n := bytes.IndexByte(b, 0) + 1
+	if n == 0 {
+		return 0, errors.New("unterminated element ID")
+	}
+	if id == "CHAP" {
+		if len(b) < n+16 {
+			return 0, fmt.Errorf("expected at least %d bytes, got %d", n+16, len(b))
+		}
+		return n + 16, nil
+	}
+
+	if len(b) < n+2 {
+		return 0, fmt.Errorf("expected at least %d bytes, got %d", n+2, len(b))
+	}
+	count := int(b[n+1])
+	n += 2
+	for i := 0; i < count; i++ {
+		j := bytes.IndexByte(b[n:], 0)
+		if j < 0 {
+			return 0, fmt.Errorf("entry %d of %d is truncated", i+1, count)
+		}
+		n += j + 1
+	}
+	return n, nil
+}
+
+// readChapterSubFrames reads the sub-frames of a CHAP or CTOC frame, which start at offset
+// of the input.
+func readChapterSubFrames(b []byte, h *id3v2Header, offset int64, w *warnings, u *unknownTags, rc *readContext) (map[string]interface{}, error) {
+	sub := &id3v2Header{Version: h.Version, Size: uint(len(b)), offset: offset}
+	frames, err := readID3v2Frames(bytes.NewReader(b), 0, sub, w, u, rc)
+	if err == ErrTruncated {
+		err = nil
+	}
+	return frames, err
+}
+
+// readCHAPFrame reads a chapter (CHAP) frame:
+//
+//	Element ID      <text string> $00
+//	Start time      $xx xx xx xx
+//	End time        $xx xx xx xx
+//	Start offset    $xx xx xx xx
+//	End offset      $xx xx xx xx
+//	<Optional embedded sub-frames>
+func readCHAPFrame(b []byte, h *id3v2Header, offset int64, w *warnings, u *unknownTags, rc *readContext) (*ChapterFrame, error) {
+	n, err := id3v2ChapterPrefix("CHAP", b)
+	if err != nil {
+		return nil, err
+	}
+	i := n - 16
+	c := &ChapterFrame{
+		ElementID:   decodeISO8859(b[:i-1]),
+		StartTime:   binary.BigEndian.Uint32(b[i:]),
+		EndTime:     binary.BigEndian.Uint32(b[i+4:]),
+		StartOffset: binary.BigEndian.Uint32(b[i+8:]),
+		EndOffset:   binary.BigEndian.Uint32(b[i+12:]),
+	}
+	c.Frames, err = readChapterSubFrames(b[n:], h, offset+int64(n), w, u, rc)
+	return c, err
+}
+
+// readCTOCFrame reads a table of contents (CTOC) frame:
+//
+//	Element ID      <text string> $00
+//	Flags           %000000ab (a: top-level, b: ordered)
+//	Entry count     $xx
+//	Child element IDs <text string> $00 (one for each entry)
+//	<Optional embedded sub-frames>
+func readCTOCFrame(b []byte, h *id3v2Header, offset int64, w *warnings, u *unknownTags, rc *readContext) (*TableOfContents, error) {
+	n, err := id3v2ChapterPrefix("CTOC", b)
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.IndexByte(b, 0)
+	t := &TableOfContents{
+		ElementID: decodeISO8859(b[:i]),
+		TopLevel:  b[i+1]&0x02 != 0,
+		Ordered:   b[i+1]&0x01 != 0,
+	}
+	for _, id := range bytes.Split(b[i+3:n], singleZero) {
+		if len(id) > 0 {
+			t.Children = append(t.Children, decodeISO8859(id))
+		}
+	}
+	t.Frames, err = readChapterSubFrames(b[n:], h, offset+int64(n), w, u, rc)
+	return t, err
+}
+
+// id3v2Chapters returns the chapters of the CHAP frames, in the order of the top-level
+// table of contents if there is one, otherwise in order of their start times.
+func id3v2Chapters(frames map[string]interface{}) []Chapter {
+	chaps := make(map[string]*ChapterFrame)
+	tocs := make(map[string]*TableOfContents)
+	var top *TableOfContents
+	for _, v := range frames {
+		switch v := v.(type) {
+		case *ChapterFrame:
+			chaps[v.ElementID] = v
+		case *TableOfContents:
+			tocs[v.ElementID] = v
+			if v.TopLevel {
+				top = v
+			}
+		}
+	}
+	if len(chaps) == 0 {
+		return nil
+	}
+
+	var ordered []*ChapterFrame
+	if top != nil {
+		// Tables of contents may be nested, and must not be visited twice.
+		seen := make(map[string]bool)
+		var visit func(t *TableOfContents)
+		visit = func(t *TableOfContents) {
+			if seen[t.ElementID] {
+				return
+			}
+			seen[t.ElementID] = true
+			for _, id := range t.Children {
+				if c, ok := chaps[id]; ok && !seen[id] {
+					seen[id] = true
+					ordered = append(ordered, c)
+				} else if t, ok := tocs[id]; ok {
+					visit(t)
+				}
+			}
+		}
+		visit(top)
+	}
+	if len(ordered) == 0 {
+		for _, c := range chaps {
+			ordered = append(ordered, c)
+		}
+		sort.Slice(ordered, func(i, j int) bool {
+			if ordered[i].StartTime != ordered[j].StartTime {
+				return ordered[i].StartTime < ordered[j].StartTime
+			}
+			return ordered[i].ElementID < ordered[j].ElementID
+		})
+	}
+
+	chapters := make([]Chapter, 0, len(ordered))
+	for i, c := range ordered {
+		ch := Chapter{
+			id:        uint8(i),
+			StartTime: formatChapterSeconds(time.Duration(c.StartTime) * time.Millisecond),
+			EndTime:   formatChapterSeconds(time.Duration(c.EndTime) * time.Millisecond),
+		}
+		ch.Title, _ = c.Frames[FrameTitle].(string)
+		ch.Picture, _ = c.Frames[FramePicture].(*Picture)
+		if url, ok := c.Frames[FrameUserURL].(*Comm); ok {
+			ch.URL = url.Text
+		}
+		chapters = append(chapters, ch)
+	}
+	return chapters
+}
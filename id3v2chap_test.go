@@ -0,0 +1,78 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testCHAP returns the data of a CHAP frame, with start and end times in seconds.
+func testCHAP(id string, start, end uint32, frames ...[]byte) []byte {
+	b := append([]byte(id), make([]byte, 17)...)
+	binary.BigEndian.PutUint32(b[len(id)+1:], start*1000)
+	binary.BigEndian.PutUint32(b[len(id)+5:], end*1000)
+	for i := len(id) + 9; i < len(b); i++ {
+		b[i] = 0xff
+	}
+	return append(b, bytes.Join(frames, nil)...)
+}
+
+func TestID3v2Chapters(t *testing.T) {
+	long := strings.Repeat("x", 200)
+	ch1 := testID3v24Frame("CHAP", testCHAP("ch1", 0, 5,
+		testID3v24Frame("TIT2", []byte("\x03One")),
+		testID3v24Frame("WXXX", []byte("\x03\x00http://example.com/1"))))
+	ch2 := testID3v24Frame("CHAP", testCHAP("ch2", 5, 10,
+		testID3v24Frame("TIT2", append([]byte("\x03"), long...)),
+		testID3v24Frame("APIC", []byte("\x03image/png\x00\x03\x00png"))))
+	toc := testID3v24Frame("CTOC", []byte("toc\x00\x03\x02ch1\x00ch2\x00"))
+	reversed := testID3v24Frame("CTOC", []byte("toc\x00\x03\x02ch2\x00ch1\x00"))
+
+	one := Chapter{id: 0, StartTime: "0.000", EndTime: "5.000", Title: "One", URL: "http://example.com/1"}
+	two := Chapter{id: 1, StartTime: "5.000", EndTime: "10.000", Title: long,
+		Picture: &Picture{Ext: "png", MIMEType: "image/png", Type: "Cover (front)", Data: []byte("png")}}
+
+	tests := []struct {
+		input []byte
+		want  []Chapter
+	}{
+		{testID3v24(ch2, ch1), []Chapter{one, two}},
+		{testID3v24(toc, ch2, ch1), []Chapter{one, two}},
+		{testID3v24(reversed, ch1, ch2), []Chapter{two, one}},
+		{testID3v24(testID3v24Frame("TIT2", []byte("\x03Title"))), nil},
+	}
+
+	for ii, tt := range tests {
+		for _, version := range []Format{ID3v2_4, ID3v2_3} {
+			b := tt.input
+			if version == ID3v2_3 {
+				out := &bytes.Buffer{}
+				if err := WriteID3v2Tags(out, bytes.NewReader(b), &Edit{ID3v2Version: version}); err != nil {
+					t.Errorf("[%d] unexpected error converting to %v: %v", ii, version, err)
+					continue
+				}
+				b = out.Bytes()
+			}
+			m, err := ReadID3v2Tags(bytes.NewReader(b))
+			if err != nil {
+				t.Errorf("[%d] %v: unexpected error: %v", ii, version, err)
+				continue
+			}
+			got := Chapters(m)
+			for i := range got {
+				if len(tt.want) > i {
+					got[i].id = tt.want[i].id
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("[%d] %v: Chapters() = %+v, expected %+v", ii, version, got, tt.want)
+			}
+		}
+	}
+}
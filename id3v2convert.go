@@ -0,0 +1,274 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"strings"
+)
+
+// id3v23Only are the ID3v2.3 frames which were removed from ID3v2.4 without a replacement
+// the frame can be converted to, and so are dropped when converting to ID3v2.4.
+var id3v23Only = map[string]bool{
+	"EQUA": true, // replaced by EQU2
+	"RVAD": true, // replaced by RVA2
+	"TRDA": true, // replaced by TDRC, but is free text
+	"TSIZ": true,
+}
+
+// id3v24Only are the ID3v2.4 frames (other than text frames) which are not in ID3v2.3, and
+// so are dropped when converting to ID3v2.3.
+var id3v24Only = map[string]bool{
+	"ASPI": true,
+	"EQU2": true,
+	"RVA2": true,
+	"SEEK": true,
+	"SIGN": true,
+}
+
+// id3v2EncodedFrames are the frames with a text encoding which are not re-encoded when
+// converting to ID3v2.3, and so are dropped if they are encoded as UTF-8.
+var id3v2EncodedFrames = map[string]bool{
+	"COMR": true,
+	"GEOB": true,
+	"OWNE": true,
+	"SYLT": true,
+	"USER": true,
+}
+
+// convertID3v2Frames converts the frames of an ID3v2.3 or ID3v2.4 tag to the other version:
+//
+//   - the year, date and time frames of ID3v2.3 (TYER, TDAT and TIME) are combined into the
+//     recording time (TDRC) of ID3v2.4, and split from it when converting to ID3v2.3.
+//   - the original release year (TORY) and the involved people (IPLS) are renamed to TDOR
+//     and TIPL, and back.
+//   - when converting to ID3v2.3, UTF-8 text is re-encoded as ISO-8859-1 or UTF-16, and
+//     text frames with several values have them separated by "/".
+//   - the sub-frames of chapter (CHAP and CTOC) frames are converted.
+//   - the frame flags are converted.  Compressed, encrypted and grouped frames cannot be
+//     converted, and are dropped, as are the frames which only exist in one version.
+func convertID3v2Frames(frames []id3v2Frame, from, to Format) []id3v2Frame {
+	if from == to {
+		return frames
+	}
+
+	out := make([]id3v2Frame, 0, len(frames))
+	var year, date, time string
+	yearIndex := -1
+	for _, f := range frames {
+		if !convertID3v2FrameFlags(&f, from) {
+			continue
+		}
+		if f.id == "CHAP" || f.id == "CTOC" {
+			if f, ok := convertID3v2ChapterFrame(f, from, to); ok {
+				out = append(out, f)
+			}
+			continue
+		}
+
+		if to == ID3v2_4 {
+			switch {
+			case id3v23Only[f.id]:
+				continue
+			case f.id == FrameYear || f.id == FrameDate || f.id == FrameTime:
+				s, _ := readTFrame(f.data, defaultReadContext())
+				switch f.id {
+				case FrameYear:
+					year, yearIndex = s, len(out)
+				case FrameDate:
+					date = s
+				case FrameTime:
+					time = s
+				}
+				continue
+			case f.id == FrameOriginalYear:
+				f.id = FrameOriginalTime
+			case f.id == FrameInvolvedPeople:
+				f.id = FrameInvolvedPeopleList
+			}
+			out = append(out, f)
+			continue
+		}
+
+		switch {
+		case id3v24Only[f.id]:
+			continue
+		case f.id == FrameRecordingTime:
+			s, _ := readTFrame(f.data, defaultReadContext())
+			for _, id := range []string{FrameYear, FrameDate, FrameTime} {
+				if v := splitRecordingTime(s, id); v != "" {
+					out = append(out, id3v2TextFrame(id, v, ID3v2_3))
+				}
+			}
+			continue
+		case f.id == FrameOriginalTime:
+			s, _ := readTFrame(f.data, defaultReadContext())
+			if len(s) >= 4 {
+				out = append(out, id3v2TextFrame(FrameOriginalYear, s[:4], ID3v2_3))
+			}
+			continue
+		case f.id == FrameInvolvedPeopleList:
+			f.id = FrameInvolvedPeople
+		}
+		if f, ok := convertID3v2FrameText(f); ok {
+			out = append(out, f)
+		}
+	}
+
+	if year != "" {
+		tdrc := id3v2TextFrame(FrameRecordingTime, joinRecordingTime(year, date, time), ID3v2_4)
+		out = append(out[:yearIndex], append([]id3v2Frame{tdrc}, out[yearIndex:]...)...)
+	}
+	return out
+}
+
+// convertID3v2ChapterFrame converts the sub-frames of a CHAP or CTOC frame.  Returns false
+// if the frame is invalid.
+func convertID3v2ChapterFrame(f id3v2Frame, from, to Format) (id3v2Frame, bool) {
+	n, err := id3v2ChapterPrefix(f.id, f.data)
+	if err != nil {
+		return f, false
+	}
+	sub, err := parseID3v2RawFrames(f.data[n:], from, false)
+	if err != nil {
+		return f, false
+	}
+	buf := bytes.NewBuffer(append([]byte(nil), f.data[:n]...))
+	if err := writeID3v2RawFrames(buf, convertID3v2Frames(sub, from, to), to); err != nil {
+		return f, false
+	}
+	f.data = buf.Bytes()
+	return f, true
+}
+
+// convertID3v2FrameFlags converts the flags of the frame from the version to the other
+// one.  Returns false if the frame is compressed, encrypted or grouped.
+func convertID3v2FrameFlags(f *id3v2Frame, from Format) bool {
+	if from == ID3v2_3 {
+		if f.flags[1]&0xe0 != 0 {
+			return false
+		}
+		f.flags = [2]byte{f.flags[0] >> 1 & 0x70, 0}
+		return true
+	}
+
+	if f.flags[1]&0x4c != 0 {
+		return false
+	}
+	if f.flags[1]&0x01 != 0 {
+		// The data length indicator.
+		if len(f.data) < 4 {
+			return false
+		}
+		f.data = f.data[4:]
+	}
+	f.flags = [2]byte{f.flags[0] << 1 & 0xe0, 0}
+	return true
+}
+
+// convertID3v2FrameText re-encodes the text of an ID3v2.4 frame for ID3v2.3.  Returns false
+// if the frame cannot be converted.
+func convertID3v2FrameText(f id3v2Frame) (id3v2Frame, bool) {
+	if len(f.data) == 0 {
+		return f, true
+	}
+	utf8 := f.data[0] == encodingUTF8
+
+	switch {
+	case f.id == FrameUserText || f.id == FrameComment || f.id == FrameLyrics:
+		if !utf8 {
+			return f, true
+		}
+		hasLang := f.id != FrameUserText
+		if hasLang && len(f.data) < 4 {
+			return f, false
+		}
+		c, err := readTextWithDescrFrame(f.data, hasLang, true, defaultReadContext())
+		if err != nil {
+			return f, false
+		}
+		if hasLang {
+			return id3v2CommFrame(f.id, c.Language, c.Description, c.Text, ID3v2_3), true
+		}
+		return id3v2TXXXFrame(c.Description, c.Text, ID3v2_3), true
+
+	case f.id == FrameUserURL:
+		if !utf8 {
+			return f, true
+		}
+		c, err := readTextWithDescrFrame(f.data, false, false, defaultReadContext())
+		if err != nil {
+			return f, false
+		}
+		enc, b := encodeID3v2Text(c.Description, ID3v2_3)
+		b = append(append([]byte{enc}, b...), id3v2Terminator(enc)...)
+		return id3v2Frame{id: f.id, flags: f.flags, data: append(b, encodeID3v2TextAs(c.Text, encodingISO8859)...)}, true
+
+	case f.id == FramePicture:
+		if !utf8 {
+			return f, true
+		}
+		p, err := readAPICFrame(f.data, defaultReadContext())
+		if err != nil {
+			return f, false
+		}
+		g := id3v2PictureFrame(p, ID3v2_3)
+		g.flags = f.flags
+		return g, true
+
+	case f.id == FrameInvolvedPeople:
+		if !utf8 {
+			return f, true
+		}
+		s, err := decodeRawText(f.data[0], f.data[1:])
+		if err != nil {
+			return f, false
+		}
+		g := id3v2TextFrame(f.id, s, ID3v2_3)
+		g.flags = f.flags
+		return g, true
+
+	case f.id[0] == 'T':
+		s, err := decodeRawText(f.data[0], f.data[1:])
+		if err != nil {
+			return f, false
+		}
+		s = strings.TrimRight(s, "\x00")
+		if !utf8 && !strings.Contains(s, "\x00") {
+			return f, true
+		}
+		g := id3v2TextFrame(f.id, strings.Replace(s, "\x00", "/", -1), ID3v2_3)
+		g.flags = f.flags
+		return g, true
+	}
+	return f, !(utf8 && id3v2EncodedFrames[f.id])
+}
+
+// splitRecordingTime returns the value of the ID3v2.3 year (YYYY), date (DDMM) or time
+// (HHMM) frame for the ID3v2.4 recording time s (yyyy-MM-ddTHH:mm:ss, or a prefix of it).
+func splitRecordingTime(s, id string) string {
+	switch {
+	case id == FrameYear && len(s) >= 4:
+		return s[:4]
+	case id == FrameDate && len(s) >= 10 && s[4] == '-' && s[7] == '-':
+		return s[8:10] + s[5:7]
+	case id == FrameTime && len(s) >= 16 && s[10] == 'T' && s[13] == ':':
+		return s[11:13] + s[14:16]
+	}
+	return ""
+}
+
+// joinRecordingTime returns the ID3v2.4 recording time for the values of the ID3v2.3 year,
+// date (DDMM) and time (HHMM) frames.
+func joinRecordingTime(year, date, time string) string {
+	if len(year) != 4 || len(date) != 4 {
+		return year
+	}
+	s := year + "-" + date[2:4] + "-" + date[0:2]
+	if len(time) == 4 {
+		s += "T" + time[0:2] + ":" + time[2:4]
+	}
+	return s
+}
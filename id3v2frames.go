@@ -9,6 +9,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"unicode/utf16"
 )
@@ -100,8 +101,10 @@ var id3v22Frames = map[string]string{
 var id3v23Frames = map[string]string{
 	"AENC": "Audio encryption]",
 	"APIC": "Attached picture",
+	"CHAP": "Chapter", // ID3v2 Chapter Frame Addendum
 	"COMM": "Comments",
 	"COMR": "Commercial frame",
+	"CTOC": "Table of contents",
 	"ENCR": "Encryption method registration",
 	"EQUA": "Equalization",
 	"ETCO": "Event timing codes",
@@ -183,8 +186,10 @@ var id3v24Frames = map[string]string{
 	"APIC": "Attached picture",
 	"ASPI": "Audio seek point index",
 
+	"CHAP": "Chapter", // ID3v2 Chapter Frame Addendum
 	"COMM": "Comments",
 	"COMR": "Commercial frame",
+	"CTOC": "Table of contents",
 
 	"ENCR": "Encryption method registration",
 	"EQU2": "Equalisation (2)",
@@ -295,22 +300,31 @@ func validID3Frame(version Format, name string) bool {
 	return ok
 }
 
-func readWFrame(b []byte) (string, error) {
+func readWFrame(b []byte, rc *readContext) (string, error) {
 	// Frame text is always encoded in ISO-8859-1
 	b = append([]byte{0}, b...)
-	return readTFrame(b)
+	return readTFrame(b, rc)
 }
 
-func readTFrame(b []byte) (string, error) {
+func readTFrame(b []byte, rc *readContext) (string, error) {
 	if len(b) == 0 {
 		return "", nil
 	}
 
-	txt, err := decodeText(b[0], b[1:])
+	txt, err := decodeRawText(b[0], b[1:])
 	if err != nil {
 		return "", err
 	}
-	return strings.Join(strings.Split(txt, string(singleZero)), ""), nil
+
+	// ID3v2.4 allows several values separated by NULs, which are joined with ";" (as for
+	// MP4 items with several data atoms).
+	var values []string
+	for _, v := range strings.Split(txt, string(singleZero)) {
+		if v = rc.sanitizeText(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return strings.Join(values, ";"), nil
 }
 
 const (
@@ -320,7 +334,17 @@ const (
 	encodingUTF8         byte = 3
 )
 
-func decodeText(enc byte, b []byte) (string, error) {
+// decodeText decodes the text b with the given ID3v2 encoding, sanitizing the result
+// (see DefaultSanitizeText).
+func decodeText(enc byte, b []byte, rc *readContext) (string, error) {
+	s, err := decodeRawText(enc, b)
+	if err != nil {
+		return "", err
+	}
+	return rc.sanitizeText(s), nil
+}
+
+func decodeRawText(enc byte, b []byte) (string, error) {
 	if len(b) == 0 {
 		return "", nil
 	}
@@ -377,9 +401,25 @@ func dataSplit(b []byte, enc byte) [][]byte {
 	return result
 }
 
+// windows1252 maps the bytes 0x80-0x9F to the characters they represent in Windows-1252
+// (bytes which are undefined in Windows-1252 are left as C1 control characters).
+var windows1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// decodeISO8859 decodes ISO-8859-1 text.  The bytes 0x80-0x9F, which are C1 control
+// characters in ISO-8859-1, are decoded as Windows-1252 (as most taggers which claim to
+// write ISO-8859-1 actually write Windows-1252).
 func decodeISO8859(b []byte) string {
 	r := make([]rune, len(b))
 	for i, x := range b {
+		if x >= 0x80 && x < 0xA0 {
+			r[i] = windows1252[x-0x80]
+			continue
+		}
 		r[i] = rune(x)
 	}
 	return string(r)
@@ -417,9 +457,9 @@ func decodeUTF16(b []byte, bo binary.ByteOrder) (string, error) {
 	return string(utf16.Decode(s)), nil
 }
 
-// Comm is a type used in COMM, UFID, TXXX, WXXX and USLT tag.
+// Comm is a type used in COMM, WXXX and USLT tag.
 // It's a text with a description and a specified language
-// For WXXX, TXXX and UFID, we don't set a Language
+// For WXXX, we don't set a Language (TXXX frames are stored as text, see UserTextPrefix)
 type Comm struct {
 	Language    string
 	Description string
@@ -451,7 +491,7 @@ func (t Comm) String() string {
 // Text encoding       $xx
 // Description         <text string according to encoding> $00 (00)
 // Value               <text string according to encoding>
-func readTextWithDescrFrame(b []byte, hasLang bool, encoded bool) (*Comm, error) {
+func readTextWithDescrFrame(b []byte, hasLang bool, encoded bool, rc *readContext) (*Comm, error) {
 	enc := b[0]
 	b = b[1:]
 
@@ -466,7 +506,7 @@ func readTextWithDescrFrame(b []byte, hasLang bool, encoded bool) (*Comm, error)
 		return nil, fmt.Errorf("error decoding tag description text: invalid encoding")
 	}
 
-	desc, err := decodeText(enc, descTextSplit[0])
+	desc, err := decodeText(enc, descTextSplit[0], rc)
 	if err != nil {
 		return nil, fmt.Errorf("error decoding tag description text: %v", err)
 	}
@@ -479,7 +519,7 @@ func readTextWithDescrFrame(b []byte, hasLang bool, encoded bool) (*Comm, error)
 	if !encoded {
 		enc = byte(0)
 	}
-	text, err := decodeText(enc, descTextSplit[1])
+	text, err := decodeText(enc, descTextSplit[1], rc)
 	if err != nil {
 		return nil, fmt.Errorf("error decoding tag text: %v", err)
 	}
@@ -511,6 +551,33 @@ func readUFID(b []byte) (*UFID, error) {
 	}, nil
 }
 
+// UniqueFileIDs returns the unique file identifier (UFID) frames of an ID3v2 tag, ordered by
+// provider, or nil if there are none or m is not the metadata of an ID3v2 tag.
+func UniqueFileIDs(m Metadata) []*UFID {
+	var result []*UFID
+	for _, v := range id3v2FramesOf(m) {
+		if u, ok := v.(*UFID); ok {
+			c := *u
+			c.Identifier = append([]byte(nil), u.Identifier...)
+			result = append(result, &c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Provider < result[j].Provider })
+	return result
+}
+
+// UniqueFileID returns the identifier of the file in the database of the provider (such as
+// "http://musicbrainz.org", for which it is the MusicBrainz recording ID), or nil if the
+// ID3v2 tag of m does not have one.
+func UniqueFileID(m Metadata, provider string) []byte {
+	for _, u := range UniqueFileIDs(m) {
+		if u.Provider == provider {
+			return u.Identifier
+		}
+	}
+	return nil
+}
+
 var pictureTypes = map[byte]string{
 	0x00: "Other",
 	0x01: "32x32 pixels 'file icon' (PNG only)",
@@ -550,6 +617,27 @@ func (p Picture) String() string {
 		p.Ext, p.MIMEType, p.Type, p.Description, len(p.Data))
 }
 
+// id3v2Pictures returns copies of the attached pictures (APIC, or PIC for ID3v2.2) of the
+// frames, in the order of the tag.
+func id3v2Pictures(frames map[string]interface{}) []*Picture {
+	var names []string
+	for k, v := range frames {
+		if _, ok := v.(*Picture); ok {
+			names = append(names, k)
+		}
+	}
+	// APIC precedes APIC_0, APIC_1, ...
+	sort.Strings(names)
+
+	var pictures []*Picture
+	for _, k := range names {
+		p := *frames[k].(*Picture)
+		p.Data = append([]byte(nil), p.Data...)
+		pictures = append(pictures, &p)
+	}
+	return pictures
+}
+
 // IDv2.2
 // -- Header
 // Attached picture   "PIC"
@@ -560,7 +648,7 @@ func (p Picture) String() string {
 // Picture type       $xx
 // Description        <textstring> $00 (00)
 // Picture data       <binary data>
-func readPICFrame(b []byte) (*Picture, error) {
+func readPICFrame(b []byte, rc *readContext) (*Picture, error) {
 	enc := b[0]
 	ext := string(b[1:4])
 	picType := b[4]
@@ -569,7 +657,7 @@ func readPICFrame(b []byte) (*Picture, error) {
 	if len(descDataSplit) != 2 {
 		return nil, errors.New("error decoding PIC description text: invalid encoding")
 	}
-	desc, err := decodeText(enc, descDataSplit[0])
+	desc, err := decodeText(enc, descDataSplit[0], rc)
 	if err != nil {
 		return nil, fmt.Errorf("error decoding PIC description text: %v", err)
 	}
@@ -600,7 +688,7 @@ func readPICFrame(b []byte) (*Picture, error) {
 // Picture type    $xx
 // Description     <text string according to encoding> $00 (00)
 // Picture data    <binary data>
-func readAPICFrame(b []byte) (*Picture, error) {
+func readAPICFrame(b []byte, rc *readContext) (*Picture, error) {
 	enc := b[0]
 	mimeDataSplit := bytes.SplitN(b[1:], singleZero, 2)
 	mimeType := string(mimeDataSplit[0])
@@ -615,7 +703,7 @@ func readAPICFrame(b []byte) (*Picture, error) {
 	if len(descDataSplit) != 2 {
 		return nil, errors.New("error decoding APIC description text: invalid encoding")
 	}
-	desc, err := decodeText(enc, descDataSplit[0])
+	desc, err := decodeText(enc, descDataSplit[0], rc)
 	if err != nil {
 		return nil, fmt.Errorf("error decoding APIC description text: %v", err)
 	}
@@ -0,0 +1,83 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// EncapsulatedObject is the content of a general encapsulated object (GEOB) frame of an
+// ID3v2 tag, in which applications store files or data of their own (such as the cue
+// points and beat grids of DJ software, identified by their description).
+type EncapsulatedObject struct {
+	MIMEType    string
+	Filename    string
+	Description string // identifies the object, such as "Serato Markers2"
+	Data        []byte
+}
+
+func (o EncapsulatedObject) String() string {
+	return fmt.Sprintf("EncapsulatedObject{MIMEType: %v, Filename: %v, Description: %v, Data.Size: %v}",
+		o.MIMEType, o.Filename, o.Description, len(o.Data))
+}
+
+// EncapsulatedObjects returns the general encapsulated object (GEOB) frames of an ID3v2 tag,
+// in the order of the tag, or nil if there are none or m is not the metadata of an ID3v2
+// tag.
+func EncapsulatedObjects(m Metadata) []*EncapsulatedObject {
+	frames := id3v2FramesOf(m)
+	var names []string
+	for k, v := range frames {
+		if _, ok := v.(*EncapsulatedObject); ok {
+			names = append(names, k)
+		}
+	}
+	// GEOB precedes GEOB_0, GEOB_1, ...
+	sort.Strings(names)
+
+	var result []*EncapsulatedObject
+	for _, k := range names {
+		o := *frames[k].(*EncapsulatedObject)
+		o.Data = append([]byte(nil), o.Data...)
+		result = append(result, &o)
+	}
+	return result
+}
+
+// readGEOBFrame reads a general encapsulated object (GEOB) frame:
+//
+//	Text encoding          $xx
+//	MIME type              <text string> $00
+//	Filename               <text string according to encoding> $00 (00)
+//	Content description    <text string according to encoding> $00 (00)
+//	Encapsulated object    <binary data>
+func readGEOBFrame(b []byte, rc *readContext) (*EncapsulatedObject, error) {
+	if len(b) < 1 {
+		return nil, errors.New("missing text encoding")
+	}
+	enc := b[0]
+	i := bytes.IndexByte(b[1:], 0)
+	if i < 0 {
+		return nil, errors.New("unterminated MIME type")
+	}
+	o := &EncapsulatedObject{MIMEType: decodeISO8859(b[1 : i+1])}
+	b = b[i+2:]
+
+	for _, s := range []*string{&o.Filename, &o.Description} {
+		text, rest, err := splitID3v2Text(enc, b)
+		if err != nil {
+			return nil, err
+		}
+		if *s, err = decodeText(enc, text, rc); err != nil {
+			return nil, err
+		}
+		b = rest
+	}
+	o.Data = b
+	return o, nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimestampFormat is the unit of the timestamps of synchronised ID3v2 frames.
+type TimestampFormat int
+
+// Timestamp formats.
+const (
+	TimestampMPEGFrames   TimestampFormat = 1 // number of MPEG frames from the start of the file
+	TimestampMilliseconds TimestampFormat = 2 // milliseconds from the start of the file
+)
+
+func (f TimestampFormat) String() string {
+	switch f {
+	case TimestampMPEGFrames:
+		return "MPEG frames"
+	case TimestampMilliseconds:
+		return "milliseconds"
+	}
+	return fmt.Sprintf("TimestampFormat(%d)", int(f))
+}
+
+// SyncedContentType is the type of the content of a synchronised lyrics/text frame.
+type SyncedContentType int
+
+// Synchronised content types.
+const (
+	SyncedOther         SyncedContentType = 0
+	SyncedLyricsContent SyncedContentType = 1
+	SyncedTranscription SyncedContentType = 2
+	SyncedPartName      SyncedContentType = 3 // movement/part name (such as "Adagio")
+	SyncedEvents        SyncedContentType = 4 // events (such as "Don Quijote enters the stage")
+	SyncedChords        SyncedContentType = 5 // chords (such as "Bb F Fsus")
+	SyncedTrivia        SyncedContentType = 6 // trivia or "pop up" information
+	SyncedWebPageURLs   SyncedContentType = 7
+	SyncedImageURLs     SyncedContentType = 8
+)
+
+var syncedContentTypeNames = map[SyncedContentType]string{
+	SyncedOther:         "other",
+	SyncedLyricsContent: "lyrics",
+	SyncedTranscription: "text transcription",
+	SyncedPartName:      "part name",
+	SyncedEvents:        "events",
+	SyncedChords:        "chords",
+	SyncedTrivia:        "trivia",
+	SyncedWebPageURLs:   "web page URLs",
+	SyncedImageURLs:     "image URLs",
+}
+
+func (t SyncedContentType) String() string {
+	if s, ok := syncedContentTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("SyncedContentType(%d)", int(t))
+}
+
+// SyncedLyrics is the content of a synchronised lyrics/text (SYLT) frame of an ID3v2 tag.
+type SyncedLyrics struct {
+	Language        string // ISO 639-2 language code
+	Description     string // content descriptor
+	ContentType     SyncedContentType
+	TimestampFormat TimestampFormat
+	Lines           []SyncedLine
+}
+
+// SyncedLine is a line (or syllable) of synchronised lyrics, which is shown from its
+// timestamp.
+type SyncedLine struct {
+	Timestamp uint32 // in the unit of the TimestampFormat of the frame
+	Text      string
+}
+
+// Time returns the timestamp of the line as a duration, or false if the timestamps are
+// not in milliseconds.
+func (s *SyncedLyrics) Time(l SyncedLine) (time.Duration, bool) {
+	if s.TimestampFormat != TimestampMilliseconds {
+		return 0, false
+	}
+	return time.Duration(l.Timestamp) * time.Millisecond, true
+}
+
+// String returns the text of the lines, in order.  Lines which do not start with a line
+// break are syllables of the preceding line.
+func (s *SyncedLyrics) String() string {
+	var b strings.Builder
+	for _, l := range s.Lines {
+		b.WriteString(l.Text)
+	}
+	return strings.TrimLeft(b.String(), "\n")
+}
+
+// SyncedLyricsInfo returns the synchronised lyrics/text (SYLT) frames of an ID3v2 tag, or
+// nil if there are none or m is not the metadata of an ID3v2 tag.
+func SyncedLyricsInfo(m Metadata) []*SyncedLyrics {
+	frames := id3v2FramesOf(m)
+	var names []string
+	for k, v := range frames {
+		if _, ok := v.(*SyncedLyrics); ok {
+			names = append(names, k)
+		}
+	}
+	// SYLT precedes SYLT_0, SYLT_1, ...
+	sort.Strings(names)
+
+	var result []*SyncedLyrics
+	for _, k := range names {
+		result = append(result, frames[k].(*SyncedLyrics))
+	}
+	return result
+}
+
+// readSYLTFrame reads a synchronised lyrics/text (SYLT) frame:
+//
+//	Text encoding       $xx
+//	Language            $xx xx xx
+//	Time stamp format   $xx
+//	Content type        $xx
+//	Content descriptor  <text string according to encoding> $00 (00)
+//
+// followed by the lines, each of which is a string (terminated as the descriptor) and a
+// 32-bit timestamp.
+func readSYLTFrame(b []byte, rc *readContext) (*SyncedLyrics, error) {
+	if len(b) < 6 {
+		return nil, fmt.Errorf("expected at least %d bytes, got %d", 6, len(b))
+	}
+	enc := b[0]
+	s := &SyncedLyrics{
+		Language:        string(b[1:4]),
+		TimestampFormat: TimestampFormat(b[4]),
+		ContentType:     SyncedContentType(b[5]),
+	}
+
+	text, b, err := splitID3v2Text(enc, b[6:])
+	if err != nil {
+		return nil, err
+	}
+	if s.Description, err = decodeText(enc, text, rc); err != nil {
+		return nil, err
+	}
+
+	for len(b) > 0 {
+		text, b, err = splitID3v2Text(enc, b)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) < 4 {
+			return nil, errors.New("missing timestamp of synchronised text")
+		}
+		l := SyncedLine{Timestamp: binary.BigEndian.Uint32(b)}
+		if l.Text, err = decodeText(enc, text, rc); err != nil {
+			return nil, err
+		}
+		s.Lines = append(s.Lines, l)
+		b = b[4:]
+	}
+	return s, nil
+}
+
+// splitID3v2Text returns the string at the start of b, which is terminated according to
+// the encoding, and the bytes following its terminator.
+func splitID3v2Text(enc byte, b []byte) ([]byte, []byte, error) {
+	if enc != encodingUTF16 && enc != encodingUTF16WithBOM {
+		i := bytes.IndexByte(b, 0)
+		if i < 0 {
+			return nil, nil, errors.New("unterminated text")
+		}
+		return b[:i], b[i+1:], nil
+	}
+	for i := 0; i+1 < len(b); i += 2 {
+		if b[i] == 0 && b[i+1] == 0 {
+			return b[:i], b[i+2:], nil
+		}
+	}
+	return nil, nil, errors.New("unterminated text")
+}
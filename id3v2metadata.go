@@ -7,49 +7,58 @@ package audiotag
 import (
 	"strconv"
 	"strings"
+	"time"
 )
 
-type frameNames map[string][2]string
-
-func (f frameNames) Name(s string, fm Format) string {
-	l, ok := f[s]
-	if !ok {
-		return ""
-	}
-
-	switch fm {
-	case ID3v2_2:
-		return l[0]
-	case ID3v2_3:
-		return l[1]
-	case ID3v2_4:
-		if s == "year" {
-			return "TDRC"
-		}
-		return l[1]
+// frameName returns the name of the frame which stores the field in ID3v2 format f.
+func frameName(field string, f Format) string {
+	if names := Registry.LookupByField(f, field); len(names) > 0 {
+		return names[0]
 	}
 	return ""
 }
 
-var frames = frameNames(map[string][2]string{
-	"title":        [2]string{"TT2", "TIT2"},
-	"artist":       [2]string{"TP1", "TPE1"},
-	"album":        [2]string{"TAL", "TALB"},
-	"album_artist": [2]string{"TP2", "TPE2"},
-	"composer":     [2]string{"TCM", "TCOM"},
-	"year":         [2]string{"TYE", "TYER"},
-	"track":        [2]string{"TRK", "TRCK"},
-	"disc":         [2]string{"TPA", "TPOS"},
-	"genre":        [2]string{"TCO", "TCON"},
-	"picture":      [2]string{"PIC", "APIC"},
-	"lyrics":       [2]string{"", "USLT"},
-	"comment":      [2]string{"COM", "COMM"},
-})
+// frames maps field names to the names of the ID3v2.2 and ID3v2.3 frames which store them
+// (ID3v2.4 uses the ID3v2.3 names, except for "year", see Registry).
+var frames = map[string][2]string{
+	"title":        [2]string{"TT2", FrameTitle},
+	"artist":       [2]string{"TP1", FrameArtist},
+	"album":        [2]string{"TAL", FrameAlbum},
+	"album_artist": [2]string{"TP2", FrameAlbumArtist},
+	"composer":     [2]string{"TCM", FrameComposer},
+	"year":         [2]string{"TYE", FrameYear},
+	"track":        [2]string{"TRK", FrameTrack},
+	"disc":         [2]string{"TPA", FrameDisc},
+	"genre":        [2]string{"TCO", FrameGenre},
+	"picture":      [2]string{"PIC", FramePicture},
+	"lyrics":       [2]string{"", FrameLyrics},
+	"comment":      [2]string{"COM", FrameComment},
+	"bpm":          [2]string{"TBP", FrameBPM},
+	"key":          [2]string{"TKE", FrameKey},
+}
 
 // metadataID3v2 is the implementation of Metadata used for ID3v2 tags.
 type metadataID3v2 struct {
+	warnings
+	unknownTags
 	header *id3v2Header
 	frames map[string]interface{}
+	audio  *mpegAudio // the MPEG audio following the tag, or nil if unknown
+}
+
+// id3v2FramesOf returns the frames of the ID3v2 tag of m (which may be the metadata of a
+// DSF file, or of an MP3 file which also has an ID3v1 tag), or nil if m is not the metadata
+// of an ID3v2 tag.
+func id3v2FramesOf(m Metadata) map[string]interface{} {
+	switch m := m.(type) {
+	case metadataID3v2:
+		return m.frames
+	case metadataDSF:
+		return id3v2FramesOf(m.id3)
+	case *metadataMP3:
+		return id3v2FramesOf(m.MetadataExt)
+	}
+	return nil
 }
 
 func (m metadataID3v2) getString(k string) string {
@@ -60,41 +69,85 @@ func (m metadataID3v2) getString(k string) string {
 	return v.(string)
 }
 
+// id3v2UserTextFields are the descriptions of the user defined text (TXXX) frames written
+// by some taggers in place of the standard frames for fields.
+var id3v2UserTextFields = map[string][]string{
+	"album_artist": {"ALBUM ARTIST", "ALBUMARTIST"},
+	"composer":     {"COMPOSER"},
+	"year":         {"DATE", "YEAR"},
+	"track":        {"TRACKNUMBER"},
+	"disc":         {"DISCNUMBER"},
+	"bpm":          {"BPM"},
+	"key":          {"INITIALKEY", "KEY"},
+}
+
+// getField returns the value of the frame which stores the field, or if there is none the
+// text of the first user defined text frame with one of its well-known descriptions.
+func (m metadataID3v2) getField(field string) string {
+	if s := m.getString(frameName(field, m.Format())); s != "" {
+		return s
+	}
+	for _, d := range id3v2UserTextFields[field] {
+		if s := userText(m.frames, d); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
 func (m metadataID3v2) Format() Format              { return m.header.Version }
 func (m metadataID3v2) FileType() FileType          { return MP3 }
-func (m metadataID3v2) Raw() map[string]interface{} { return m.frames }
+func (m metadataID3v2) Raw() map[string]interface{} { return copyRaw(m.frames) }
 
 func (m metadataID3v2) Title() string {
-	return m.getString(frames.Name("title", m.Format()))
+	return m.getField("title")
 }
 
 func (m metadataID3v2) Artist() string {
-	return m.getString(frames.Name("artist", m.Format()))
+	return m.getField("artist")
 }
 
 func (m metadataID3v2) Album() string {
-	return m.getString(frames.Name("album", m.Format()))
+	return m.getField("album")
 }
 
 func (m metadataID3v2) AlbumArtist() string {
-	return m.getString(frames.Name("album_artist", m.Format()))
+	return m.getField("album_artist")
 }
 
 func (m metadataID3v2) Composer() string {
-	return m.getString(frames.Name("composer", m.Format()))
+	return m.getField("composer")
 }
 
 func (m metadataID3v2) Genre() string {
-	return id3v2genre(m.getString(frames.Name("genre", m.Format())))
+	return id3v2genre(m.getField("genre"))
 }
 
 func (m metadataID3v2) Year() int {
-	year, _ := strconv.Atoi(m.getString(frames.Name("year", m.Format())))
-	return year
+	return parseYear(m.getField("year"))
 }
 
+// Duration returns the duration of the MPEG audio following the tag, which is estimated if
+// it does not have a Xing/Info or VBRI header (see DurationInfo).
 func (m metadataID3v2) Duration() int {
-	return 0
+	d, _ := m.audio.duration()
+	return int(d / time.Second)
+}
+
+func (m metadataID3v2) BPM() float64 {
+	return parseBPM(m.getField("bpm"))
+}
+
+func (m metadataID3v2) Key() Key {
+	return ParseKey(m.getField("key"))
+}
+
+func (m metadataID3v2) Chapters() []Chapter {
+	return id3v2Chapters(m.frames)
+}
+
+func (m metadataID3v2) Conflicts() []Conflict {
+	return nil
 }
 
 func parseXofN(s string) (x, n int) {
@@ -109,15 +162,15 @@ func parseXofN(s string) (x, n int) {
 }
 
 func (m metadataID3v2) Track() (int, int) {
-	return parseXofN(m.getString(frames.Name("track", m.Format())))
+	return parseXofN(m.getField("track"))
 }
 
 func (m metadataID3v2) Disc() (int, int) {
-	return parseXofN(m.getString(frames.Name("disc", m.Format())))
+	return parseXofN(m.getField("disc"))
 }
 
 func (m metadataID3v2) Lyrics() string {
-	t, ok := m.frames[frames.Name("lyrics", m.Format())]
+	t, ok := m.frames[frameName("lyrics", m.Format())]
 	if !ok {
 		return ""
 	}
@@ -125,7 +178,7 @@ func (m metadataID3v2) Lyrics() string {
 }
 
 func (m metadataID3v2) Comment() string {
-	t, ok := m.frames[frames.Name("comment", m.Format())]
+	t, ok := m.frames[frameName("comment", m.Format())]
 	if !ok {
 		return ""
 	}
@@ -137,7 +190,7 @@ func (m metadataID3v2) Comment() string {
 }
 
 func (m metadataID3v2) Picture() *Picture {
-	v, ok := m.frames[frames.Name("picture", m.Format())]
+	v, ok := m.frames[frameName("picture", m.Format())]
 	if !ok {
 		return nil
 	}
@@ -4,7 +4,11 @@
 
 package audiotag
 
-import "testing"
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
 
 func TestParseXofN(t *testing.T) {
 	table := []struct {
@@ -27,3 +31,46 @@ func TestParseXofN(t *testing.T) {
 		}
 	}
 }
+
+func TestID3v2UserText(t *testing.T) {
+	frames := [][]byte{
+		testID3v23Frame("TXXX", []byte("\x00REPLAYGAIN_TRACK_GAIN\x00-6.5 dB")),
+		testID3v23Frame("TXXX", []byte("\x00Album Artist\x00Various Artists")),
+		testID3v23Frame("TXXX", []byte("\x00initialkey\x00Am")),
+		testID3v23Frame("TXXX", []byte("\x00BPM\x00128")),
+		testID3v23Frame("TBPM", []byte("\x00120")),
+		testID3v23Frame("TXXX", []byte("\x00DATE\x002001-02-03")),
+		testID3v23Frame("TXXX", []byte("\x00BARCODE\x00123")),
+		testID3v23Frame("TXXX", []byte("\x00BARCODE\x00456")),
+	}
+	m, err := ReadID3v2Tags(bytes.NewReader(testID3v23(len(bytes.Join(frames, nil)), frames...)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"txxx:REPLAYGAIN_TRACK_GAIN": "-6.5 dB",
+		"txxx:Album Artist":          "Various Artists",
+		"txxx:initialkey":            "Am",
+		"txxx:BPM":                   "128",
+		"TBPM":                       "120",
+		"txxx:DATE":                  "2001-02-03",
+		"txxx:BARCODE":               "123", // the first frame (see FormatDefault)
+		"txxx:BARCODE_0":             "456",
+	}
+	if got := m.Raw(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Raw() = %v, expected %v", got, want)
+	}
+	if got := m.AlbumArtist(); got != "Various Artists" {
+		t.Errorf("AlbumArtist() = %q, expected %q", got, "Various Artists")
+	}
+	if got := InitialKey(m); got != ParseKey("Am") {
+		t.Errorf("Key() = %v, expected Am", got)
+	}
+	if got := BPM(m); got != 120 {
+		t.Errorf("BPM() = %v, expected the TBPM frame to take precedence", got)
+	}
+	if got := m.Year(); got != 2001 {
+		t.Errorf("Year() = %d, expected 2001", got)
+	}
+}
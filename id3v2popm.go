@@ -0,0 +1,92 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Popularimeter is the content of a popularimeter (POPM) frame of an ID3v2 tag, which
+// records the rating and play count of the file for a user (usually of a player, such as
+// Windows Media Player).
+type Popularimeter struct {
+	Email   string // identifies the user
+	Rating  byte   // from 1 (worst) to 255 (best), or 0 if unknown
+	Counter uint64 // number of times the file was played
+}
+
+func (p Popularimeter) String() string {
+	return fmt.Sprintf("%v (rating: %d, counter: %d)", p.Email, p.Rating, p.Counter)
+}
+
+// PopularimeterInfo returns the popularimeter (POPM) frames of an ID3v2 tag, ordered by
+// email, or nil if there are none or m is not the metadata of an ID3v2 tag.
+func PopularimeterInfo(m Metadata) []*Popularimeter {
+	var result []*Popularimeter
+	for _, v := range id3v2FramesOf(m) {
+		if p, ok := v.(*Popularimeter); ok {
+			result = append(result, p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Email < result[j].Email })
+	return result
+}
+
+// PlayCount returns the play counter (PCNT) of an ID3v2 tag, or 0 if it does not have one
+// or m is not the metadata of an ID3v2 tag.
+func PlayCount(m Metadata) uint64 {
+	frames := id3v2FramesOf(m)
+	for _, k := range []string{"PCNT", "CNT"} {
+		if n, ok := frames[k].(uint64); ok {
+			return n
+		}
+	}
+	return 0
+}
+
+// readPOPMFrame reads a popularimeter (POPM) frame:
+//
+//	Email to user   <text string> $00
+//	Rating          $xx
+//	Counter         $xx xx xx xx (xx ...)
+//
+// The counter may be omitted.
+func readPOPMFrame(b []byte) (*Popularimeter, error) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 || i+1 >= len(b) {
+		return nil, errors.New("missing rating")
+	}
+	p := &Popularimeter{
+		Email:  decodeISO8859(b[:i]),
+		Rating: b[i+1],
+	}
+	if len(b) > i+2 {
+		n, err := readID3v2Counter(b[i+2:])
+		if err != nil {
+			return nil, err
+		}
+		p.Counter = n
+	}
+	return p, nil
+}
+
+// readID3v2Counter reads the counter of a play counter (PCNT) or popularimeter frame: a
+// big-endian integer of at least 4 bytes.
+func readID3v2Counter(b []byte) (uint64, error) {
+	if len(b) < 4 {
+		return 0, fmt.Errorf("expected at least %d bytes for counter, got %d", 4, len(b))
+	}
+	if len(b) > 8 {
+		return 0, fmt.Errorf("counter too large: %d bytes", len(b))
+	}
+	var n uint64
+	for _, x := range b {
+		n = n<<8 | uint64(x)
+	}
+	return n, nil
+}
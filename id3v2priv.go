@@ -0,0 +1,58 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// PrivateFrame is the content of a private (PRIV) frame of an ID3v2 tag, which contains
+// data in a format defined by its owner (such as "WM/MediaClassPrimaryID" for Windows
+// Media Player, or "www.amazon.com" for files bought from Amazon).
+type PrivateFrame struct {
+	Owner string // identifies the owner, usually by a URL or email address
+	Data  []byte
+}
+
+func (p PrivateFrame) String() string {
+	return fmt.Sprintf("%v (%d bytes)", p.Owner, len(p.Data))
+}
+
+// PrivateFrames returns the private (PRIV) frames of an ID3v2 tag, in the order of the tag,
+// or nil if there are none or m is not the metadata of an ID3v2 tag.
+func PrivateFrames(m Metadata) []*PrivateFrame {
+	frames := id3v2FramesOf(m)
+	var names []string
+	for k, v := range frames {
+		if _, ok := v.(*PrivateFrame); ok {
+			names = append(names, k)
+		}
+	}
+	// PRIV precedes PRIV_0, PRIV_1, ...
+	sort.Strings(names)
+
+	var result []*PrivateFrame
+	for _, k := range names {
+		p := *frames[k].(*PrivateFrame)
+		p.Data = append([]byte(nil), p.Data...)
+		result = append(result, &p)
+	}
+	return result
+}
+
+// readPRIVFrame reads a private (PRIV) frame:
+//
+//	Owner identifier    <text string> $00
+//	The private data    <binary data>
+func readPRIVFrame(b []byte) (*PrivateFrame, error) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return nil, errors.New("unterminated owner identifier")
+	}
+	return &PrivateFrame{Owner: decodeISO8859(b[:i]), Data: b[i+1:]}, nil
+}
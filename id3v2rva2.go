@@ -0,0 +1,147 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ChannelType is the channel of a relative volume adjustment.
+type ChannelType byte
+
+// Channel types.
+const (
+	ChannelOther        ChannelType = 0
+	ChannelMasterVolume ChannelType = 1
+	ChannelFrontRight   ChannelType = 2
+	ChannelFrontLeft    ChannelType = 3
+	ChannelBackRight    ChannelType = 4
+	ChannelBackLeft     ChannelType = 5
+	ChannelFrontCentre  ChannelType = 6
+	ChannelBackCentre   ChannelType = 7
+	ChannelSubwoofer    ChannelType = 8
+)
+
+var channelTypeNames = map[ChannelType]string{
+	ChannelOther:        "other",
+	ChannelMasterVolume: "master volume",
+	ChannelFrontRight:   "front right",
+	ChannelFrontLeft:    "front left",
+	ChannelBackRight:    "back right",
+	ChannelBackLeft:     "back left",
+	ChannelFrontCentre:  "front centre",
+	ChannelBackCentre:   "back centre",
+	ChannelSubwoofer:    "subwoofer",
+}
+
+func (c ChannelType) String() string {
+	if s, ok := channelTypeNames[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("ChannelType(%d)", int(c))
+}
+
+// VolumeAdjustment is the content of a relative volume adjustment (RVA2) frame of an
+// ID3v2.4 tag.
+type VolumeAdjustment struct {
+	Identification string // identifies the situation of the adjustment, such as "track" or "album"
+	Channels       []ChannelAdjustment
+}
+
+// ChannelAdjustment is the relative volume adjustment of a channel.
+type ChannelAdjustment struct {
+	Channel ChannelType
+	Gain    float64 // adjustment in dB
+	Peak    float64 // peak sample amplitude, relative to full scale (0 if not given)
+}
+
+// Adjustment returns the adjustment of the master volume, or if there is none of the first
+// channel, or false if there are no channels.
+func (v *VolumeAdjustment) Adjustment() (ChannelAdjustment, bool) {
+	for _, c := range v.Channels {
+		if c.Channel == ChannelMasterVolume {
+			return c, true
+		}
+	}
+	if len(v.Channels) == 0 {
+		return ChannelAdjustment{}, false
+	}
+	return v.Channels[0], true
+}
+
+// VolumeAdjustments returns the relative volume adjustment (RVA2) frames of an ID3v2 tag, or
+// nil if there are none or m is not the metadata of an ID3v2 tag.
+func VolumeAdjustments(m Metadata) []*VolumeAdjustment {
+	frames := id3v2FramesOf(m)
+	var names []string
+	for k, v := range frames {
+		if _, ok := v.(*VolumeAdjustment); ok {
+			names = append(names, k)
+		}
+	}
+	// RVA2 precedes RVA2_0, RVA2_1, ...
+	sort.Strings(names)
+
+	var result []*VolumeAdjustment
+	for _, k := range names {
+		v := *frames[k].(*VolumeAdjustment)
+		v.Channels = append([]ChannelAdjustment(nil), v.Channels...)
+		result = append(result, &v)
+	}
+	return result
+}
+
+// readRVA2Frame reads a relative volume adjustment (RVA2) frame:
+//
+//	Identification          <text string> $00
+//
+// followed by the channels:
+//
+//	Type of channel         $xx
+//	Volume adjustment       $xx xx
+//	Bits representing peak  $xx
+//	Peak volume             $xx (xx ...)
+//
+// The volume adjustment is a signed 16-bit number of 1/512 dB, and the peak volume is
+// padded to whole bytes.
+func readRVA2Frame(b []byte) (*VolumeAdjustment, error) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return nil, errors.New("unterminated identification")
+	}
+	v := &VolumeAdjustment{Identification: decodeISO8859(b[:i])}
+	b = b[i+1:]
+
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("expected at least %d bytes of channel, got %d", 4, len(b))
+		}
+		c := ChannelAdjustment{
+			Channel: ChannelType(b[0]),
+			Gain:    float64(int16(binary.BigEndian.Uint16(b[1:]))) / 512,
+		}
+		bits := int(b[3])
+		n := (bits + 7) / 8
+		b = b[4:]
+		if len(b) < n {
+			return nil, fmt.Errorf("expected %d bytes of peak volume, got %d", n, len(b))
+		}
+		if bits > 0 {
+			var peak float64
+			for _, x := range b[:n] {
+				peak = peak*256 + float64(x)
+			}
+			c.Peak = math.Ldexp(peak, 1-bits)
+		}
+		v.Channels = append(v.Channels, c)
+		b = b[n:]
+	}
+	return v, nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"sort"
+	"strings"
+)
+
+// URLLink is a URL link frame of an ID3v2 tag, such as the official artist web page (WOAR)
+// or a commercial information page (WCOM).
+type URLLink struct {
+	ID          string // frame ID (such as WOAR, or WAR for ID3v2.2)
+	Description string // description of a user defined link (WXXX), otherwise empty
+	URL         string
+}
+
+// URLLinks returns the URL link frames (W***) of an ID3v2 tag, ordered by frame ID (with
+// frames which occur several times, such as WCOM and WOAR, in the order of the tag), or
+// nil if there are none or m is not the metadata of an ID3v2 tag.
+func URLLinks(m Metadata) []URLLink {
+	frames := id3v2FramesOf(m)
+	var names []string
+	for k := range frames {
+		if k[0] == 'W' {
+			names = append(names, k)
+		}
+	}
+	// WCOM precedes WCOM_0, WCOM_1, ...
+	sort.Strings(names)
+
+	var result []URLLink
+	for _, k := range names {
+		id := k
+		if i := strings.IndexByte(k, '_'); i >= 0 {
+			id = k[:i]
+		}
+		switch v := frames[k].(type) {
+		case string:
+			result = append(result, URLLink{ID: id, URL: v})
+		case *Comm:
+			result = append(result, URLLink{ID: id, Description: v.Description, URL: v.Text})
+		}
+	}
+	return result
+}
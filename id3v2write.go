@@ -0,0 +1,461 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// DefaultID3v2Version is the version of the ID3v2 tags written to MP3 files which do not
+// have one (ID3v2_3 or ID3v2_4).  Existing ID3v2.3 and ID3v2.4 tags keep their version.
+var DefaultID3v2Version = ID3v2_3
+
+// DefaultID3v2Padding is the padding added to an ID3v2 tag when it is written and there is
+// not enough room in the existing tag, so that future edits can be done in place.
+var DefaultID3v2Padding = 1024
+
+// maxID3v2Size is the largest size of an ID3v2 tag or frame (a 28-bit synchsafe integer).
+const maxID3v2Size = 1<<28 - 1
+
+// id3v2Frame is a frame of an ID3v2.3 or ID3v2.4 tag.
+type id3v2Frame struct {
+	id    string
+	flags [2]byte
+	data  []byte
+}
+
+// compressed returns true if the data of the frame is compressed or encrypted (and so
+// cannot be decoded).
+func (f *id3v2Frame) compressed(version Format) bool {
+	if version == ID3v2_3 {
+		return f.flags[1]&0xc0 != 0
+	}
+	return f.flags[1]&0x0c != 0
+}
+
+// readID3v2RawFrames reads the frames of the ID3v2 tag at the start of r without decoding
+// them.  Unsynchronisation is removed.
+func readID3v2RawFrames(r io.Reader) (*id3v2Header, []id3v2Frame, error) {
+	h, offset, err := readID3v2Header(r, defaultReadContext())
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.Version == ID3v2_2 {
+		return h, nil, nil
+	}
+	if uint(offset)-10 > h.Size {
+		return nil, nil, errors.New("ID3v2 extended header exceeds the tag")
+	}
+	b, err := readBytes(r, h.Size-(offset-10))
+	if err != nil {
+		return nil, nil, err
+	}
+	// The sizes of ID3v2.4 frames include the bytes added by unsynchronisation, so it is
+	// removed from each frame.
+	if h.Unsynchronisation && h.Version == ID3v2_3 {
+		b = removeUnsynchronisation(b)
+	}
+
+	frames, err := parseID3v2RawFrames(b, h.Version, h.Unsynchronisation)
+	return h, frames, err
+}
+
+// parseID3v2RawFrames parses the ID3v2.3 or ID3v2.4 frames in b (the frames of a tag, or
+// the sub-frames of a chapter frame) up to the padding.  If unsync is true then all
+// ID3v2.4 frames have been unsynchronised, which is removed.
+func parseID3v2RawFrames(b []byte, version Format, unsync bool) ([]id3v2Frame, error) {
+	var frames []id3v2Frame
+	for len(b) >= 10 && b[0] != 0 {
+		f := id3v2Frame{id: string(b[:4]), flags: [2]byte{b[8], b[9]}}
+		size := int(binary.BigEndian.Uint32(b[4:8]))
+		if version == ID3v2_4 {
+			size = get7BitChunkedInt(b[4:8])
+		}
+		if !wellFormedID3FrameName(f.id) {
+			break // corrupted padding
+		}
+		if size > len(b)-10 {
+			return nil, fmt.Errorf("ID3v2 frame %q extends beyond the end of the tag", f.id)
+		}
+		f.data = b[10 : 10+size]
+		if version == ID3v2_4 && (unsync || f.flags[1]&0x02 != 0) {
+			f.data = removeUnsynchronisation(f.data)
+			f.flags[1] &^= 0x02
+		}
+		frames = append(frames, f)
+		b = b[10+size:]
+	}
+	return frames, nil
+}
+
+// writeID3v2RawFrames writes the frames to buf, with the frame headers of the version.
+func writeID3v2RawFrames(buf *bytes.Buffer, frames []id3v2Frame, version Format) error {
+	for _, f := range frames {
+		if len(f.data) > maxID3v2Size {
+			return fmt.Errorf("ID3v2 frame %q too large: %d bytes", f.id, len(f.data))
+		}
+		size := make([]byte, 4)
+		if version == ID3v2_4 {
+			putSynchsafe(size, len(f.data))
+		} else {
+			binary.BigEndian.PutUint32(size, uint32(len(f.data)))
+		}
+		buf.WriteString(f.id)
+		buf.Write(size)
+		buf.Write(f.flags[:])
+		buf.Write(f.data)
+	}
+	return nil
+}
+
+// WriteID3v2Tags copies the MP3 data from r to w, updating its ID3v2 tag with the Edit (or
+// adding one, of version DefaultID3v2Version).  Fields are written as text frames (track
+// and disc numbers as "n/total"), the comment and lyrics as COMM and USLT frames, custom
+// fields as TXXX frames and pictures as APIC frames.  Frames which are not changed are kept
+// as they are.  If the new tag fits in the existing one then the audio data remains at the
+// same offset, otherwise DefaultID3v2Padding is added.  Chapters are not written.
+//
+// If Edit.ID3v2Version is set then the tag is written as that version, converting the
+// existing frames: the ID3v2.3 year, date and time (TYER, TDAT and TIME) are combined into
+// the ID3v2.4 recording time (TDRC) and split from it, TORY and TDOR are renamed, UTF-8
+// text is re-encoded for ID3v2.3, and frames which only exist in one version, or which
+// are compressed or encrypted, are dropped.  Existing ID3v2.2 tags cannot be updated, unless the Edit clears them.  Any further
+// ID3v2 tags following the first, and trailing APE and ID3v1 tags, are copied unchanged.
+func WriteID3v2Tags(w io.Writer, r io.ReadSeeker, e *Edit) error {
+	l, err := readMP3Layout(r)
+	if err != nil {
+		return err
+	}
+
+	version := DefaultID3v2Version
+	var frames []id3v2Frame
+	if l.firstID3v2End > 0 {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		h, f, err := readID3v2RawFrames(r)
+		if err != nil {
+			return err
+		}
+		if h.Version == ID3v2_2 && !e.Clear {
+			return errors.New("cannot update ID3v2.2 tag")
+		}
+		if h.Version != ID3v2_2 {
+			version, frames = h.Version, f
+		}
+	}
+	if e.Clear {
+		frames = nil
+	}
+	if e.ID3v2Version != "" {
+		if e.ID3v2Version != ID3v2_3 && e.ID3v2Version != ID3v2_4 {
+			return fmt.Errorf("cannot write %v tags", e.ID3v2Version)
+		}
+		frames = convertID3v2Frames(frames, version, e.ID3v2Version)
+		version = e.ID3v2Version
+	}
+	if version != ID3v2_3 && version != ID3v2_4 {
+		return fmt.Errorf("cannot write %v tags", version)
+	}
+
+	frames, err = editID3v2Frames(frames, version, e)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeID3v2RawFrames(buf, frames, version); err != nil {
+		return err
+	}
+
+	// Keep the audio data at the same offset if the existing tag has room.
+	padding := DefaultID3v2Padding
+	if l.firstID3v2End > 0 && int64(buf.Len())+10 <= l.firstID3v2End {
+		padding = int(l.firstID3v2End) - 10 - buf.Len()
+	}
+	size := buf.Len() + padding
+	if size > maxID3v2Size {
+		return fmt.Errorf("ID3v2 tag too large: %d bytes", size)
+	}
+
+	header := []byte{'I', 'D', '3', byte(version.id3v2Version()), 0, 0, 0, 0, 0, 0}
+	putSynchsafe(header[6:], size)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if _, err := w.Write(make([]byte, padding)); err != nil {
+		return err
+	}
+	return copyRange(w, r, l.firstID3v2End, l.size)
+}
+
+// id3v2Version returns the major version number of an ID3v2 format.
+func (f Format) id3v2Version() int {
+	switch f {
+	case ID3v2_2:
+		return 2
+	case ID3v2_3:
+		return 3
+	}
+	return 4
+}
+
+// putSynchsafe writes x to b as a synchsafe integer (7 bits per byte).
+func putSynchsafe(b []byte, x int) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(x & 0x7f)
+		x >>= 7
+	}
+}
+
+// editID3v2Frames applies the edit to the frames of an ID3v2.3 or ID3v2.4 tag, preserving
+// the order of the frames which are not changed.
+func editID3v2Frames(frames []id3v2Frame, version Format, e *Edit) ([]id3v2Frame, error) {
+	// text returns the text of the first frame with the given ID.
+	text := func(id string) string {
+		for i := range frames {
+			if frames[i].id == id && !frames[i].compressed(version) {
+				s, _ := readTFrame(frames[i].data, defaultReadContext())
+				return s
+			}
+		}
+		return ""
+	}
+	var remove []func(*id3v2Frame) bool
+	var added []id3v2Frame
+	set := func(f id3v2Frame, match func(*id3v2Frame) bool) {
+		remove = append(remove, match)
+		if f.data != nil {
+			added = append(added, f)
+		}
+	}
+	byID := func(ids ...string) func(*id3v2Frame) bool {
+		return func(f *id3v2Frame) bool {
+			for _, id := range ids {
+				if f.id == id {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	// withDescription matches the frames with the given ID and description (ignoring case).
+	withDescription := func(id, desc string, hasLang bool) func(*id3v2Frame) bool {
+		return func(f *id3v2Frame) bool {
+			if f.id != id || len(f.data) == 0 || f.compressed(version) {
+				return false
+			}
+			c, err := readTextWithDescrFrame(f.data, hasLang, true, defaultReadContext())
+			return err == nil && strings.EqualFold(c.Description, desc)
+		}
+	}
+
+	for _, p := range []struct{ id, number, total string }{
+		{FrameTrack, "track", "track_total"},
+		{FrameDisc, "disc", "disc_total"},
+	} {
+		number, setNumber := e.Fields[p.number]
+		total, setTotal := e.Fields[p.total]
+		if !setNumber && !setTotal {
+			continue
+		}
+		x, n := parseXofN(text(p.id))
+		var err error
+		if setNumber {
+			if x, err = parseMP4Int(p.number, number, math.MaxInt32); err != nil {
+				return nil, err
+			}
+		}
+		if setTotal {
+			if n, err = parseMP4Int(p.total, total, math.MaxInt32); err != nil {
+				return nil, err
+			}
+		}
+		var f id3v2Frame
+		switch {
+		case n != 0:
+			f = id3v2TextFrame(p.id, strconv.Itoa(x)+"/"+strconv.Itoa(n), version)
+		case x != 0:
+			f = id3v2TextFrame(p.id, strconv.Itoa(x), version)
+		}
+		set(f, byID(p.id))
+	}
+
+	// Set the fields in a stable order.
+	names := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v := e.Fields[k]
+		var f id3v2Frame
+		switch k {
+		case "track", "track_total", "disc", "disc_total":
+			continue
+
+		case "comment", "lyrics":
+			id := FrameComment
+			if k == "lyrics" {
+				id = FrameLyrics
+			}
+			if v != "" {
+				f = id3v2CommFrame(id, "eng", "", v, version)
+			}
+			set(f, withDescription(id, "", true))
+			continue
+
+		case "bpm":
+			if v != "" {
+				bpm, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+				if err != nil || bpm < 0 {
+					return nil, fmt.Errorf("invalid bpm: %q", v)
+				}
+				f = id3v2TextFrame(FrameBPM, strconv.Itoa(int(bpm+0.5)), version)
+			}
+			set(f, byID(FrameBPM))
+			continue
+
+		case "year":
+			id := frameName(k, version)
+			if v != "" {
+				f = id3v2TextFrame(id, v, version)
+			}
+			set(f, byID(FrameYear, FrameRecordingTime))
+			continue
+		}
+
+		if id := frameName(k, version); len(id) == 4 && id[0] == 'T' {
+			if v != "" {
+				f = id3v2TextFrame(id, v, version)
+			}
+			set(f, byID(id))
+			continue
+		}
+		if v != "" {
+			f = id3v2TXXXFrame(k, v, version)
+		}
+		set(f, withDescription(FrameUserText, k, false))
+	}
+
+	if e.Pictures != nil {
+		remove = append(remove, byID(FramePicture))
+		for _, p := range e.Pictures {
+			added = append(added, id3v2PictureFrame(p, version))
+		}
+	}
+
+	result := make([]id3v2Frame, 0, len(frames)+len(added))
+	for i := range frames {
+		keep := true
+		for _, match := range remove {
+			if match(&frames[i]) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			result = append(result, frames[i])
+		}
+	}
+	return append(result, added...), nil
+}
+
+// encodeID3v2Text returns the encoding used for the text s in the ID3v2 version, and the
+// encoded text: UTF-8 for ID3v2.4, and ISO-8859-1 or UTF-16 with a byte order mark for
+// ID3v2.3.
+func encodeID3v2Text(s string, version Format) (byte, []byte) {
+	if version == ID3v2_4 {
+		return encodingUTF8, []byte(s)
+	}
+	latin1 := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			b := []byte{0xff, 0xfe}
+			for _, x := range utf16.Encode([]rune(s)) {
+				b = append(b, byte(x), byte(x>>8))
+			}
+			return encodingUTF16WithBOM, b
+		}
+		latin1 = append(latin1, byte(r))
+	}
+	return encodingISO8859, latin1
+}
+
+// id3v2Terminator returns the terminator of text strings in the encoding.
+func id3v2Terminator(enc byte) []byte {
+	if enc == encodingUTF16WithBOM || enc == encodingUTF16 {
+		return []byte{0, 0}
+	}
+	return []byte{0}
+}
+
+// id3v2TextFrame returns a text frame.
+func id3v2TextFrame(id, s string, version Format) id3v2Frame {
+	enc, b := encodeID3v2Text(s, version)
+	return id3v2Frame{id: id, data: append([]byte{enc}, b...)}
+}
+
+// id3v2TXXXFrame returns a user defined text (TXXX) frame.
+func id3v2TXXXFrame(desc, s string, version Format) id3v2Frame {
+	enc, _ := encodeID3v2Text(desc+s, version)
+	b := append([]byte{enc}, encodeID3v2TextAs(desc, enc)...)
+	b = append(b, id3v2Terminator(enc)...)
+	return id3v2Frame{id: FrameUserText, data: append(b, encodeID3v2TextAs(s, enc)...)}
+}
+
+// id3v2CommFrame returns a comment (COMM) or lyrics (USLT) frame.
+func id3v2CommFrame(id, lang, desc, s string, version Format) id3v2Frame {
+	enc, _ := encodeID3v2Text(desc+s, version)
+	b := append([]byte{enc}, lang...)
+	b = append(b, encodeID3v2TextAs(desc, enc)...)
+	b = append(b, id3v2Terminator(enc)...)
+	return id3v2Frame{id: id, data: append(b, encodeID3v2TextAs(s, enc)...)}
+}
+
+// encodeID3v2TextAs encodes s in the encoding enc, which must be able to represent it.
+func encodeID3v2TextAs(s string, enc byte) []byte {
+	switch enc {
+	case encodingISO8859:
+		_, b := encodeID3v2Text(s, ID3v2_3)
+		return b
+	case encodingUTF16WithBOM:
+		b := []byte{0xff, 0xfe}
+		for _, x := range utf16.Encode([]rune(s)) {
+			b = append(b, byte(x), byte(x>>8))
+		}
+		return b
+	}
+	return []byte(s)
+}
+
+// id3v2PictureFrame returns an attached picture (APIC) frame.
+func id3v2PictureFrame(p *Picture, version Format) id3v2Frame {
+	mimeType := p.MIMEType
+	if mimeType == "" {
+		mimeType = "image/jpeg"
+		if strings.EqualFold(p.Ext, "png") {
+			mimeType = "image/png"
+		}
+	}
+	enc, desc := encodeID3v2Text(p.Description, version)
+	b := append([]byte{enc}, mimeType...)
+	b = append(b, 0, pictureTypeIndex(p.Type))
+	b = append(b, desc...)
+	b = append(b, id3v2Terminator(enc)...)
+	return id3v2Frame{id: FramePicture, data: append(b, p.Data...)}
+}
@@ -0,0 +1,197 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWriteID3v2Tags(t *testing.T) {
+	audio := []byte("\xff\xfbaudio data")
+	tag := testID3v23(200,
+		testID3v23Frame("TIT2", []byte("\x00Title")),
+		testID3v23Frame("TPE1", []byte("\x00Artist")),
+		testID3v23Frame("TRCK", []byte("\x003")),
+		testID3v23Frame("TXXX", []byte("\x00MOOD\x00Sad")),
+		testID3v23Frame("WXXX", []byte("\x00\x00http://example.com")),
+	)
+	tag = append(tag, make([]byte, 210-len(tag))...)
+
+	edit := &Edit{
+		Fields: map[string]string{
+			"title":       "Tïtle",
+			"artist":      "Артист",
+			"album":       "Album",
+			"track_total": "14",
+			"comment":     "Comment",
+			"mood":        "Happy",
+			"year":        "2001",
+		},
+		Pictures: []*Picture{{MIMEType: "image/png", Type: "Cover (front)", Data: []byte("png")}},
+	}
+
+	tests := []struct {
+		in       []byte
+		version  Format
+		sameSize bool
+	}{
+		{append(append([]byte(nil), tag...), audio...), ID3v2_3, true},
+		{audio, ID3v2_3, false},
+		{append(testID3v2Tag(4), audio...), ID3v2_3, false},
+		{append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0}, audio...), ID3v2_4, false},
+	}
+
+	for ii, tt := range tests {
+		out := &bytes.Buffer{}
+		if err := WriteTags(out, bytes.NewReader(tt.in), edit); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		b := out.Bytes()
+		if !bytes.HasSuffix(b, audio) {
+			t.Errorf("[%d] audio data not preserved", ii)
+		}
+		if tt.sameSize && len(b) != len(tt.in) {
+			t.Errorf("[%d] expected padding to be reused: got %d bytes, expected %d", ii, len(b), len(tt.in))
+		}
+
+		m, err := ReadFrom(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("[%d] unexpected error reading written file: %v", ii, err)
+			continue
+		}
+		if m.Format() != tt.version {
+			t.Errorf("[%d] Format() = %v, expected %v", ii, m.Format(), tt.version)
+		}
+		if m.Title() != "Tïtle" || m.Artist() != "Артист" || m.Album() != "Album" {
+			t.Errorf("[%d] Title(), Artist(), Album() = %q, %q, %q", ii, m.Title(), m.Artist(), m.Album())
+		}
+		if m.Comment() != "Comment" || m.Year() != 2001 {
+			t.Errorf("[%d] Comment(), Year() = %q, %d", ii, m.Comment(), m.Year())
+		}
+		if x, n := m.Track(); n != 14 || ii == 0 && x != 3 {
+			t.Errorf("[%d] Track() = %d, %d", ii, x, n)
+		}
+		if got := m.Raw()["txxx:mood"]; got != "Happy" {
+			t.Errorf("[%d] Raw()[\"txxx:mood\"] = %v, expected \"Happy\"", ii, got)
+		}
+		if got, ok := m.Raw()["txxx:MOOD"]; ok {
+			t.Errorf("[%d] Raw()[\"txxx:MOOD\"] = %v, expected frame to be replaced", ii, got)
+		}
+		if p := m.Picture(); p == nil || p.MIMEType != "image/png" || string(p.Data) != "png" {
+			t.Errorf("[%d] Picture() = %v, expected PNG picture", ii, p)
+		}
+		if ii == 0 && !bytes.Contains(b, []byte("http://example.com")) {
+			t.Errorf("[%d] unknown frame was not preserved", ii)
+		}
+	}
+}
+
+func TestWriteID3v2TagsEncoding(t *testing.T) {
+	tests := []struct {
+		version Format
+		in      string
+		enc     byte
+	}{
+		{ID3v2_3, "abc", encodingISO8859},
+		{ID3v2_3, "ïé", encodingISO8859},
+		{ID3v2_3, "Артист", encodingUTF16WithBOM},
+		{ID3v2_4, "abc", encodingUTF8},
+	}
+
+	for ii, tt := range tests {
+		f := id3v2TextFrame("TIT2", tt.in, tt.version)
+		if f.data[0] != tt.enc {
+			t.Errorf("[%d] encoding = %d, expected %d", ii, f.data[0], tt.enc)
+		}
+		if got, err := readTFrame(f.data, defaultReadContext()); err != nil || got != tt.in {
+			t.Errorf("[%d] readTFrame = %q, %v, expected %q", ii, got, err, tt.in)
+		}
+	}
+}
+
+// testID3v24 returns an ID3v2.4 tag containing the frames.
+func testID3v24(frames ...[]byte) []byte {
+	b := bytes.Join(frames, nil)
+	h := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 0}
+	putSynchsafe(h[6:], len(b))
+	return append(h, b...)
+}
+
+// testID3v24Frame returns an ID3v2.4 frame.
+func testID3v24Frame(name string, data []byte) []byte {
+	b := []byte{name[0], name[1], name[2], name[3], 0, 0, 0, 0, 0, 0}
+	putSynchsafe(b[4:8], len(data))
+	return append(b, data...)
+}
+
+func TestWriteID3v2TagsConvert(t *testing.T) {
+	audio := []byte("\xff\xfbaudio data")
+	v23 := testID3v23(66,
+		testID3v23Frame("TYER", []byte("\x002001")),
+		testID3v23Frame("TDAT", []byte("\x000302")),
+		testID3v23Frame("TIME", []byte("\x000405")),
+		testID3v23Frame("TORY", []byte("\x001999")),
+		testID3v23Frame("RVAD", []byte{0, 0}),
+	)
+	v24 := testID3v24(
+		testID3v24Frame("TPE1", []byte("\x03Артист\x00B")),
+		testID3v24Frame("TDRC", []byte("\x032001-02-03T04:05")),
+		testID3v24Frame("TDOR", []byte("\x031999-06")),
+		testID3v24Frame("COMM", []byte("\x03fraDescription\x00Commentaire")),
+		testID3v24Frame("RVA2", []byte("\x00")),
+	)
+
+	tests := []struct {
+		in      []byte
+		version Format
+		want    map[string]interface{}
+	}{
+		{v23, ID3v2_4, map[string]interface{}{
+			"TDRC": "2001-02-03T04:05",
+			"TDOR": "1999",
+		}},
+		{v24, ID3v2_3, map[string]interface{}{
+			"TPE1": "Артист/B",
+			"TYER": "2001",
+			"TDAT": "0302",
+			"TIME": "0405",
+			"TORY": "1999",
+			"COMM": &Comm{Language: "fra", Description: "Description", Text: "Commentaire"},
+		}},
+		{v24, ID3v2_4, map[string]interface{}{
+			"TPE1": "Артист;B",
+			"TDRC": "2001-02-03T04:05",
+			"TDOR": "1999-06",
+			"COMM": &Comm{Language: "fra", Description: "Description", Text: "Commentaire"},
+			"RVA2": &VolumeAdjustment{},
+		}},
+	}
+
+	for ii, tt := range tests {
+		out := &bytes.Buffer{}
+		if err := WriteID3v2Tags(out, bytes.NewReader(append(tt.in, audio...)), &Edit{ID3v2Version: tt.version}); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		m, err := ReadID3v2Tags(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Errorf("[%d] unexpected error reading written file: %v", ii, err)
+			continue
+		}
+		if m.Format() != tt.version {
+			t.Errorf("[%d] Format() = %v, expected %v", ii, m.Format(), tt.version)
+		}
+		if got := m.Raw(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%d] Raw() = %v, expected %v", ii, got, tt.want)
+		}
+	}
+
+	if err := WriteID3v2Tags(&bytes.Buffer{}, bytes.NewReader(audio), &Edit{ID3v2Version: ID3v2_2}); err == nil {
+		t.Errorf("expected error converting to %v", ID3v2_2)
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ContentAdvisory is the content advisory rating of a track, from the iTunes rtng atom.
+type ContentAdvisory int
+
+// Content advisory ratings.
+const (
+	AdvisoryNone     ContentAdvisory = 0
+	AdvisoryExplicit ContentAdvisory = 1
+	AdvisoryClean    ContentAdvisory = 2
+)
+
+func (a ContentAdvisory) String() string {
+	switch a {
+	case AdvisoryNone:
+		return "none"
+	case AdvisoryExplicit:
+		return "explicit"
+	case AdvisoryClean:
+		return "clean"
+	}
+	return fmt.Sprintf("ContentAdvisory(%d)", int(a))
+}
+
+// Advisory returns the content advisory rating of an MP4 file, or AdvisoryNone if it does
+// not have one or m is not the metadata of an MP4 file.
+func Advisory(m Metadata) ContentAdvisory {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return AdvisoryNone
+	}
+	switch x := mp4.getInt([]string{AtomAdvisory}); x {
+	case 1, 4: // 4 is used by older versions of iTunes
+		return AdvisoryExplicit
+	case 2:
+		return AdvisoryClean
+	}
+	return AdvisoryNone
+}
+
+// MediaKind is the kind of media of a track, from the iTunes stik atom.
+type MediaKind int
+
+// Media kinds, with the values used in the stik atom.
+const (
+	MediaKindUnknown    MediaKind = -1 // no stik atom
+	MediaKindMusic      MediaKind = 1
+	MediaKindAudiobook  MediaKind = 2
+	MediaKindBookmark   MediaKind = 5 // "whacked bookmark"
+	MediaKindMusicVideo MediaKind = 6
+	MediaKindMovie      MediaKind = 9
+	MediaKindTVShow     MediaKind = 10
+	MediaKindBooklet    MediaKind = 11
+	MediaKindRingtone   MediaKind = 14
+	MediaKindPodcast    MediaKind = 21
+	MediaKindITunesU    MediaKind = 23
+)
+
+var mediaKindNames = map[MediaKind]string{
+	MediaKindUnknown:    "unknown",
+	MediaKindMusic:      "music",
+	MediaKindAudiobook:  "audiobook",
+	MediaKindBookmark:   "bookmark",
+	MediaKindMusicVideo: "music video",
+	MediaKindMovie:      "movie",
+	MediaKindTVShow:     "TV show",
+	MediaKindBooklet:    "booklet",
+	MediaKindRingtone:   "ringtone",
+	MediaKindPodcast:    "podcast",
+	MediaKindITunesU:    "iTunes U",
+}
+
+func (k MediaKind) String() string {
+	if s, ok := mediaKindNames[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("MediaKind(%d)", int(k))
+}
+
+// Kind returns the media kind of an MP4 file, or MediaKindUnknown if it does not have one
+// or m is not the metadata of an MP4 file.
+func Kind(m Metadata) MediaKind {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return MediaKindUnknown
+	}
+	x, ok := mp4.data[AtomMediaKind].(int)
+	if !ok {
+		return MediaKindUnknown
+	}
+	if x == 0 {
+		return MediaKindMovie // used by older versions of iTunes
+	}
+	return MediaKind(x)
+}
+
+// Gapless returns true if an MP4 file is marked as part of a gapless album by the iTunes
+// pgap atom.
+func Gapless(m Metadata) bool {
+	mp4, ok := m.(*metadataMP4)
+	return ok && mp4.getInt([]string{AtomGapless}) != 0
+}
+
+// VideoDefinition is the definition of a video, from the iTunes hdvd atom.
+type VideoDefinition int
+
+// Video definitions, with the values used in the hdvd atom.
+const (
+	DefinitionSD    VideoDefinition = 0
+	Definition720p  VideoDefinition = 1
+	Definition1080p VideoDefinition = 2
+)
+
+func (d VideoDefinition) String() string {
+	switch d {
+	case DefinitionSD:
+		return "SD"
+	case Definition720p:
+		return "720p"
+	case Definition1080p:
+		return "1080p"
+	}
+	return fmt.Sprintf("VideoDefinition(%d)", int(d))
+}
+
+// Definition returns the video definition of an MP4 file, or DefinitionSD if it does not
+// have an hdvd atom or m is not the metadata of an MP4 file.
+func Definition(m Metadata) VideoDefinition {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return DefinitionSD
+	}
+	return VideoDefinition(mp4.getInt([]string{AtomHDVideo}))
+}
+
+// ContentRating is the content rating of a movie or TV show, from the iTunEXTC custom atom.
+type ContentRating struct {
+	System     string // rating system, such as "mpaa", "us-tv" or "uk-movie"
+	Label      string // such as "PG-13" or "TV-MA"
+	Score      int    // for ordering the ratings of a system: higher is more restrictive
+	Annotation string // reason for the rating, if any
+}
+
+// ParseContentRating parses an iTunEXTC string: the rating system, label, score and
+// annotation separated by "|" (such as "us-tv|TV-MA|600|").  The annotation may be
+// omitted.
+func ParseContentRating(s string) (*ContentRating, error) {
+	fields := strings.SplitN(strings.TrimSpace(s), "|", 4)
+	if len(fields) < 3 || fields[0] == "" || fields[1] == "" {
+		return nil, fmt.Errorf("invalid iTunEXTC: %q", s)
+	}
+	score, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid iTunEXTC score %q", fields[2])
+	}
+	r := &ContentRating{System: fields[0], Label: fields[1], Score: score}
+	if len(fields) == 4 {
+		r.Annotation = fields[3]
+	}
+	return r, nil
+}
+
+// ContentRatingInfo returns the content rating of an MP4 file, or nil if it does not have a
+// valid iTunEXTC atom or m is not the metadata of an MP4 file.
+func ContentRatingInfo(m Metadata) *ContentRating {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return nil
+	}
+	r, err := ParseContentRating(mp4.getString([]string{"iTunEXTC"}))
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+// Podcast is the podcast metadata of an MP4 file.
+type Podcast struct {
+	Podcast         bool   // pcst: the file is a podcast episode
+	FeedURL         string // purl
+	EpisodeGUID     string // egid
+	Category        string // catg
+	Keywords        string // keyw
+	Description     string // desc
+	LongDescription string // ldes
+}
+
+// PodcastInfo returns the podcast metadata of an MP4 file, or nil if it does not have any
+// or m is not the metadata of an MP4 file.
+func PodcastInfo(m Metadata) *Podcast {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return nil
+	}
+	p := &Podcast{
+		Podcast:         mp4.getInt([]string{AtomPodcast}) != 0,
+		FeedURL:         mp4.getString([]string{AtomPodcastURL}),
+		EpisodeGUID:     mp4.getString([]string{AtomEpisodeGUID}),
+		Category:        mp4.getString([]string{AtomCategory}),
+		Keywords:        mp4.getString([]string{AtomKeywords}),
+		Description:     mp4.getString([]string{AtomDescription}),
+		LongDescription: mp4.getString([]string{AtomLongDesc}),
+	}
+	if *p == (Podcast{}) {
+		return nil
+	}
+	return p
+}
+
+// SoundCheck is the iTunes volume normalization (Sound Check) of a track, from the iTunNORM
+// custom atom (or comment).
+type SoundCheck struct {
+	Gain   float64    // adjustment in dB (negative for tracks which are louder than the reference)
+	Peak   float64    // peak sample amplitude, relative to full scale
+	Values [10]uint32 // the values of the iTunNORM string
+}
+
+// ParseSoundCheck parses an iTunNORM string: ten 32-bit hexadecimal values, of which the
+// first two are the loudness of the left and right channels (in thousandths of a
+// milliwatt), and the seventh and eighth are their peak sample values (with full scale
+// 32768).  The gain and peak are those of the louder channel.
+func ParseSoundCheck(s string) (*SoundCheck, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 10 {
+		return nil, fmt.Errorf("invalid iTunNORM: expected 10 values, got %d", len(fields))
+	}
+	sc := &SoundCheck{}
+	for i, f := range fields {
+		x, err := strconv.ParseUint(f, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid iTunNORM value %q: %v", f, err)
+		}
+		sc.Values[i] = uint32(x)
+	}
+
+	loudness := sc.Values[0]
+	if sc.Values[1] > loudness {
+		loudness = sc.Values[1]
+	}
+	if loudness == 0 {
+		return nil, fmt.Errorf("invalid iTunNORM: zero loudness")
+	}
+	sc.Gain = -10 * math.Log10(float64(loudness)/1000)
+
+	peak := sc.Values[6]
+	if sc.Values[7] > peak {
+		peak = sc.Values[7]
+	}
+	sc.Peak = float64(peak) / 32768
+	return sc, nil
+}
+
+// SoundCheckInfo returns the Sound Check volume normalization of an MP4 file, or nil if it
+// does not have a valid iTunNORM atom or m is not the metadata of an MP4 file.
+func SoundCheckInfo(m Metadata) *SoundCheck {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return nil
+	}
+	sc, err := ParseSoundCheck(mp4.getString([]string{"iTunNORM"}))
+	if err != nil {
+		return nil
+	}
+	return sc
+}
+
+// GaplessSamples is the encoder delay and padding of a track, from the iTunSMPB custom atom
+// (or comment), which gapless players trim from the decoded audio.
+type GaplessSamples struct {
+	Delay   int   // priming samples added by the encoder at the start
+	Padding int   // samples added by the encoder at the end
+	Samples int64 // number of samples of the original audio
+}
+
+// ParseGaplessSamples parses an iTunSMPB string: hexadecimal values, of which the second and
+// third are the encoder delay and padding (32 bits) and the fourth is the number of samples
+// of the original audio (64 bits).
+func ParseGaplessSamples(s string) (*GaplessSamples, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid iTunSMPB: expected at least 4 values, got %d", len(fields))
+	}
+	var x [4]uint64
+	for i, f := range fields[:4] {
+		bits := 32
+		if i == 3 {
+			bits = 64
+		}
+		v, err := strconv.ParseUint(f, 16, bits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid iTunSMPB value %q: %v", f, err)
+		}
+		x[i] = v
+	}
+	if x[3] > math.MaxInt64 {
+		return nil, fmt.Errorf("invalid iTunSMPB sample count %d", x[3])
+	}
+	return &GaplessSamples{Delay: int(x[1]), Padding: int(x[2]), Samples: int64(x[3])}, nil
+}
+
+// GaplessInfo returns the encoder delay and padding of an MP4 file, or nil if it does not
+// have a valid iTunSMPB atom or m is not the metadata of an MP4 file.
+func GaplessInfo(m Metadata) *GaplessSamples {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return nil
+	}
+	g, err := ParseGaplessSamples(mp4.getString([]string{"iTunSMPB"}))
+	if err != nil {
+		return nil
+	}
+	return g
+}
+
+// Audiobook is the audiobook metadata of an MP4 (M4B) file.
+type Audiobook struct {
+	Narrator    string // ©nrt, or a NARRATOR custom atom
+	Series      string // SERIES custom atom
+	SeriesPart  string // SERIES-PART custom atom (such as "1" or "2.5")
+	Description string // ©des, or desc
+}
+
+// AudiobookInfo returns the audiobook metadata of an MP4 file, or nil if it does not have
+// any or m is not the metadata of an MP4 file.  The series and part are read from the
+// custom atoms written by Audible tools (their names are matched ignoring case).
+func AudiobookInfo(m Metadata) *Audiobook {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return nil
+	}
+	custom := func(name string) string {
+		if s, ok := mp4.data[name].(string); ok {
+			return s
+		}
+		for k, v := range mp4.data {
+			if s, ok := v.(string); ok && strings.EqualFold(k, name) {
+				return s
+			}
+		}
+		return ""
+	}
+	b := &Audiobook{
+		Narrator:    mp4.getString([]string{AtomNarrator}),
+		Series:      custom("SERIES"),
+		SeriesPart:  custom("SERIES-PART"),
+		Description: mp4.getString([]string{AtomDescAlt, AtomDescription}),
+	}
+	if b.Narrator == "" {
+		b.Narrator = custom("NARRATOR")
+	}
+	if *b == (Audiobook{}) {
+		return nil
+	}
+	return b
+}
+
+// AccountAtoms are the atoms of files bought from the iTunes Store which identify the
+// buyer, removed by Edit.Scrub.
+var AccountAtoms = []string{AtomAppleID, AtomOwner, AtomPurchaseDate}
+
+// Account identifies the buyer of an MP4 file bought from the iTunes Store.
+type Account struct {
+	AppleID      string // apID, the email address of the account
+	Owner        string // ownr, the name of the account holder
+	PurchaseDate string // purd, such as "2010-01-02 03:04:05"
+}
+
+// AccountInfo returns the account which bought an MP4 file, or nil if the file does not
+// have any account atoms or m is not the metadata of an MP4 file.  Files should be
+// scrubbed (see Edit.Scrub) before they are shared if it is not nil.
+func AccountInfo(m Metadata) *Account {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return nil
+	}
+	a := &Account{
+		AppleID:      mp4.getString([]string{AtomAppleID}),
+		Owner:        mp4.getString([]string{AtomOwner}),
+		PurchaseDate: mp4.getString([]string{AtomPurchaseDate}),
+	}
+	if *a == (Account{}) {
+		return nil
+	}
+	return a
+}
@@ -0,0 +1,440 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// testIntItem returns an ilst item atom with an integer data atom.
+func testIntItem(name string, value ...byte) []byte {
+	return testAtom(name, testAtom("data", []byte{0, 0, 0, 21, 0, 0, 0, 0}, value))
+}
+
+func TestAdvisory(t *testing.T) {
+	tests := []struct {
+		items [][]byte
+		want  ContentAdvisory
+	}{
+		{nil, AdvisoryNone},
+		{[][]byte{testIntItem(AtomAdvisory, 0)}, AdvisoryNone},
+		{[][]byte{testIntItem(AtomAdvisory, 1)}, AdvisoryExplicit},
+		{[][]byte{testIntItem(AtomAdvisory, 2)}, AdvisoryClean},
+		{[][]byte{testIntItem(AtomAdvisory, 4)}, AdvisoryExplicit},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A(tt.items)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := Advisory(m); got != tt.want {
+			t.Errorf("[%d] Advisory() = %v, expected %v", ii, got, tt.want)
+		}
+	}
+
+	if got := Advisory(metadataID3v1{}); got != AdvisoryNone {
+		t.Errorf("Advisory() = %v for ID3v1 metadata, expected %v", got, AdvisoryNone)
+	}
+	if got := ContentAdvisory(3).String(); got != "ContentAdvisory(3)" {
+		t.Errorf("String() = %q, expected %q", got, "ContentAdvisory(3)")
+	}
+}
+
+func TestKind(t *testing.T) {
+	tests := []struct {
+		items [][]byte
+		want  MediaKind
+	}{
+		{nil, MediaKindUnknown},
+		{[][]byte{testIntItem(AtomMediaKind, 0)}, MediaKindMovie},
+		{[][]byte{testIntItem(AtomMediaKind, 1)}, MediaKindMusic},
+		{[][]byte{testIntItem(AtomMediaKind, 2)}, MediaKindAudiobook},
+		{[][]byte{testIntItem(AtomMediaKind, 21)}, MediaKindPodcast},
+		{[][]byte{testIntItem(AtomMediaKind, 99)}, MediaKind(99)},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A(tt.items)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := Kind(m); got != tt.want {
+			t.Errorf("[%d] Kind() = %v, expected %v", ii, got, tt.want)
+		}
+		if len(UnknownTags(m)) != 0 {
+			t.Errorf("[%d] UnknownTags() = %v, expected none", ii, UnknownTags(m))
+		}
+	}
+
+	names := []struct {
+		k    MediaKind
+		want string
+	}{
+		{MediaKindTVShow, "TV show"},
+		{MediaKindUnknown, "unknown"},
+		{MediaKind(99), "MediaKind(99)"},
+	}
+	for ii, tt := range names {
+		if got := tt.k.String(); got != tt.want {
+			t.Errorf("[%d] String() = %q, expected %q", ii, got, tt.want)
+		}
+	}
+}
+
+func TestGapless(t *testing.T) {
+	tests := []struct {
+		items [][]byte
+		want  bool
+	}{
+		{nil, false},
+		{[][]byte{testIntItem(AtomGapless, 0)}, false},
+		{[][]byte{testIntItem(AtomGapless, 1)}, true},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A(tt.items)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := Gapless(m); got != tt.want {
+			t.Errorf("[%d] Gapless() = %v, expected %v", ii, got, tt.want)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	tests := []struct {
+		items [][]byte
+		want  VideoDefinition
+	}{
+		{nil, DefinitionSD},
+		{[][]byte{testIntItem(AtomHDVideo, 1)}, Definition720p},
+		{[][]byte{testIntItem(AtomHDVideo, 2)}, Definition1080p},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A(tt.items)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := Definition(m); got != tt.want {
+			t.Errorf("[%d] Definition() = %v, expected %v", ii, got, tt.want)
+		}
+	}
+}
+
+func TestPodcastInfo(t *testing.T) {
+	// testImplicitItem returns an ilst item atom with an implicit (class 0) data atom.
+	testImplicitItem := func(name, value string) []byte {
+		return testAtom(name, testAtom("data", make([]byte, 8), []byte(value)))
+	}
+
+	tests := []struct {
+		items [][]byte
+		want  *Podcast
+	}{
+		{nil, nil},
+		{[][]byte{testTextItem(AtomTitle, "Title")}, nil},
+		{
+			[][]byte{
+				testIntItem(AtomPodcast, 1),
+				testImplicitItem(AtomPodcastURL, "https://example.com/feed.xml"),
+				testImplicitItem(AtomEpisodeGUID, "episode-1"),
+				testTextItem(AtomCategory, "Technology"),
+				testTextItem(AtomKeywords, "go,audio"),
+				testTextItem(AtomDescription, "Short"),
+				testTextItem(AtomLongDesc, "Long description"),
+			},
+			&Podcast{
+				Podcast:         true,
+				FeedURL:         "https://example.com/feed.xml",
+				EpisodeGUID:     "episode-1",
+				Category:        "Technology",
+				Keywords:        "go,audio",
+				Description:     "Short",
+				LongDescription: "Long description",
+			},
+		},
+		{[][]byte{testTextItem(AtomDescription, "Short")}, &Podcast{Description: "Short"}},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A(tt.items)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		got := PodcastInfo(m)
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("[%d] PodcastInfo() = %+v, expected %+v", ii, got, tt.want)
+		}
+		if len(Warnings(m)) != 0 {
+			t.Errorf("[%d] unexpected warnings: %v", ii, Warnings(m))
+		}
+	}
+}
+
+func TestReadAtomsTVShow(t *testing.T) {
+	m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{
+		testTextItem(AtomTVShow, "Show"),
+		testTextItem(AtomTVEpisodeID, "S01E02"),
+		testIntItem(AtomTVSeason, 0, 0, 0, 1),
+		testIntItem(AtomTVEpisode, 0, 0, 1, 2),
+		testTextItem(AtomTVNetwork, "Network"),
+	})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]interface{}{
+		AtomTVShow:      "Show",
+		AtomTVEpisodeID: "S01E02",
+		AtomTVSeason:    1,
+		AtomTVEpisode:   258,
+		AtomTVNetwork:   "Network",
+	}
+	if got := m.Raw(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Raw() = %v, expected %v", got, want)
+	}
+}
+
+func TestReadAtomsStoreIDs(t *testing.T) {
+	m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{
+		testIntItem(AtomCatalogID, 0x3a, 0xde, 0x68, 0xb1),
+		testIntItem(AtomArtistID, 0, 0, 0x30, 0x39),
+		testIntItem(AtomPlaylistID, 0, 0, 0, 0, 0x3a, 0xde, 0x68, 0xb0),
+		testIntItem(AtomGenreStoreID, 0, 0, 0, 21),
+		testIntItem(AtomStorefrontID, 0, 2, 0x30, 0x51),
+	})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]interface{}{
+		AtomCatalogID:    987654321,
+		AtomArtistID:     12345,
+		AtomPlaylistID:   987654320,
+		AtomGenreStoreID: 21,
+		AtomStorefrontID: 143441, // United States
+	}
+	if got := m.Raw(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Raw() = %v, expected %v", got, want)
+	}
+	if got, ok := Registry.LookupByAtom(MP4, AtomCatalogID); !ok || got != "catalog_id" {
+		t.Errorf("LookupByAtom(MP4, %q) = %q, %v, expected %q, true", AtomCatalogID, got, ok, "catalog_id")
+	}
+}
+
+func TestParseSoundCheck(t *testing.T) {
+	tests := []struct {
+		in   string
+		gain float64
+		peak float64
+		err  bool
+	}{
+		{" 000003E8 000003E8 00002710 00002710 00024CA8 00024CA8 00004000 00008000 00024CA8 00024CA8", 0, 1, false},
+		{" 00002710 000003E8 00002710 00002710 00024CA8 00024CA8 00004000 00002000 00024CA8 00024CA8", -10, 0.5, false},
+		{"00000064 00000064 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000", 10, 0, false},
+		{"000003E8 000003E8", 0, 0, true},
+		{"000003E8 000003E8 x 00002710 00024CA8 00024CA8 00004000 00008000 00024CA8 00024CA8", 0, 0, true},
+		{"00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000", 0, 0, true},
+		{"", 0, 0, true},
+	}
+
+	for ii, tt := range tests {
+		sc, err := ParseSoundCheck(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("[%d] expected error", ii)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if math.Abs(sc.Gain-tt.gain) > 1e-9 || sc.Peak != tt.peak {
+			t.Errorf("[%d] Gain, Peak = %v, %v, expected %v, %v", ii, sc.Gain, sc.Peak, tt.gain, tt.peak)
+		}
+	}
+}
+
+func TestSoundCheckInfo(t *testing.T) {
+	norm := " 00002710 000003E8 00002710 00002710 00024CA8 00024CA8 00004000 00002000 00024CA8 00024CA8"
+	item := testAtom(AtomCustom,
+		testAtom("mean", make([]byte, 4), []byte("com.apple.iTunes")),
+		testAtom("name", make([]byte, 4), []byte("iTunNORM")),
+		testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(norm)))
+
+	m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{item})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sc := SoundCheckInfo(m)
+	if sc == nil || sc.Gain != -10 || sc.Values[2] != 0x2710 {
+		t.Errorf("SoundCheckInfo() = %+v, expected gain -10", sc)
+	}
+
+	m, err = ReadAtoms(bytes.NewReader(testM4A(nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sc := SoundCheckInfo(m); sc != nil {
+		t.Errorf("SoundCheckInfo() = %+v, expected nil", sc)
+	}
+	if sc := SoundCheckInfo(metadataID3v1{}); sc != nil {
+		t.Errorf("SoundCheckInfo() = %+v for ID3v1 metadata, expected nil", sc)
+	}
+}
+
+func TestParseContentRating(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *ContentRating
+	}{
+		{"us-tv|TV-MA|600|", &ContentRating{System: "us-tv", Label: "TV-MA", Score: 600}},
+		{"mpaa|R|400|Violence", &ContentRating{System: "mpaa", Label: "R", Score: 400, Annotation: "Violence"}},
+		{"uk-movie|12|300", &ContentRating{System: "uk-movie", Label: "12", Score: 300}},
+		{"mpaa|R", nil},
+		{"mpaa|R|x|", nil},
+		{"|R|400|", nil},
+	}
+
+	for ii, tt := range tests {
+		got, err := ParseContentRating(tt.in)
+		if (err != nil) != (tt.want == nil) {
+			t.Errorf("[%d] ParseContentRating(%q) error = %v, expected error: %v", ii, tt.in, err, tt.want == nil)
+			continue
+		}
+		if got != nil && *got != *tt.want {
+			t.Errorf("[%d] ParseContentRating(%q) = %+v, expected %+v", ii, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestContentRatingInfo(t *testing.T) {
+	item := testAtom(AtomCustom,
+		testAtom("mean", make([]byte, 4), []byte("com.apple.iTunes")),
+		testAtom("name", make([]byte, 4), []byte("iTunEXTC")),
+		testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("us-tv|TV-MA|600|")))
+
+	m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{item})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &ContentRating{System: "us-tv", Label: "TV-MA", Score: 600}
+	if got := ContentRatingInfo(m); got == nil || *got != *want {
+		t.Errorf("ContentRatingInfo() = %+v, expected %+v", got, want)
+	}
+	if got := ContentRatingInfo(metadataID3v1{}); got != nil {
+		t.Errorf("ContentRatingInfo() = %+v for ID3v1 metadata, expected nil", got)
+	}
+}
+
+func TestParseGaplessSamples(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *GaplessSamples
+	}{
+		{
+			" 00000000 00000840 000001CA 00000000003F31F6 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000",
+			&GaplessSamples{Delay: 2112, Padding: 458, Samples: 4141558},
+		},
+		{"00000000 00000840 00000000 0000000000000400", &GaplessSamples{Delay: 2112, Samples: 1024}},
+		{"00000000 00000840 000001CA", nil},
+		{"00000000 00000840 1000001CA 00000000003F31F6", nil},
+		{"00000000 00000840 000001CA FFFFFFFFFFFFFFFF", nil},
+		{"", nil},
+	}
+
+	for ii, tt := range tests {
+		got, err := ParseGaplessSamples(tt.in)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("[%d] expected error", ii)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%d] ParseGaplessSamples() = %+v, expected %+v", ii, got, tt.want)
+		}
+	}
+}
+
+func TestGaplessInfo(t *testing.T) {
+	item := testAtom(AtomCustom,
+		testAtom("mean", make([]byte, 4), []byte("com.apple.iTunes")),
+		testAtom("name", make([]byte, 4), []byte("iTunSMPB")),
+		testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(" 00000000 00000840 000001CA 00000000003F31F6 00000000")))
+
+	m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{item})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &GaplessSamples{Delay: 2112, Padding: 458, Samples: 4141558}
+	if got := GaplessInfo(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("GaplessInfo() = %+v, expected %+v", got, want)
+	}
+	if got := GaplessInfo(metadataID3v1{}); got != nil {
+		t.Errorf("GaplessInfo() = %+v for ID3v1 metadata, expected nil", got)
+	}
+}
+
+func TestAudiobookInfo(t *testing.T) {
+	custom := func(name, value string) []byte {
+		return testAtom(AtomCustom,
+			testAtom("mean", make([]byte, 4), []byte("com.apple.iTunes")),
+			testAtom("name", make([]byte, 4), []byte(name)),
+			testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(value)))
+	}
+
+	tests := []struct {
+		items [][]byte
+		want  *Audiobook
+	}{
+		{nil, nil},
+		{
+			[][]byte{
+				testTextItem(AtomNarrator, "Narrator"),
+				custom("SERIES", "Series"),
+				custom("SERIES-PART", "2.5"),
+				testTextItem(AtomDescAlt, "Description"),
+				testTextItem(AtomDescription, "Short"),
+			},
+			&Audiobook{Narrator: "Narrator", Series: "Series", SeriesPart: "2.5", Description: "Description"},
+		},
+		{
+			[][]byte{custom("narrator", "Narrator"), custom("series", "Series"), testTextItem(AtomDescription, "Short")},
+			&Audiobook{Narrator: "Narrator", Series: "Series", Description: "Short"},
+		},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A(tt.items)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := AudiobookInfo(m); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%d] AudiobookInfo() = %+v, expected %+v", ii, got, tt.want)
+		}
+	}
+
+	if got := AudiobookInfo(metadataID3v1{}); got != nil {
+		t.Errorf("AudiobookInfo() = %+v for ID3v1 metadata, expected nil", got)
+	}
+}
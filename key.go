@@ -0,0 +1,184 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Key is a musical key in standard notation, such as "C", "F#m" or "Bbm".  Keys
+// parsed from tags are normalised so that the same key written in different
+// notations (standard, Camelot, Open Key) compares equal.
+type Key string
+
+// UnknownKey is the Key returned when no key is set, or it could not be parsed.
+const UnknownKey Key = ""
+
+// Key names indexed by pitch class of the tonic (C = 0).
+var (
+	majorKeys = [12]string{"C", "Db", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"}
+	minorKeys = [12]string{"Cm", "C#m", "Dm", "Ebm", "Em", "Fm", "F#m", "Gm", "G#m", "Am", "Bbm", "Bm"}
+)
+
+// Pitch classes of the natural notes.
+var notePitchClasses = map[byte]int{
+	'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11,
+}
+
+// ParseKey parses a musical key written in standard notation ("Am", "A minor",
+// "Bb", "B♭m"), Camelot notation ("8A", "08A") or Open Key notation ("1m", "1d").
+// Values written by Mixed In Key which combine notations ("8A - Am") are also
+// accepted.  Returns UnknownKey if the key could not be parsed.
+func ParseKey(s string) Key {
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '/' || r == ',' }) {
+		if k := parseKey(strings.TrimSpace(p)); k != UnknownKey {
+			return k
+		}
+	}
+	return UnknownKey
+}
+
+func parseKey(s string) Key {
+	if s == "" {
+		return UnknownKey
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		return parseWheelKey(s)
+	}
+
+	pc, ok := notePitchClasses[strings.ToUpper(s[:1])[0]]
+	if !ok {
+		return UnknownKey
+	}
+	s = s[1:]
+
+	switch {
+	case strings.HasPrefix(s, "#"):
+		pc++
+		s = s[1:]
+	case strings.HasPrefix(s, "♯"):
+		pc++
+		s = s[len("♯"):]
+	case strings.HasPrefix(s, "♭"):
+		pc--
+		s = s[len("♭"):]
+	case strings.HasPrefix(s, "b"):
+		pc--
+		s = s[1:]
+	}
+	pc = (pc + 12) % 12
+
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "maj", "major", "dur":
+		return Key(majorKeys[pc])
+	case "m", "min", "minor", "moll":
+		return Key(minorKeys[pc])
+	}
+	return UnknownKey
+}
+
+// parseWheelKey parses keys in Camelot ("8A") and Open Key ("1m") notations.
+func parseWheelKey(s string) Key {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i < 0 {
+		return UnknownKey
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil || n < 1 || n > 12 {
+		return UnknownKey
+	}
+
+	var minor bool
+	switch strings.ToLower(s[i:]) {
+	case "a":
+		minor = true
+	case "b":
+	case "m":
+		// Open Key n is Camelot n+7.
+		n, minor = (n+6)%12+1, true
+	case "d":
+		n = (n+6)%12 + 1
+	default:
+		return UnknownKey
+	}
+
+	// Camelot 8B is C major, each step clockwise is a fifth (7 semitones) up.
+	pc := ((n - 8 + 12) * 7) % 12
+	if minor {
+		// The relative minor is 3 semitones below the major.
+		return Key(minorKeys[(pc+9)%12])
+	}
+	return Key(majorKeys[pc])
+}
+
+// pitchClass returns the pitch class of the tonic and whether the key is minor.
+func (k Key) pitchClass() (pc int, minor bool, ok bool) {
+	for i := range majorKeys {
+		if string(k) == majorKeys[i] {
+			return i, false, true
+		}
+		if string(k) == minorKeys[i] {
+			return i, true, true
+		}
+	}
+	return 0, false, false
+}
+
+// Minor returns true if k is a minor key.
+func (k Key) Minor() bool {
+	_, minor, _ := k.pitchClass()
+	return minor
+}
+
+// camelot returns the Camelot wheel number of the key.
+func (k Key) camelot() (n int, minor bool, ok bool) {
+	pc, minor, ok := k.pitchClass()
+	if !ok {
+		return 0, false, false
+	}
+	if minor {
+		pc = (pc + 3) % 12 // relative major
+	}
+	return (pc*7+7)%12 + 1, minor, true
+}
+
+// Camelot returns the key in Camelot notation (e.g. "8A" for A minor), or an empty
+// string if the key is unknown.
+func (k Key) Camelot() string {
+	n, minor, ok := k.camelot()
+	if !ok {
+		return ""
+	}
+	if minor {
+		return strconv.Itoa(n) + "A"
+	}
+	return strconv.Itoa(n) + "B"
+}
+
+// OpenKey returns the key in Open Key notation (e.g. "1m" for A minor), or an empty
+// string if the key is unknown.
+func (k Key) OpenKey() string {
+	n, minor, ok := k.camelot()
+	if !ok {
+		return ""
+	}
+	n = (n+4)%12 + 1
+	if minor {
+		return strconv.Itoa(n) + "m"
+	}
+	return strconv.Itoa(n) + "d"
+}
+
+// parseBPM parses a tempo in beats per minute, allowing for decimal commas
+// and surrounding whitespace.  Returns 0 if the value cannot be parsed.
+func parseBPM(s string) float64 {
+	s = strings.Replace(trimString(s), ",", ".", 1)
+	bpm, err := strconv.ParseFloat(s, 64)
+	if err != nil || bpm < 0 {
+		return 0
+	}
+	return bpm
+}
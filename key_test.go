@@ -0,0 +1,106 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import "testing"
+
+func TestParseKey(t *testing.T) {
+	tests := map[string]Key{
+		"":         UnknownKey,
+		"o":        UnknownKey,
+		"Am":       "Am",
+		"am":       "Am",
+		"A minor":  "Am",
+		"Amin":     "Am",
+		"C":        "C",
+		"C major":  "C",
+		"A#m":      "Bbm",
+		"Bbm":      "Bbm",
+		"B♭m":      "Bbm",
+		"Db":       "Db",
+		"C#":       "Db",
+		"F♯":       "F#",
+		"Gb":       "F#",
+		"Cb":       "B",
+		"8A":       "Am",
+		"08A":      "Am",
+		"8B":       "C",
+		"1A":       "G#m",
+		"1B":       "B",
+		"12A":      "C#m",
+		"13A":      UnknownKey,
+		"1m":       "Am",
+		"1d":       "C",
+		"6d":       "B",
+		"8A - Am":  "Am",
+		"Am/8A":    "Am",
+		" 11B ":    "A",
+		"Hm":       UnknownKey,
+		"A dorian": UnknownKey,
+	}
+
+	for s, k := range tests {
+		if got := ParseKey(s); got != k {
+			t.Errorf("ParseKey(%q) = %q, expected %q", s, got, k)
+		}
+	}
+}
+
+func TestKeyNotations(t *testing.T) {
+	tests := []struct {
+		key     Key
+		camelot string
+		openKey string
+		minor   bool
+	}{
+		{UnknownKey, "", "", false},
+		{"C", "8B", "1d", false},
+		{"Am", "8A", "1m", true},
+		{"G", "9B", "2d", false},
+		{"B", "1B", "6d", false},
+		{"G#m", "1A", "6m", true},
+		{"F", "7B", "12d", false},
+		{"Dm", "7A", "12m", true},
+	}
+
+	for ii, tt := range tests {
+		if got := tt.key.Camelot(); got != tt.camelot {
+			t.Errorf("[%d] %q.Camelot() = %q, expected %q", ii, tt.key, got, tt.camelot)
+		}
+		if got := tt.key.OpenKey(); got != tt.openKey {
+			t.Errorf("[%d] %q.OpenKey() = %q, expected %q", ii, tt.key, got, tt.openKey)
+		}
+		if got := tt.key.Minor(); got != tt.minor {
+			t.Errorf("[%d] %q.Minor() = %v, expected %v", ii, tt.key, got, tt.minor)
+		}
+		if tt.key != UnknownKey {
+			if got := ParseKey(tt.camelot); got != tt.key {
+				t.Errorf("[%d] ParseKey(%q) = %q, expected %q", ii, tt.camelot, got, tt.key)
+			}
+			if got := ParseKey(tt.openKey); got != tt.key {
+				t.Errorf("[%d] ParseKey(%q) = %q, expected %q", ii, tt.openKey, got, tt.key)
+			}
+		}
+	}
+}
+
+func TestParseBPM(t *testing.T) {
+	tests := map[string]float64{
+		"":        0,
+		"128":     128,
+		" 128 ":   128,
+		"127.5":   127.5,
+		"127,5":   127.5,
+		"128\x00": 128,
+		"fast":    0,
+		"-1":      0,
+	}
+
+	for s, bpm := range tests {
+		if got := parseBPM(s); got != bpm {
+			t.Errorf("parseBPM(%q) = %v, expected %v", s, got, bpm)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"time"
+)
+
+// LintIssue is a suspicious value found by Lint.
+type LintIssue struct {
+	Field   string // field name, as in FieldDiff
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%v: %v", i.Field, i.Message)
+}
+
+// Lint checks the values read from a file for problems which are not spec violations, but
+// which usually indicate bad tagging: an empty title, a missing year or one in the future,
+// a track or disc number greater than the total, the same artist listed more than once,
+// and a picture which is not a valid JPEG or PNG image.  It is intended for checking music
+// libraries, see Validate to check the structure of the tags.
+func Lint(m Metadata) []LintIssue {
+	var issues []LintIssue
+	add := func(field, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(m.Title()) == "" {
+		add("title", "empty title")
+	}
+
+	switch y := m.Year(); {
+	case y == 0:
+		add("year", "no year")
+	case y > time.Now().Year():
+		add("year", "year %d is in the future", y)
+	}
+
+	if x, n := m.Track(); n != 0 && x > n {
+		add("track", "track number %d is greater than the track total %d", x, n)
+	}
+	if x, n := m.Disc(); n != 0 && x > n {
+		add("disc", "disc number %d is greater than the disc total %d", x, n)
+	}
+
+	for _, f := range []struct {
+		name, value string
+	}{
+		{"artist", m.Artist()},
+		{"album_artist", m.AlbumArtist()},
+	} {
+		if a := duplicateArtist(f.value); a != "" {
+			add(f.name, "artist %q is listed more than once", a)
+		}
+	}
+
+	if p := m.Picture(); p != nil && !validImage(p.Data) {
+		add("picture", "picture data (%d bytes) is not a valid JPEG or PNG image", len(p.Data))
+	}
+	return issues
+}
+
+// duplicateArtist returns the first artist which appears more than once (ignoring case)
+// in the list of artists s (separated by ";" or NULs, see Genres), or "" if there is none.
+func duplicateArtist(s string) string {
+	seen := make(map[string]bool)
+	for _, a := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == 0 }) {
+		a = strings.TrimSpace(a)
+		k := strings.ToLower(a)
+		if a == "" {
+			continue
+		}
+		if seen[k] {
+			return a
+		}
+		seen[k] = true
+	}
+	return ""
+}
+
+// validImage returns true if b starts with a valid JPEG or PNG header.
+func validImage(b []byte) bool {
+	if _, err := jpeg.DecodeConfig(bytes.NewReader(b)); err == nil {
+		return true
+	}
+	_, err := png.DecodeConfig(bytes.NewReader(b))
+	return err == nil
+}
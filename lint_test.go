@@ -0,0 +1,64 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLint(t *testing.T) {
+	img := &bytes.Buffer{}
+	if err := png.Encode(img, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	future := strconv.Itoa(time.Now().Year() + 1)
+
+	tests := []struct {
+		comments map[string]string
+		picture  []byte
+		issues   []LintIssue
+	}{
+		{map[string]string{"title": "Title", "date": "2001", "tracknumber": "2", "tracktotal": "10"}, img.Bytes(), nil},
+		{
+			map[string]string{"date": future},
+			nil,
+			[]LintIssue{{"title", "empty title"}, {"year", "year " + future + " is in the future"}},
+		},
+		{
+			map[string]string{"title": "Title", "tracknumber": "11", "tracktotal": "10", "discnumber": "3", "disctotal": "2"},
+			nil,
+			[]LintIssue{
+				{"year", "no year"},
+				{"track", "track number 11 is greater than the track total 10"},
+				{"disc", "disc number 3 is greater than the disc total 2"},
+			},
+		},
+		{
+			map[string]string{"title": "Title", "date": "2001", "artist": "A; B; a", "albumartist": "AC/DC"},
+			[]byte("not an image"),
+			[]LintIssue{
+				{"artist", `artist "a" is listed more than once`},
+				{"picture", "picture data (12 bytes) is not a valid JPEG or PNG image"},
+			},
+		},
+	}
+
+	for ii, tt := range tests {
+		m := &metadataFLAC{newMetadataVorbis(defaultReadContext())}
+		m.c = tt.comments
+		if tt.picture != nil {
+			m.p = &Picture{Data: tt.picture}
+		}
+		if issues := Lint(m); !reflect.DeepEqual(issues, tt.issues) {
+			t.Errorf("[%d] Lint() = %v, expected %v", ii, issues, tt.issues)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build ignore
+// +build ignore
+
+// This program generates nfctables.go, the tables used for Unicode normalization
+// (see DefaultNormalizeText), from the Unicode Character Database.
+//
+// Usage:
+//
+//	go run maketables.go [-ucd https://www.unicode.org/Public/15.0.0/ucd/]
+//
+// The -ucd flag is the URL or local directory containing UnicodeData.txt and
+// CompositionExclusions.txt.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ucd = flag.String("ucd", "https://www.unicode.org/Public/15.0.0/ucd/", "URL or directory of the Unicode Character Database")
+
+func open(name string) (io.ReadCloser, error) {
+	if strings.HasPrefix(*ucd, "http://") || strings.HasPrefix(*ucd, "https://") {
+		resp, err := http.Get(strings.TrimSuffix(*ucd, "/") + "/" + name)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%v: %v", name, resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return os.Open(filepath.Join(*ucd, name))
+}
+
+// readLines calls fn with the fields of each line of the named file, ignoring comments.
+func readLines(name string, fn func(fields []string) error) error {
+	f, err := open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if err := fn(fields); err != nil {
+			return fmt.Errorf("%v: %v: %q", name, err, line)
+		}
+	}
+	return s.Err()
+}
+
+func parseRune(s string) (rune, error) {
+	n, err := strconv.ParseUint(s, 16, 32)
+	return rune(n), err
+}
+
+func main() {
+	flag.Parse()
+
+	decomp := make(map[rune][]rune)
+	ccc := make(map[rune]uint8)
+	err := readLines("UnicodeData.txt", func(fields []string) error {
+		if len(fields) < 6 {
+			return fmt.Errorf("expected at least 6 fields")
+		}
+		r, err := parseRune(fields[0])
+		if err != nil {
+			return err
+		}
+		if c, _ := strconv.Atoi(fields[3]); c != 0 {
+			ccc[r] = uint8(c)
+		}
+		// Compatibility decompositions start with a <tag>.
+		if fields[5] == "" || strings.HasPrefix(fields[5], "<") {
+			return nil
+		}
+		for _, x := range strings.Fields(fields[5]) {
+			d, err := parseRune(x)
+			if err != nil {
+				return err
+			}
+			decomp[r] = append(decomp[r], d)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var exclusions []rune
+	err = readLines("CompositionExclusions.txt", func(fields []string) error {
+		r, err := parseRune(fields[0])
+		if err != nil {
+			return err
+		}
+		exclusions = append(exclusions, r)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by go run maketables.go. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package audiotag\n\n")
+
+	fmt.Fprintf(&b, "// nfcDecompositions are the canonical decompositions of characters (other than Hangul\n")
+	fmt.Fprintf(&b, "// syllables, which are decomposed algorithmically), to one or two characters.\n")
+	fmt.Fprintf(&b, "var nfcDecompositions = map[rune][2]rune{\n")
+	writeSorted(&b, decomp, func(r rune) string {
+		d := decomp[r]
+		if len(d) == 1 {
+			return fmt.Sprintf("{%#04x}", d[0])
+		}
+		return fmt.Sprintf("{%#04x, %#04x}", d[0], d[1])
+	})
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "// nfcCombiningClasses are the non-zero canonical combining classes of characters.\n")
+	fmt.Fprintf(&b, "var nfcCombiningClasses = map[rune]uint8{\n")
+	writeSorted(&b, ccc, func(r rune) string { return strconv.Itoa(int(ccc[r])) })
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "// nfcExclusions are the characters excluded from composition by CompositionExclusions.txt.\n")
+	fmt.Fprintf(&b, "var nfcExclusions = map[rune]bool{\n")
+	ex := make(map[rune]bool)
+	for _, r := range exclusions {
+		ex[r] = true
+	}
+	writeSorted(&b, ex, func(rune) string { return "true" })
+	fmt.Fprintf(&b, "}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("nfctables.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeSorted writes the keys of m (a map keyed by rune) in order, with the values
+// formatted by value, several to a line.
+func writeSorted(w io.Writer, m interface{}, value func(rune) string) {
+	var keys []rune
+	switch m := m.(type) {
+	case map[rune][]rune:
+		for r := range m {
+			keys = append(keys, r)
+		}
+	case map[rune]uint8:
+		for r := range m {
+			keys = append(keys, r)
+		}
+	case map[rune]bool:
+		for r := range m {
+			keys = append(keys, r)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	const perLine = 4
+	for i, r := range keys {
+		fmt.Fprintf(w, "%#04x: %s,", r, value(r))
+		if i%perLine == perLine-1 || i == len(keys)-1 {
+			fmt.Fprintf(w, "\n")
+		} else {
+			fmt.Fprintf(w, " ")
+		}
+	}
+}
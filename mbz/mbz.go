@@ -65,20 +65,20 @@ func (i Info) set(t, v string) {
 // extractID3 attempts to extract MusicBrainz Picard tags from m.Raw(), where m.Format
 // is assumed to be a supported version of ID3.
 func extractID3(m audiotag.Metadata) Info {
-	var txxx, ufid string
+	var ufid string
 	switch m.Format() {
 	case audiotag.ID3v2_2:
-		txxx, ufid = "TXX", "UFI"
+		ufid = "UFI"
 	case audiotag.ID3v2_3, audiotag.ID3v2_4:
-		txxx, ufid = "TXXX", "UFID"
+		ufid = "UFID"
 	}
 
 	i := Info{}
 	for k, v := range m.Raw() {
 		switch {
-		case strings.HasPrefix(k, txxx):
-			if str, ok := v.(*audiotag.Comm); ok {
-				i.set(str.Description, str.Text)
+		case strings.HasPrefix(k, audiotag.UserTextPrefix):
+			if str, ok := v.(string); ok {
+				i.set(k[len(audiotag.UserTextPrefix):], str)
 			}
 		case strings.HasPrefix(k, ufid):
 			if id, ok := v.(*audiotag.UFID); ok {
@@ -0,0 +1,143 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"io"
+	"strings"
+)
+
+// ID3Preference selects which tag is used for the fields found in both the ID3v2 and
+// ID3v1 tags of an MP3 file.
+type ID3Preference int
+
+// Supported ID3 preferences.
+const (
+	PreferID3v2 ID3Preference = iota // use the ID3v2 value (the default)
+	PreferID3v1                      // use the ID3v1 value
+)
+
+// DefaultID3Preference is the ID3Preference used by ReadFrom, unless another is given by
+// ReadOptions.  Whichever tag is preferred, a field which is missing from one tag is read
+// from the other, and fields with different values in both tags are reported by
+// Conflicts.
+var DefaultID3Preference = PreferID3v2
+
+// readMP3Tags reads the ID3v2 tags of an MP3 file, combined with its ID3v1 tag if it has one.
+func readMP3Tags(r io.ReadSeeker, rc *readContext) (Metadata, error) {
+	m, err := readID3v2Tags(r, rc)
+	if err != nil && err != ErrTruncated {
+		return nil, err
+	}
+
+	v1, _, v1err := readID3v1Tag(r, rc)
+	if v1err != nil {
+		// The ID3v2 tag is usable without the ID3v1 tag.
+		return m, err
+	}
+	return newMetadataMP3(m.(MetadataExt), v1, rc.ID3Preference), err
+}
+
+// metadataMP3 is the implementation of Metadata used for MP3 files which have both
+// ID3v2 and ID3v1 tags.  Fields which ID3v1 does not support are read from ID3v2.
+type metadataMP3 struct {
+	MetadataExt // ID3v2
+
+	first, second Metadata // in order of the ID3Preference
+	conflicts     []Conflict
+}
+
+func newMetadataMP3(v2 MetadataExt, v1 Metadata, p ID3Preference) *metadataMP3 {
+	m := &metadataMP3{MetadataExt: v2, first: v2, second: v1}
+	if p == PreferID3v1 {
+		m.first, m.second = v1, v2
+	}
+
+	a, b := FieldValues(m.first), FieldValues(m.second)
+	for _, f := range []string{"title", "album", "artist", "genre", "year", "track", "comment"} {
+		if a[f] != "" && b[f] != "" && !sameID3Value(a[f], b[f]) {
+			m.conflicts = append(m.conflicts, Conflict{
+				Field:       f,
+				Format:      m.first.Format(),
+				Value:       a[f],
+				OtherFormat: m.second.Format(),
+				OtherValue:  b[f],
+			})
+		}
+	}
+	return m
+}
+
+// sameID3Value returns true if the ID3v2 and ID3v1 values a and b (in either order) are
+// the same, allowing for the truncation of ID3v1 text to 30 bytes.
+func sameID3Value(a, b string) bool {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	return a == b || (len(b) >= 28 && strings.HasPrefix(a, b))
+}
+
+// Format returns the version of the ID3v2 tag, whichever tag is preferred: the accessors
+// which depend on the format (such as SortOrder) read the ID3v2 frames.
+func (m *metadataMP3) Format() Format {
+	return m.MetadataExt.Format()
+}
+
+func (m *metadataMP3) Title() string {
+	if s := m.first.Title(); s != "" {
+		return s
+	}
+	return m.second.Title()
+}
+
+func (m *metadataMP3) Album() string {
+	if s := m.first.Album(); s != "" {
+		return s
+	}
+	return m.second.Album()
+}
+
+func (m *metadataMP3) Artist() string {
+	if s := m.first.Artist(); s != "" {
+		return s
+	}
+	return m.second.Artist()
+}
+
+func (m *metadataMP3) Genre() string {
+	if s := m.first.Genre(); s != "" {
+		return s
+	}
+	return m.second.Genre()
+}
+
+func (m *metadataMP3) Year() int {
+	if y := m.first.Year(); y != 0 {
+		return y
+	}
+	return m.second.Year()
+}
+
+func (m *metadataMP3) Track() (int, int) {
+	x, n := m.first.Track()
+	if x == 0 {
+		return m.second.Track()
+	}
+	if y, total := m.second.Track(); n == 0 && y == x {
+		n = total
+	}
+	return x, n
+}
+
+func (m *metadataMP3) Comment() string {
+	if s := m.first.Comment(); s != "" {
+		return s
+	}
+	return m.second.Comment()
+}
+
+func (m *metadataMP3) Conflicts() []Conflict {
+	return m.conflicts
+}
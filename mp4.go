@@ -10,130 +10,503 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// atomTypes maps the classes (well-known types) of data atoms to their content types.
 var atomTypes = map[int]string{
 	0:  "implicit", // automatic based on atom name
 	1:  "text",
+	2:  "utf16",
+	4:  "text",  // for sorting
+	5:  "utf16", // for sorting
 	13: "jpeg",
 	14: "png",
-	21: "uint8",
-}
-
-// NB: atoms does not include "----", this is handled separately
-var atoms = atomNames(map[string]string{
-	"\xa9alb": "album",
-	"\xa9art": "artist",
-	"\xa9ART": "artist",
-	"aART":    "album_artist",
-	"\xa9day": "year",
-	"\xa9nam": "title",
-	"\xa9gen": "genre",
-	"trkn":    "track",
-	"\xa9wrt": "composer",
-	"\xa9too": "encoder",
-	"cprt":    "copyright",
-	"covr":    "picture",
-	"\xa9grp": "grouping",
-	"keyw":    "keyword",
-	"\xa9lyr": "lyrics",
-	"\xa9cmt": "comment",
-	"\xa9mvn": "movement",
-	"\xa9mvc": "total_mov",
-	"\xa9mvi": "mov_index",
-	"shwm":    "showMovement",
-	"tmpo":    "tempo",
-	"cpil":    "compilation",
-	"disk":    "disc",
-	"chpl":    "chapter",
-	"catg":    "catg",
-})
+	21: "int", // big-endian signed integer of 1, 2, 3, 4 or 8 bytes
+	22: "uint",
+	23: "float32",
+	24: "float64",
+	27: "bmp",
+	65: "int",  // 8-bit signed integer
+	66: "int",  // 16-bit
+	67: "int",  // 32-bit
+	74: "int",  // 64-bit
+	75: "uint", // 8-bit unsigned integer
+	76: "uint", // 16-bit
+	77: "uint", // 32-bit
+	78: "uint", // 64-bit
+}
+
+// atoms maps the names of the atoms which are read to their field names (see Registry).
+// NB: atoms does not include AtomCustom, this is handled separately.
+var atoms = map[string]string{
+	AtomAlbum:         "album",
+	AtomArtistAlt:     "artist",
+	AtomArtist:        "artist",
+	AtomAlbumArtist:   "album_artist",
+	AtomYear:          "year",
+	AtomTitle:         "title",
+	AtomGenre:         "genre",
+	AtomGenreID:       "genre_id",
+	AtomTrack:         "track",
+	AtomComposer:      "composer",
+	AtomEncoder:       "encoder",
+	AtomCopyright:     "copyright",
+	AtomPicture:       "picture",
+	AtomGrouping:      "grouping",
+	AtomKeywords:      "keyword",
+	AtomLyrics:        "lyrics",
+	AtomComment:       "comment",
+	AtomMovement:      "movement",
+	AtomMovementCount: "total_mov",
+	AtomMovementIndex: "mov_index",
+	AtomShowMovement:  "showMovement",
+	AtomWork:          "work",
+	AtomTempo:         "tempo",
+	AtomCompilation:   "compilation",
+	AtomDisc:          "disc",
+	AtomChapters:      "chapter",
+	AtomCategory:      "catg",
+	AtomAdvisory:      "advisory",
+	AtomMediaKind:     "media_kind",
+	AtomGapless:       "gapless",
+	AtomHDVideo:       "hd_video",
+	AtomPodcast:       "podcast",
+	AtomPodcastURL:    "podcast_url",
+	AtomEpisodeGUID:   "episode_guid",
+	AtomDescription:   "description",
+	AtomLongDesc:      "long_description",
+	AtomDescAlt:       "description",
+	AtomNarrator:      "narrator",
+	AtomTVShow:        "tv_show",
+	AtomTVEpisodeID:   "tv_episode_id",
+	AtomTVSeason:      "tv_season",
+	AtomTVEpisode:     "tv_episode",
+	AtomTVNetwork:     "tv_network",
+	AtomSortTitle:     "sort_title",
+	AtomSortArtist:    "sort_artist",
+	AtomSortAlbum:     "sort_album",
+	AtomSortAlbumArt:  "sort_album_artist",
+	AtomSortComposer:  "sort_composer",
+	AtomSortShow:      "sort_show",
+	AtomCatalogID:     "catalog_id",
+	AtomArtistID:      "artist_id",
+	AtomPlaylistID:    "playlist_id",
+	AtomGenreStoreID:  "store_genre_id",
+	AtomStorefrontID:  "storefront_id",
+	AtomAppleID:       "apple_id",
+	AtomOwner:         "owner",
+	AtomPurchaseDate:  "purchase_date",
+}
 
 // Detect PNG image if "implicit" class is used
 var pngHeader = []byte{137, 80, 78, 71, 13, 10, 26, 10}
 
-var _ Metadata = &metadataMP4{}
-
-type atomNames map[string]string
+// imageSignatures are the signatures used to detect the type of pictures in covr atoms
+// with the "implicit" class.
+var imageSignatures = []struct {
+	prefix string
+	ext    string
+}{
+	{string(pngHeader), "png"},
+	{"\xff\xd8\xff", "jpeg"},
+	{"GIF87a", "gif"},
+	{"GIF89a", "gif"},
+	{"BM", "bmp"},
+}
 
-func (f atomNames) Name(n string) []string {
-	res := make([]string, 1)
-	for k, v := range f {
-		if v == n {
-			res = append(res, k)
+// detectImageType returns the type of the image data b ("png", "jpeg", "gif" or "bmp") from
+// its signature, or the empty string if it is not recognized.
+func detectImageType(b []byte) string {
+	for _, s := range imageSignatures {
+		if bytes.HasPrefix(b, []byte(s.prefix)) {
+			return s.ext
 		}
 	}
-	return res
+	return ""
 }
 
+var _ MetadataExt = &metadataMP4{}
+
 // metadataMP4 is the implementation of Metadata for MP4 tag (atom) data.
 type metadataMP4 struct {
+	warnings
+	unknownTags
+	rc       *readContext // the options the atoms are read with
 	fileType FileType
+	brands   []string // from the ftyp atom, major brand first
 	data     map[string]interface{}
 	duration int
+
+	timeScale uint32               // of the movie (from mvhd)
+	fragments mp4Fragments         // durations from the atoms of fragmented files
+	tracks    map[uint32]*mp4Track // by track ID, for QuickTime chapter tracks
+	pictures  []*Picture           // all of the pictures in covr atoms
+	audio     *AudioProperties     // of the first audio track (from stsd)
+	video     bool                 // there is a video track
+	moov      bool                 // the moov atom has been read
+	mediaSize int64                // total size of the mdat atoms
+
+	annotations map[string]interface{} // QuickTime text annotations in udta
 }
 
 // ReadAtoms reads MP4 metadata atoms from the io.ReadSeeker into a Metadata, returning
-// non-nil error if there was a problem.
+// non-nil error if there was a problem.  If the data ends part way through an atom then
+// the atoms read so far are returned with ErrTruncated (in Lenient mode), unless the moov
+// atom has been read and only the media data is missing, which is a warning.
+//
+// Only the atom headers and metadata are read: the media data (mdat) and other atoms
+// which are not needed are skipped by seeking, and the moov atom is read with a single
+// read (see DefaultMP4ReadAhead).  So a file whose moov atom follows its media data (as
+// in files which are not "fast start") can be probed efficiently through a remote
+// io.ReadSeeker, such as an HTTP range reader: the bytes read are at most the headers of
+// the top-level atoms and the contents of the ftyp and moov atoms (and the samples of a
+// QuickTime chapter track, if there is one).
 func ReadAtoms(r io.ReadSeeker) (Metadata, error) {
+	rc := defaultReadContext()
+	return readMP4Atoms(rc.reader(r), rc)
+}
+
+// readMP4Atoms is ReadAtoms with the options of rc.
+func readMP4Atoms(r io.ReadSeeker, rc *readContext) (Metadata, error) {
 	m := &metadataMP4{
+		rc:       rc,
 		data:     make(map[string]interface{}),
 		fileType: UnknownFileType,
 	}
-	err := m.readAtoms(r)
+	start := tell(r)
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	err = m.readAtoms(r, "", size, size)
+	for name, v := range m.annotations {
+		// QuickTime annotations are only used for the tags which are not in ilst.
+		if _, ok := m.data[name]; !ok {
+			m.data[name] = v
+		}
+	}
+	if m.duration == 0 {
+		m.duration = int(m.fragments.duration(m.timeScale))
+	}
+	if m.fileType == UnknownFileType && !m.video && m.audio != nil && m.audio.Codec != "ALAC" {
+		// The brands are generic (such as "mp42" or "isom"), so use the codec.
+		m.fileType = M4A
+	}
+	if err == nil && m.data[AtomChapters] == nil {
+		// Nero chapters (chpl) are preferred to a QuickTime chapter track.
+		if cerr := m.readChapterTrack(r); cerr != nil {
+			if cerr == ErrMetadataTooLarge {
+				return m, cerr
+			}
+			err = m.rc.structureViolation(&m.warnings, MP4, "chapter track", -1, cerr)
+		}
+	}
 
 	return m, err
 }
 
-func (m *metadataMP4) readAtoms(r io.ReadSeeker) error {
+// readAtoms reads the atoms from the current position up to end (the end of the containing
+// atom named parent, limited to the end of the file at fileSize).
+func (m *metadataMP4) readAtoms(r io.ReadSeeker, parent string, end, fileSize int64) error {
 	for {
+		start := tell(r)
+		if start < 0 || start >= end {
+			return nil
+		}
+		if end-start < 8 {
+			if end == fileSize && parent == "" && m.moov {
+				// Lenient: the metadata is complete, only the media data is missing.
+				return m.rc.structureViolation(&m.warnings, MP4, "atom header", start, ErrTruncated)
+			}
+			if end == fileSize {
+				return m.rc.truncated(MP4, start)
+			}
+			return m.rc.violation(&m.warnings, MP4, start, fmt.Errorf("%d trailing bytes in atom", end-start))
+		}
+
 		name, size, err := readAtomHeader(r)
 		if err != nil {
-			if err == io.EOF {
-				return nil
+			if isTruncation(err) {
+				// Lenient: return the atoms read before the truncated one.
+				return m.rc.truncated(MP4, start)
 			}
-			return err
+			return parseError(MP4, "atom header", start, err)
+		}
+		atom := fmt.Sprintf("atom %q", name)
+
+		// The size includes the header, and may be 0 (the atom extends to the end of the
+		// file, or its container) or 1 (a 64-bit size follows the header).
+		atomSize, headerSize := int64(size), int64(8)
+		switch size {
+		case 0:
+			atomSize = end - start
+		case 1:
+			b, err := readBytes(r, 8)
+			if err != nil {
+				if isTruncation(err) {
+					return m.rc.truncated(MP4, start)
+				}
+				return parseError(MP4, atom, start, err)
+			}
+			atomSize, headerSize = int64(binary.BigEndian.Uint64(b)), 16
+		}
+		if atomSize < headerSize {
+			// Lenient: we cannot find the next atom, so stop here.
+			return m.rc.structureViolation(&m.warnings, MP4, atom, start, fmt.Errorf("invalid size %d", atomSize))
+		}
+
+		atomEnd, cut := start+atomSize, false
+		if atomEnd > end || atomEnd < start {
+			if end < fileSize {
+				// Lenient: assume the atom ends with its container.
+				if err := m.rc.structureViolation(&m.warnings, MP4, atom, start, errors.New("extends beyond its container")); err != nil {
+					return err
+				}
+			} else {
+				cut = true // the file is truncated
+			}
+			atomEnd = end
 		}
 
 		switch name {
 		case "meta":
-			// next_item_id (int32)
-			_, err := readBytes(r, 4)
+			handler, err := m.readMetaHeader(r, start+headerSize, atomEnd)
 			if err != nil {
-				return err
+				if isTruncation(err) {
+					return m.rc.truncated(MP4, start)
+				}
+				return parseError(MP4, atom, start, err)
+			}
+			if handler != "" && handler != "mdir" {
+				// Not iTunes metadata (such as ID3 or MPEG-7 metadata), so skip it.
+				if cut {
+					return m.rc.truncated(MP4, start)
+				}
+				if _, err := r.Seek(atomEnd, io.SeekStart); err != nil {
+					return err
+				}
+				continue
 			}
 			fallthrough
 
-		case "moov", "udta", "ilst":
-			return m.readAtoms(r)
+		case "moov", "udta", "ilst", "trak", "mdia", "minf", "stbl", "mvex", "moof", "traf", "tref":
+			cr := r
+			if name == "moov" && parent == "" {
+				cr, err = readAhead(r, start+headerSize, atomEnd)
+				if err != nil {
+					return parseError(MP4, atom, start, err)
+				}
+			}
+			if err := m.readAtoms(cr, name, atomEnd, fileSize); err != nil {
+				return err
+			}
+			if cut {
+				return m.rc.truncated(MP4, start)
+			}
+			if name == "moov" {
+				m.moov = true
+			}
+			if _, err := r.Seek(atomEnd, io.SeekStart); err != nil {
+				return err
+			}
+			continue
+		}
 
-		case "mvhd":
+		if name == "mdat" {
+			m.mediaSize += atomEnd - start - headerSize
+		}
+		if cut {
+			if m.moov && (name == "mdat" || name == "free" || name == "skip" || name == "wide") {
+				// Lenient: the metadata is complete, only the media data is missing.
+				return m.rc.structureViolation(&m.warnings, MP4, atom, start, ErrTruncated)
+			}
+			return m.rc.truncated(MP4, start)
+		}
+		if atomEnd-start-headerSize > math.MaxUint32-8 {
+			// Too large to be a metadata atom.
+			if _, err := r.Seek(atomEnd, io.SeekStart); err != nil {
+				return err
+			}
+			continue
+		}
+		// The size of the atom as if it had a 32-bit size in its header.
+		size = uint32(atomEnd - start - headerSize + 8)
+
+		if name == "mvhd" {
 			err := m.readMHVDAtom(r, size)
 			if err != nil {
+				if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := r.Seek(atomEnd, io.SeekStart); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "ftyp" || name == "stsd" {
+			b, err := readBytes(r, uint(size-8))
+			if err == nil {
+				if name == "ftyp" {
+					err = m.readFileType(b)
+				} else {
+					err = m.readSampleDescription(b)
+				}
+			}
+			if err != nil {
+				if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if parent == "stbl" && mp4SampleTables[name] {
+			// The samples of a chapter track are read once all of the tracks are known.
+			m.track(m.fragments.track).tables[name] = [2]int64{start + headerSize, atomEnd - start - headerSize}
+			if _, err := r.Seek(atomEnd, io.SeekStart); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "uuid" && size >= 24 {
+			// The extended type (a UUID) follows the header.
+			b, err := readBytes(r, 16)
+			if err == nil && string(b) == xmpUUID {
+				if b, err = readBytes(r, uint(size-24)); err == nil {
+					err = m.readXMP(b)
+				}
+			}
+			if err != nil {
+				if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := r.Seek(atomEnd, io.SeekStart); err != nil {
 				return err
 			}
+			continue
+		}
 
+		if parent == "udta" && name[0] == 0xa9 {
+			b, err := readBytes(r, uint(size-8))
+			if err == nil && isAnnotation(b) {
+				err = m.readAnnotation(name, b)
+			} else if err == nil {
+				// An item outside ilst: read it as usual.
+				b = nil
+				if _, err := r.Seek(start+headerSize, io.SeekStart); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if b != nil {
+				continue
+			}
+		}
+
+		if parent == "udta" && mp4AssetAtoms[name] {
+			b, err := readBytes(r, uint(size-8))
+			if err == nil {
+				err = m.readAssetAtom(name, b)
+			}
+			if err != nil {
+				if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if parent == "udta" && name == "Xtra" {
+			b, err := readBytes(r, uint(size-8))
+			if err == nil {
+				err = m.readXtra(b)
+			}
+			if err != nil {
+				if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if parent == "tref" && name == "chap" {
+			err := m.readChapterReference(r, size-8)
+			if err != nil {
+				if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if mp4FragmentAtoms[name] {
+			b, err := readBytes(r, uint(size-8))
+			if err == nil {
+				err = m.fragments.read(name, b)
+			}
+			if err != nil {
+				if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if name == AtomChapters {
+			err := m.readChapterList(r, size-8)
+			if err != nil {
+				if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+					return err
+				}
+			}
 			continue
 		}
 
 		_, ok := atoms[name]
 		var data []string
-		if name == "----" {
+		if name == AtomCustom {
 			name, data, err = readCustomAtom(r, size)
 			if err != nil {
-				return err
+				if err := m.skipInvalidAtom(r, AtomCustom, start, atomEnd, err); err != nil {
+					return err
+				}
+				continue
 			}
 
-			if name != "----" {
+			if name != AtomCustom {
 				ok = true
-				size = 0 // already read data
+			} else if _, err := r.Seek(atomEnd, io.SeekStart); err != nil {
+				return err
 			}
 		}
 
 		if !ok {
-			_, err := r.Seek(int64(size-8), io.SeekCurrent)
+			if parent == "ilst" && name != AtomCustom {
+				if err := m.readUnknownAtom(r, name, start, atomEnd-start-headerSize); err != nil {
+					if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+						return err
+					}
+				}
+			}
+			_, err := r.Seek(atomEnd, io.SeekStart)
 			if err != nil {
 				return err
 			}
@@ -142,104 +515,507 @@ func (m *metadataMP4) readAtoms(r io.ReadSeeker) error {
 
 		err = m.readAtomData(r, name, size-8, data)
 		if err != nil {
+			if err := m.skipInvalidAtom(r, name, start, atomEnd, err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readMetaHeader reads the start of the meta atom whose contents are between start and
+// end, returning the handler type from its hdlr atom ("" if it has none) and leaving r at
+// its first child.  meta is a full atom, so 4 bytes of version and flags precede its
+// children, but QuickTime files omit them.  The hdlr atom is usually the first child, but
+// some encoders write it after the others.
+func (m *metadataMP4) readMetaHeader(r io.ReadSeeker, start, end int64) (string, error) {
+	n := end - start
+	if n < 4 {
+		n = 4
+	} else if n > 12 {
+		n = 12
+	}
+	b, err := readBytes(r, uint(n))
+	if err != nil {
+		return "", err
+	}
+	childStart := start + 4
+	if len(b) >= 8 && string(b[4:8]) == "hdlr" {
+		childStart = start
+	}
+
+	var handler string
+	for pos := childStart; end-pos >= 8; {
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return "", err
+		}
+		name, size, err := readAtomHeader(r)
+		if err != nil || size < 8 {
+			// Invalid children are reported when they are read.
+			break
+		}
+		if name == "hdlr" {
+			// version and flags, pre_defined (int32), handler_type
+			if b, err := readBytes(r, 12); err == nil && size >= 20 {
+				handler = string(b[8:12])
+			}
+			break
+		}
+		pos += int64(size)
+	}
+	_, err = r.Seek(childStart, io.SeekStart)
+	return handler, err
+}
+
+// readUnknownAtom records the unrecognized metadata item atom at offset start, whose
+// contents are size bytes from the current position (see ReadOptions.UnknownTagPolicy).
+func (m *metadataMP4) readUnknownAtom(r io.Reader, name string, start, size int64) error {
+	var b []byte
+	if m.rc.UnknownTagPolicy == CaptureUnknown {
+		var err error
+		if b, err = readBytes(r, uint(size)); err != nil {
 			return err
 		}
 	}
+	m.unknownTags.add(m.rc.UnknownTagPolicy, MP4, name, start, size, b)
+	return nil
+}
+
+// skipInvalidAtom handles an error reading the atom at offset start.  In Lenient mode
+// the reader is positioned at the end of the atom so that reading can continue.
+func (m *metadataMP4) skipInvalidAtom(r io.ReadSeeker, name string, start, end int64, err error) error {
+	if err == ErrMetadataTooLarge {
+		return err
+	}
+	if isTruncation(err) {
+		return m.rc.truncated(MP4, start)
+	}
+	if err := m.rc.structureViolation(&m.warnings, MP4, fmt.Sprintf("atom %q", name), start, err); err != nil {
+		return err
+	}
+	_, err = r.Seek(end, io.SeekStart)
+	return err
 }
 
 func (m *metadataMP4) readAtomData(r io.ReadSeeker, name string, size uint32, processedData []string) error {
-	var b []byte
-	var err error
-	var contentType string
 	if len(processedData) > 0 {
-		b = []byte(strings.Join(processedData, ";")) // add delimiter if multiple data fields
-		contentType = "text"
-	} else {
-		// read the data
-		b, err = readBytes(r, uint(size))
+		m.set(name, textValues(processedData, m.rc))
+		return nil
+	}
+
+	// read the data
+	b, err := readBytes(r, uint(size))
+	if err != nil {
+		return err
+	}
+	values, err := dataAtoms(b)
+	if err != nil {
+		return err
+	}
+
+	if name == AtomPicture {
+		// A covr atom can contain several pictures (such as front and back covers).
+		var pictures []*Picture
+		for _, v := range values {
+			data, err := decodeAtomData(name, v, m.rc)
+			if err != nil {
+				return err
+			}
+			if p, ok := data.(*Picture); ok {
+				pictures = append(pictures, p)
+			}
+		}
+		if len(pictures) > 0 {
+			m.set(name, pictures[0])
+			m.pictures = append(m.pictures, pictures...)
+		}
+		return nil
+	}
+
+	if name == AtomTrack || name == AtomDisc {
+		b, err := atomDataValue(values[0])
 		if err != nil {
 			return err
 		}
-		if len(b) < 8 {
-			return fmt.Errorf("invalid encoding: expected at least %d bytes, got %d", 8, len(b))
+		if len(b) < 4 {
+			return fmt.Errorf("invalid encoding: expected at least %d bytes, for track and disk numbers, got %d", 4, len(b))
 		}
 
-		// "data" + size (4 bytes each)
-		b = b[8:]
+		// 2 bytes padding, then the 16-bit number and total (which some taggers omit).
+		total := 0
+		if len(b) >= 6 {
+			total = int(binary.BigEndian.Uint16(b[4:6]))
+		}
+		m.set(name, int(binary.BigEndian.Uint16(b[2:4])))
+		m.set(name+"_count", total)
+		return nil
+	}
 
-		if len(b) < 3 {
-			return fmt.Errorf("invalid encoding: expected at least %d bytes, for class, got %d", 3, len(b))
+	// Localized values are kept as "name@locale" (such as "\xa9nam@fra-FR").
+	values, locales, localized := dataLocales(values)
+	data, err := decodeAtomValues(name, values, m.rc)
+	if err != nil {
+		return err
+	}
+	m.set(name, data)
+	for _, locale := range locales {
+		data, err := decodeAtomValues(name, localized[locale], m.rc)
+		if err != nil {
+			return err
 		}
-		class := getInt(b[1:4])
-		var ok bool
-		contentType, ok = atomTypes[class]
-		if !ok {
-			return fmt.Errorf("invalid content type: %v (%x) (%x)", class, b[1:4], b)
+		m.set(name+"@"+locale, data)
+	}
+	return nil
+}
+
+// decodeAtomValues decodes the contents of the data atoms of the named item atom.  Several
+// text data atoms (such as multiple artists) are returned as a []string.
+func decodeAtomValues(name string, values [][]byte, rc *readContext) (interface{}, error) {
+	var texts []string
+	var data interface{}
+	for i, v := range values {
+		x, err := decodeAtomData(name, v, rc)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			data = x
+		}
+		if s, ok := x.(string); ok {
+			texts = append(texts, s)
 		}
+	}
+	if len(values) > 1 && len(texts) == len(values) {
+		data = texts
+	}
+	return data, nil
+}
 
-		// 4: atom version (1 byte) + atom flags (3 bytes)
-		// 4: NULL (usually locale indicator)
-		if len(b) < 8 {
-			return fmt.Errorf("invalid encoding: expected at least %d bytes, for atom version and flags, got %d", 8, len(b))
+// dataLocales splits the contents of the data atoms of an item by their locale, returning
+// those of the default locale (or if there are none, of the first locale), then the other
+// locales in the order they appear, and their data atoms.
+func dataLocales(values [][]byte) (preferred [][]byte, locales []string, localized map[string][][]byte) {
+	localized = make(map[string][][]byte)
+	for _, v := range values {
+		locale := ""
+		if len(v) >= 8 {
+			locale = dataLocale(v[4:8])
+		}
+		if _, ok := localized[locale]; !ok {
+			locales = append(locales, locale)
 		}
-		b = b[8:]
+		localized[locale] = append(localized[locale], v)
 	}
 
-	if name == "trkn" || name == "disk" {
-		if len(b) < 6 {
-			return fmt.Errorf("invalid encoding: expected at least %d bytes, for track and disk numbers, got %d", 6, len(b))
+	first := locales[0]
+	if _, ok := localized[""]; ok {
+		first = ""
+	}
+	preferred = localized[first]
+	for i, locale := range locales {
+		if locale == first {
+			locales = append(locales[:i:i], locales[i+1:]...)
+			break
 		}
+	}
+	return preferred, locales, localized
+}
 
-		m.data[name] = int(b[3])
-		m.data[name+"_count"] = int(b[5])
-		return nil
+// dataLocale returns the locale indicator of a data atom: the language and country, such
+// as "fra-FR", or "" for the default locale.  The country (16 bits) is either an ISO 3166
+// code or an index into the QuickTime country list, and the language (16 bits) is either
+// an ISO 639-2/T code packed as three 5-bit letters, or an index into the QuickTime
+// language list.
+func dataLocale(b []byte) string {
+	var country, language string
+	switch c := binary.BigEndian.Uint16(b); {
+	case isUpperASCII(b[0]) && isUpperASCII(b[1]):
+		country = string(b[:2])
+	case c != 0:
+		country = strconv.Itoa(int(c))
+	}
+	switch l := binary.BigEndian.Uint16(b[2:]); {
+	case l >= 0x400 && l < 0x8000:
+		language = string([]byte{byte(l>>10&0x1f) + 0x60, byte(l>>5&0x1f) + 0x60, byte(l&0x1f) + 0x60})
+	case l != 0:
+		language = strconv.Itoa(int(l))
+	}
+	if country == "" || language == "" {
+		return language + country
+	}
+	return language + "-" + country
+}
+
+// isUpperASCII returns true if c is an upper case ASCII letter.
+func isUpperASCII(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+// textValues returns the text values, sanitized, as a string if there is one value and as a
+// []string otherwise.
+func textValues(values []string, rc *readContext) interface{} {
+	if len(values) == 1 {
+		return rc.sanitizeText(values[0])
+	}
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = rc.sanitizeText(v)
+	}
+	return result
+}
+
+// dataAtoms returns the contents of the data atoms in b, the contents of an item atom.
+// A data atom with an invalid size is assumed to extend to the end of the item.
+func dataAtoms(b []byte) ([][]byte, error) {
+	var values [][]byte
+	var first []byte
+	for len(b) > 0 {
+		if len(b) < 8 {
+			if first != nil {
+				break // trailing bytes
+			}
+			return nil, fmt.Errorf("invalid encoding: expected at least %d bytes, got %d", 8, len(b))
+		}
+		size := int(binary.BigEndian.Uint32(b))
+		if size < 8 || size > len(b) {
+			size = len(b)
+		}
+		if first == nil {
+			first = b[8:size]
+		}
+		if string(b[4:8]) == "data" {
+			values = append(values, b[8:size])
+		}
+		b = b[size:]
+	}
+	if len(values) == 0 {
+		// Lenient: use the first child, whatever its name.
+		values = append(values, first)
+	}
+	return values, nil
+}
+
+// atomDataValue returns the value from the contents of a data atom.
+func atomDataValue(b []byte) ([]byte, error) {
+	// 4: atom version (1 byte) + atom flags (3 bytes), which give the class
+	// 4: locale indicator (country and language), 0 for the default locale
+	if len(b) < 3 {
+		return nil, fmt.Errorf("invalid encoding: expected at least %d bytes, for class, got %d", 3, len(b))
+	}
+	if len(b) < 8 {
+		return nil, fmt.Errorf("invalid encoding: expected at least %d bytes, for atom version and flags, got %d", 8, len(b))
 	}
+	return b[8:], nil
+}
 
-	if name == "chpl" {
-		contentType = "chapter"
+// decodeAtomData decodes the contents of a data atom of the named item atom.
+func decodeAtomData(name string, b []byte, rc *readContext) (interface{}, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("invalid encoding: expected at least %d bytes, for class, got %d", 4, len(b))
+	}
+	class := getInt(b[1:4])
+	contentType, ok := atomTypes[class]
+	if !ok {
+		// Data of the classes which are not decoded (such as S/JIS text, GIF images,
+		// UUIDs or dates) is stored as opaque binary data.
+		contentType = "binary"
+	}
+	b, err := atomDataValue(b)
+	if err != nil {
+		return nil, err
 	}
 
 	if contentType == "implicit" {
-		if name == "covr" {
-			if bytes.HasPrefix(b, pngHeader) {
-				contentType = "png"
+		switch name {
+		case AtomPodcastURL, AtomEpisodeGUID:
+			// iTunes writes these as implicit (binary) data, but they are text.
+			contentType = "text"
+		case AtomGenreID:
+			contentType = "uint"
+		}
+		if name == AtomPicture {
+			if t := detectImageType(b); t != "" {
+				contentType = t
 			}
-			// TODO(dhowden): Detect JPEG formats too (harder).
 		}
 	}
 
-	var data interface{}
 	switch contentType {
 	case "implicit":
-		if _, ok := atoms[name]; ok {
-			return fmt.Errorf("unhandled implicit content type for required atom: %q", name)
-		}
-		return nil
+		return nil, fmt.Errorf("unhandled implicit content type for required atom: %q", name)
 
 	case "text":
-		data = string(b)
+		return rc.sanitizeText(string(b)), nil
 
-	case "chapter":
-		data, err = parseChapters(b)
+	case "utf16":
+		t, err := decodeUTF16(b, binary.BigEndian)
 		if err != nil {
-			return nil
+			return nil, err
 		}
+		return rc.sanitizeText(t), nil
 
-	case "uint8":
+	case "int", "uint":
 		if len(b) < 1 {
-			return fmt.Errorf("invalid encoding: expected at least %d bytes, for integer tag data, got %d", 1, len(b))
+			return nil, fmt.Errorf("invalid encoding: expected at least %d bytes, for integer tag data, got %d", 1, len(b))
+		}
+		// NB: the width of the integer is given by the data length (e.g. tmpo is 16 bits).
+		if len(b) > 8 {
+			b = b[:8]
+		}
+		x := getInt(b)
+		if contentType == "int" && len(b) < 8 && b[0]&0x80 != 0 {
+			x -= 1 << (8 * uint(len(b)))
+		}
+		return x, nil
+
+	case "float32":
+		if len(b) != 4 {
+			return nil, fmt.Errorf("invalid encoding: expected %d bytes, for float tag data, got %d", 4, len(b))
 		}
-		data = getInt(b[:1])
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
 
-	case "jpeg", "png":
-		data = &Picture{
+	case "float64":
+		if len(b) != 8 {
+			return nil, fmt.Errorf("invalid encoding: expected %d bytes, for float tag data, got %d", 8, len(b))
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+
+	case "jpeg", "png", "gif", "bmp":
+		return &Picture{
 			Ext:      contentType,
 			MIMEType: "image/" + contentType,
 			Data:     b,
+		}, nil
+	}
+	return b, nil
+}
+
+// set sets the value of the named atom, applying the DuplicatePolicy of m.rc if it has already
+// been read.
+func (m *metadataMP4) set(name string, v interface{}) {
+	if old, ok := m.data[name]; ok {
+		v = m.rc.mergeDuplicate(old, v)
+	}
+	m.data[name] = v
+}
+
+// mp4TopLevelAtoms are the atoms which are found at the top level of MP4 files.
+var mp4TopLevelAtoms = map[string]bool{
+	"ftyp": true, "styp": true, "pdin": true, "moov": true, "moof": true, "mfra": true,
+	"mdat": true, "free": true, "skip": true, "wide": true, "meta": true, "uuid": true,
+	"sidx": true, "junk": true,
+}
+
+// isMP4 returns true if r (from its current position) starts with a sequence of top-level
+// MP4 atoms which includes a moov atom, so that MP4 files which do not start with an ftyp
+// atom are recognized.  The position of r is restored.
+func isMP4(r io.ReadSeeker) (bool, error) {
+	start := tell(r)
+	defer r.Seek(start, io.SeekStart)
+
+	pos := start
+	for i := 0; i < 32; i++ {
+		name, size, err := readAtomHeader(r)
+		if err != nil {
+			return false, nil
+		}
+		if !mp4TopLevelAtoms[name] {
+			return false, nil
 		}
+		if name == "moov" {
+			return true, nil
+		}
+
+		atomSize := int64(size)
+		switch size {
+		case 0:
+			return false, nil // extends to the end of the file
+		case 1:
+			b, err := readBytes(r, 8)
+			if err != nil {
+				return false, nil
+			}
+			atomSize = int64(binary.BigEndian.Uint64(b))
+		}
+		if atomSize < 8 {
+			return false, nil
+		}
+		pos += atomSize
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// brandFileTypes maps MP4 brands (with trailing spaces removed) to file types.
+var brandFileTypes = map[string]FileType{
+	"M4A":  M4A,
+	"M4B":  M4B,
+	"M4P":  M4P,
+	"M4V":  M4V,
+	"M4VH": M4V,
+	"M4VP": M4V,
+}
+
+// brandFileType returns the file type of the first of the brands which identifies one.
+func brandFileType(brands []string) FileType {
+	for _, b := range brands {
+		if t, ok := brandFileTypes[strings.TrimRight(b, " ")]; ok {
+			return t
+		}
+	}
+	return UnknownFileType
+}
+
+// readFileType reads the contents of the ftyp atom: the major brand, minor version and
+// compatible brands.
+func (m *metadataMP4) readFileType(b []byte) error {
+	if len(b) < 8 || len(b)%4 != 0 {
+		return fmt.Errorf("invalid size %d", len(b))
+	}
+	m.brands = []string{string(b[:4])}
+	for i := 8; i < len(b); i += 4 {
+		m.brands = append(m.brands, string(b[i:i+4]))
 	}
-	m.data[name] = data
+	if m.fileType != ALAC && m.fileType != M4P {
+		m.fileType = brandFileType(m.brands)
+	}
+	return nil
+}
 
+// readSampleDescription reads the contents of an stsd atom to detect Apple Lossless audio
+// and the properties of the audio stream: version and flags (4 bytes), the number of
+// entries (4 bytes), then the entries, which start with their size (4 bytes) and format.
+func (m *metadataMP4) readSampleDescription(b []byte) error {
+	if len(b) < 16 {
+		return nil
+	}
+	format := string(b[12:16])
+	if t := m.track(m.fragments.track); t.format == "" {
+		t.format = format
+	}
+	switch format {
+	case "alac":
+		m.fileType = ALAC
+	case "drms":
+		m.fileType = M4P // FairPlay protected AAC
+	}
+	if mp4VideoFormats[format] {
+		m.video = true
+	}
+	if _, ok := mp4AudioCodecs[format]; !ok || m.audio != nil {
+		// Not audio (such as the text of a chapter track), or not the first audio track.
+		return nil
+	}
+	size := binary.BigEndian.Uint32(b[8:12])
+	if size < 8 || int64(size) > int64(len(b)-8) {
+		return fmt.Errorf("sample entry %q has invalid size %d", format, size)
+	}
+	p, err := readAudioSampleEntry(format, b[16:8+size])
+	if err != nil {
+		return err
+	}
+	m.audio = p
 	return nil
 }
 
@@ -290,7 +1066,10 @@ func (m *metadataMP4) readMHVDAtom(r io.ReadSeeker, atomHeaderSize uint32) error
 
 		seekBytesLeft -= 16
 
-		duration = float64(dur) / float64(timeScale)
+		m.timeScale = timeScale
+		if timeScale > 0 {
+			duration = float64(dur) / float64(timeScale)
+		}
 
 	} else {
 		// version 1 uses 64 bit integers for timestamps
@@ -315,7 +1094,10 @@ func (m *metadataMP4) readMHVDAtom(r io.ReadSeeker, atomHeaderSize uint32) error
 
 		seekBytesLeft -= 28
 
-		duration = float64(dur) / float64(timeScale)
+		m.timeScale = timeScale
+		if timeScale > 0 {
+			duration = float64(dur) / float64(timeScale)
+		}
 	}
 
 	m.duration = int(duration)
@@ -327,21 +1109,68 @@ func (m *metadataMP4) readMHVDAtom(r io.ReadSeeker, atomHeaderSize uint32) error
 	return nil
 }
 
+// readAtomHeader reads the 32-bit size and the name of an atom.  The size includes the
+// header, except that 1 means a 64-bit size follows the name and 0 means the atom extends
+// to the end of its container (or the file): callers must handle both.
 func readAtomHeader(r io.ReadSeeker) (name string, size uint32, err error) {
-	err = binary.Read(r, binary.BigEndian, &size)
-	if err != nil {
+	// A single read: each read may be a request when r is a remote file.
+	var b [8]byte
+	if _, err = io.ReadFull(r, b[:]); err != nil {
 		return
 	}
-	name, err = readString(r, 4)
-	return
+	return string(b[4:]), binary.BigEndian.Uint32(b[:4]), nil
+}
+
+// DefaultMP4ReadAhead is the maximum size of a moov atom which is read with a single read
+// rather than atom by atom, which reduces the number of reads (and so requests) when the
+// file is remote, such as when r is an HTTP range reader.  Zero disables it.
+var DefaultMP4ReadAhead int64 = 1 << 20
+
+// offsetReader is an io.ReadSeeker of bytes read from a file at offset base, whose
+// positions are those in the file.
+type offsetReader struct {
+	*bytes.Reader
+	base int64
+}
+
+func (o *offsetReader) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		offset -= o.base
+	}
+	n, err := o.Reader.Seek(offset, whence)
+	return n + o.base, err
+}
+
+// readAhead returns a reader of the contents of the atom from start (the current position
+// of r) to end, read with a single read if it is no larger than DefaultMP4ReadAhead (and
+// the metadata limit), or r otherwise.  The file may end before end.
+func readAhead(r io.ReadSeeker, start, end int64) (io.ReadSeeker, error) {
+	n := end - start
+	if n > DefaultMP4ReadAhead {
+		return r, nil
+	}
+	if l, ok := r.(*limitedReadSeeker); ok && n > l.rc.remaining {
+		// Reading the atom atom by atom may stay within the limit.
+		return r, nil
+	}
+	b := make([]byte, n)
+	k, err := io.ReadFull(r, b)
+	if err != nil && !isTruncation(err) {
+		return nil, err
+	}
+	return &offsetReader{bytes.NewReader(b[:k]), start}, nil
 }
 
 // Generic atom.
 // Should have 3 sub atoms : mean, name and data.
-// We check that mean is "com.apple.iTunes" and we use the subname as
-// the name, and move to the data atom.
+// If mean is "com.apple.iTunes" we use the subname as the name, otherwise
+// the name is "mean:name" (such as "org.musicbrainz:MusicBrainz Track Id"),
+// and move to the data atom.
 // Data atom could have multiple data values, each with a header.
 // If anything goes wrong, we jump at the end of the "----" atom.
+// itunesMean is the namespace (mean) of iTunes custom atoms.
+const itunesMean = "com.apple.iTunes"
+
 func readCustomAtom(r io.ReadSeeker, size uint32) (_ string, data []string, _ error) {
 	subNames := make(map[string]string)
 
@@ -351,14 +1180,31 @@ func readCustomAtom(r io.ReadSeeker, size uint32) (_ string, data []string, _ er
 			return "", nil, err
 		}
 
+		// As in readAtoms, size 0 extends to the end of the containing atom and size 1
+		// means a 64-bit size follows the header.
+		headerSize := uint32(8)
+		switch subSize {
+		case 0:
+			subSize = size - 8
+		case 1:
+			large, err := readUint64BigEndian(r)
+			if err != nil {
+				return "", nil, err
+			}
+			if large > uint64(size-8) {
+				return "", nil, errors.New("--- invalid size")
+			}
+			subSize, headerSize = uint32(large), 16
+		}
+
 		// Remove the size of the atom from the size counter
-		if size >= subSize {
+		if subSize >= headerSize && size >= subSize {
 			size -= subSize
 		} else {
 			return "", nil, errors.New("--- invalid size")
 		}
 
-		b, err := readBytes(r, uint(subSize-8))
+		b, err := readBytes(r, uint(subSize-headerSize))
 		if err != nil {
 			return "", nil, err
 		}
@@ -370,7 +1216,11 @@ func readCustomAtom(r io.ReadSeeker, size uint32) (_ string, data []string, _ er
 		case "mean", "name":
 			subNames[subName] = string(b[4:])
 		case "data":
-			data = append(data, string(b[4:]))
+			// 4: class, 4: locale indicator
+			if len(b) < 8 {
+				return "", nil, fmt.Errorf("invalid encoding: expected at least %d bytes, got %d", 8, len(b))
+			}
+			data = append(data, string(b[8:]))
 		}
 	}
 
@@ -380,8 +1230,11 @@ func readCustomAtom(r io.ReadSeeker, size uint32) (_ string, data []string, _ er
 		return "", nil, err
 	}
 
-	if subNames["mean"] != "com.apple.iTunes" || subNames["name"] == "" || len(data) == 0 {
-		return "----", nil, nil
+	if subNames["mean"] == "" || subNames["name"] == "" || len(data) == 0 {
+		return AtomCustom, nil, nil
+	}
+	if subNames["mean"] != itunesMean {
+		return subNames["mean"] + ":" + subNames["name"], data, nil
 	}
 
 	return subNames["name"], data, nil
@@ -390,93 +1243,169 @@ func readCustomAtom(r io.ReadSeeker, size uint32) (_ string, data []string, _ er
 func (metadataMP4) Format() Format        { return MP4 }
 func (m *metadataMP4) FileType() FileType { return m.fileType }
 
-func (m *metadataMP4) Raw() map[string]interface{} { return m.data }
+// Brands returns the brands of an MP4 file (such as "M4A " and "isom", from its ftyp atom)
+// with the major brand first, or nil if m is not the metadata of an MP4 file.
+func Brands(m Metadata) []string {
+	if m, ok := m.(*metadataMP4); ok && m.brands != nil {
+		return append([]string(nil), m.brands...)
+	}
+	return nil
+}
+
+// Pictures returns all of the pictures of the metadata: every picture in the covr atoms of
+// an MP4 file or the attached picture (APIC) frames of an ID3v2 tag (such as front and back
+// covers, in the order of the file), or otherwise the result of m.Picture().
+func Pictures(m Metadata) []*Picture {
+	if frames := id3v2FramesOf(m); frames != nil {
+		return id3v2Pictures(frames)
+	}
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		if p := m.Picture(); p != nil {
+			return []*Picture{p}
+		}
+		return nil
+	}
+	var pictures []*Picture
+	for _, p := range mp4.pictures {
+		x := *p
+		x.Data = append([]byte(nil), p.Data...)
+		pictures = append(pictures, &x)
+	}
+	return pictures
+}
+
+// Artists returns the artists of the track: each value of a multi-valued MP4 artist atom, or
+// otherwise m.Artist().  Returns nil if there is no artist.
+func Artists(m Metadata) []string {
+	return fieldValues(m, "artist", m.Artist)
+}
+
+// AlbumArtists returns the album artists of the track, as Artists.
+func AlbumArtists(m Metadata) []string {
+	return fieldValues(m, "album_artist", m.AlbumArtist)
+}
+
+// Composers returns the composers of the track, as Artists.
+func Composers(m Metadata) []string {
+	return fieldValues(m, "composer", m.Composer)
+}
+
+// fieldValues returns the values of the field for MP4 metadata, or otherwise the value
+// returned by get.
+func fieldValues(m Metadata, field string, get func() string) []string {
+	if mp4, ok := m.(*metadataMP4); ok {
+		return mp4.getStrings(Registry.LookupByField(MP4, field))
+	}
+	if v := get(); v != "" {
+		return []string{v}
+	}
+	return nil
+}
+
+func (m *metadataMP4) Raw() map[string]interface{} { return copyRaw(m.data) }
 
 func (m *metadataMP4) getString(n []string) string {
 	for _, k := range n {
-		if x, ok := m.data[k]; ok {
-			return x.(string)
+		switch x := m.data[k].(type) {
+		case string:
+			return x
+		case []string:
+			return strings.Join(x, ";") // add delimiter if multiple data fields
 		}
 	}
 	return ""
 }
 
+// getStrings returns all of the values of the first of the named atoms which is present.
+func (m *metadataMP4) getStrings(n []string) []string {
+	for _, k := range n {
+		switch x := m.data[k].(type) {
+		case string:
+			if x == "" {
+				return nil
+			}
+			return []string{x}
+		case []string:
+			return append([]string(nil), x...)
+		}
+	}
+	return nil
+}
+
 func (m *metadataMP4) getInt(n []string) int {
 	for _, k := range n {
-		if x, ok := m.data[k]; ok {
-			return x.(int)
+		if x, ok := m.data[k].(int); ok {
+			return x
 		}
 	}
 	return 0
 }
 
 func (m *metadataMP4) Title() string {
-	return m.getString(atoms.Name("title"))
+	return m.getString(m.lookup("title"))
 }
 
 func (m *metadataMP4) Artist() string {
-	return m.getString(atoms.Name("artist"))
+	return m.getString(m.lookup("artist"))
 }
 
 func (m *metadataMP4) Album() string {
-	return m.getString(atoms.Name("album"))
+	return m.getString(m.lookup("album"))
 }
 
 func (m *metadataMP4) AlbumArtist() string {
-	return m.getString(atoms.Name("album_artist"))
+	return m.getString(Registry.LookupByField(MP4, "album_artist"))
 }
 
 func (m *metadataMP4) Composer() string {
-	return m.getString(atoms.Name("composer"))
+	return m.getString(Registry.LookupByField(MP4, "composer"))
 }
 
 func (m *metadataMP4) Genre() string {
-	return m.getString(atoms.Name("genre"))
+	if g := m.getString(m.lookup("genre")); g != "" {
+		return g
+	}
+	// Fall back to the numeric genre (an ID3v1 genre index + 1).
+	if x := m.getInt([]string{AtomGenreID}); x > 0 && x <= len(id3v1Genres) {
+		return id3v1Genres[x-1]
+	}
+	return ""
 }
 
 func (m *metadataMP4) Year() int {
-	date := m.getString(atoms.Name("year"))
-	if len(date) >= 4 {
-		year, _ := strconv.Atoi(date[:4])
-		return year
+	if y := parseYear(m.getString(Registry.LookupByField(MP4, "year"))); y != 0 {
+		return y
 	}
-	return 0
+	return m.getInt([]string{"yrrc"})
 }
 
 func (m *metadataMP4) Track() (int, int) {
-	x := m.getInt([]string{"trkn"})
-	if n, ok := m.data["trkn_count"]; ok {
+	x := m.getInt([]string{AtomTrack})
+	if n, ok := m.data[AtomTrackTotal]; ok {
 		return x, n.(int)
 	}
 	return x, 0
 }
 
 func (m *metadataMP4) Disc() (int, int) {
-	x := m.getInt([]string{"disk"})
-	if n, ok := m.data["disk_count"]; ok {
+	x := m.getInt([]string{AtomDisc})
+	if n, ok := m.data[AtomDiscTotal]; ok {
 		return x, n.(int)
 	}
 	return x, 0
 }
 
 func (m *metadataMP4) Lyrics() string {
-	t, ok := m.data["\xa9lyr"]
-	if !ok {
-		return ""
-	}
-	return t.(string)
+	return m.getString([]string{AtomLyrics})
 }
 
 func (m *metadataMP4) Comment() string {
-	t, ok := m.data["\xa9cmt"]
-	if !ok {
-		return ""
-	}
-	return t.(string)
+	return m.getString([]string{AtomComment})
 }
 
 func (m *metadataMP4) Picture() *Picture {
-	v, ok := m.data["covr"]
+	v, ok := m.data[AtomPicture]
 	if !ok {
 		return nil
 	}
@@ -488,48 +1417,140 @@ func (m *metadataMP4) Duration() int {
 	return m.duration
 }
 
+func (m *metadataMP4) BPM() float64 {
+	if bpm := m.getInt([]string{AtomTempo}); bpm > 0 {
+		return float64(bpm)
+	}
+	return parseBPM(m.getString([]string{"BPM", "bpm"}))
+}
+
+func (m *metadataMP4) Key() Key {
+	return ParseKey(m.getString([]string{"initialkey", "KEY", "key"}))
+}
+
+func (m *metadataMP4) Chapters() []Chapter {
+	c, _ := m.data[AtomChapters].([]Chapter)
+	return c
+}
+
+func (m *metadataMP4) Conflicts() []Conflict {
+	return nil
+}
+
 // Chapter represents a chapter with start time, end time, and title.
 type Chapter struct {
 	id        uint8
 	StartTime string
 	EndTime   string
 	Title     string
+	Picture   *Picture // artwork of the chapter (from an MP4 chapter track or ID3v2 CHAP frame), or nil
+	URL       string   // web page of the chapter (from an ID3v2 CHAP frame)
 }
 
-// parseChapters parses chapter marker data from a byte slice.
-func parseChapters(data []byte) ([]Chapter, error) {
+// parseChapterTime parses a chapter time given either in seconds (as in Chapter.StartTime)
+// or as [HH:]MM:SS[.mmm].
+func parseChapterTime(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid chapter time: %q", s)
+	}
 
-	var chapters []Chapter
+	var secs float64
+	for _, p := range parts {
+		x, err := strconv.ParseFloat(p, 64)
+		if err != nil || x < 0 {
+			return 0, fmt.Errorf("invalid chapter time: %q", s)
+		}
+		secs = secs*60 + x
+	}
+	return time.Duration(secs*float64(time.Second) + 0.5), nil
+}
 
-	// Split the string by null character and filter out empty entries
-	separator := []byte{0, 0, 0}
-	sections := bytes.Split(data, separator)
+// formatChapterSeconds formats d in the form used by Chapter.StartTime and Chapter.EndTime.
+func formatChapterSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
 
-	for index, section := range sections {
-		if len(section) < 2 {
-			continue
+// formatChapterTime formats d as HH:MM:SS.mmm.
+func formatChapterTime(d time.Duration) string {
+	ms := d.Round(time.Millisecond) / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// readChapterList reads the contents of a Nero chapter list (chpl) atom.
+func (m *metadataMP4) readChapterList(r io.Reader, size uint32) error {
+	b, err := readBytes(r, uint(size))
+	if err != nil {
+		return err
+	}
+	chapters, err := parseChapters(b, m.rc)
+	if err != nil {
+		return err
+	}
+	m.data[AtomChapters] = chapters
+	return nil
+}
+
+// parseChapters parses the contents of a Nero chapter list (chpl) atom:
+//
+//	version            1 byte
+//	flags              3 bytes
+//	reserved           1 byte (version 1 only)
+//	chapter count      4 bytes (version 1), or 1 byte (version 0)
+//	chapters:
+//	  start time       8 bytes, in units of 100ns
+//	  title length     1 byte
+//	  title            UTF-8 text
+//
+// FFmpeg writes version 1 atoms with 4 reserved bytes and a 1 byte count, which has the
+// same layout for up to 255 chapters.  The end time of each chapter is the start time of
+// the next.
+func parseChapters(b []byte, rc *readContext) ([]Chapter, error) {
+	if len(b) < 5 {
+		return nil, fmt.Errorf("invalid chapter list: expected at least %d bytes, got %d", 5, len(b))
+	}
+	version := b[0]
+	b = b[4:]
+
+	var n int
+	switch version {
+	case 0:
+		n, b = int(b[0]), b[1:]
+
+	case 1:
+		if len(b) < 5 {
+			return nil, fmt.Errorf("invalid chapter list: expected at least %d bytes for chapter count, got %d", 5, len(b))
 		}
+		n, b = int(binary.BigEndian.Uint32(b[1:5])), b[5:]
 
-		startTime := binary.BigEndian.Uint32(section[0:4])
-		title := string(section[6:])
-		result := float64(startTime) * 256 / 10000000
-		rounded := fmt.Sprintf("%.3f", result)
+	default:
+		return nil, fmt.Errorf("unsupported chapter list version: %d", version)
+	}
 
-		if index > 0 {
-			chapters[index-1].EndTime = rounded
-		} else {
-			startTime = 0
-			title = string(section)
-			rounded = "0.000"
+	var chapters []Chapter
+	for i := 0; i < n; i++ {
+		if len(b) < 9 {
+			return nil, fmt.Errorf("invalid chapter list: chapter %d of %d is truncated", i+1, n)
+		}
+		start := binary.BigEndian.Uint64(b[:8])
+		l := int(b[8])
+		b = b[9:]
+		if len(b) < l {
+			return nil, fmt.Errorf("invalid chapter list: title of chapter %d of %d is truncated", i+1, n)
 		}
+		title := rc.sanitizeText(string(b[:l]))
+		b = b[l:]
 
+		startTime := formatChapterSeconds(time.Duration(start) * 100 * time.Nanosecond)
+		if i > 0 {
+			chapters[i-1].EndTime = startTime
+		}
 		chapters = append(chapters, Chapter{
-			id:        uint8(index),
-			StartTime: rounded,
-			EndTime:   "",
+			id:        uint8(i),
+			StartTime: startTime,
 			Title:     title,
 		})
 	}
-
 	return chapters, nil
 }
@@ -0,0 +1,1506 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testM4A returns an M4A file with the given ilst items, followed by the trailing data.
+func testM4A(items [][]byte, trailing ...[]byte) []byte {
+	b := [][]byte{
+		testAtom("ftyp", []byte("M4A \x00\x00\x00\x00")),
+		testAtom("moov", testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst", items...)))),
+	}
+	return bytes.Join(append(b, trailing...), nil)
+}
+
+// testTextItem returns an ilst item atom with a text data atom.
+func testTextItem(name, value string) []byte {
+	return testAtom(name, testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(value)))
+}
+
+func TestReadAtomsSizes(t *testing.T) {
+	album := testTextItem("\xa9alb", "Album")
+	free := testAtom("free", make([]byte, 8))
+
+	// An item with size 0 extends to the end of its container.
+	zero := testTextItem("\xa9nam", "Title")
+	copy(zero, []byte{0, 0, 0, 0})
+
+	// A top-level atom with size 0 extends to the end of the file.
+	mdat := append([]byte{0, 0, 0, 0, 'm', 'd', 'a', 't'}, bytes.Repeat([]byte{0xff}, 32)...)
+
+	// A 64-bit size (1, followed by the size after the name).
+	large := append([]byte{0, 0, 0, 1, 'f', 'r', 'e', 'e', 0, 0, 0, 0, 0, 0, 0, 24}, make([]byte, 8)...)
+
+	// An item with a size smaller than its header.
+	tiny := []byte{0, 0, 0, 4, 0xa9, 'n', 'a', 'm'}
+
+	// An item with a size larger than its container.
+	huge := testTextItem("\xa9nam", "Title")
+	copy(huge, []byte{0xff, 0xff, 0xff, 0xf0})
+
+	tests := []struct {
+		input    []byte
+		title    string
+		warnings int
+	}{
+		{testM4A([][]byte{album, zero}, free), "Title", 0},
+		{testM4A([][]byte{album, testTextItem("\xa9nam", "Title")}, free, mdat), "Title", 0},
+		{append(large, testM4A([][]byte{album, testTextItem("\xa9nam", "Title")})...), "Title", 0},
+		{testM4A([][]byte{album, tiny}, free), "", 1},
+		{testM4A([][]byte{album, huge}, free), "Title", 1},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		testValue(t, "Album", m.Album())
+		if m.Title() != tt.title {
+			t.Errorf("[%d] Title() = %q, expected %q", ii, m.Title(), tt.title)
+		}
+		if len(Warnings(m)) != tt.warnings {
+			t.Errorf("[%d] Warnings() = %v, expected %d warnings", ii, Warnings(m), tt.warnings)
+		}
+	}
+}
+
+// testSparseReader is an io.ReadSeeker of head, followed by gap zero bytes and then tail,
+// for testing files larger than 4GB.
+type testSparseReader struct {
+	head, tail []byte
+	gap, pos   int64
+}
+
+func (s *testSparseReader) Read(p []byte) (int, error) {
+	head, gap := int64(len(s.head)), int64(len(s.head))+s.gap
+	var n int
+	switch {
+	case s.pos < head:
+		n = copy(p, s.head[s.pos:])
+	case s.pos < gap:
+		if int64(len(p)) > gap-s.pos {
+			p = p[:gap-s.pos]
+		}
+		for i := range p {
+			p[i] = 0
+		}
+		n = len(p)
+	case s.pos < gap+int64(len(s.tail)):
+		n = copy(p, s.tail[s.pos-gap:])
+	default:
+		return 0, io.EOF
+	}
+	s.pos += int64(n)
+	return n, nil
+}
+
+func (s *testSparseReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += int64(len(s.head)) + s.gap + int64(len(s.tail))
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+func TestReadAtomsLargeFile(t *testing.T) {
+	const mdatSize = 5 << 30
+
+	ftyp := testAtom("ftyp", []byte("M4B \x00\x00\x00\x00"))
+	mdat := []byte{0, 0, 0, 1, 'm', 'd', 'a', 't', 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(mdat[8:], mdatSize)
+	moov := testM4A([][]byte{testTextItem(AtomTitle, "Title")})[len(ftyp):]
+
+	// A moov atom with a 64-bit size.
+	moov64 := append([]byte{0, 0, 0, 1, 'm', 'o', 'o', 'v', 0, 0, 0, 0, 0, 0, 0, 0}, moov[8:]...)
+	binary.BigEndian.PutUint64(moov64[8:], uint64(len(moov64)))
+
+	// A custom atom whose data atom has a 64-bit size.
+	custom := testAtom(AtomCustom,
+		testAtom("mean", make([]byte, 4), []byte("com.apple.iTunes")),
+		testAtom("name", make([]byte, 4), []byte("MOOD")),
+		[]byte{0, 0, 0, 1, 'd', 'a', 't', 'a', 0, 0, 0, 0, 0, 0, 0, 29, 0, 0, 0, 1, 0, 0, 0, 0}, []byte("Happy"))
+	moovCustom := testM4A([][]byte{testTextItem(AtomTitle, "Title"), custom})[len(ftyp):]
+
+	tests := []struct {
+		tail []byte
+		raw  map[string]interface{}
+	}{
+		{moov, map[string]interface{}{AtomTitle: "Title"}},
+		{moov64, map[string]interface{}{AtomTitle: "Title"}},
+		{moovCustom, map[string]interface{}{AtomTitle: "Title", "MOOD": "Happy"}},
+	}
+
+	for ii, tt := range tests {
+		r := &testSparseReader{head: append(ftyp, mdat...), gap: mdatSize - int64(len(mdat)), tail: tt.tail}
+		m, err := ReadFrom(r)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := m.Raw(); !reflect.DeepEqual(got, tt.raw) {
+			t.Errorf("[%d] Raw() = %v, expected %v", ii, got, tt.raw)
+		}
+		if m.FileType() != M4B {
+			t.Errorf("[%d] FileType() = %v, expected %v", ii, m.FileType(), M4B)
+		}
+	}
+}
+
+// testCountingReader is an io.ReadSeeker which counts the reads from it, and the bytes read.
+type testCountingReader struct {
+	io.ReadSeeker
+	reads, n int
+}
+
+func (c *testCountingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadSeeker.Read(p)
+	c.reads++
+	c.n += n
+	return n, err
+}
+
+func TestReadAtomsBytesRead(t *testing.T) {
+	const mdatSize = 100 << 20
+
+	ftyp := testAtom("ftyp", []byte("M4A \x00\x00\x00\x00"))
+	mdat := []byte{0, 0, 0, 0, 'm', 'd', 'a', 't'}
+	binary.BigEndian.PutUint32(mdat, mdatSize)
+	moov := testM4A([][]byte{testTextItem(AtomTitle, "Title"), testTextItem(AtomArtist, "Artist")})[len(ftyp):]
+	free := testAtom("free", make([]byte, 1000))
+
+	tests := []struct {
+		tail      []byte
+		readAhead int64
+		reads, n  int
+	}{
+		// ReadFrom reads 11 bytes, then the header and contents of ftyp, the header of mdat
+		// and the header and contents of moov.
+		{moov, 1 << 20, 6, 11 + len(ftyp) + 8 + len(moov)},
+		{append(moov, free...), 1 << 20, 7, 11 + len(ftyp) + 8 + len(moov) + 8},
+
+		// Without read ahead, moov is read atom by atom (and the start of meta twice).
+		{moov, 0, 14, 146},
+	}
+
+	defer func(n int64) { DefaultMP4ReadAhead = n }(DefaultMP4ReadAhead)
+	for ii, tt := range tests {
+		DefaultMP4ReadAhead = tt.readAhead
+		r := &testCountingReader{ReadSeeker: &testSparseReader{head: append(ftyp, mdat...), gap: mdatSize - int64(len(mdat)), tail: tt.tail}}
+		m, err := ReadFrom(r)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if m.Title() != "Title" || m.Artist() != "Artist" {
+			t.Errorf("[%d] Title(), Artist() = %q, %q, expected %q, %q", ii, m.Title(), m.Artist(), "Title", "Artist")
+		}
+		if r.reads != tt.reads || r.n != tt.n {
+			t.Errorf("[%d] read %d bytes in %d reads, expected %d bytes in %d reads", ii, r.n, r.reads, tt.n, tt.reads)
+		}
+	}
+}
+
+func TestReadAtomsTrackDisc(t *testing.T) {
+	// testNumberItem returns a trkn or disk item with the given number and total.
+	testNumberItem := func(name string, n, total int) []byte {
+		return testAtom(name, testAtom("data", make([]byte, 8), []byte{0, 0, byte(n >> 8), byte(n), byte(total >> 8), byte(total), 0, 0}))
+	}
+
+	tests := []struct {
+		n, total int
+	}{
+		{1, 10},
+		{255, 0},
+		{300, 1200},
+		{65535, 65535},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{testNumberItem("trkn", tt.n, tt.total), testNumberItem("disk", tt.n, tt.total)})))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if n, total := m.Track(); n != tt.n || total != tt.total {
+			t.Errorf("[%d] Track() = %d, %d, expected %d, %d", ii, n, total, tt.n, tt.total)
+		}
+		if n, total := m.Disc(); n != tt.n || total != tt.total {
+			t.Errorf("[%d] Disc() = %d, %d, expected %d, %d", ii, n, total, tt.n, tt.total)
+		}
+	}
+
+	// Short atoms without the total (or padding).
+	short := [][]byte{
+		testAtom("trkn", testAtom("data", make([]byte, 8), []byte{0, 0, 1, 44})),
+		testAtom("disk", testAtom("data", make([]byte, 8), []byte{0, 0, 0, 2, 0, 3})),
+	}
+	m, err := ReadAtoms(bytes.NewReader(testM4A(short)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n, total := m.Track(); n != 300 || total != 0 {
+		t.Errorf("Track() = %d, %d, expected 300, 0", n, total)
+	}
+	if n, total := m.Disc(); n != 2 || total != 3 {
+		t.Errorf("Disc() = %d, %d, expected 2, 3", n, total)
+	}
+	if len(Warnings(m)) != 0 {
+		t.Errorf("unexpected warnings: %v", Warnings(m))
+	}
+}
+
+func TestReadAtomsChapters(t *testing.T) {
+	// testChapter returns a chapter list entry starting at the given number of seconds.
+	testChapter := func(secs float64, title string) []byte {
+		ts := uint64(secs * 10000000)
+		b := []byte{byte(ts >> 56), byte(ts >> 48), byte(ts >> 40), byte(ts >> 32), byte(ts >> 24), byte(ts >> 16), byte(ts >> 8), byte(ts), byte(len(title))}
+		return append(b, title...)
+	}
+	// testM4AChapters returns an M4A file with the chapter list atom in moov/udta.
+	testM4AChapters := func(chpl ...[]byte) []byte {
+		return bytes.Join([][]byte{
+			testAtom("ftyp", []byte("M4A \x00\x00\x00\x00")),
+			testAtom("moov", testAtom("udta", testAtom("chpl", chpl...), testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem("\xa9alb", "Album"))))),
+		}, nil)
+	}
+
+	intro := testChapter(0, "Intro")
+	one := testChapter(12.5, "Chapter \x00\x00\x00 One")
+	two := testChapter(3725.25, "Chapter Two")
+
+	// More chapters than fit in a 1 byte count.
+	many := [][]byte{{1, 0, 0, 0, 0, 0, 0, 300 >> 8, 300 & 0xff}}
+	var manyChapters []Chapter
+	for i := 0; i < 300; i++ {
+		many = append(many, testChapter(float64(i)*1.5, strconv.Itoa(i+1)))
+		c := Chapter{StartTime: fmt.Sprintf("%.3f", float64(i)*1.5), Title: strconv.Itoa(i + 1)}
+		if i < 299 {
+			c.EndTime = fmt.Sprintf("%.3f", float64(i+1)*1.5)
+		}
+		manyChapters = append(manyChapters, c)
+	}
+
+	tests := []struct {
+		input    []byte
+		chapters []Chapter
+		warnings int
+	}{
+		// FFmpeg: version 1, 4 reserved bytes and a 1 byte count.
+		{
+			testM4AChapters([]byte{1, 0, 0, 0, 0, 0, 0, 0, 3}, intro, one, two),
+			[]Chapter{
+				{StartTime: "0.000", EndTime: "12.500", Title: "Intro"},
+				{StartTime: "12.500", EndTime: "3725.250", Title: "Chapter  One"},
+				{StartTime: "3725.250", Title: "Chapter Two"},
+			},
+			0,
+		},
+		// Nero: version 1, 1 reserved byte and a 4 byte count.
+		{
+			testM4AChapters([]byte{1, 0, 0, 0, 0, 0, 0, 0, 2}, one, two),
+			[]Chapter{
+				{StartTime: "12.500", EndTime: "3725.250", Title: "Chapter  One"},
+				{StartTime: "3725.250", Title: "Chapter Two"},
+			},
+			0,
+		},
+		{
+			testM4AChapters(many...),
+			manyChapters,
+			0,
+		},
+		// Version 0: 1 byte count.
+		{
+			testM4AChapters([]byte{0, 0, 0, 0, 1}, two),
+			[]Chapter{{StartTime: "3725.250", Title: "Chapter Two"}},
+			0,
+		},
+		// Declared count larger than the number of chapters.
+		{
+			testM4AChapters([]byte{1, 0, 0, 0, 0, 0, 0, 0, 3}, intro, one),
+			nil,
+			1,
+		},
+		// Unsupported version.
+		{
+			testM4AChapters([]byte{2, 0, 0, 0, 0, 0, 0, 0, 1}, intro),
+			nil,
+			1,
+		},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		testValue(t, "Album", m.Album())
+		got := Chapters(m)
+		if len(got) != len(tt.chapters) {
+			t.Errorf("[%d] Chapters() = %v, expected %v", ii, got, tt.chapters)
+			continue
+		}
+		for i, c := range got {
+			c.id = 0
+			if c != tt.chapters[i] {
+				t.Errorf("[%d] Chapters()[%d] = %v, expected %v", ii, i, c, tt.chapters[i])
+			}
+		}
+		if len(Warnings(m)) != tt.warnings {
+			t.Errorf("[%d] Warnings() = %v, expected %d warnings", ii, Warnings(m), tt.warnings)
+		}
+	}
+}
+
+func TestReadAtomsFileType(t *testing.T) {
+	ilst := testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem("\xa9nam", "Title"))))
+	stsd := func(format string) []byte {
+		entry := testAtom(format, make([]byte, 28))
+		return testAtom("trak", testAtom("mdia", testAtom("minf", testAtom("stbl",
+			testAtom("stsd", []byte{0, 0, 0, 0, 0, 0, 0, 1}, entry)))))
+	}
+
+	tests := []struct {
+		ftyp     string // contents of the ftyp atom
+		trak     []byte
+		fileType FileType
+		brands   []string
+	}{
+		{"M4A \x00\x00\x00\x00", nil, M4A, []string{"M4A "}},
+		{"isom\x00\x00\x02\x00iso2M4B ", nil, M4B, []string{"isom", "iso2", "M4B "}},
+		{"M4V \x00\x00\x00\x01M4V mp42isom", stsd("avc1"), M4V, []string{"M4V ", "M4V ", "mp42", "isom"}},
+		{"M4A \x00\x00\x00\x00M4A mp42isom", stsd("alac"), ALAC, []string{"M4A ", "M4A ", "mp42", "isom"}},
+		{"mp42\x00\x00\x00\x00mp42isom", stsd("mp4a"), M4A, []string{"mp42", "mp42", "isom"}},
+		{"mp42\x00\x00\x00\x00mp42isom", append(stsd("avc1"), stsd("mp4a")...), UnknownFileType, []string{"mp42", "mp42", "isom"}},
+		{"mp42\x00\x00\x00\x00mp42isom", stsd("alac"), ALAC, []string{"mp42", "mp42", "isom"}},
+		{"M4A \x00\x00\x00\x00M4A mp42isom", stsd("drms"), M4P, []string{"M4A ", "M4A ", "mp42", "isom"}},
+		{"isom\x00\x00\x00\x00isom", nil, UnknownFileType, []string{"isom", "isom"}},
+	}
+
+	for ii, tt := range tests {
+		b := append(testAtom("ftyp", []byte(tt.ftyp)), testAtom("moov", tt.trak, ilst)...)
+		m, err := ReadAtoms(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if m.FileType() != tt.fileType {
+			t.Errorf("[%d] FileType() = %v, expected %v", ii, m.FileType(), tt.fileType)
+		}
+		if brands := Brands(m); !reflect.DeepEqual(brands, tt.brands) {
+			t.Errorf("[%d] Brands() = %q, expected %q", ii, brands, tt.brands)
+		}
+		if m.Title() != "Title" {
+			t.Errorf("[%d] Title() = %q, expected %q", ii, m.Title(), "Title")
+		}
+	}
+}
+
+func TestProperties(t *testing.T) {
+	u32 := func(x uint32) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, x)
+		return b
+	}
+	// entry returns an audio sample entry (version 0) followed by the child atoms.
+	entry := func(format string, channels, bits uint16, rate uint32, children ...[]byte) []byte {
+		b := make([]byte, 28)
+		binary.BigEndian.PutUint16(b[16:], channels)
+		binary.BigEndian.PutUint16(b[18:], bits)
+		binary.BigEndian.PutUint32(b[24:], rate<<16)
+		return testAtom(format, append([][]byte{b}, children...)...)
+	}
+	// esds returns an esds atom for MPEG-4 audio with the audio object type and bitrate.
+	esds := func(objectType byte, bitrate uint32) []byte {
+		asc := []byte{0x05, 2, objectType << 3, 0x10}
+		config := append(append([]byte{0x04, byte(13 + len(asc)), 0x40, 0x15, 0, 0, 0}, u32(bitrate)...), u32(bitrate)...)
+		config = append(config, asc...)
+		es := append([]byte{0x03, 0x80, 0x80, byte(3 + len(config)), 0, 1, 0}, config...)
+		return testAtom("esds", make([]byte, 4), es)
+	}
+	alac := testAtom("alac", make([]byte, 4), []byte{0, 0, 16, 0, 0, 24, 40, 10, 14, 2, 0, 255},
+		u32(0), u32(2000000), u32(96000))
+	v2 := make([]byte, 28+36)
+	binary.BigEndian.PutUint16(v2[8:], 2)
+	binary.BigEndian.PutUint64(v2[32:], math.Float64bits(48000))
+	binary.BigEndian.PutUint32(v2[40:], 6)
+	binary.BigEndian.PutUint32(v2[48:], 16)
+
+	stsd := func(entries ...[]byte) []byte {
+		return testAtom("trak", testAtom("mdia", testAtom("minf", testAtom("stbl",
+			testAtom("stsd", []byte{0, 0, 0, 0}, u32(uint32(len(entries))), bytes.Join(entries, nil))))))
+	}
+	mvhd := testAtom("mvhd", make([]byte, 12), u32(1000), u32(10000), make([]byte, 80))
+
+	tests := []struct {
+		atoms [][]byte
+		want  *AudioProperties
+	}{
+		{
+			[][]byte{testAtom("moov", mvhd, stsd(entry("mp4a", 2, 16, 44100, esds(2, 256000))))},
+			&AudioProperties{Codec: "AAC", SampleRate: 44100, Channels: 2, Bitrate: 256000},
+		},
+		{
+			[][]byte{testAtom("moov", stsd(entry("mp4a", 2, 16, 48000, esds(5, 64000))))},
+			&AudioProperties{Codec: "HE-AAC", SampleRate: 48000, Channels: 2, Bitrate: 64000},
+		},
+		{
+			[][]byte{testAtom("moov", stsd(entry("alac", 2, 16, 96000, alac)))},
+			&AudioProperties{Codec: "ALAC", SampleRate: 96000, Channels: 2, BitDepth: 24, Bitrate: 2000000},
+		},
+		{
+			[][]byte{testAtom("moov", stsd(testAtom("lpcm", v2)))},
+			&AudioProperties{Codec: "PCM", SampleRate: 48000, Channels: 6, BitDepth: 16},
+		},
+		{
+			// The bitrate is estimated from the size of the media data.
+			[][]byte{testAtom("moov", mvhd, stsd(entry("mp4a", 1, 16, 22050))), testAtom("mdat", make([]byte, 40000))},
+			&AudioProperties{Codec: "AAC", SampleRate: 22050, Channels: 1, Bitrate: 32000},
+		},
+		{
+			// Only the first audio track is used.
+			[][]byte{testAtom("moov", stsd(testAtom("text", make([]byte, 8))), stsd(entry("ac-3", 6, 16, 48000)), stsd(entry("mp4a", 2, 16, 44100)))},
+			&AudioProperties{Codec: "AC-3", SampleRate: 48000, Channels: 6},
+		},
+		{[][]byte{testAtom("moov", stsd(testAtom("avc1", make([]byte, 78))))}, nil},
+		{[][]byte{testAtom("moov")}, nil},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(bytes.Join(append([][]byte{testAtom("ftyp", []byte("M4A \x00\x00\x00\x00"))}, tt.atoms...), nil)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if len(Warnings(m)) != 0 {
+			t.Errorf("[%d] unexpected warnings: %v", ii, Warnings(m))
+		}
+		if got := Properties(m); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%d] Properties() = %+v, expected %+v", ii, got, tt.want)
+		}
+	}
+
+	// An invalid esds atom is a structure violation.
+	b := append(testAtom("ftyp", []byte("M4A \x00\x00\x00\x00")), testAtom("moov", stsd(entry("mp4a", 2, 16, 44100, testAtom("esds", make([]byte, 4), []byte{0x03, 0x7f}))))...)
+	m, err := ReadAtoms(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(Warnings(m)) != 1 || Properties(m) != nil {
+		t.Errorf("Warnings(), Properties() = %v, %v, expected one warning and nil", Warnings(m), Properties(m))
+	}
+	if Properties(&metadataFLAC{}) != nil {
+		t.Errorf("expected nil properties for FLAC metadata")
+	}
+}
+
+func TestReadAtomsXtra(t *testing.T) {
+	u32 := func(x int) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(x))
+		return b
+	}
+	value := func(typ uint16, b []byte) []byte {
+		return append(append(u32(6+len(b)), byte(typ>>8), byte(typ)), b...)
+	}
+	utf16 := func(s string) []byte {
+		var b []byte
+		for _, r := range s + "\x00" {
+			b = append(b, byte(r), 0)
+		}
+		return value(8, b)
+	}
+	field := func(name string, values ...[]byte) []byte {
+		b := append(append(append(u32(len(name)), name...), u32(len(values))...), bytes.Join(values, nil)...)
+		return append(u32(4+len(b)), b...)
+	}
+	xtra := func(fields ...[]byte) []byte {
+		return testAtom("Xtra", fields...)
+	}
+	moov := func(udta ...[]byte) []byte {
+		return testAtom("moov", testAtom("udta", append(udta, testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem(AtomTitle, "Title"))))...))
+	}
+
+	tests := []struct {
+		moov     []byte
+		raw      map[string]interface{}
+		warnings int
+	}{
+		{
+			moov(xtra(
+				field("WM/SharedUserRating", value(19, []byte{75, 0, 0, 0, 0, 0, 0, 0})),
+				field("WM/Mood", utf16("Happy"), utf16("Calm")),
+				field("WM/Publisher", utf16("Label")),
+				field("WM/EncodingTime", value(21, []byte{0x00, 0x80, 0x3e, 0xd5, 0xde, 0xb1, 0x9d, 0x01})),
+				field("WM/MediaClassPrimaryID", value(72, []byte{0xbc, 0x7d, 0x60, 0xd1, 0x23, 0xe3, 0xe2, 0x4b, 0x86, 0xa1, 0x48, 0xa4, 0x2a, 0x28, 0x44, 0x1e})),
+				field("WM/Empty"),
+			)),
+			map[string]interface{}{
+				AtomTitle:                "Title",
+				"WM/SharedUserRating":    75,
+				"WM/Mood":                []string{"Happy", "Calm"},
+				"WM/Publisher":           "Label",
+				"WM/EncodingTime":        time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+				"WM/MediaClassPrimaryID": "{D1607DBC-E323-4BE2-86A1-48A42A28441E}",
+			},
+			0,
+		},
+		{
+			// Fields before an invalid one are kept.
+			moov(xtra(field("WM/Publisher", utf16("Label")), field("WM/Bad", value(3, []byte{1, 2})))),
+			map[string]interface{}{AtomTitle: "Title", "WM/Publisher": "Label"},
+			1,
+		},
+		{moov(xtra(u32(100))), map[string]interface{}{AtomTitle: "Title"}, 1},
+		// Xtra atoms are only read from udta.
+		{testAtom("moov", xtra(field("WM/Publisher", utf16("Label")))), map[string]interface{}{}, 0},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(append(testAtom("ftyp", []byte("M4A \x00\x00\x00\x00")), tt.moov...)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := m.Raw(); !reflect.DeepEqual(got, tt.raw) {
+			t.Errorf("[%d] Raw() = %v, expected %v", ii, got, tt.raw)
+		}
+		if len(Warnings(m)) != tt.warnings {
+			t.Errorf("[%d] Warnings() = %v, expected %d warnings", ii, Warnings(m), tt.warnings)
+		}
+	}
+}
+
+func TestWalkAtoms(t *testing.T) {
+	large := append([]byte{0, 0, 0, 1, 'w', 'i', 'd', 'e', 0, 0, 0, 0, 0, 0, 0, 20}, "data"...)
+	hdlr := testAtom("hdlr", make([]byte, 8), []byte("mdir"), make([]byte, 12))
+	b := bytes.Join([][]byte{
+		testAtom("ftyp", []byte("M4A \x00\x00\x00\x00")),
+		testAtom("moov",
+			testAtom("trak", testAtom("tkhd", make([]byte, 84))),
+			testAtom("udta", testAtom("meta", make([]byte, 4), hdlr, testAtom("ilst", testTextItem(AtomTitle, "Title")))),
+			testAtom("udta", testAtom("meta", hdlr)),
+		),
+		large,
+		testAtom("mdat", []byte("audio")),
+	}, nil)
+
+	var got []string
+	var title []byte
+	err := WalkAtoms(bytes.NewReader(b), func(path string, h AtomHeader, body io.Reader) error {
+		got = append(got, fmt.Sprintf("%s %d %d %d", path, h.Offset, h.Size, h.HeaderSize))
+		if path == "moov/trak" {
+			return SkipAtom
+		}
+		if path == "moov/udta/meta/ilst/"+AtomTitle+"/data" {
+			var err error
+			if title, err = ioutil.ReadAll(body); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"ftyp 0 16 8",
+		"moov 16 245 8",
+		"moov/trak 24 100 8",
+		"moov/udta 124 89 8",
+		"moov/udta/meta 132 81 8",
+		"moov/udta/meta/hdlr 144 32 8",
+		"moov/udta/meta/ilst 176 37 8",
+		"moov/udta/meta/ilst/\xa9nam 184 29 8",
+		"moov/udta/meta/ilst/\xa9nam/data 192 21 8",
+		"moov/udta 213 48 8",
+		"moov/udta/meta 221 40 8",
+		"moov/udta/meta/hdlr 229 32 8",
+		"wide 261 20 16",
+		"mdat 281 13 8",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkAtoms() visited:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if want := "\x00\x00\x00\x01\x00\x00\x00\x00Title"; string(title) != want {
+		t.Errorf("body of data atom = %q, expected %q", title, want)
+	}
+
+	// Errors from the function and invalid atoms stop the walk.
+	errStop := errors.New("stop")
+	if err := WalkAtoms(bytes.NewReader(b), func(string, AtomHeader, io.Reader) error { return errStop }); err != errStop {
+		t.Errorf("WalkAtoms() = %v, expected %v", err, errStop)
+	}
+	invalid := [][]byte{
+		append(testAtom("moov", testAtom("udta")), 0, 0),
+		testAtom("moov", []byte{0, 0, 0, 100, 'u', 'd', 't', 'a'}),
+		testAtom("moov", []byte{0, 0, 0, 4, 'u', 'd', 't', 'a'}),
+	}
+	for ii, b := range invalid {
+		if err := WalkAtoms(bytes.NewReader(b), func(string, AtomHeader, io.Reader) error { return nil }); err == nil {
+			t.Errorf("[%d] expected error", ii)
+		}
+	}
+}
+
+func TestReadAtomsLocales(t *testing.T) {
+	// text returns a text data atom with the locale indicator.
+	text := func(locale []byte, value string) []byte {
+		return testAtom("data", []byte{0, 0, 0, 1}, locale, []byte(value))
+	}
+	def := []byte{0, 0, 0, 0}
+	fr := []byte{'F', 'R', 0x1a, 0x41}
+	ja := []byte{0, 0, 0x2a, 0x0e}
+
+	tests := []struct {
+		item []byte
+		raw  map[string]interface{}
+	}{
+		{
+			testAtom(AtomTitle, text(fr, "Titre"), text(def, "Title"), text(ja, "Taitoru")),
+			map[string]interface{}{AtomTitle: "Title", AtomTitle + "@fra-FR": "Titre", AtomTitle + "@jpn": "Taitoru"},
+		},
+		{
+			testAtom(AtomTitle, text(fr, "Titre"), text(ja, "Taitoru")),
+			map[string]interface{}{AtomTitle: "Titre", AtomTitle + "@jpn": "Taitoru"},
+		},
+		{
+			testAtom(AtomArtist, text(def, "A"), text(fr, "Un"), text(def, "B"), text(fr, "Deux")),
+			map[string]interface{}{AtomArtist: []string{"A", "B"}, AtomArtist + "@fra-FR": []string{"Un", "Deux"}},
+		},
+		{
+			testAtom(AtomTitle, text([]byte{0, 12, 0, 5}, "Title")),
+			map[string]interface{}{AtomTitle: "Title"},
+		},
+		{
+			testAtom(AtomTitle, text(def, "Title"), text([]byte{0, 12, 0, 5}, "Other")),
+			map[string]interface{}{AtomTitle: "Title", AtomTitle + "@5-12": "Other"},
+		},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{tt.item})))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := m.Raw(); !reflect.DeepEqual(got, tt.raw) {
+			t.Errorf("[%d] Raw() = %q, expected %q", ii, got, tt.raw)
+		}
+	}
+}
+
+func TestReadAtomsTruncatedMedia(t *testing.T) {
+	defer func(m ParseMode) { DefaultParseMode = m }(DefaultParseMode)
+
+	ftyp := testAtom("ftyp", []byte("M4A \x00\x00\x00\x00"))
+	moov := testAtom("moov", testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem(AtomAlbum, "Album")))))
+	mdat := testAtom("mdat", make([]byte, 100))
+
+	tests := []struct {
+		input []byte
+		err   error // in Lenient mode
+		album string
+	}{
+		{bytes.Join([][]byte{ftyp, moov, mdat[:50]}, nil), nil, "Album"},
+		{bytes.Join([][]byte{ftyp, moov, mdat[:4]}, nil), nil, "Album"},
+		{bytes.Join([][]byte{ftyp, moov, testAtom("free", make([]byte, 8))[:12]}, nil), nil, "Album"},
+		// The moov atom follows the media data, so the metadata is missing.
+		{bytes.Join([][]byte{ftyp, mdat, moov}, nil)[:len(ftyp)+50], ErrTruncated, ""},
+		{bytes.Join([][]byte{ftyp, moov}, nil)[:len(ftyp)+len(moov)-3], ErrTruncated, ""},
+	}
+
+	for ii, tt := range tests {
+		DefaultParseMode = Lenient
+		m, err := ReadAtoms(bytes.NewReader(tt.input))
+		if err != tt.err {
+			t.Errorf("[%d] Lenient: error = %v, expected %v", ii, err, tt.err)
+		}
+		if m.Album() != tt.album {
+			t.Errorf("[%d] Lenient: Album() = %q, expected %q", ii, m.Album(), tt.album)
+		}
+		if tt.err == nil {
+			if w := Warnings(m); len(w) != 1 || !errors.Is(w[0], ErrTruncated) {
+				t.Errorf("[%d] Lenient: Warnings() = %v, expected ErrTruncated", ii, w)
+			}
+		}
+
+		DefaultParseMode = Strict
+		if _, err := ReadAtoms(bytes.NewReader(tt.input)); !errors.Is(err, ErrTruncated) {
+			t.Errorf("[%d] Strict: error = %v, expected ErrTruncated", ii, err)
+		}
+	}
+}
+
+func TestReadAtomsUnknownClass(t *testing.T) {
+	// A title with data of an unknown class (99), which is not decoded.
+	title := testAtom("\xa9nam", testAtom("data", []byte{0, 0, 0, 99, 0, 0, 0, 0}, []byte("\x00T")))
+
+	m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{title, testTextItem("\xa9alb", "Album")})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(Warnings(m)) != 0 {
+		t.Errorf("Warnings() = %v, expected none", Warnings(m))
+	}
+	if m.Title() != "" {
+		t.Errorf("Title() = %q, expected %q", m.Title(), "")
+	}
+	testValue(t, "Album", m.Album())
+	if b, ok := m.Raw()["\xa9nam"].([]byte); !ok || string(b) != "\x00T" {
+		t.Errorf("Raw()[\"\\xa9nam\"] = %#v, expected the binary data", m.Raw()["\xa9nam"])
+	}
+}
+
+func TestReadAtomsShortData(t *testing.T) {
+	// A data atom too short for its class, which must not be read past its end.
+	short := testAtom(AtomAlbumArtist, testAtom("data", []byte{0, 0, 1}))
+
+	m, err := ReadFrom(bytes.NewReader(testM4A([][]byte{short, testTextItem("\xa9alb", "Album")})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(Warnings(m)) != 1 {
+		t.Errorf("Warnings() = %v, expected 1 warning", Warnings(m))
+	}
+	testValue(t, "Album", m.Album())
+}
+
+func TestReadAtomsClasses(t *testing.T) {
+	// testItem returns a tmpo item with a data atom of the given class.
+	testItem := func(class byte, value string) []byte {
+		return testAtom(AtomTempo, testAtom("data", []byte{0, 0, 0, class, 0, 0, 0, 0}, []byte(value)))
+	}
+
+	tests := []struct {
+		item     []byte
+		want     interface{}
+		warnings int
+	}{
+		{testItem(1, "text"), "text", 0},
+		{testItem(2, "\x00U\x00T\x00F\x00-\x001\x006"), "UTF-16", 0},
+		{testItem(2, "\x00"), nil, 1}, // odd length
+		{testItem(4, "sort"), "sort", 0},
+		{testItem(5, "\x00s"), "s", 0},
+		{testItem(21, "\x00\x78"), 120, 0},
+		{testItem(21, "\xff"), -1, 0},
+		{testItem(21, "\xff\xfe"), -2, 0},
+		{testItem(21, "\x00\x00\x00\x00\x00\x00\x01\x00"), 256, 0},
+		{testItem(22, "\xff"), 255, 0},
+		{testItem(22, "\xff\xfe"), 65534, 0},
+		{testItem(65, "\x80"), -128, 0},
+		{testItem(67, "\xff\xff\xff\xff"), -1, 0},
+		{testItem(77, "\xff\xff\xff\xff"), 4294967295, 0},
+		{testItem(23, "\x3f\xc0\x00\x00"), 1.5, 0},
+		{testItem(23, "\x3f\xc0"), nil, 1},
+		{testItem(24, "\x3f\xf8\x00\x00\x00\x00\x00\x00"), 1.5, 0},
+		{testItem(99, "x"), []byte("x"), 0},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{tt.item})))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := m.Raw()[AtomTempo]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%d] Raw()[%q] = %#v, expected %#v", ii, AtomTempo, got, tt.want)
+		}
+		if len(Warnings(m)) != tt.warnings {
+			t.Errorf("[%d] Warnings() = %v, expected %d warnings", ii, Warnings(m), tt.warnings)
+		}
+	}
+
+	// BMP pictures (class 27).
+	m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{testAtom(AtomPicture, testAtom("data", []byte{0, 0, 0, 27, 0, 0, 0, 0}, []byte("BMbmp")))})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p := m.Picture(); p == nil || p.MIMEType != "image/bmp" || p.Ext != "bmp" {
+		t.Errorf("Picture() = %v, expected BMP picture", p)
+	}
+}
+
+func TestReadAtomsFragmented(t *testing.T) {
+	// u32 returns the big-endian encoding of the numbers.
+	u32 := func(xs ...uint32) []byte {
+		b := make([]byte, 4*len(xs))
+		for i, x := range xs {
+			binary.BigEndian.PutUint32(b[4*i:], x)
+		}
+		return b
+	}
+
+	mvhd := testAtom("mvhd", u32(0, 0, 0, 1000, 0), make([]byte, 80))
+	trak := testAtom("trak", testAtom("tkhd", u32(0, 0, 0, 1)), testAtom("mdia", testAtom("mdhd", u32(0, 0, 0, 44100, 0))))
+	trex := testAtom("trex", u32(0, 1, 1, 1024, 0, 0))
+	udta := testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem(AtomTitle, "Title"))))
+	moof := func(tfhd, trun []byte) []byte {
+		return testAtom("moof", testAtom("mfhd", u32(0, 1)), testAtom("traf", testAtom("tfhd", tfhd), testAtom("trun", trun)))
+	}
+	ftyp := testAtom("ftyp", []byte("dash\x00\x00\x00\x00"))
+
+	tests := []struct {
+		atoms    [][]byte
+		duration int
+	}{
+		// Movie extends header.
+		{[][]byte{testAtom("moov", mvhd, trak, testAtom("mvex", testAtom("mehd", u32(0, 5000)), trex), udta)}, 5},
+		// Segment index, with a reference to another index which is not counted.
+		{[][]byte{
+			testAtom("moov", mvhd, trak, testAtom("mvex", trex), udta),
+			testAtom("sidx", u32(0, 1, 44100, 0, 0, 3), u32(0, 220500, 0), u32(0, 220500, 0), u32(1<<31, 441000, 0)),
+		}, 10},
+		// Track fragments using the default sample duration of the track.
+		{[][]byte{
+			testAtom("moov", mvhd, trak, testAtom("mvex", trex), udta),
+			moof(u32(0, 1), u32(0, 431)),
+			moof(u32(0, 1), u32(0, 431)),
+		}, 20},
+		// Track fragments with sample durations and sizes, and a default duration.
+		{[][]byte{
+			testAtom("moov", mvhd, trak, testAtom("mvex", trex), udta),
+			moof(u32(0, 1), u32(0x301, 3, 0, 44100, 10, 44100, 10, 44100, 10)),
+			moof(u32(0x08, 1, 88200), u32(0, 2)),
+		}, 7},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadFrom(bytes.NewReader(bytes.Join(append([][]byte{ftyp}, tt.atoms...), nil)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if d := m.(*metadataMP4).Duration(); d != tt.duration {
+			t.Errorf("[%d] Duration() = %d, expected %d", ii, d, tt.duration)
+		}
+		testValue(t, "Title", m.Title())
+		if len(Warnings(m)) != 0 {
+			t.Errorf("[%d] unexpected warnings: %v", ii, Warnings(m))
+		}
+	}
+}
+
+func TestReadAtomsChapterTrack(t *testing.T) {
+	u32 := func(xs ...uint32) []byte {
+		b := make([]byte, 4*len(xs))
+		for i, x := range xs {
+			binary.BigEndian.PutUint32(b[4*i:], x)
+		}
+		return b
+	}
+
+	samples := [][]byte{
+		append([]byte("\x00\x05Intro"), testAtom("encd", u32(0x100))...),
+		[]byte("\x00\x0a\xfe\xff\x00P\x00a\x00r\x00t"),
+		[]byte("\x00\x03End"),
+	}
+	mdat := testAtom("mdat", samples...)
+
+	// testFile returns the file with the chapter track samples in two chunks, the first
+	// with two samples.
+	testFile := func(udta []byte) []byte {
+		ftyp := testAtom("ftyp", []byte("M4B \x00\x00\x00\x00"))
+		moov := func(offset uint32) []byte {
+			audio := testAtom("trak", testAtom("tkhd", u32(0, 0, 0, 1)), testAtom("tref", testAtom("chap", u32(2))),
+				testAtom("mdia", testAtom("mdhd", u32(0, 0, 0, 44100, 0))))
+			text := testAtom("trak", testAtom("tkhd", u32(0, 0, 0, 2)),
+				testAtom("mdia", testAtom("mdhd", u32(0, 0, 0, 1000, 0)), testAtom("minf", testAtom("stbl",
+					testAtom("stts", u32(0, 2, 2, 60000, 1, 30500)),
+					testAtom("stsz", u32(0, 0, 3, uint32(len(samples[0])), uint32(len(samples[1])), uint32(len(samples[2])))),
+					testAtom("stsc", u32(0, 2, 1, 2, 1, 2, 1, 1)),
+					testAtom("stco", u32(0, 2, offset, offset+uint32(len(samples[0])+len(samples[1])))),
+				))))
+			return testAtom("moov", audio, text, udta)
+		}
+		offset := uint32(len(ftyp) + len(moov(0)) + 8)
+		return bytes.Join([][]byte{ftyp, moov(offset), mdat}, nil)
+	}
+
+	chpl := testAtom("udta", testAtom("chpl", []byte{1, 0, 0, 0, 0, 0, 0, 0, 1}, make([]byte, 8), []byte{4}, []byte("Nero")))
+
+	tests := []struct {
+		in   []byte
+		want []string
+	}{
+		{testFile(nil), []string{"0.000-60.000 Intro", "60.000-120.000 Part", "120.000-150.500 End"}},
+		{testFile(chpl), []string{"0.000- Nero"}},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadFrom(bytes.NewReader(tt.in))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		var got []string
+		for _, c := range Chapters(m) {
+			got = append(got, c.StartTime+"-"+c.EndTime+" "+c.Title)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%d] Chapters() = %q, expected %q", ii, got, tt.want)
+		}
+		if len(Warnings(m)) != 0 {
+			t.Errorf("[%d] unexpected warnings: %v", ii, Warnings(m))
+		}
+	}
+
+	// A chapter track whose samples are beyond the end of the file.
+	b := testFile(nil)
+	m, err := ReadFrom(bytes.NewReader(b[:len(b)-len(mdat)]))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(Chapters(m)) != 0 || len(Warnings(m)) != 1 {
+		t.Errorf("Chapters() = %v, Warnings() = %v, expected no chapters and 1 warning", Chapters(m), Warnings(m))
+	}
+}
+
+func TestReadAtomsChapterImages(t *testing.T) {
+	u32 := func(xs ...uint32) []byte {
+		b := make([]byte, 4*len(xs))
+		for i, x := range xs {
+			binary.BigEndian.PutUint32(b[4*i:], x)
+		}
+		return b
+	}
+	jpeg := append([]byte("\xff\xd8\xff\xe0"), "first"...)
+	png := append([]byte(pngHeader), "second"...)
+	samples := [][]byte{[]byte("\x00\x05Intro"), []byte("\x00\x04Part"), []byte("\x00\x03End"), jpeg, png}
+
+	// track returns a chapter track of the samples at the offsets, one per chunk, with the
+	// given sample entry format and time to sample entries (count and duration).
+	track := func(id uint32, format string, samples [][]byte, offsets []uint32, stts ...uint32) []byte {
+		var sizes []uint32
+		for _, b := range samples {
+			sizes = append(sizes, uint32(len(b)))
+		}
+		return testAtom("trak", testAtom("tkhd", u32(0, 0, 0, id)),
+			testAtom("mdia", testAtom("mdhd", u32(0, 0, 0, 1000, 0)), testAtom("minf", testAtom("stbl",
+				testAtom("stsd", u32(0, 1, 16), []byte(format), make([]byte, 8)),
+				testAtom("stts", append(u32(0, uint32(len(stts)/2)), u32(stts...)...)),
+				testAtom("stsz", append(u32(0, 0, uint32(len(sizes))), u32(sizes...)...)),
+				testAtom("stsc", u32(0, 1, 1, 1, 1)),
+				testAtom("stco", append(u32(0, uint32(len(offsets))), u32(offsets...)...)),
+			))))
+	}
+
+	ftyp := testAtom("ftyp", []byte("M4B \x00\x00\x00\x00"))
+	moov := func(base uint32) []byte {
+		var offsets []uint32
+		for _, b := range samples {
+			offsets = append(offsets, base)
+			base += uint32(len(b))
+		}
+		audio := testAtom("trak", testAtom("tkhd", u32(0, 0, 0, 1)), testAtom("tref", testAtom("chap", u32(3, 2))),
+			testAtom("mdia", testAtom("mdhd", u32(0, 0, 0, 44100, 0))))
+		return testAtom("moov", audio,
+			track(2, "text", samples[:3], offsets[:3], 2, 60000, 1, 30500),
+			track(3, "jpeg", samples[3:], offsets[3:], 1, 90000, 1, 60500))
+	}
+	base := uint32(len(ftyp) + len(moov(0)) + 8)
+	b := bytes.Join([][]byte{ftyp, moov(base), testAtom("mdat", samples...)}, nil)
+
+	m, err := ReadFrom(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(Warnings(m)) != 0 {
+		t.Errorf("unexpected warnings: %v", Warnings(m))
+	}
+	want := []struct {
+		title string
+		ext   string
+		data  []byte
+	}{
+		{"Intro", "jpeg", jpeg},
+		{"Part", "jpeg", jpeg},
+		{"End", "png", png},
+	}
+	got := Chapters(m)
+	if len(got) != len(want) {
+		t.Fatalf("Chapters() = %v, expected %d chapters", got, len(want))
+	}
+	for i, c := range got {
+		if c.Title != want[i].title || c.Picture == nil || c.Picture.Ext != want[i].ext || !bytes.Equal(c.Picture.Data, want[i].data) {
+			t.Errorf("Chapters()[%d] = %v with picture %v, expected %q with %v picture", i, c, c.Picture, want[i].title, want[i].ext)
+		}
+	}
+}
+
+func TestReadAtomsGenreID(t *testing.T) {
+	// testGenreID returns a gnre item (implicit class) with the given value.
+	testGenreID := func(x int) []byte {
+		return testAtom(AtomGenreID, testAtom("data", make([]byte, 8), []byte{byte(x >> 8), byte(x)}))
+	}
+
+	tests := []struct {
+		items [][]byte
+		genre string
+	}{
+		{[][]byte{testGenreID(18)}, "Rock"},
+		{[][]byte{testGenreID(1)}, "Blues"},
+		{[][]byte{testGenreID(0)}, ""},
+		{[][]byte{testGenreID(1000)}, ""},
+		{[][]byte{testGenreID(18), testTextItem(AtomGenre, "Shoegaze")}, "Shoegaze"},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A(tt.items)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if m.Genre() != tt.genre {
+			t.Errorf("[%d] Genre() = %q, expected %q", ii, m.Genre(), tt.genre)
+		}
+		if len(Warnings(m)) != 0 {
+			t.Errorf("[%d] unexpected warnings: %v", ii, Warnings(m))
+		}
+	}
+}
+
+func TestPictures(t *testing.T) {
+	jpeg := testAtom("data", []byte{0, 0, 0, 13, 0, 0, 0, 0}, []byte("front"))
+	png := testAtom("data", []byte{0, 0, 0, 14, 0, 0, 0, 0}, []byte("back"))
+
+	tests := []struct {
+		items   [][]byte
+		want    []string
+		picture string // Picture(): the first picture, or the last covr atom (see FormatDefault)
+	}{
+		{nil, nil, ""},
+		{[][]byte{testAtom(AtomPicture, jpeg)}, []string{"image/jpeg front"}, "image/jpeg front"},
+		{[][]byte{testAtom(AtomPicture, jpeg, png)}, []string{"image/jpeg front", "image/png back"}, "image/jpeg front"},
+		{[][]byte{testAtom(AtomPicture, jpeg), testAtom(AtomPicture, png)}, []string{"image/jpeg front", "image/png back"}, "image/png back"},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A(tt.items)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		var got []string
+		for _, p := range Pictures(m) {
+			got = append(got, p.MIMEType+" "+string(p.Data))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%d] Pictures() = %q, expected %q", ii, got, tt.want)
+		}
+		if p := m.Picture(); tt.picture != "" && (p == nil || p.MIMEType+" "+string(p.Data) != tt.picture) {
+			t.Errorf("[%d] Picture() = %v, expected %q", ii, p, tt.picture)
+		}
+	}
+
+	if got := Pictures(metadataID3v1{}); got != nil {
+		t.Errorf("Pictures() = %v for ID3v1 metadata, expected nil", got)
+	}
+}
+
+func TestReadAtomsImplicitPicture(t *testing.T) {
+	// testImplicitPicture returns a covr item with an implicit class data atom.
+	testImplicitPicture := func(data string) []byte {
+		return testAtom(AtomPicture, testAtom("data", make([]byte, 8), []byte(data)))
+	}
+
+	tests := []struct {
+		data     string
+		mimeType string
+		warnings int
+	}{
+		{"\x89PNG\r\n\x1a\npng", "image/png", 0},
+		{"\xff\xd8\xff\xe0jpeg", "image/jpeg", 0},
+		{"GIF89agif", "image/gif", 0},
+		{"GIF87agif", "image/gif", 0},
+		{"BMbmp", "image/bmp", 0},
+		{"unknown", "", 1},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{testImplicitPicture(tt.data)})))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		var mimeType string
+		if p := m.Picture(); p != nil {
+			mimeType = p.MIMEType
+			if string(p.Data) != tt.data {
+				t.Errorf("[%d] Picture().Data = %q, expected %q", ii, p.Data, tt.data)
+			}
+		}
+		if mimeType != tt.mimeType {
+			t.Errorf("[%d] Picture().MIMEType = %q, expected %q", ii, mimeType, tt.mimeType)
+		}
+		if len(Warnings(m)) != tt.warnings {
+			t.Errorf("[%d] Warnings() = %v, expected %d warnings", ii, Warnings(m), tt.warnings)
+		}
+	}
+}
+
+func TestReadAtomsCustom(t *testing.T) {
+	// testCustomItem returns a custom item with the given namespace, name and values.
+	testCustomItem := func(mean, name string, values ...string) []byte {
+		b := [][]byte{
+			testAtom("mean", make([]byte, 4), []byte(mean)),
+			testAtom("name", make([]byte, 4), []byte(name)),
+		}
+		for _, v := range values {
+			b = append(b, testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(v)))
+		}
+		return testAtom(AtomCustom, b...)
+	}
+
+	tests := []struct {
+		item []byte
+		raw  map[string]interface{}
+	}{
+		{testCustomItem("com.apple.iTunes", "MOOD", "Happy"), map[string]interface{}{"MOOD": "Happy"}},
+		{
+			testCustomItem("org.musicbrainz", "MusicBrainz Track Id", "b1a9c0e9"),
+			map[string]interface{}{"org.musicbrainz:MusicBrainz Track Id": "b1a9c0e9"},
+		},
+		{testCustomItem("com.foobar2000", "RATING", "5"), map[string]interface{}{"com.foobar2000:RATING": "5"}},
+		{testCustomItem("", "RATING", "5"), map[string]interface{}{}},
+		{testCustomItem("com.apple.iTunes", "RATING"), map[string]interface{}{}},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{tt.item})))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := m.Raw(); !reflect.DeepEqual(got, tt.raw) {
+			t.Errorf("[%d] Raw() = %v, expected %v", ii, got, tt.raw)
+		}
+	}
+}
+
+func TestReadAtomsMetaHandler(t *testing.T) {
+	ftyp := testAtom("ftyp", []byte("M4A \x00\x00\x00\x00"))
+	hdlr := func(handler string) []byte {
+		return testAtom("hdlr", make([]byte, 8), []byte(handler), make([]byte, 12))
+	}
+	ilst := testAtom("ilst", testTextItem(AtomTitle, "Title"))
+
+	tests := []struct {
+		moov  []byte
+		title string
+	}{
+		{testAtom("moov", testAtom("udta", testAtom("meta", make([]byte, 4), hdlr("mdir"), ilst))), "Title"},
+		{testAtom("moov", testAtom("udta", testAtom("meta", make([]byte, 4), ilst, hdlr("mdir")))), "Title"},
+		{testAtom("moov", testAtom("udta", testAtom("meta", hdlr("mdir"), ilst))), "Title"},
+		{testAtom("moov", testAtom("meta", make([]byte, 4), hdlr("mdir"), ilst)), "Title"},
+		{testAtom("moov", testAtom("trak", testAtom("udta", testAtom("meta", make([]byte, 4), hdlr("mdir"), ilst)))), "Title"},
+		{testAtom("moov", testAtom("udta", testAtom("meta", make([]byte, 4), hdlr("ID32"), ilst))), ""},
+		{testAtom("moov", testAtom("udta", testAtom("meta", hdlr("mp7t"), ilst))), ""},
+		{testAtom("moov", testAtom("udta", testAtom("meta", make([]byte, 4), hdlr("ID32")), testAtom("meta", make([]byte, 4), ilst))), "Title"},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(append(append([]byte(nil), ftyp...), tt.moov...)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if m.Title() != tt.title {
+			t.Errorf("[%d] Title() = %q, expected %q", ii, m.Title(), tt.title)
+		}
+		if len(Warnings(m)) != 0 {
+			t.Errorf("[%d] unexpected warnings: %v", ii, Warnings(m))
+		}
+	}
+}
+
+func TestReadAtomsMultipleValues(t *testing.T) {
+	text := func(v string) []byte { return testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(v)) }
+
+	m, err := ReadAtoms(bytes.NewReader(testM4A([][]byte{
+		testAtom(AtomArtist, text("Artist 1"), text("Artist 2")),
+		testAtom(AtomComposer, text("Composer")),
+		testAtom(AtomCustom,
+			testAtom("mean", make([]byte, 4), []byte("com.apple.iTunes")),
+			testAtom("name", make([]byte, 4), []byte("MOOD")),
+			text("Happy"), text("Calm")),
+	})))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw := m.Raw()
+	if want := []string{"Artist 1", "Artist 2"}; !reflect.DeepEqual(raw[AtomArtist], want) {
+		t.Errorf("Raw()[%q] = %#v, expected %#v", AtomArtist, raw[AtomArtist], want)
+	}
+	if want := []string{"Happy", "Calm"}; !reflect.DeepEqual(raw["MOOD"], want) {
+		t.Errorf("Raw()[\"MOOD\"] = %#v, expected %#v", raw["MOOD"], want)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"Artist()", m.Artist(), "Artist 1;Artist 2"},
+		{"Artists()", Artists(m), []string{"Artist 1", "Artist 2"}},
+		{"Composers()", Composers(m), []string{"Composer"}},
+		{"AlbumArtists()", AlbumArtists(m), []string(nil)},
+	}
+	for ii, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("[%d] %v = %#v, expected %#v", ii, tt.name, tt.got, tt.want)
+		}
+	}
+
+	// Raw returns a copy.
+	raw[AtomArtist].([]string)[0] = "changed"
+	if got := Artists(m); got[0] != "Artist 1" {
+		t.Errorf("Artists() = %q after changing Raw(), expected it to be unchanged", got)
+	}
+
+	flac, err := readFLACBytes(testFLAC(t, []string{"ARTIST=Artist"}, 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := Artists(flac), []string{"Artist"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Artists() = %q, expected %q", got, want)
+	}
+}
+
+func TestReadAtomsXMP(t *testing.T) {
+	uuid := func(packet string) []byte { return testAtom("uuid", []byte(xmpUUID), []byte(packet)) }
+	packet := func(description string) string {
+		return `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>` +
+			`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+			`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">` + description +
+			`</rdf:Description></rdf:RDF></x:xmpmeta><?xpacket end="w"?>`
+	}
+	dc := packet(`<dc:title><rdf:Alt><rdf:li xml:lang="en-GB">Interview (en)</rdf:li><rdf:li xml:lang="x-default">Interview</rdf:li></rdf:Alt></dc:title>` +
+		`<dc:creator><rdf:Seq><rdf:li>Alice</rdf:li><rdf:li>Bob</rdf:li></rdf:Seq></dc:creator>` +
+		`<dc:description><rdf:Alt><rdf:li xml:lang="x-default">Field recording</rdf:li></rdf:Alt></dc:description>`)
+	m4a := testM4A([][]byte{testTextItem(AtomTitle, "Title")})
+
+	tests := []struct {
+		b        []byte
+		dc       *DublinCore
+		raw      map[string]interface{}
+		warnings int
+	}{
+		{
+			append(append([]byte(nil), m4a...), uuid(dc+"\x00\x00")...),
+			&DublinCore{Title: "Interview", Creators: []string{"Alice", "Bob"}, Description: "Field recording"},
+			map[string]interface{}{
+				AtomTitle:        "Title",
+				"dc:title":       "Interview",
+				"dc:creator":     []string{"Alice", "Bob"},
+				"dc:description": "Field recording",
+			},
+			0,
+		},
+		{
+			// Simple properties as attributes, in a uuid atom in moov.
+			append(testAtom("ftyp", []byte("M4A \x00\x00\x00\x00")), testAtom("moov", uuid(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">`+
+				`<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/" dc:title="Memo"/></rdf:RDF>`))...),
+			&DublinCore{Title: "Memo"},
+			map[string]interface{}{"dc:title": "Memo"},
+			0,
+		},
+		{
+			// Other uuid atoms are skipped.
+			append(append([]byte(nil), m4a...), testAtom("uuid", make([]byte, 16), []byte(dc))...),
+			nil,
+			map[string]interface{}{AtomTitle: "Title"},
+			0,
+		},
+		{
+			append(append([]byte(nil), m4a...), uuid(packet(`<dc:title>`))...),
+			nil,
+			map[string]interface{}{AtomTitle: "Title"},
+			1,
+		},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(tt.b))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := DublinCoreInfo(m); !reflect.DeepEqual(got, tt.dc) {
+			t.Errorf("[%d] DublinCoreInfo() = %+v, expected %+v", ii, got, tt.dc)
+		}
+		if got := m.Raw(); !reflect.DeepEqual(got, tt.raw) {
+			t.Errorf("[%d] Raw() = %v, expected %v", ii, got, tt.raw)
+		}
+		if len(Warnings(m)) != tt.warnings {
+			t.Errorf("[%d] Warnings() = %v, expected %d warnings", ii, Warnings(m), tt.warnings)
+		}
+	}
+}
+
+func TestReadAtomsAssetInfo(t *testing.T) {
+	// asset returns a 3GPP asset information atom with a string in the given language.
+	asset := func(name, language string, s []byte) []byte {
+		l := uint16(language[0]-0x60)<<10 | uint16(language[1]-0x60)<<5 | uint16(language[2]-0x60)
+		return testAtom(name, make([]byte, 4), []byte{byte(l >> 8), byte(l)}, s)
+	}
+	ftyp := testAtom("ftyp", []byte("3gp4\x00\x00\x00\x00"))
+
+	tests := []struct {
+		udta                        []byte
+		title, artist, album, genre string
+		year                        int
+		raw                         map[string]interface{}
+	}{
+		{
+			testAtom("udta",
+				asset("titl", "eng", []byte("Voice memo\x00")),
+				asset("titl", "fra", []byte("Mémo vocal\x00")),
+				asset("auth", "eng", []byte("Author\x00")),
+				asset("perf", "eng", []byte("\xfe\xff\x00P\x00e\x00r\x00f\x00\x00")),
+				asset("albm", "eng", []byte("Album\x00\x03")),
+				asset("gnre", "eng", []byte("Speech\x00")),
+				asset("dscp", "eng", []byte("Description\x00")),
+				asset("cprt", "eng", []byte("Copyright\x00")),
+				testAtom("yrrc", make([]byte, 4), []byte{0x07, 0xe8}),
+			),
+			"Voice memo", "Perf", "Album", "Speech", 2024,
+			map[string]interface{}{
+				"titl":     "Voice memo",
+				"titl@fra": "Mémo vocal",
+				"auth":     "Author",
+				"perf":     "Perf",
+				"albm":     "Album",
+				"gnre":     "Speech",
+				"dscp":     "Description",
+				"cprt":     "Copyright",
+				"yrrc":     2024,
+			},
+		},
+		{
+			// The author is used when there is no performer.
+			testAtom("udta", asset("auth", "und", []byte("Author"))),
+			"", "Author", "", "", 0,
+			map[string]interface{}{"auth": "Author"},
+		},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(append(ftyp, testAtom("moov", tt.udta)...)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if m.Title() != tt.title || m.Artist() != tt.artist || m.Album() != tt.album || m.Genre() != tt.genre || m.Year() != tt.year {
+			t.Errorf("[%d] Title(), Artist(), Album(), Genre(), Year() = %q, %q, %q, %q, %d, expected %q, %q, %q, %q, %d", ii,
+				m.Title(), m.Artist(), m.Album(), m.Genre(), m.Year(), tt.title, tt.artist, tt.album, tt.genre, tt.year)
+		}
+		if got := m.Raw(); !reflect.DeepEqual(got, tt.raw) {
+			t.Errorf("[%d] Raw() = %v, expected %v", ii, got, tt.raw)
+		}
+		if len(Warnings(m)) != 0 {
+			t.Errorf("[%d] unexpected warnings: %v", ii, Warnings(m))
+		}
+	}
+}
+
+func TestReadAtomsAnnotations(t *testing.T) {
+	// annotation returns a QuickTime text annotation atom with a text item for each pair
+	// of language code and text.
+	annotation := func(name string, items ...interface{}) []byte {
+		var b []byte
+		for i := 0; i < len(items); i += 2 {
+			s := items[i+1].(string)
+			b = append(b, byte(len(s)>>8), byte(len(s)), byte(items[i].(int)>>8), byte(items[i].(int)))
+			b = append(b, s...)
+		}
+		return testAtom(name, b)
+	}
+	ftyp := testAtom("ftyp", []byte("qt  \x00\x00\x00\x00"))
+	fra := int('f'-0x60)<<10 | int('r'-0x60)<<5 | int('a'-0x60)
+
+	tests := []struct {
+		udta          []byte
+		title, artist string
+		raw           map[string]interface{}
+		warnings      int
+	}{
+		{
+			testAtom("udta",
+				annotation(AtomTitle, 0, "Title", fra, "Titre"),
+				annotation(AtomArtist, 0, "Caf\xe9"),
+				annotation("\xa9cpy", 0, "Copyright"),
+			),
+			"Title", "Café",
+			map[string]interface{}{AtomTitle: "Title", AtomTitle + "@fra": "Titre", AtomArtist: "Café", "\xa9cpy": "Copyright"},
+			0,
+		},
+		{
+			// The tags of ilst are preferred.
+			testAtom("udta",
+				annotation(AtomTitle, 0, "Old title"),
+				annotation(AtomArtist, 0, "Artist"),
+				testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem(AtomTitle, "Title"))),
+			),
+			"Title", "Artist",
+			map[string]interface{}{AtomTitle: "Title", AtomArtist: "Artist"},
+			0,
+		},
+		{
+			// Items with data atoms directly in udta are read as usual.
+			testAtom("udta", testTextItem(AtomTitle, "Title")),
+			"Title", "",
+			map[string]interface{}{AtomTitle: "Title"},
+			0,
+		},
+		{
+			testAtom("udta", annotation(AtomArtist, 0, "Artist"), testAtom(AtomTitle, []byte{0, 10, 0, 0, 'T'})),
+			"", "Artist",
+			map[string]interface{}{AtomArtist: "Artist"},
+			1,
+		},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadAtoms(bytes.NewReader(append(ftyp, testAtom("moov", tt.udta)...)))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if m.Title() != tt.title || m.Artist() != tt.artist {
+			t.Errorf("[%d] Title(), Artist() = %q, %q, expected %q, %q", ii, m.Title(), m.Artist(), tt.title, tt.artist)
+		}
+		if got := m.Raw(); !reflect.DeepEqual(got, tt.raw) {
+			t.Errorf("[%d] Raw() = %v, expected %v", ii, got, tt.raw)
+		}
+		if len(Warnings(m)) != tt.warnings {
+			t.Errorf("[%d] Warnings() = %v, expected %d warnings", ii, Warnings(m), tt.warnings)
+		}
+	}
+}
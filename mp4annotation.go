@@ -0,0 +1,61 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+)
+
+// isAnnotation returns true if b is the contents of a QuickTime text annotation atom (such
+// as ©nam or ©ART), as written by old versions of QuickTime directly in udta instead of in
+// an ilst atom.  Annotations have the same names as iTunes items, but contain text items
+// rather than data atoms.
+func isAnnotation(b []byte) bool {
+	return !(len(b) >= 8 && string(b[4:8]) == "data")
+}
+
+// readAnnotation reads the contents of a QuickTime text annotation atom: a list of
+// international text items, each of which is its size (int16, of the text only), language
+// (int16, a Macintosh language code or an ISO 639-2/T code packed as three 5-bit letters)
+// and text.  The first text is kept as the atom name and the others as "name@language"
+// (such as "©nam@fra").  Annotations are only used for the atoms which are not in ilst
+// (see ReadAtoms).
+func (m *metadataMP4) readAnnotation(name string, b []byte) error {
+	if m.annotations == nil {
+		m.annotations = make(map[string]interface{})
+	}
+	for first := true; len(b) > 0; first = false {
+		if len(b) < 4 {
+			return fmt.Errorf("invalid text item: %d trailing bytes", len(b))
+		}
+		n := int(binary.BigEndian.Uint16(b))
+		if n > len(b)-4 {
+			return fmt.Errorf("invalid text item: size %d exceeds %d bytes", n, len(b)-4)
+		}
+		language := dataLocale([]byte{0, 0, b[2], b[3]})
+		text := b[4 : 4+n]
+		b = b[4+n:]
+
+		// Text is in the Macintosh encoding of its language, but UTF-8 is common.
+		s := string(text)
+		if !utf8.Valid(text) {
+			s = decodeISO8859(text)
+		}
+		if s = m.rc.sanitizeText(s); s == "" {
+			continue
+		}
+
+		key := name
+		if !first && language != "" {
+			key += "@" + language
+		}
+		if _, ok := m.annotations[key]; !ok {
+			m.annotations[key] = s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// mp4AssetAtoms are the 3GPP asset information atoms (3GPP TS 26.244) of udta which are
+// read, as written by phones and other recorders of .3gp and .m4a files.  Except for yrrc
+// (the recording year), their values are language-coded strings.
+var mp4AssetAtoms = map[string]bool{
+	"titl": true, // title
+	"perf": true, // performer
+	"auth": true, // author
+	"dscp": true, // description
+	"gnre": true, // genre (a string, unlike the iTunes gnre item)
+	"cprt": true, // copyright notice
+	"albm": true, // album title
+	"yrrc": true, // recording year
+}
+
+// mp4AssetFields are the asset information atoms which store the fields read by Metadata
+// methods, used when the iTunes atoms for the fields are missing.  The copyright notice is
+// stored as cprt, as for iTunes.
+var mp4AssetFields = map[string][]string{
+	"title":  {"titl"},
+	"artist": {"perf", "auth"},
+	"album":  {"albm"},
+	"genre":  {"gnre"},
+}
+
+// lookup returns the names of the atoms which store the field, in order of preference:
+// the iTunes atoms, then the 3GPP asset information atoms.
+func (m *metadataMP4) lookup(field string) []string {
+	return append(Registry.LookupByField(MP4, field), mp4AssetFields[field]...)
+}
+
+// readAssetAtom reads the contents of a 3GPP asset information atom: version and flags
+// (int32), then for yrrc the year (int16), and for the others a pad bit and ISO 639-2/T
+// language code packed as three 5-bit letters (int16) and a null-terminated string (UTF-8,
+// or UTF-16 with a byte order mark).  The values of the first language are kept as the
+// atom name, and those of other languages as "name@language" (such as "titl@fra").
+func (m *metadataMP4) readAssetAtom(name string, b []byte) error {
+	if len(b) < 6 {
+		return fmt.Errorf("expected at least %d bytes, got %d", 6, len(b))
+	}
+	if name == "yrrc" {
+		m.set(name, int(binary.BigEndian.Uint16(b[4:6])))
+		return nil
+	}
+
+	var language string
+	if l := binary.BigEndian.Uint16(b[4:6]) & 0x7fff; l != 0 {
+		language = string([]byte{byte(l>>10&0x1f) + 0x60, byte(l>>5&0x1f) + 0x60, byte(l&0x1f) + 0x60})
+	}
+
+	// The album title may be followed by a track number, which is ignored.
+	b = b[6:]
+	s := string(b)
+	if len(b) >= 2 && (b[0] == 0xfe && b[1] == 0xff || b[0] == 0xff && b[1] == 0xfe) {
+		n := 2
+		for n+1 < len(b) && (b[n] != 0 || b[n+1] != 0) {
+			n += 2
+		}
+		x, err := decodeUTF16WithBOM(b[:n])
+		if err != nil {
+			return err
+		}
+		s = x
+	} else if i := bytes.IndexByte(b, 0); i >= 0 {
+		s = string(b[:i])
+	}
+	if s = m.rc.sanitizeText(s); s == "" {
+		return nil
+	}
+
+	if _, ok := m.data[name]; ok && language != "" {
+		name += "@" + language
+	}
+	m.set(name, s)
+	return nil
+}
@@ -0,0 +1,256 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// AudioProperties are the properties of the audio stream of a file.
+type AudioProperties struct {
+	Codec      string // such as "AAC", "HE-AAC", "ALAC" or "MP3"
+	SampleRate int    // samples per second
+	Channels   int
+	BitDepth   int // bits per sample, or 0 if not known (as for lossy codecs)
+	Bitrate    int // average bits per second, or 0 if not known
+
+	// MPEG audio (MP3) only.
+	Version     string // MPEG version and layer, such as "MPEG-1 Layer III"
+	ChannelMode string // "stereo", "joint stereo", "dual channel" or "mono"
+	VBR         bool   // the bitrate is variable
+}
+
+// mp4AudioCodecs are the names of the codecs of the audio sample entries of an stsd atom.
+var mp4AudioCodecs = map[string]string{
+	"mp4a": "AAC", // refined by the esds atom
+	"drms": "AAC", // FairPlay protected
+	"alac": "ALAC",
+	"ac-3": "AC-3",
+	"ec-3": "E-AC-3",
+	"fLaC": "FLAC",
+	"Opus": "Opus",
+	".mp3": "MP3",
+	"samr": "AMR",
+	"sawb": "AMR-WB",
+	"lpcm": "PCM",
+	"sowt": "PCM",
+	"twos": "PCM",
+	"ipcm": "PCM",
+	"fpcm": "PCM",
+}
+
+// mp4VideoFormats are the formats of the video sample entries of an stsd atom.
+var mp4VideoFormats = map[string]bool{
+	"avc1": true, "avc3": true, "hvc1": true, "hev1": true, "mp4v": true, "av01": true, "vp09": true, "drmi": true,
+}
+
+// mp4AudioObjectTypes are the names of the MPEG-4 audio object types (from the audio
+// specific config of an esds atom) which are not plain AAC.
+var mp4AudioObjectTypes = map[byte]string{
+	5:  "HE-AAC",
+	29: "HE-AACv2",
+	34: "MP3",
+	36: "ALS",
+	42: "xHE-AAC",
+}
+
+// Properties returns the properties of the audio stream of an MP4 file, from the sample
+// description (stsd) of its first audio track, or of an MP3 file from the header of its
+// first frame (and its Xing/Info or VBRI header) if its audio was read (see DefaultMP3Audio).
+// Returns nil if there is no audio stream or m is not the metadata of an MP4 or MP3 file.
+func Properties(m Metadata) *AudioProperties {
+	if a := mpegAudioOf(m); a != nil {
+		return a.properties()
+	}
+	mp4, ok := m.(*metadataMP4)
+	if !ok || mp4.audio == nil {
+		return nil
+	}
+	p := *mp4.audio
+	if p.Bitrate == 0 && mp4.mediaSize > 0 && mp4.duration > 0 {
+		p.Bitrate = int(mp4.mediaSize * 8 / int64(mp4.duration))
+	}
+	return &p
+}
+
+// readAudioSampleEntry reads an audio sample entry of an stsd atom (after its size and
+// format), and the atoms which follow it.
+func readAudioSampleEntry(format string, b []byte) (*AudioProperties, error) {
+	// reserved (6 bytes), data reference index (int16), version (int16), revision level
+	// (int16), vendor (int32), channels (int16), sample size (int16), compression ID
+	// (int16), packet size (int16), sample rate (16.16 fixed point)
+	if len(b) < 28 {
+		return nil, fmt.Errorf("invalid %q sample entry: expected at least %d bytes, got %d", format, 28, len(b))
+	}
+	p := &AudioProperties{
+		Codec:      mp4AudioCodecs[format],
+		Channels:   int(binary.BigEndian.Uint16(b[16:18])),
+		BitDepth:   int(binary.BigEndian.Uint16(b[18:20])),
+		SampleRate: int(binary.BigEndian.Uint32(b[24:28]) >> 16),
+	}
+	children := b[28:]
+	switch version := binary.BigEndian.Uint16(b[8:10]); version {
+	case 1:
+		// QuickTime: samples per packet, bytes per packet, bytes per frame and bytes per
+		// sample (int32)
+		if len(children) < 16 {
+			return nil, fmt.Errorf("invalid %q sample entry: version 1 fields missing", format)
+		}
+		children = children[16:]
+	case 2:
+		// QuickTime: size of the struct (int32), sample rate (float64), channels (int32),
+		// always 0x7F000000, bits per channel (int32), flags, bytes per packet and frames
+		// per packet (int32)
+		if len(children) < 36 {
+			return nil, fmt.Errorf("invalid %q sample entry: version 2 fields missing", format)
+		}
+		p.SampleRate = int(math.Float64frombits(binary.BigEndian.Uint64(children[4:12])))
+		p.Channels = int(binary.BigEndian.Uint32(children[12:16]))
+		p.BitDepth = int(binary.BigEndian.Uint32(children[20:24]))
+		children = children[36:]
+	}
+
+	for len(children) >= 8 {
+		size := int(binary.BigEndian.Uint32(children))
+		if size < 8 || size > len(children) {
+			return nil, fmt.Errorf("invalid %q sample entry: child atom has invalid size %d", format, size)
+		}
+		name, data := string(children[4:8]), children[8:size]
+		children = children[size:]
+
+		var err error
+		switch name {
+		case "esds":
+			err = p.readESDS(data)
+		case "alac":
+			err = p.readALACConfig(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q atom: %v", name, err)
+		}
+	}
+
+	switch p.Codec {
+	case "AAC", "HE-AAC", "HE-AACv2", "xHE-AAC", "MP3", "AC-3", "E-AC-3", "Opus", "AMR", "AMR-WB":
+		// The sample size field is nominal (usually 16) for lossy codecs.
+		p.BitDepth = 0
+	}
+	return p, nil
+}
+
+// readALACConfig reads the contents of the alac atom of an alac sample entry: version and
+// flags (int32), frame length (int32), compatible version, bit depth, three tuning
+// parameters, channels (byte), max run (int16), max frame bytes, average bitrate and
+// sample rate (int32).
+func (p *AudioProperties) readALACConfig(b []byte) error {
+	if len(b) < 28 {
+		return fmt.Errorf("expected at least %d bytes, got %d", 28, len(b))
+	}
+	p.BitDepth = int(b[9])
+	p.Channels = int(b[13])
+	p.Bitrate = int(binary.BigEndian.Uint32(b[20:24]))
+	p.SampleRate = int(binary.BigEndian.Uint32(b[24:28]))
+	return nil
+}
+
+// readESDS reads the contents of an esds atom: version and flags (int32), then an
+// elementary stream descriptor containing a decoder config descriptor.
+func (p *AudioProperties) readESDS(b []byte) error {
+	if len(b) < 4 {
+		return fmt.Errorf("expected at least %d bytes, got %d", 4, len(b))
+	}
+	tag, es, _, err := readDescriptor(b[4:])
+	if err != nil {
+		return err
+	}
+	if tag != 0x03 {
+		return fmt.Errorf("expected elementary stream descriptor, got tag %#x", tag)
+	}
+	// ES ID (int16), flags, then optional fields given by the flags
+	if len(es) < 3 {
+		return fmt.Errorf("elementary stream descriptor too short (%d bytes)", len(es))
+	}
+	flags, offset := es[2], 3
+	if flags&0x80 != 0 { // depends on ES ID
+		offset += 2
+	}
+	if flags&0x40 != 0 { // URL
+		if len(es) <= offset {
+			return fmt.Errorf("elementary stream descriptor too short (%d bytes)", len(es))
+		}
+		offset += 1 + int(es[offset])
+	}
+	if flags&0x20 != 0 { // OCR ES ID
+		offset += 2
+	}
+	if len(es) < offset {
+		return fmt.Errorf("elementary stream descriptor too short (%d bytes)", len(es))
+	}
+
+	for rest := es[offset:]; len(rest) > 0; {
+		tag, d, next, err := readDescriptor(rest)
+		if err != nil {
+			return err
+		}
+		rest = next
+		if tag != 0x04 {
+			continue
+		}
+		// object type, stream type (byte), buffer size (24 bits), max bitrate, average
+		// bitrate (int32), then the decoder specific info
+		if len(d) < 13 {
+			return fmt.Errorf("decoder config descriptor too short (%d bytes)", len(d))
+		}
+		switch d[0] {
+		case 0x69, 0x6b:
+			p.Codec = "MP3"
+		}
+		p.Bitrate = int(binary.BigEndian.Uint32(d[9:13]))
+
+		for info := d[13:]; len(info) > 0; {
+			tag, asc, next, err := readDescriptor(info)
+			if err != nil {
+				return err
+			}
+			info = next
+			if tag == 0x05 && d[0] == 0x40 && len(asc) > 0 {
+				// The audio specific config starts with the object type (5 bits).
+				if name, ok := mp4AudioObjectTypes[asc[0]>>3]; ok {
+					p.Codec = name
+				}
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// readDescriptor reads an MPEG-4 descriptor from the start of b: its tag and size (up to
+// four bytes of 7 bits, the high bit set when another follows), returning the tag, the
+// contents of the descriptor and the remaining bytes.
+func readDescriptor(b []byte) (tag byte, d, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, fmt.Errorf("descriptor too short (%d bytes)", len(b))
+	}
+	tag, b = b[0], b[1:]
+	var size int
+	for i := 0; ; i++ {
+		if i == 4 || len(b) == 0 {
+			return 0, nil, nil, fmt.Errorf("invalid size of descriptor with tag %#x", tag)
+		}
+		c := b[0]
+		b = b[1:]
+		size = size<<7 | int(c&0x7f)
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	if size > len(b) {
+		return 0, nil, nil, fmt.Errorf("descriptor with tag %#x: size %d exceeds %d bytes", tag, size, len(b))
+	}
+	return tag, b[:size], b[size:], nil
+}
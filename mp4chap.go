@@ -0,0 +1,317 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// maxChapterSamples is the largest number of samples read from a QuickTime chapter track.
+const maxChapterSamples = 1 << 16
+
+// mp4SampleTables are the sample table atoms whose positions are recorded, so that the
+// samples of a chapter track can be read once its track is known.
+var mp4SampleTables = map[string]bool{
+	"stts": true, // time to sample
+	"stsz": true, // sample sizes
+	"stsc": true, // sample to chunk
+	"stco": true, // chunk offsets
+	"co64": true, // 64-bit chunk offsets
+}
+
+// mp4Track is the information about a track (trak atom) used to read QuickTime chapters.
+type mp4Track struct {
+	chapters []uint32            // IDs of the chapter tracks, from tref/chap
+	tables   map[string][2]int64 // offsets and sizes of the contents of the sample tables
+	format   string              // of the first sample entry (from stsd)
+}
+
+// mp4ChapterImageFormats are the formats of the sample entries of the chapter tracks which
+// contain the artwork of the chapters (in enhanced podcasts and audiobooks), and their
+// image types.
+var mp4ChapterImageFormats = map[string]string{
+	"jpeg": "jpeg",
+	"png ": "png",
+}
+
+// mp4Sample is a sample of a track, with its start and end times.
+type mp4Sample struct {
+	start, end time.Duration
+	data       []byte
+}
+
+// track returns the track with the given ID, adding it if needed.
+func (m *metadataMP4) track(id uint32) *mp4Track {
+	if m.tracks == nil {
+		m.tracks = make(map[uint32]*mp4Track)
+	}
+	t, ok := m.tracks[id]
+	if !ok {
+		t = &mp4Track{tables: make(map[string][2]int64)}
+		m.tracks[id] = t
+	}
+	return t
+}
+
+// readChapterReference reads the contents of a tref/chap atom of the current track.
+func (m *metadataMP4) readChapterReference(r io.Reader, size uint32) error {
+	b, err := readBytes(r, uint(size))
+	if err != nil {
+		return err
+	}
+	t := m.track(m.fragments.track)
+	for ; len(b) >= 4; b = b[4:] {
+		t.chapters = append(t.chapters, binary.BigEndian.Uint32(b))
+	}
+	return nil
+}
+
+// readChapterTrack reads the chapters from the text samples of the QuickTime chapter track
+// referenced by a tref/chap atom, if there is one, and their artwork from the samples of a
+// chapter track of images, if there is also one.
+func (m *metadataMP4) readChapterTrack(r io.ReadSeeker) error {
+	// Use the chapter tracks of the first track (by ID) which has them.
+	var refs []uint32
+	var ref uint32
+	for x, t := range m.tracks {
+		if len(t.chapters) > 0 && (ref == 0 || x < ref) {
+			refs, ref = t.chapters, x
+		}
+	}
+	var text, images uint32
+	for _, id := range refs {
+		t, ok := m.tracks[id]
+		switch {
+		case !ok:
+		case mp4ChapterImageFormats[t.format] != "":
+			if images == 0 {
+				images = id
+			}
+		case text == 0:
+			text = id
+		}
+	}
+	if text == 0 {
+		return nil
+	}
+
+	samples, err := m.readTrackSamples(r, text)
+	if err != nil {
+		return err
+	}
+	var chapters []Chapter
+	for i, s := range samples {
+		title, err := decodeChapterSample(s.data)
+		if err != nil {
+			return fmt.Errorf("chapter %d: %v", i+1, err)
+		}
+		chapters = append(chapters, Chapter{
+			id:        uint8(i),
+			StartTime: formatChapterSeconds(s.start),
+			EndTime:   formatChapterSeconds(s.end),
+			Title:     m.rc.sanitizeText(title),
+		})
+	}
+	if len(chapters) == 0 {
+		return nil
+	}
+	m.data[AtomChapters] = chapters
+	if images == 0 {
+		return nil
+	}
+
+	// Each chapter has the image which is shown at its start, if any.
+	pictures, err := m.readTrackSamples(r, images)
+	if err != nil {
+		return fmt.Errorf("chapter images: %v", err)
+	}
+	for i := range chapters {
+		for _, p := range pictures {
+			if p.start <= samples[i].start && samples[i].start < p.end && len(p.data) > 0 {
+				t := detectImageType(p.data)
+				if t == "" {
+					t = mp4ChapterImageFormats[m.tracks[images].format]
+				}
+				chapters[i].Picture = &Picture{Ext: t, MIMEType: "image/" + t, Data: p.data}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// readTrackSamples reads the samples of the track with the given ID, using its sample
+// tables.
+func (m *metadataMP4) readTrackSamples(r io.ReadSeeker, id uint32) ([]mp4Sample, error) {
+	t := m.tracks[id]
+	timeScale := m.fragments.timeScales[id]
+	if timeScale == 0 {
+		return nil, fmt.Errorf("chapter track %d: missing time scale", id)
+	}
+
+	tables := make(map[string][]byte)
+	for name, pos := range t.tables {
+		if _, err := r.Seek(pos[0], io.SeekStart); err != nil {
+			return nil, err
+		}
+		b, err := readBytes(r, uint(pos[1]))
+		if err != nil {
+			return nil, err
+		}
+		tables[name] = b
+	}
+	if tables["co64"] != nil {
+		tables["stco"] = nil
+	}
+
+	durations, err := readTimeToSample(tables["stts"])
+	if err != nil {
+		return nil, err
+	}
+	offsets, sizes, err := readSampleLocations(tables["stsz"], tables["stsc"], tables["stco"], tables["co64"])
+	if err != nil {
+		return nil, err
+	}
+
+	seconds := func(x uint64) time.Duration {
+		return time.Duration(float64(x) / float64(timeScale) * float64(time.Second))
+	}
+	var samples []mp4Sample
+	var start uint64
+	for i := range offsets {
+		if i >= len(durations) {
+			break
+		}
+		if _, err := r.Seek(offsets[i], io.SeekStart); err != nil {
+			return nil, err
+		}
+		b, err := readBytes(r, uint(sizes[i]))
+		if err != nil {
+			return nil, err
+		}
+		end := start + uint64(durations[i])
+		samples = append(samples, mp4Sample{start: seconds(start), end: seconds(end), data: b})
+		start = end
+	}
+	return samples, nil
+}
+
+// readTimeToSample returns the durations of the samples from the contents of an stts atom.
+func readTimeToSample(b []byte) ([]uint32, error) {
+	n, b, err := sampleTableEntries("stts", b, 8)
+	if err != nil {
+		return nil, err
+	}
+	var durations []uint32
+	for i := 0; i < n; i++ {
+		count, delta := binary.BigEndian.Uint32(b[8*i:]), binary.BigEndian.Uint32(b[8*i+4:])
+		for ; count > 0 && len(durations) < maxChapterSamples; count-- {
+			durations = append(durations, delta)
+		}
+	}
+	return durations, nil
+}
+
+// readSampleLocations returns the offsets and sizes of the samples from the contents of
+// the stsz, stsc and stco (or co64) atoms of a track.
+func readSampleLocations(stsz, stsc, stco, co64 []byte) (offsets []int64, sizes []uint32, err error) {
+	if len(stsz) < 12 {
+		return nil, nil, errors.New("invalid stsz atom")
+	}
+	size, count := binary.BigEndian.Uint32(stsz[4:8]), int(binary.BigEndian.Uint32(stsz[8:12]))
+	if count > maxChapterSamples {
+		count = maxChapterSamples
+	}
+	for i := 0; i < count; i++ {
+		if size == 0 {
+			if len(stsz) < 16+4*i {
+				return nil, nil, fmt.Errorf("invalid stsz atom: %d sizes do not fit in %d bytes", count, len(stsz)-12)
+			}
+			sizes = append(sizes, binary.BigEndian.Uint32(stsz[12+4*i:]))
+			continue
+		}
+		sizes = append(sizes, size)
+	}
+
+	var chunks []int64
+	if co64 != nil {
+		n, b, err := sampleTableEntries("co64", co64, 8)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := 0; i < n; i++ {
+			chunks = append(chunks, int64(binary.BigEndian.Uint64(b[8*i:])))
+		}
+	} else {
+		n, b, err := sampleTableEntries("stco", stco, 4)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := 0; i < n; i++ {
+			chunks = append(chunks, int64(binary.BigEndian.Uint32(b[4*i:])))
+		}
+	}
+
+	// Each entry gives the first chunk (counting from 1) of a run of chunks with the same
+	// number of samples.
+	n, b, err := sampleTableEntries("stsc", stsc, 12)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := 0; i < n && len(offsets) < len(sizes); i++ {
+		first, perChunk := int(binary.BigEndian.Uint32(b[12*i:])), int(binary.BigEndian.Uint32(b[12*i+4:]))
+		last := len(chunks)
+		if i+1 < n {
+			last = int(binary.BigEndian.Uint32(b[12*(i+1):])) - 1
+		}
+		if first < 1 || last > len(chunks) {
+			return nil, nil, fmt.Errorf("invalid stsc atom: chunk %d of %d", first, len(chunks))
+		}
+		for c := first; c <= last; c++ {
+			offset := chunks[c-1]
+			for s := 0; s < perChunk && len(offsets) < len(sizes); s++ {
+				offsets = append(offsets, offset)
+				offset += int64(sizes[len(offsets)-1])
+			}
+		}
+	}
+	return offsets, sizes[:len(offsets)], nil
+}
+
+// sampleTableEntries returns the number of entries in the contents of the named sample
+// table atom, which have the given width, and the entries.
+func sampleTableEntries(name string, b []byte, width int) (int, []byte, error) {
+	if len(b) < 8 {
+		return 0, nil, fmt.Errorf("invalid %v atom", name)
+	}
+	n := int(binary.BigEndian.Uint32(b[4:8]))
+	b = b[8:]
+	if n > len(b)/width {
+		return 0, nil, fmt.Errorf("invalid %v atom: %d entries do not fit in %d bytes", name, n, len(b))
+	}
+	return n, b, nil
+}
+
+// decodeChapterSample decodes a QuickTime text sample: a 16-bit length, followed by the
+// text (UTF-8, or UTF-16 with a byte order mark) and optional atoms.
+func decodeChapterSample(b []byte) (string, error) {
+	if len(b) < 2 {
+		return "", fmt.Errorf("invalid text sample: expected at least %d bytes, got %d", 2, len(b))
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < n {
+		return "", fmt.Errorf("invalid text sample: expected %d bytes of text, got %d", n, len(b))
+	}
+	b = b[:n]
+	if len(b) >= 2 && (b[0] == 0xfe && b[1] == 0xff || b[0] == 0xff && b[1] == 0xfe) {
+		return decodeUTF16WithBOM(b)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// mp4FragmentAtoms are the atoms read to find the duration of fragmented MP4 files (such
+// as DASH audio), whose movie header (mvhd) usually has a zero duration.
+var mp4FragmentAtoms = map[string]bool{
+	"tkhd": true, // track ID of the trak being read
+	"mdhd": true, // time scale of the track
+	"mehd": true, // duration of the fragmented movie
+	"trex": true, // default sample duration of a track
+	"sidx": true, // durations of the segments of a track
+	"tfhd": true, // track ID and default sample duration of a track fragment
+	"trun": true, // sample durations of a track fragment
+}
+
+// mp4Fragments accumulates the durations given by the fragment atoms of an MP4 file.
+type mp4Fragments struct {
+	fragmentDuration uint64             // from mehd, in the movie time scale
+	timeScales       map[uint32]uint32  // time scales of the tracks (from mdhd)
+	defaults         map[uint32]uint32  // default sample durations of the tracks (from trex)
+	segments         map[uint32]float64 // seconds of the segments of each track (from sidx)
+	samples          map[uint32]uint64  // total sample durations of each track (from trun)
+
+	track           uint32 // track ID of the current trak or traf atom
+	defaultDuration uint32 // default sample duration of the current traf atom
+}
+
+// mp4FullAtom checks that b is long enough for the version and flags of a "full" atom
+// followed by n bytes, returning the version and flags.
+func mp4FullAtom(b []byte, n int) (version byte, flags uint32, err error) {
+	if len(b) < 4+n {
+		return 0, 0, fmt.Errorf("invalid encoding: expected at least %d bytes, got %d", 4+n, len(b))
+	}
+	return b[0], binary.BigEndian.Uint32(b[0:4]) & 0xffffff, nil
+}
+
+// read reads the contents of the named fragment atom.
+func (f *mp4Fragments) read(name string, b []byte) error {
+	if f.timeScales == nil {
+		f.timeScales = make(map[uint32]uint32)
+		f.defaults = make(map[uint32]uint32)
+		f.segments = make(map[uint32]float64)
+		f.samples = make(map[uint32]uint64)
+	}
+
+	switch name {
+	case "tkhd":
+		// creation and modification times (32 or 64 bits), track ID
+		version, _, err := mp4FullAtom(b, 12)
+		if err != nil {
+			return err
+		}
+		if version == 1 {
+			if _, _, err := mp4FullAtom(b, 20); err != nil {
+				return err
+			}
+			f.track = binary.BigEndian.Uint32(b[20:24])
+			return nil
+		}
+		f.track = binary.BigEndian.Uint32(b[12:16])
+
+	case "mdhd":
+		// creation and modification times (32 or 64 bits), time scale
+		version, _, err := mp4FullAtom(b, 12)
+		if err != nil {
+			return err
+		}
+		if version == 1 {
+			if _, _, err := mp4FullAtom(b, 20); err != nil {
+				return err
+			}
+			f.timeScales[f.track] = binary.BigEndian.Uint32(b[20:24])
+			return nil
+		}
+		f.timeScales[f.track] = binary.BigEndian.Uint32(b[12:16])
+
+	case "mehd":
+		version, _, err := mp4FullAtom(b, 4)
+		if err != nil {
+			return err
+		}
+		if version == 1 {
+			if _, _, err := mp4FullAtom(b, 8); err != nil {
+				return err
+			}
+			f.fragmentDuration = binary.BigEndian.Uint64(b[4:12])
+			return nil
+		}
+		f.fragmentDuration = uint64(binary.BigEndian.Uint32(b[4:8]))
+
+	case "trex":
+		// track ID, default sample description index, default sample duration
+		if _, _, err := mp4FullAtom(b, 12); err != nil {
+			return err
+		}
+		f.defaults[binary.BigEndian.Uint32(b[4:8])] = binary.BigEndian.Uint32(b[12:16])
+
+	case "sidx":
+		return f.readSegmentIndex(b)
+
+	case "tfhd":
+		// track ID, then optional fields given by the flags
+		_, flags, err := mp4FullAtom(b, 4)
+		if err != nil {
+			return err
+		}
+		f.track = binary.BigEndian.Uint32(b[4:8])
+		f.defaultDuration = f.defaults[f.track]
+		offset := 8
+		if flags&0x01 != 0 { // base data offset
+			offset += 8
+		}
+		if flags&0x02 != 0 { // sample description index
+			offset += 4
+		}
+		if flags&0x08 != 0 {
+			if _, _, err := mp4FullAtom(b, offset); err != nil {
+				return err
+			}
+			f.defaultDuration = binary.BigEndian.Uint32(b[offset : offset+4])
+		}
+
+	case "trun":
+		return f.readTrackRun(b)
+	}
+	return nil
+}
+
+// readSegmentIndex reads the contents of a segment index (sidx) atom.  Only references to
+// media segments are counted, so that hierarchical indexes are not counted twice.
+func (f *mp4Fragments) readSegmentIndex(b []byte) error {
+	// reference ID, time scale, earliest presentation time and first offset (32 or 64
+	// bits), reserved (16 bits), reference count (16 bits)
+	version, _, err := mp4FullAtom(b, 20)
+	if err != nil {
+		return err
+	}
+	id, timeScale := binary.BigEndian.Uint32(b[4:8]), binary.BigEndian.Uint32(b[8:12])
+	offset := 20
+	if version == 1 {
+		offset = 28
+		if _, _, err := mp4FullAtom(b, offset); err != nil {
+			return err
+		}
+	}
+	n := int(binary.BigEndian.Uint16(b[offset+2 : offset+4]))
+	offset += 4
+	if len(b) < offset+12*n {
+		return fmt.Errorf("invalid segment index: %d references do not fit in %d bytes", n, len(b)-offset)
+	}
+	if timeScale == 0 {
+		return nil
+	}
+
+	var total uint64
+	for i := 0; i < n; i++ {
+		ref := b[offset+12*i:]
+		if ref[0]&0x80 != 0 {
+			continue // reference to another segment index
+		}
+		total += uint64(binary.BigEndian.Uint32(ref[4:8]))
+	}
+	f.segments[id] += float64(total) / float64(timeScale)
+	return nil
+}
+
+// readTrackRun reads the contents of a track fragment run (trun) atom.
+func (f *mp4Fragments) readTrackRun(b []byte) error {
+	// sample count, then optional fields given by the flags
+	_, flags, err := mp4FullAtom(b, 4)
+	if err != nil {
+		return err
+	}
+	n := int(binary.BigEndian.Uint32(b[4:8]))
+	offset := 8
+	if flags&0x01 != 0 { // data offset
+		offset += 4
+	}
+	if flags&0x04 != 0 { // first sample flags
+		offset += 4
+	}
+	if flags&0x100 == 0 {
+		f.samples[f.track] += uint64(n) * uint64(f.defaultDuration)
+		return nil
+	}
+
+	// The size of each sample record is given by the sample duration, size, flags and
+	// composition time offset flags.
+	var width int
+	for _, flag := range []uint32{0x100, 0x200, 0x400, 0x800} {
+		if flags&flag != 0 {
+			width += 4
+		}
+	}
+	if n > (len(b)-offset)/width {
+		return fmt.Errorf("invalid track run: %d samples do not fit in %d bytes", n, len(b)-offset)
+	}
+	for i := 0; i < n; i++ {
+		f.samples[f.track] += uint64(binary.BigEndian.Uint32(b[offset+width*i:]))
+	}
+	return nil
+}
+
+// duration returns the duration in seconds, given the time scale of the movie, or zero if
+// it is not known.  The duration from the movie extends header (mehd) is preferred, then
+// the segment indexes, then the total duration of the samples in the track fragments.
+func (f *mp4Fragments) duration(timeScale uint32) float64 {
+	if f.fragmentDuration > 0 && timeScale > 0 {
+		return float64(f.fragmentDuration) / float64(timeScale)
+	}
+
+	var d float64
+	for _, x := range f.segments {
+		if x > d {
+			d = x
+		}
+	}
+	if d > 0 {
+		return d
+	}
+
+	// Use the longest track.
+	for id, x := range f.samples {
+		if ts := f.timeScales[id]; ts > 0 && float64(x)/float64(ts) > d {
+			d = float64(x) / float64(ts)
+		}
+	}
+	return d
+}
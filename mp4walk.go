@@ -0,0 +1,140 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SkipAtom is used as a return value from WalkAtomFuncs to indicate that the children of
+// the atom in the call are to be skipped.  It is not returned as an error by any function.
+var SkipAtom = errors.New("skip this atom")
+
+// AtomHeader is the header of an MP4 atom.
+type AtomHeader struct {
+	Name       string
+	Offset     int64 // of the start of the atom, relative to the start of the data
+	Size       int64 // including the header
+	HeaderSize int64 // 8, or 16 if the atom has a 64-bit size
+}
+
+// WalkAtomFunc is the type of the function called by WalkAtoms for each atom.  The path is
+// the names of the atom and its ancestors, separated by "/" (such as "moov/udta/meta"), and
+// body reads the contents of the atom (after its header).  If the function returns
+// SkipAtom, the children of the atom are not visited; any other error stops the walk.
+type WalkAtomFunc func(path string, h AtomHeader, body io.Reader) error
+
+// mp4WalkContainers are the atoms whose children are visited by WalkAtoms, with the number
+// of bytes which precede their children.  The items of ilst atoms are also visited.
+var mp4WalkContainers = map[string]int64{
+	"moov": 0, "trak": 0, "mdia": 0, "minf": 0, "stbl": 0, "dinf": 0, "edts": 0,
+	"udta": 0, "ilst": 0, "tref": 0, "mvex": 0, "moof": 0, "traf": 0, "mfra": 0,
+	"sinf": 0, "schi": 0,
+	"meta": 4, // version and flags, which QuickTime files omit
+	"stsd": 8, // version and flags, number of entries
+}
+
+// WalkAtoms calls fn for each atom of the MP4 data in r, in the order they appear in the
+// file, visiting the children of container atoms after the container.  Only the headers of
+// the atoms are read, unless fn reads their contents.
+func WalkAtoms(r io.ReadSeeker, fn WalkAtomFunc) error {
+	origin := tell(r)
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	return walkAtoms(r, origin, "", "", origin, end, fn)
+}
+
+// walkAtoms visits the atoms between start and end, the contents of the atom named parent
+// at path.
+func walkAtoms(r io.ReadSeeker, origin int64, path, parent string, start, end int64, fn WalkAtomFunc) error {
+	for pos := start; pos < end; {
+		if end-pos < 8 {
+			if parent == "" {
+				return fmt.Errorf("%d trailing bytes at offset %d", end-pos, pos-origin)
+			}
+			return fmt.Errorf("%d trailing bytes in atom %q at offset %d", end-pos, parent, pos-origin)
+		}
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+		name, size, err := readAtomHeader(r)
+		if err != nil {
+			return err
+		}
+		h := AtomHeader{Name: name, Offset: pos - origin, Size: int64(size), HeaderSize: 8}
+		switch size {
+		case 0:
+			h.Size = end - pos
+		case 1:
+			x, err := readUint64BigEndian(r)
+			if err != nil {
+				return err
+			}
+			h.Size, h.HeaderSize = int64(x), 16
+		}
+		if h.Size < h.HeaderSize || h.Size > end-pos {
+			return fmt.Errorf("atom %q at offset %d: invalid size %d", name, h.Offset, h.Size)
+		}
+
+		p := name
+		if path != "" {
+			p = path + "/" + name
+		}
+		body := io.NewSectionReader(readerAt{r}, pos+h.HeaderSize, h.Size-h.HeaderSize)
+		err = fn(p, h, body)
+		if err == SkipAtom {
+			pos += h.Size
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		prefix, ok := mp4WalkContainers[name]
+		if !ok && parent == "ilst" {
+			ok = true // a metadata item, containing data atoms
+		}
+		if ok {
+			if name == "meta" {
+				// QuickTime meta atoms have no version and flags before the hdlr atom.
+				if _, err := r.Seek(pos+h.HeaderSize, io.SeekStart); err != nil {
+					return err
+				}
+				if b, err := readBytes(r, 8); err == nil && string(b[4:]) == "hdlr" {
+					prefix = 0
+				}
+			}
+			if prefix > h.Size-h.HeaderSize {
+				return fmt.Errorf("atom %q at offset %d: too short for a container", name, h.Offset)
+			}
+			if err := walkAtoms(r, origin, p, name, pos+h.HeaderSize+prefix, pos+h.Size, fn); err != nil {
+				return err
+			}
+		}
+		pos += h.Size
+	}
+	return nil
+}
+
+// readerAt implements io.ReaderAt using an io.ReadSeeker, for reading the contents of
+// atoms.  It is not safe for concurrent use.
+type readerAt struct {
+	r io.ReadSeeker
+}
+
+func (r readerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := r.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,791 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// mp4WriteContainers maps the names of the atoms which are rebuilt when writing tags to
+// the name of their parent: the path to the ilst atom and to the chunk offset tables.
+var mp4WriteContainers = map[string]string{
+	"udta": "moov",
+	"meta": "udta",
+	"ilst": "meta",
+	"trak": "moov",
+	"mdia": "trak",
+	"minf": "mdia",
+	"stbl": "minf",
+}
+
+// mp4EditAtoms maps Edit field names to the item atoms they are written to.  Other fields
+// are written as iTunes custom ("----") atoms.
+var mp4EditAtoms = map[string]string{
+	"title":        AtomTitle,
+	"album":        AtomAlbum,
+	"artist":       AtomArtist,
+	"album_artist": AtomAlbumArtist,
+	"composer":     AtomComposer,
+	"genre":        AtomGenre,
+	"year":         AtomYear,
+	"comment":      AtomComment,
+	"lyrics":       AtomLyrics,
+}
+
+// mp4Atom is an atom read for writing tags: either a container of child atoms, or an atom
+// whose contents are kept as they are.
+type mp4Atom struct {
+	name      string
+	container bool
+	prefix    []byte     // data before the children of a container (version and flags of meta)
+	children  []*mp4Atom // children of a container
+	data      []byte     // contents of other atoms
+}
+
+// parseMP4Atoms parses the atoms in b, the contents of the atom named parent.
+func parseMP4Atoms(b []byte, parent string) ([]*mp4Atom, error) {
+	var atoms []*mp4Atom
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, fmt.Errorf("%d trailing bytes in atom %q", len(b), parent)
+		}
+		size, headerSize := int64(binary.BigEndian.Uint32(b)), int64(8)
+		name := string(b[4:8])
+		switch size {
+		case 0:
+			size = int64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return nil, fmt.Errorf("atom %q: truncated 64-bit size", name)
+			}
+			size, headerSize = int64(binary.BigEndian.Uint64(b[8:16])), 16
+		}
+		if size < headerSize || size > int64(len(b)) {
+			return nil, fmt.Errorf("atom %q: invalid size %d", name, size)
+		}
+
+		a := &mp4Atom{name: name}
+		body := b[headerSize:size]
+		if p, ok := mp4WriteContainers[name]; ok && p == parent {
+			a.container = true
+			if name == "meta" {
+				if len(body) < 4 {
+					return nil, errors.New(`atom "meta": missing version and flags`)
+				}
+				// QuickTime files omit the version and flags.
+				if len(body) < 8 || string(body[4:8]) != "hdlr" {
+					a.prefix, body = body[:4], body[4:]
+				}
+			}
+			children, err := parseMP4Atoms(body, name)
+			if err != nil {
+				return nil, err
+			}
+			a.children = children
+		} else {
+			a.data = body
+		}
+		atoms = append(atoms, a)
+		b = b[size:]
+	}
+	return atoms, nil
+}
+
+// size returns the size of the atom, including its header.
+func (a *mp4Atom) size() int64 {
+	if !a.container {
+		return 8 + int64(len(a.data))
+	}
+	n := 8 + int64(len(a.prefix))
+	for _, c := range a.children {
+		n += c.size()
+	}
+	return n
+}
+
+// write appends the atom to buf.  The size of the atom must fit in 32 bits.
+func (a *mp4Atom) write(buf *bytes.Buffer) {
+	binary.Write(buf, binary.BigEndian, uint32(a.size()))
+	buf.WriteString(a.name)
+	if !a.container {
+		buf.Write(a.data)
+		return
+	}
+	buf.Write(a.prefix)
+	for _, c := range a.children {
+		c.write(buf)
+	}
+}
+
+// child returns the first child of the container with the given name, adding an empty
+// container if there is none.
+func (a *mp4Atom) child(name string) *mp4Atom {
+	for _, c := range a.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := newMP4Container(name)
+	a.children = append(a.children, c)
+	return c
+}
+
+// newMP4Container returns an empty container atom with the given name.
+func newMP4Container(name string) *mp4Atom {
+	c := &mp4Atom{name: name, container: true}
+	if name == "meta" {
+		c.prefix = make([]byte, 4)
+		// iTunes requires a metadata handler before the ilst atom.
+		c.children = []*mp4Atom{{name: "hdlr", data: []byte("\x00\x00\x00\x00\x00\x00\x00\x00mdirappl\x00\x00\x00\x00\x00\x00\x00\x00\x00")}}
+	}
+	return c
+}
+
+// itunesMeta returns the meta atom of udta which holds iTunes metadata (its handler is
+// "mdir", or it has no handler), adding one if there is none.
+func itunesMeta(udta *mp4Atom) *mp4Atom {
+	for _, c := range udta.children {
+		if c.name != "meta" || !c.container {
+			continue
+		}
+		handler := ""
+		for _, h := range c.children {
+			if h.name == "hdlr" && len(h.data) >= 12 {
+				handler = string(h.data[8:12])
+				break
+			}
+		}
+		if handler == "" || handler == "mdir" {
+			return c
+		}
+	}
+	c := newMP4Container("meta")
+	udta.children = append(udta.children, c)
+	return c
+}
+
+// walk calls f for the atom and each of its descendants.
+func (a *mp4Atom) walk(f func(*mp4Atom) error) error {
+	if err := f(a); err != nil {
+		return err
+	}
+	for _, c := range a.children {
+		if err := c.walk(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mp4TopLevelAtom is the position of an atom at the top level of an MP4 file.
+type mp4TopLevelAtom struct {
+	name        string
+	start, size int64
+}
+
+// readMP4TopLevelAtoms reads the names and positions of the top-level atoms of the file
+// from r, relative to its current position, up to end.
+func readMP4TopLevelAtoms(r io.ReadSeeker, end int64) ([]mp4TopLevelAtom, error) {
+	var atoms []mp4TopLevelAtom
+	for pos := int64(0); pos < end; {
+		name, size, err := readAtomHeader(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading atom header at offset %d: %v", pos, err)
+		}
+		atomSize, headerSize := int64(size), int64(8)
+		switch size {
+		case 0:
+			atomSize = end - pos
+		case 1:
+			x, err := readUint64BigEndian(r)
+			if err != nil {
+				return nil, err
+			}
+			atomSize, headerSize = int64(x), 16
+		}
+		if atomSize < headerSize || atomSize > end-pos {
+			return nil, fmt.Errorf("atom %q at offset %d: invalid size %d", name, pos, atomSize)
+		}
+		atoms = append(atoms, mp4TopLevelAtom{name: name, start: pos, size: atomSize})
+		if _, err := r.Seek(atomSize-headerSize, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		pos += atomSize
+	}
+	return atoms, nil
+}
+
+// DefaultMP4Padding is the size of the free atom left after the metadata when
+// UpdateMP4Tags has to move the media data, so that later edits can be made in place.
+var DefaultMP4Padding int64 = 4096
+
+// mp4Plan is a planned edit of an MP4 file: replaced bytes from offset start (relative to
+// origin) are replaced by moov (the new moov atom, and any padding), and the data which
+// followed them (up to end) moves by delta bytes.
+type mp4Plan struct {
+	origin, end     int64
+	start, replaced int64
+	moov            []byte
+	delta           int64
+}
+
+// planMP4Edit reads the MP4 data from r and plans the edit of its moov atom.  The space of the moov atom,
+// the free (or skip) atoms which follow it and the free atoms inside its meta atom is
+// reused if the new moov atom fits, with the rest left as padding.  Otherwise the data
+// after the moov atom moves: if inPlace is true the data never moves backwards (so that the
+// file does not need to be truncated) and DefaultMP4Padding bytes of padding are added.
+// Returns ErrMetadataTooLarge if the moov atom is larger than limit (if positive).
+func planMP4Edit(r io.ReadSeeker, edit func(moov *mp4Atom) error, inPlace bool, limit int64) (*mp4Plan, error) {
+	origin := tell(r)
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	end -= origin
+	if _, err := r.Seek(origin, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	atoms, err := readMP4TopLevelAtoms(r, end)
+	if err != nil {
+		return nil, err
+	}
+	moovIndex := -1
+	for i, a := range atoms {
+		if a.name == "moov" {
+			moovIndex = i
+			break
+		}
+	}
+	if moovIndex < 0 {
+		return nil, errors.New("expected MP4 moov atom")
+	}
+	moov := atoms[moovIndex]
+	if limit > 0 && moov.size > limit {
+		return nil, ErrMetadataTooLarge
+	}
+
+	if _, err := r.Seek(origin+moov.start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b, err := readBytes(r, uint(moov.size))
+	if err != nil {
+		return nil, err
+	}
+	headerSize := 8
+	if binary.BigEndian.Uint32(b) == 1 {
+		headerSize = 16
+	}
+	children, err := parseMP4Atoms(b[headerSize:], "moov")
+	if err != nil {
+		return nil, err
+	}
+	root := &mp4Atom{name: "moov", container: true, children: children}
+
+	if err := edit(root); err != nil {
+		return nil, err
+	}
+	meta := removeMP4Padding(root)
+
+	// Keep the media data at the same offset if the padding allows.
+	newSize, available := root.size(), moov.size
+	for _, a := range atoms[moovIndex+1:] {
+		if a.name != "free" && a.name != "skip" {
+			break
+		}
+		available += a.size
+	}
+	replaced, padding, delta := moov.size, int64(0), newSize-moov.size
+	if n := available - newSize; n == 0 || n >= 8 {
+		replaced, padding, delta = available, n, 0
+	} else if inPlace {
+		replaced, padding = available, DefaultMP4Padding
+		if padding < 8 {
+			padding = 0
+			if n > 0 {
+				padding = 8 // the data cannot move backwards
+			}
+		}
+		delta = newSize + padding - available
+	}
+
+	if delta != 0 {
+		for _, a := range atoms {
+			if a.name == "moof" {
+				return nil, errors.New("cannot resize the moov atom of a fragmented MP4 file")
+			}
+		}
+		if err := shiftChunkOffsets(root, moov.start+replaced, delta); err != nil {
+			return nil, err
+		}
+	}
+
+	var free *mp4Atom
+	if padding >= 8 {
+		free = &mp4Atom{name: "free", data: make([]byte, padding-8)}
+		if meta != nil {
+			// Keep the padding where it was.
+			meta.children = append(meta.children, free)
+			free = nil
+		}
+	}
+	if root.size() > math.MaxUint32 {
+		return nil, fmt.Errorf("moov atom too large: %d bytes", root.size())
+	}
+
+	buf := &bytes.Buffer{}
+	root.write(buf)
+	if free != nil {
+		free.write(buf)
+	}
+	return &mp4Plan{origin: origin, end: end, start: moov.start, replaced: replaced, moov: buf.Bytes(), delta: delta}, nil
+}
+
+// removeMP4Padding removes the free (and skip) atoms from the meta atoms of moov/udta,
+// returning the first meta atom which had any, or nil if there were none.
+func removeMP4Padding(moov *mp4Atom) *mp4Atom {
+	var padded *mp4Atom
+	for _, udta := range moov.children {
+		if udta.name != "udta" || !udta.container {
+			continue
+		}
+		for _, meta := range udta.children {
+			if meta.name != "meta" || !meta.container {
+				continue
+			}
+			children := meta.children[:0]
+			for _, c := range meta.children {
+				if c.name == "free" || c.name == "skip" {
+					if padded == nil {
+						padded = meta
+					}
+					continue
+				}
+				children = append(children, c)
+			}
+			meta.children = children
+		}
+	}
+	return padded
+}
+
+// WriteMP4Tags copies the MP4 data from r to w, updating the metadata item (ilst) atoms
+// and the chapter list with the Edit.  The moov/udta/meta/ilst hierarchy is created if
+// needed.  Free atoms following the moov atom (or inside its meta atom) are resized where
+// possible so that the media data remains at the same offset, otherwise the chunk offsets
+// (stco and co64) are shifted to match the new size of the moov atom.  Returns
+// ErrMetadataTooLarge if the moov atom is larger than DefaultMetadataLimit.
+func WriteMP4Tags(w io.Writer, r io.ReadSeeker, e *Edit) error {
+	p, err := planMP4Edit(r, func(moov *mp4Atom) error { return editMP4(moov, e) }, false, DefaultMetadataLimit)
+	if err != nil {
+		return err
+	}
+	return p.write(w, r)
+}
+
+// StripAtoms copies the MP4 data from r to w without its metadata: the udta and meta atoms
+// of the movie and its tracks (including the metadata items, chapter list and any other user
+// data) are replaced by free atoms of the same size, so that the media data remains at the
+// same offset.  Returns ErrMetadataTooLarge if the moov atom is larger than
+// DefaultMetadataLimit.
+func StripAtoms(w io.Writer, r io.ReadSeeker) error {
+	p, err := planMP4Edit(r, func(moov *mp4Atom) error {
+		stripMP4(moov)
+		for _, c := range moov.children {
+			if c.name == "trak" && c.container {
+				stripMP4(c)
+			}
+		}
+		return nil
+	}, false, DefaultMetadataLimit)
+	if err != nil {
+		return err
+	}
+	return p.write(w, r)
+}
+
+// stripMP4 replaces the udta and meta children of the atom with free atoms of the same size.
+func stripMP4(a *mp4Atom) {
+	for i, c := range a.children {
+		if c.name == "udta" || c.name == "meta" {
+			a.children[i] = &mp4Atom{name: "free", data: make([]byte, c.size()-8)}
+		}
+	}
+}
+
+// write copies the data from r to w, with the planned edit.
+func (p *mp4Plan) write(w io.Writer, r io.ReadSeeker) error {
+	if _, err := r.Seek(p.origin, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(w, r, p.start); err != nil {
+		return err
+	}
+	if _, err := w.Write(p.moov); err != nil {
+		return err
+	}
+	if _, err := r.Seek(p.origin+p.start+p.replaced, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, r)
+	return err
+}
+
+// UpdateMP4Tags updates the tags of the MP4 data in f in place, as WriteMP4Tags.  If the new
+// moov atom fits in the space of the old one and its padding (free atoms), only the
+// metadata is rewritten.  Otherwise the data which follows it is moved towards the end of
+// f, with DefaultMP4Padding bytes of padding left so that later edits can be made in
+// place.  The data is never moved backwards: when the moov atom shrinks the space is left
+// as padding, so f never needs to be truncated.
+func UpdateMP4Tags(f io.ReadWriteSeeker, e *Edit) error {
+	p, err := planMP4Edit(f, func(moov *mp4Atom) error { return editMP4(moov, e) }, true, DefaultMetadataLimit)
+	if err != nil {
+		return err
+	}
+	if p.delta > 0 {
+		if err := moveData(f, p.origin+p.start+p.replaced, p.origin+p.end, p.delta); err != nil {
+			return err
+		}
+	}
+	if _, err := f.Seek(p.origin+p.start, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = f.Write(p.moov)
+	return err
+}
+
+// moveData moves the data of f between from and end forward by delta bytes, starting at
+// the end so that no data is overwritten before it has been moved.
+func moveData(f io.ReadWriteSeeker, from, end, delta int64) error {
+	buf := make([]byte, 1<<20)
+	for end > from {
+		n := int64(len(buf))
+		if end-from < n {
+			n = end - from
+		}
+		end -= n
+		if _, err := f.Seek(end, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(f, buf[:n]); err != nil {
+			return err
+		}
+		if _, err := f.Seek(end+delta, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := f.Write(buf[:n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shiftChunkOffsets adds delta to the chunk offsets in the stco and co64 atoms of moov
+// which are at or after the offset from (the end of the original moov atom).
+func shiftChunkOffsets(moov *mp4Atom, from, delta int64) error {
+	return moov.walk(func(a *mp4Atom) error {
+		var width int
+		switch a.name {
+		case "stco":
+			width = 4
+		case "co64":
+			width = 8
+		default:
+			return nil
+		}
+		// version and flags (4 bytes), entry count (4 bytes)
+		if len(a.data) < 8 {
+			return fmt.Errorf("atom %q: invalid size %d", a.name, len(a.data)+8)
+		}
+		n := int(binary.BigEndian.Uint32(a.data[4:8]))
+		if n > (len(a.data)-8)/width {
+			return fmt.Errorf("atom %q: %d entries do not fit in %d bytes", a.name, n, len(a.data)-8)
+		}
+
+		data := append([]byte(nil), a.data...)
+		for i := 0; i < n; i++ {
+			b := data[8+i*width : 8+(i+1)*width]
+			if width == 4 {
+				x := int64(binary.BigEndian.Uint32(b))
+				if x < from {
+					continue
+				}
+				x += delta
+				if x < 0 || x > math.MaxUint32 {
+					return fmt.Errorf("chunk offset %d does not fit in stco atom", x)
+				}
+				binary.BigEndian.PutUint32(b, uint32(x))
+				continue
+			}
+			x := int64(binary.BigEndian.Uint64(b))
+			if x >= from {
+				binary.BigEndian.PutUint64(b, uint64(x+delta))
+			}
+		}
+		a.data = data
+		return nil
+	})
+}
+
+// editMP4 applies the edit to the moov atom.
+func editMP4(moov *mp4Atom, e *Edit) error {
+	udta := moov.child("udta")
+	ilst := itunesMeta(udta).child("ilst")
+
+	items, err := editMP4Items(ilst.children, e)
+	if err != nil {
+		return err
+	}
+	ilst.children = items
+
+	if e.Chapters == nil && !e.Clear {
+		return nil
+	}
+	var chpl *mp4Atom
+	if len(e.Chapters) > 0 {
+		b, err := encodeChapters(e.Chapters)
+		if err != nil {
+			return err
+		}
+		chpl = &mp4Atom{name: AtomChapters, data: b}
+	}
+	udta.children = setMP4Item(udta.children, chpl, func(a *mp4Atom) bool { return a.name == AtomChapters })
+	return nil
+}
+
+// editMP4Items applies the edit to the ilst item atoms.
+func editMP4Items(items []*mp4Atom, e *Edit) ([]*mp4Atom, error) {
+	if e.Clear {
+		items = nil
+	}
+	byName := func(names ...string) func(*mp4Atom) bool {
+		return func(a *mp4Atom) bool {
+			for _, n := range names {
+				if a.name == n {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	if e.Scrub {
+		items = setMP4Item(items, nil, byName(AccountAtoms...))
+	}
+
+	for _, f := range []struct{ atom, number, total string }{
+		{AtomTrack, "track", "track_total"},
+		{AtomDisc, "disc", "disc_total"},
+	} {
+		_, setNumber := e.Fields[f.number]
+		_, setTotal := e.Fields[f.total]
+		if !setNumber && !setTotal {
+			continue
+		}
+		x, n := mp4ItemPair(items, f.atom)
+		var err error
+		if setNumber {
+			if x, err = parseMP4Int(f.number, e.Fields[f.number], math.MaxUint16); err != nil {
+				return nil, err
+			}
+		}
+		if setTotal {
+			if n, err = parseMP4Int(f.total, e.Fields[f.total], math.MaxUint16); err != nil {
+				return nil, err
+			}
+		}
+		var a *mp4Atom
+		if x != 0 || n != 0 {
+			a = mp4DataItem(f.atom, 0, []byte{0, 0, byte(x >> 8), byte(x), byte(n >> 8), byte(n), 0, 0})
+		}
+		items = setMP4Item(items, a, byName(f.atom))
+	}
+
+	// Add the new items in a stable order.
+	names := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v := e.Fields[k]
+		switch k {
+		case "track", "track_total", "disc", "disc_total":
+			continue
+
+		case "bpm":
+			var a *mp4Atom
+			if v != "" {
+				bpm, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+				if err != nil || bpm < 0 || bpm > math.MaxUint16 {
+					return nil, fmt.Errorf("invalid bpm: %q", v)
+				}
+				x := int(bpm + 0.5)
+				a = mp4DataItem(AtomTempo, 21, []byte{byte(x >> 8), byte(x)})
+			}
+			items = setMP4Item(items, a, byName(AtomTempo))
+			continue
+
+		case "key":
+			k = "initialkey"
+		}
+
+		if name, ok := mp4EditAtoms[k]; ok {
+			var a *mp4Atom
+			if v != "" {
+				a = mp4DataItem(name, 1, []byte(v))
+			}
+			match := byName(name)
+			switch name {
+			case AtomArtist:
+				match = byName(AtomArtist, AtomArtistAlt)
+			case AtomGenre:
+				match = byName(AtomGenre, AtomGenreID) // also replace ID3v1 genre numbers
+			}
+			items = setMP4Item(items, a, match)
+			continue
+		}
+
+		var a *mp4Atom
+		if v != "" {
+			a = mp4CustomItem(k, v)
+		}
+		name := k
+		items = setMP4Item(items, a, func(a *mp4Atom) bool {
+			if a.name != AtomCustom {
+				return false
+			}
+			n, _, err := readCustomAtom(bytes.NewReader(a.data), uint32(len(a.data)+8))
+			return err == nil && strings.EqualFold(n, name)
+		})
+	}
+
+	if e.Pictures != nil {
+		var a *mp4Atom
+		if len(e.Pictures) > 0 {
+			a = &mp4Atom{name: AtomPicture, container: true}
+			for _, p := range e.Pictures {
+				class := 13 // JPEG
+				if p.MIMEType == "image/png" || strings.EqualFold(p.Ext, "png") {
+					class = 14
+				}
+				a.children = append(a.children, mp4DataAtom(class, p.Data))
+			}
+		}
+		items = setMP4Item(items, a, byName(AtomPicture))
+	}
+	return items, nil
+}
+
+// setMP4Item replaces the first atom matching match with a (or removes it if a is nil),
+// removing any others.  If there is no match then a is appended.
+func setMP4Item(items []*mp4Atom, a *mp4Atom, match func(*mp4Atom) bool) []*mp4Atom {
+	result := make([]*mp4Atom, 0, len(items)+1)
+	for _, x := range items {
+		if !match(x) {
+			result = append(result, x)
+			continue
+		}
+		if a != nil {
+			result = append(result, a)
+			a = nil
+		}
+	}
+	if a != nil {
+		result = append(result, a)
+	}
+	return result
+}
+
+// mp4ItemPair returns the number and total of the trkn or disk item.
+func mp4ItemPair(items []*mp4Atom, name string) (int, int) {
+	for _, a := range items {
+		if a.name != name {
+			continue
+		}
+		// data atom header (8 bytes), class and locale (8 bytes), padding (2 bytes)
+		if len(a.data) < 22 || string(a.data[4:8]) != "data" {
+			return 0, 0
+		}
+		b := a.data[18:22]
+		return int(binary.BigEndian.Uint16(b[0:2])), int(binary.BigEndian.Uint16(b[2:4]))
+	}
+	return 0, 0
+}
+
+// parseMP4Int parses the value of the named Edit field as an integer between 0 and max.
+func parseMP4Int(field, v string, max int) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	x, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || x < 0 || x > max {
+		return 0, fmt.Errorf("invalid %s: %q", field, v)
+	}
+	return x, nil
+}
+
+// mp4DataAtom returns a data atom with the given class and value.
+func mp4DataAtom(class int, value []byte) *mp4Atom {
+	b := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint32(b, uint32(class))
+	return &mp4Atom{name: "data", data: append(b, value...)}
+}
+
+// mp4DataItem returns an item atom with a single data atom.
+func mp4DataItem(name string, class int, value []byte) *mp4Atom {
+	return &mp4Atom{name: name, container: true, children: []*mp4Atom{mp4DataAtom(class, value)}}
+}
+
+// mp4CustomItem returns a custom ("----") item atom with a text value.  Names of the form
+// "mean:name" (see readCustomAtom) are written with the given namespace, and others in the
+// iTunes namespace.
+func mp4CustomItem(name, value string) *mp4Atom {
+	mean := itunesMean
+	if i := strings.Index(name, ":"); i > 0 {
+		mean, name = name[:i], name[i+1:]
+	}
+	return &mp4Atom{name: AtomCustom, container: true, children: []*mp4Atom{
+		{name: "mean", data: append(make([]byte, 4), mean...)},
+		{name: "name", data: append(make([]byte, 4), name...)},
+		mp4DataAtom(1, []byte(value)),
+	}}
+}
+
+// encodeChapters encodes the chapters as the contents of a version 1 Nero chapter list
+// (chpl) atom (see parseChapters).
+func encodeChapters(chapters []Chapter) ([]byte, error) {
+	b := []byte{1, 0, 0, 0, 0}
+	b = append(b, make([]byte, 4)...)
+	binary.BigEndian.PutUint32(b[5:], uint32(len(chapters)))
+	for _, c := range chapters {
+		d, err := parseChapterTime(c.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		title := c.Title
+		if len(title) > 255 {
+			n := 255
+			for n > 0 && !utf8.RuneStart(title[n]) {
+				n--
+			}
+			title = title[:n]
+		}
+		start := make([]byte, 9)
+		binary.BigEndian.PutUint64(start, uint64(d/(100*time.Nanosecond)))
+		start[8] = byte(len(title))
+		b = append(append(b, start...), title...)
+	}
+	return b, nil
+}
@@ -0,0 +1,459 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// testM4AMedia returns an M4A file with the moov atom (containing the udta atom and a
+// track with a chunk offset table) before the media data, followed by the trailing atoms.
+// The chunk offset points to the "audio data" in the mdat atom.
+func testM4AMedia(udta []byte, trailing ...[]byte) []byte {
+	ftyp := testAtom("ftyp", []byte("M4A \x00\x00\x00\x00"))
+	stco := func(offset uint32) []byte {
+		b := []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[8:], offset)
+		return testAtom("trak", testAtom("mdia", testAtom("minf", testAtom("stbl", testAtom("stco", b)))))
+	}
+	moov := testAtom("moov", stco(0), udta)
+	var n int
+	for _, b := range trailing {
+		n += len(b)
+	}
+	moov = testAtom("moov", stco(uint32(len(ftyp)+len(moov)+n+8)), udta)
+	return bytes.Join(append(append([][]byte{ftyp, moov}, trailing...), testAtom("mdat", []byte("audio data"))), nil)
+}
+
+// testChunkOffset returns the data at the chunk offset of the first stco atom in b.
+func testChunkOffset(t *testing.T, b []byte) []byte {
+	i := bytes.Index(b, []byte("stco"))
+	if i < 0 {
+		t.Fatal("no stco atom")
+	}
+	offset := binary.BigEndian.Uint32(b[i+12:])
+	if int(offset) > len(b) {
+		t.Fatalf("chunk offset %d beyond end of file (%d bytes)", offset, len(b))
+	}
+	return b[offset:]
+}
+
+func TestWriteMP4Tags(t *testing.T) {
+	ilst := testAtom("udta", testAtom("meta", make([]byte, 4),
+		testAtom("ilst",
+			testTextItem(AtomTitle, "Title"),
+			testTextItem(AtomArtistAlt, "Artist"),
+			testAtom(AtomTrack, testAtom("data", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 3, 0, 12, 0, 0})),
+			testAtom("xxxx", testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("unknown"))),
+		)))
+	edit := &Edit{
+		Fields: map[string]string{
+			"title":                                "New Title",
+			"artist":                               "New Artist",
+			"album":                                "Album",
+			"track_total":                          "14",
+			"bpm":                                  "120",
+			"key":                                  "Am",
+			"mood":                                 "Happy",
+			"org.musicbrainz:MusicBrainz Track Id": "b1a9c0e9",
+		},
+		Pictures: []*Picture{{MIMEType: "image/png", Data: append(append([]byte(nil), pngHeader...), "png"...)}},
+		Chapters: []Chapter{{StartTime: "0.000", Title: "One"}, {StartTime: "61.500", Title: "Two"}},
+	}
+
+	tests := []struct {
+		in       []byte
+		sameSize bool
+	}{
+		{testM4AMedia(ilst), false},
+		{testM4AMedia(ilst, testAtom("free", make([]byte, 1024))), true},
+		{testM4AMedia(testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst"), testAtom("free", make([]byte, 1024))))), true},
+		{testM4AMedia(testAtom("udta")), false},
+		{testM4AMedia(nil), false},
+	}
+
+	for ii, tt := range tests {
+		out := &bytes.Buffer{}
+		if err := WriteTags(out, bytes.NewReader(tt.in), edit); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		b := out.Bytes()
+
+		if tt.sameSize && len(b) != len(tt.in) {
+			t.Errorf("[%d] expected free atom to be reused: got %d bytes, expected %d", ii, len(b), len(tt.in))
+		}
+		if got := testChunkOffset(t, b); !bytes.HasPrefix(got, []byte("audio data")) {
+			t.Errorf("[%d] chunk offset not updated: points to %q", ii, got)
+		}
+
+		m, err := ReadFrom(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("[%d] unexpected error reading written file: %v", ii, err)
+			continue
+		}
+		if m.Title() != "New Title" || m.Artist() != "New Artist" || m.Album() != "Album" {
+			t.Errorf("[%d] Title(), Artist(), Album() = %q, %q, %q", ii, m.Title(), m.Artist(), m.Album())
+		}
+		if BPM(m) != 120 || InitialKey(m) != ParseKey("Am") {
+			t.Errorf("[%d] BPM(), Key() = %v, %v, expected 120, Am", ii, BPM(m), InitialKey(m))
+		}
+		if got := m.Raw()["mood"]; got != "Happy" {
+			t.Errorf("[%d] Raw()[\"mood\"] = %v, expected \"Happy\"", ii, got)
+		}
+		if got := m.Raw()["org.musicbrainz:MusicBrainz Track Id"]; got != "b1a9c0e9" {
+			t.Errorf("[%d] Raw()[\"org.musicbrainz:MusicBrainz Track Id\"] = %v, expected \"b1a9c0e9\"", ii, got)
+		}
+		if p := m.Picture(); p == nil || p.MIMEType != "image/png" {
+			t.Errorf("[%d] Picture() = %v, expected PNG picture", ii, p)
+		}
+		var titles []string
+		for _, c := range Chapters(m) {
+			titles = append(titles, c.StartTime+" "+c.Title)
+		}
+		if want := []string{"0.000 One", "61.500 Two"}; !reflect.DeepEqual(titles, want) {
+			t.Errorf("[%d] Chapters() = %v, expected %v", ii, titles, want)
+		}
+	}
+
+	// The existing track number is kept when only the total is set, and unknown items are kept.
+	out := &bytes.Buffer{}
+	if err := WriteTags(out, bytes.NewReader(tests[0].in), edit); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, err := ReadFrom(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if x, n := m.Track(); x != 3 || n != 14 {
+		t.Errorf("Track() = %d, %d, expected 3, 14", x, n)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("xxxx")) {
+		t.Errorf("unknown item atom was not preserved")
+	}
+	if bytes.Contains(out.Bytes(), []byte(AtomArtistAlt)) {
+		t.Errorf("expected %q atom to be replaced", AtomArtistAlt)
+	}
+}
+
+func TestWriteMP4TagsRemove(t *testing.T) {
+	in := testM4AMedia(testAtom("udta", testAtom("meta", make([]byte, 4),
+		testAtom("ilst",
+			testTextItem(AtomTitle, "Title"),
+			testTextItem(AtomAlbum, "Album"),
+			testAtom(AtomCustom,
+				testAtom("mean", make([]byte, 4), []byte("com.apple.iTunes")),
+				testAtom("name", make([]byte, 4), []byte("MOOD")),
+				testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("Happy"))),
+		))))
+
+	tests := []struct {
+		edit *Edit
+		want map[string]interface{}
+	}{
+		{
+			&Edit{Fields: map[string]string{"album": "", "mood": ""}},
+			map[string]interface{}{AtomTitle: "Title"},
+		},
+		{
+			&Edit{Clear: true, Fields: map[string]string{"track": "2", "disc": "1", "disc_total": "2"}},
+			map[string]interface{}{AtomTrack: 2, AtomTrackTotal: 0, AtomDisc: 1, AtomDiscTotal: 2},
+		},
+	}
+
+	for ii, tt := range tests {
+		out := &bytes.Buffer{}
+		if err := WriteMP4Tags(out, bytes.NewReader(in), tt.edit); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := testChunkOffset(t, out.Bytes()); !bytes.HasPrefix(got, []byte("audio data")) {
+			t.Errorf("[%d] chunk offset not updated: points to %q", ii, got)
+		}
+		m, err := ReadAtoms(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Errorf("[%d] unexpected error reading written file: %v", ii, err)
+			continue
+		}
+		if got := m.Raw(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%d] Raw() = %v, expected %v", ii, got, tt.want)
+		}
+	}
+}
+
+func TestWriteMP4TagsStableOrder(t *testing.T) {
+	in := testM4AMedia(testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem(AtomTitle, "Title")))))
+	e := &Edit{Fields: map[string]string{
+		"album": "Album", "artist": "Artist", "composer": "Composer", "genre": "Jazz", "year": "2001",
+		"comment": "Comment", "bpm": "120", "key": "Am", "mood": "Happy", "rating": "5",
+	}}
+
+	var first []byte
+	for i := 0; i < 10; i++ {
+		out := &bytes.Buffer{}
+		if err := WriteMP4Tags(out, bytes.NewReader(in), e); err != nil {
+			t.Fatalf("[%d] unexpected error: %v", i, err)
+		}
+		if i == 0 {
+			first = out.Bytes()
+		} else if !bytes.Equal(out.Bytes(), first) {
+			t.Fatalf("[%d] output differs from the first write of the same edit", i)
+		}
+	}
+}
+
+func TestWriteMP4TagsScrub(t *testing.T) {
+	in := testM4AMedia(testAtom("udta", testAtom("meta", make([]byte, 4),
+		testAtom("ilst",
+			testTextItem(AtomTitle, "Title"),
+			testTextItem(AtomAppleID, "buyer@example.com"),
+			testTextItem(AtomOwner, "A Buyer"),
+			testTextItem(AtomPurchaseDate, "2010-01-02 03:04:05"),
+		))))
+
+	m, err := ReadAtoms(bytes.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &Account{AppleID: "buyer@example.com", Owner: "A Buyer", PurchaseDate: "2010-01-02 03:04:05"}
+	if got := AccountInfo(m); got == nil || *got != *want {
+		t.Errorf("AccountInfo() = %+v, expected %+v", got, want)
+	}
+
+	out := &bytes.Buffer{}
+	if err := WriteMP4Tags(out, bytes.NewReader(in), &Edit{Scrub: true, Fields: map[string]string{"album": "Album"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, err = ReadAtoms(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error reading written file: %v", err)
+	}
+	if got, want := m.Raw(), map[string]interface{}{AtomTitle: "Title", AtomAlbum: "Album"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Raw() = %v, expected %v", got, want)
+	}
+	if got := AccountInfo(m); got != nil {
+		t.Errorf("AccountInfo() = %+v, expected nil", got)
+	}
+}
+
+func TestWriteMP4TagsMetaHandler(t *testing.T) {
+	hdlr := func(handler string) []byte {
+		return testAtom("hdlr", make([]byte, 8), []byte(handler), make([]byte, 12))
+	}
+	ilst := testAtom("ilst", testTextItem(AtomTitle, "Title"), testTextItem(AtomAlbum, "Album"))
+
+	tests := []struct {
+		udta  []byte
+		album string
+	}{
+		// QuickTime meta atom, without version and flags.
+		{testAtom("udta", testAtom("meta", hdlr("mdir"), ilst)), "Album"},
+		// The ID3 metadata is left alone, and iTunes metadata added.
+		{testAtom("udta", testAtom("meta", make([]byte, 4), hdlr("ID32"), ilst)), ""},
+	}
+
+	for ii, tt := range tests {
+		out := &bytes.Buffer{}
+		edit := &Edit{Fields: map[string]string{"title": "New Title"}}
+		if err := WriteMP4Tags(out, bytes.NewReader(testM4AMedia(tt.udta)), edit); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		m, err := ReadAtoms(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Errorf("[%d] unexpected error reading written file: %v", ii, err)
+			continue
+		}
+		if m.Title() != "New Title" || m.Album() != tt.album {
+			t.Errorf("[%d] Title(), Album() = %q, %q, expected \"New Title\", %q", ii, m.Title(), m.Album(), tt.album)
+		}
+	}
+}
+
+// testFile is an in-memory io.ReadWriteSeeker.
+type testFile struct {
+	b   []byte
+	pos int64
+}
+
+func (f *testFile) Read(p []byte) (int, error) {
+	if f.pos >= int64(len(f.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.b[f.pos:])
+	f.pos += int64(n)
+	return n, nil
+}
+
+func (f *testFile) Write(p []byte) (int, error) {
+	if end := f.pos + int64(len(p)); end > int64(len(f.b)) {
+		f.b = append(f.b, make([]byte, end-int64(len(f.b)))...)
+	}
+	n := copy(f.b[f.pos:], p)
+	f.pos += int64(n)
+	return n, nil
+}
+
+func (f *testFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += int64(len(f.b))
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func TestUpdateMP4Tags(t *testing.T) {
+	ilst := testAtom("ilst", testTextItem(AtomTitle, "Title"), testTextItem(AtomAlbum, "Album"))
+	udta := testAtom("udta", testAtom("meta", make([]byte, 4), ilst))
+	free := testAtom("free", make([]byte, 1024))
+
+	long := &Edit{Fields: map[string]string{"title": "A Much Longer Title", "comment": "Comment"}}
+	short := &Edit{Fields: map[string]string{"title": "T", "album": ""}}
+
+	tests := []struct {
+		in      []byte
+		edit    *Edit
+		growth  int64 // of the file
+		title   string
+		album   string
+		inPlace bool // the media data is not moved
+	}{
+		{testM4AMedia(udta, free), long, 0, "A Much Longer Title", "Album", true},
+		{testM4AMedia(udta, testAtom("skip", make([]byte, 8)), free), long, 0, "A Much Longer Title", "Album", true},
+		{testM4AMedia(testAtom("udta", testAtom("meta", make([]byte, 4), ilst, free))), long, 0, "A Much Longer Title", "Album", true},
+		{testM4AMedia(udta), short, 0, "T", "", true},
+		{testM4AMedia(udta), long, 45 + DefaultMP4Padding, "A Much Longer Title", "Album", false},
+		{testM4AMedia(nil), long, 135 + DefaultMP4Padding, "A Much Longer Title", "", false},
+	}
+
+	for ii, tt := range tests {
+		f := &testFile{b: append([]byte(nil), tt.in...)}
+		if err := UpdateMP4Tags(f, tt.edit); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := int64(len(f.b) - len(tt.in)); got != tt.growth {
+			t.Errorf("[%d] file grew by %d bytes, expected %d", ii, got, tt.growth)
+		}
+		if got := testChunkOffset(t, f.b); !bytes.HasPrefix(got, []byte("audio data")) {
+			t.Errorf("[%d] chunk offset not updated: points to %q", ii, got)
+		}
+		if moved := !bytes.Equal(f.b[len(f.b)-18:], tt.in[len(tt.in)-18:]) || len(f.b) != len(tt.in); moved == tt.inPlace {
+			t.Errorf("[%d] media data moved = %v, expected %v", ii, moved, !tt.inPlace)
+		}
+
+		m, err := ReadAtoms(bytes.NewReader(f.b))
+		if err != nil {
+			t.Errorf("[%d] unexpected error reading updated file: %v", ii, err)
+			continue
+		}
+		if m.Title() != tt.title || m.Album() != tt.album {
+			t.Errorf("[%d] Title(), Album() = %q, %q, expected %q, %q", ii, m.Title(), m.Album(), tt.title, tt.album)
+		}
+		if len(Warnings(m)) != 0 {
+			t.Errorf("[%d] unexpected warnings: %v", ii, Warnings(m))
+		}
+
+		// The padding left by the update is enough for another edit in place.
+		n := len(f.b)
+		f.pos = 0
+		if err := UpdateMP4Tags(f, short); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if len(f.b) != n {
+			t.Errorf("[%d] second update changed the size from %d to %d", ii, n, len(f.b))
+		}
+	}
+}
+
+func TestStripAtoms(t *testing.T) {
+	udta := testAtom("udta",
+		testAtom(AtomChapters, make([]byte, 9)),
+		testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem(AtomTitle, "Secret Title"), testTextItem(AtomArtist, "Secret Artist"))),
+		testAtom("\xa9xyz", []byte("+51.5+000.1/")),
+	)
+
+	tests := []struct {
+		in []byte
+	}{
+		{testM4AMedia(udta)},
+		{testM4AMedia(udta, testAtom("free", make([]byte, 64)))},
+		{testM4AMedia(testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem(AtomTitle, "Secret Title"))))},
+		{testM4AMedia(nil)},
+	}
+
+	for ii, tt := range tests {
+		out := &bytes.Buffer{}
+		if err := StripAtoms(out, bytes.NewReader(tt.in)); err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		b := out.Bytes()
+		if len(b) != len(tt.in) {
+			t.Errorf("[%d] stripped file is %d bytes, expected %d", ii, len(b), len(tt.in))
+		}
+		if bytes.Contains(b, []byte("Secret")) || bytes.Contains(b, []byte("+51.5")) {
+			t.Errorf("[%d] metadata not removed: %q", ii, b)
+		}
+		if got := testChunkOffset(t, b); !bytes.HasPrefix(got, []byte("audio data")) {
+			t.Errorf("[%d] chunk offset not updated: points to %q", ii, got)
+		}
+		m, err := ReadAtoms(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("[%d] unexpected error reading stripped file: %v", ii, err)
+			continue
+		}
+		if raw := m.Raw(); len(raw) != 0 || len(Warnings(m)) != 0 {
+			t.Errorf("[%d] Raw(), Warnings() = %v, %v, expected no metadata or warnings", ii, raw, Warnings(m))
+		}
+	}
+}
+
+func TestWriteMP4TagsErrors(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		edit *Edit
+	}{
+		{testAtom("ftyp", []byte("M4A \x00\x00\x00\x00")), &Edit{}},
+		{testM4AMedia(nil), &Edit{Fields: map[string]string{"track": "x"}}},
+		{testM4AMedia(nil), &Edit{Fields: map[string]string{"bpm": "-1"}}},
+		{testM4AMedia(nil), &Edit{Chapters: []Chapter{{StartTime: "x"}}}},
+	}
+
+	for ii, tt := range tests {
+		if err := WriteTags(&bytes.Buffer{}, bytes.NewReader(tt.in), tt.edit); err == nil {
+			t.Errorf("[%d] expected error", ii)
+		}
+	}
+}
+
+func TestPlanMP4EditLimit(t *testing.T) {
+	defer func(n int64) { DefaultMetadataLimit = n }(DefaultMetadataLimit)
+	DefaultMetadataLimit = 1
+
+	in := testM4AMedia(testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem(AtomTitle, "Title")))))
+	edit := func(moov *mp4Atom) error { return nil }
+
+	// The limit is given explicitly, whatever DefaultMetadataLimit is.
+	for _, limit := range []int64{0, int64(len(in))} {
+		if _, err := planMP4Edit(bytes.NewReader(in), edit, false, limit); err != nil {
+			t.Errorf("limit %d: unexpected error: %v", limit, err)
+		}
+	}
+	if _, err := planMP4Edit(bytes.NewReader(in), edit, false, 16); err != ErrMetadataTooLarge {
+		t.Errorf("limit 16: error = %v, expected ErrMetadataTooLarge", err)
+	}
+	if err := WriteMP4Tags(&bytes.Buffer{}, bytes.NewReader(in), &Edit{}); err != ErrMetadataTooLarge {
+		t.Errorf("WriteMP4Tags: error = %v, expected ErrMetadataTooLarge", err)
+	}
+}
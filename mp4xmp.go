@@ -0,0 +1,166 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// xmpUUID is the extended type of the uuid atom containing an XMP packet
+// (BE7ACFCB-97A9-42E8-9C71-999491E3AFAC).
+const xmpUUID = "\xbe\x7a\xcf\xcb\x97\xa9\x42\xe8\x9c\x71\x99\x94\x91\xe3\xaf\xac"
+
+// XML namespaces of XMP packets.
+const (
+	xmpRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmpDC  = "http://purl.org/dc/elements/1.1/"
+	xmpXML = "http://www.w3.org/XML/1998/namespace"
+)
+
+// xmpDCFields are the Dublin Core properties read from XMP packets, and the names of the
+// raw fields they are stored as.
+var xmpDCFields = map[string]string{
+	"title":       "dc:title",
+	"creator":     "dc:creator",
+	"description": "dc:description",
+}
+
+// DublinCore is the Dublin Core metadata of an XMP packet.
+type DublinCore struct {
+	Title       string
+	Creators    []string
+	Description string
+}
+
+// DublinCoreInfo returns the Dublin Core metadata of the XMP packet of an MP4 file (stored
+// in a uuid atom, as by Adobe applications and some field recorders), or nil if it does
+// not have one or m is not the metadata of an MP4 file.
+func DublinCoreInfo(m Metadata) *DublinCore {
+	mp4, ok := m.(*metadataMP4)
+	if !ok {
+		return nil
+	}
+	dc := &DublinCore{
+		Title:       mp4.getString([]string{"dc:title"}),
+		Creators:    mp4.getStrings([]string{"dc:creator"}),
+		Description: mp4.getString([]string{"dc:description"}),
+	}
+	if dc.Title == "" && len(dc.Creators) == 0 && dc.Description == "" {
+		return nil
+	}
+	return dc
+}
+
+// readXMP reads the Dublin Core properties of the XMP packet b.  The properties are either
+// attributes of an rdf:Description element, or elements whose value is text or an array
+// (rdf:Alt, rdf:Bag or rdf:Seq) of rdf:li elements.  Of the items of a language
+// alternative (rdf:Alt), only the default (x-default) is kept.
+func (m *metadataMP4) readXMP(b []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(bytes.TrimRight(b, "\x00 \t\r\n")))
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if e.Name.Space == xmpRDF && e.Name.Local == "Description" {
+			for _, a := range e.Attr {
+				if name, ok := xmpDCFields[a.Name.Local]; ok && a.Name.Space == xmpDC && a.Value != "" {
+					m.set(name, m.rc.sanitizeText(a.Value))
+				}
+			}
+			continue
+		}
+		name, ok := xmpDCFields[e.Name.Local]
+		if !ok || e.Name.Space != xmpDC {
+			continue
+		}
+		values, err := readXMPValues(d, m.rc)
+		if err != nil {
+			return err
+		}
+		switch len(values) {
+		case 0:
+		case 1:
+			m.set(name, values[0])
+		default:
+			m.set(name, values)
+		}
+	}
+}
+
+// readXMPValues reads the values of the property whose start element has just been read,
+// up to and including its end element.
+func readXMPValues(d *xml.Decoder, rc *readContext) ([]string, error) {
+	var values []string
+	var text, item []byte
+	var alt, inItem, isDefault bool
+	var def string
+	for depth := 1; depth > 0; {
+		t, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case t.Name.Space == xmpRDF && t.Name.Local == "Alt":
+				alt = true
+			case t.Name.Space == xmpRDF && t.Name.Local == "li":
+				inItem, isDefault, item = true, false, item[:0]
+				for _, a := range t.Attr {
+					if a.Name.Space == xmpXML && a.Name.Local == "lang" {
+						isDefault = a.Value == "x-default"
+					}
+				}
+			}
+
+		case xml.EndElement:
+			depth--
+			if inItem && t.Name.Space == xmpRDF && t.Name.Local == "li" {
+				inItem = false
+				s := rc.sanitizeText(string(bytes.TrimSpace(item)))
+				if s == "" {
+					continue
+				}
+				if isDefault && def == "" {
+					def = s
+				}
+				values = append(values, s)
+			}
+
+		case xml.CharData:
+			if inItem {
+				item = append(item, t...)
+			} else if depth == 1 {
+				text = append(text, t...)
+			}
+		}
+	}
+	if alt && len(values) > 1 {
+		if def == "" {
+			def = values[0]
+		}
+		return []string{def}, nil
+	}
+	if len(values) == 0 {
+		if s := rc.sanitizeText(string(bytes.TrimSpace(text))); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Types of the values of the Windows Xtra atom.
+const (
+	xtraUint32   = 3
+	xtraString   = 8 // UTF-16 (little endian), null terminated
+	xtraUint64   = 19
+	xtraFileTime = 21 // 100-nanosecond intervals since 1601-01-01 UTC
+	xtraGUID     = 72
+)
+
+// xtraEpoch is the start of Windows FILETIME values (1601-01-01) in Unix time.
+const xtraEpoch = -11644473600
+
+// readXtra reads the contents of the moov/udta/Xtra atom written by Windows (Explorer and
+// Windows Media Player), whose fields have WM/-style names (such as "WM/SharedUserRating").
+// The fields are a sequence of: size (int32, including itself), name length (int32), name,
+// number of values (int32), then the values, each of which is its size (int32, including
+// itself), type (int16) and data.
+func (m *metadataMP4) readXtra(b []byte) error {
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return fmt.Errorf("invalid field: %d trailing bytes", len(b))
+		}
+		size := int(binary.BigEndian.Uint32(b))
+		if size < 12 || size > len(b) {
+			return fmt.Errorf("invalid field size %d", size)
+		}
+		field := b[4:size]
+		b = b[size:]
+
+		n := int(binary.BigEndian.Uint32(field))
+		if n > len(field)-8 {
+			return fmt.Errorf("invalid field: name length %d exceeds %d bytes", n, len(field)-8)
+		}
+		name := string(field[4 : 4+n])
+		count := int(binary.BigEndian.Uint32(field[4+n:]))
+		field = field[8+n:]
+
+		var values []interface{}
+		for i := 0; i < count; i++ {
+			if len(field) < 6 {
+				return fmt.Errorf("field %q: value %d missing", name, i+1)
+			}
+			size := int(binary.BigEndian.Uint32(field))
+			if size < 6 || size > len(field) {
+				return fmt.Errorf("field %q: invalid value size %d", name, size)
+			}
+			v, err := decodeXtraValue(binary.BigEndian.Uint16(field[4:6]), field[6:size], m.rc)
+			if err != nil {
+				return fmt.Errorf("field %q: %v", name, err)
+			}
+			values = append(values, v)
+			field = field[size:]
+		}
+
+		switch len(values) {
+		case 0:
+		case 1:
+			m.set(name, values[0])
+		default:
+			// Multiple strings are kept as a slice, as for ilst items.
+			var s []string
+			for _, v := range values {
+				if x, ok := v.(string); ok {
+					s = append(s, x)
+				}
+			}
+			if len(s) == len(values) {
+				m.set(name, s)
+				continue
+			}
+			m.set(name, values[0])
+		}
+	}
+	return nil
+}
+
+// decodeXtraValue decodes the data of a value of the Xtra atom with the given type.
+func decodeXtraValue(typ uint16, b []byte, rc *readContext) (interface{}, error) {
+	switch typ {
+	case xtraString:
+		s, err := decodeUTF16(b, binary.LittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		return rc.sanitizeText(strings.TrimRight(s, "\x00")), nil
+
+	case xtraUint32:
+		if len(b) != 4 {
+			return nil, fmt.Errorf("invalid 32-bit integer: %d bytes", len(b))
+		}
+		return int(binary.LittleEndian.Uint32(b)), nil
+
+	case xtraUint64:
+		if len(b) != 8 {
+			return nil, fmt.Errorf("invalid 64-bit integer: %d bytes", len(b))
+		}
+		return int(binary.LittleEndian.Uint64(b)), nil
+
+	case xtraFileTime:
+		if len(b) != 8 {
+			return nil, fmt.Errorf("invalid file time: %d bytes", len(b))
+		}
+		ticks := binary.LittleEndian.Uint64(b)
+		return time.Unix(xtraEpoch+int64(ticks/1e7), int64(ticks%1e7)*100).UTC(), nil
+
+	case xtraGUID:
+		if len(b) != 16 {
+			return nil, fmt.Errorf("invalid GUID: %d bytes", len(b))
+		}
+		return fmt.Sprintf("{%08X-%04X-%04X-%X-%X}", binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]),
+			binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16]), nil
+	}
+	return append([]byte(nil), b...), nil
+}
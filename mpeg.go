@@ -0,0 +1,445 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// mpegVersion is the version of the MPEG audio standard of a frame.
+type mpegVersion int
+
+// MPEG audio versions.
+const (
+	mpeg1  mpegVersion = 1
+	mpeg2  mpegVersion = 2
+	mpeg25 mpegVersion = 3 // unofficial MPEG 2.5 extension for low sample rates
+)
+
+func (v mpegVersion) String() string {
+	switch v {
+	case mpeg1:
+		return "MPEG-1"
+	case mpeg2:
+		return "MPEG-2"
+	case mpeg25:
+		return "MPEG-2.5"
+	}
+	return fmt.Sprintf("mpegVersion(%d)", int(v))
+}
+
+// mpegLayers are the names of MPEG audio layers I, II and III, and their codecs.
+var mpegLayers = [3][2]string{{"I", "MP1"}, {"II", "MP2"}, {"III", "MP3"}}
+
+// mpegChannelModes are the names of the channel modes of MPEG audio frames.
+var mpegChannelModes = [4]string{"stereo", "joint stereo", "dual channel", "mono"}
+
+// mpegBitrates are the bitrates (in kbit/s) of the bitrate indexes 1-14 of MPEG-1 layers
+// I, II and III and MPEG-2/2.5 layers I and II/III.
+var mpegBitrates = [5][14]int{
+	{32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+	{32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+	{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+	{8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// mpegSampleRates are the sample rates (in Hz) of the sample rate indexes 0-2 of each
+// mpegVersion.
+var mpegSampleRates = map[mpegVersion][3]int{
+	mpeg1:  {44100, 48000, 32000},
+	mpeg2:  {22050, 24000, 16000},
+	mpeg25: {11025, 12000, 8000},
+}
+
+// mpegFrameHeader is the header of an MPEG audio frame.
+type mpegFrameHeader struct {
+	version     mpegVersion
+	layer       int  // 1, 2 or 3
+	crc         bool // the header is followed by a 16-bit CRC
+	bitrate     int  // in kbit/s
+	sampleRate  int  // in Hz
+	padding     bool // the frame has an extra slot
+	channelMode byte // 0: stereo, 1: joint stereo, 2: dual channel, 3: mono
+}
+
+// parseMPEGFrameHeader parses the 4-byte MPEG audio frame header at the start of b.
+// Returns false if b does not start with a valid header (including free format frames,
+// whose size cannot be found from their header).
+func parseMPEGFrameHeader(b []byte) (mpegFrameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return mpegFrameHeader{}, false
+	}
+	var h mpegFrameHeader
+	switch (b[1] >> 3) & 0x03 {
+	case 0:
+		h.version = mpeg25
+	case 2:
+		h.version = mpeg2
+	case 3:
+		h.version = mpeg1
+	default:
+		return mpegFrameHeader{}, false
+	}
+	h.layer = 4 - int((b[1]>>1)&0x03)
+	if h.layer == 4 {
+		return mpegFrameHeader{}, false
+	}
+	h.crc = b[1]&0x01 == 0
+
+	bitrate, sampleRate := int(b[2]>>4), int((b[2]>>2)&0x03)
+	if bitrate == 0 || bitrate == 15 || sampleRate == 3 {
+		return mpegFrameHeader{}, false
+	}
+	table := h.layer - 1
+	if h.version != mpeg1 {
+		table = 3
+		if h.layer > 1 {
+			table = 4
+		}
+	}
+	h.bitrate = mpegBitrates[table][bitrate-1]
+	h.sampleRate = mpegSampleRates[h.version][sampleRate]
+	h.padding = getBit(b[2], 1)
+	h.channelMode = b[3] >> 6
+	return h, true
+}
+
+// samples returns the number of samples (per channel) in the frame.
+func (h mpegFrameHeader) samples() int {
+	switch {
+	case h.layer == 1:
+		return 384
+	case h.layer == 3 && h.version != mpeg1:
+		return 576
+	}
+	return 1152
+}
+
+// size returns the size of the frame in bytes, including its header.
+func (h mpegFrameHeader) size() int {
+	if h.layer == 1 {
+		n := 12 * h.bitrate * 1000 / h.sampleRate
+		if h.padding {
+			n++
+		}
+		return n * 4
+	}
+	n := h.samples() / 8 * h.bitrate * 1000 / h.sampleRate
+	if h.padding {
+		n++
+	}
+	return n
+}
+
+// sideInfoSize returns the size of the layer III side information which follows the
+// header (and CRC) of the frame.
+func (h mpegFrameHeader) sideInfoSize() int {
+	mono := h.channelMode == 3
+	switch {
+	case h.version == mpeg1 && mono:
+		return 17
+	case h.version == mpeg1:
+		return 32
+	case mono:
+		return 9
+	}
+	return 17
+}
+
+// VBRHeader is the Xing/Info or VBRI header in the first frame of an MP3 file, which
+// describes the whole audio stream so that the duration of variable bitrate files can be
+// found without reading every frame.
+type VBRHeader struct {
+	Type   string // "Xing", "Info" (written by LAME for constant bitrate files) or "VBRI"
+	Frames uint32 // number of audio frames (excluding the frame of the header), or 0 if unknown
+	Bytes  uint32 // size of the audio in bytes, or 0 if unknown
+	TOC    []byte // Xing seek table: the position of each percent of the duration, in 256ths of Bytes
+}
+
+// parseVBRHeader parses the Xing/Info or VBRI header in the MPEG audio frame b, which has
+// header h, returning nil if there is none.
+func parseVBRHeader(h mpegFrameHeader, b []byte) *VBRHeader {
+	// The Xing header follows the side information, VBRI a fixed 32 bytes.
+	i := 4 + h.sideInfoSize()
+	if h.crc {
+		i += 2
+	}
+	if len(b) >= i+8 && (string(b[i:i+4]) == "Xing" || string(b[i:i+4]) == "Info") {
+		v := &VBRHeader{Type: string(b[i : i+4])}
+		flags := binary.BigEndian.Uint32(b[i+4:])
+		b = b[i+8:]
+		if flags&0x01 != 0 && len(b) >= 4 {
+			v.Frames, b = binary.BigEndian.Uint32(b), b[4:]
+		}
+		if flags&0x02 != 0 && len(b) >= 4 {
+			v.Bytes, b = binary.BigEndian.Uint32(b), b[4:]
+		}
+		if flags&0x04 != 0 && len(b) >= 100 {
+			v.TOC = append([]byte(nil), b[:100]...)
+		}
+		return v
+	}
+
+	// VBRI: version, delay and quality (16 bits), then bytes and frames (32 bits).
+	if len(b) >= 36+18 && string(b[36:40]) == "VBRI" {
+		return &VBRHeader{
+			Type:   "VBRI",
+			Bytes:  binary.BigEndian.Uint32(b[46:]),
+			Frames: binary.BigEndian.Uint32(b[50:]),
+		}
+	}
+	return nil
+}
+
+// DefaultMP3Audio enables reading the MPEG audio stream of MP3 files (the first frame, and
+// its Xing/Info or VBRI header), which gives their duration (see DurationInfo) and
+// Properties.  It is off by default, so that reading tags only reads the tags: the audio
+// is searched for in up to 64KB following them.  The audio does not count towards the
+// metadata limit.  ReadOptions.MP3Audio sets it for a single read.
+var DefaultMP3Audio = false
+
+// DefaultMP3FrameScan enables counting every frame of MP3 files which do not have a
+// Xing/Info or VBRI header giving their number of frames, so that their duration is exact
+// rather than estimated from the bitrate of the first frame (which is only accurate for
+// constant bitrate files).  It reads the header of every frame, so is slow for remote files.
+// It implies DefaultMP3Audio.  ReadOptions.MP3FrameScan sets it for a single read.
+var DefaultMP3FrameScan = false
+
+// DurationAccuracy describes how the duration of a track was found.
+type DurationAccuracy int
+
+// Duration accuracies.
+const (
+	DurationUnknown   DurationAccuracy = iota // the duration is not known
+	DurationExact                             // from the tags or headers, or by counting frames
+	DurationEstimated                         // from the bitrate of the first MP3 frame and the size of the audio
+)
+
+func (a DurationAccuracy) String() string {
+	switch a {
+	case DurationUnknown:
+		return "unknown"
+	case DurationExact:
+		return "exact"
+	case DurationEstimated:
+		return "estimated"
+	}
+	return fmt.Sprintf("DurationAccuracy(%d)", int(a))
+}
+
+// DurationInfo returns the duration of the track and how it was found.  The duration of an
+// MP3 file is only known if its audio was read (see DefaultMP3Audio): it is exact if it
+// has a Xing/Info or VBRI header giving its number of frames (or if DefaultMP3FrameScan is
+// set), and otherwise estimated.  The durations of other files are
+// those returned by m.Duration().
+func DurationInfo(m Metadata) (time.Duration, DurationAccuracy) {
+	if a := mpegAudioOf(m); a != nil {
+		return a.duration()
+	}
+	if d := m.Duration(); d > 0 {
+		return time.Duration(d) * time.Second, DurationExact
+	}
+	return 0, DurationUnknown
+}
+
+// mpegAudio describes the MPEG audio stream of an MP3 file, from its first frame.
+type mpegAudio struct {
+	header mpegFrameHeader
+	vbr    *VBRHeader // nil if there is none
+	start  int64      // position of the first frame
+	end    int64      // end of the audio (the start of any trailing tags)
+	frames int64      // number of frames counted (see DefaultMP3FrameScan), or 0
+	varies bool       // the counted frames have different bitrates
+}
+
+// mpegSearchLimit is the number of bytes searched for the first MPEG audio frame after
+// the tags.
+const mpegSearchLimit = 64 << 10
+
+// readMP3Audio returns the MPEG audio of an MP3 file from offset start of r up to any
+// trailing tags, or nil if the options of rc do not read it (see DefaultMP3Audio) or it
+// cannot be found.  It is read from the reader underlying the metadata limit.
+func (rc *readContext) readMP3Audio(r io.ReadSeeker, start int64) *mpegAudio {
+	if !rc.MP3Audio && !rc.MP3FrameScan {
+		return nil
+	}
+	if l, ok := r.(*limitedReadSeeker); ok {
+		r = l.ReadSeeker
+	}
+	l, err := readMP3Layout(r)
+	if err != nil {
+		return nil
+	}
+	a, _ := readMPEGAudio(r, start, l.audioEnd, rc.MP3FrameScan)
+	return a
+}
+
+// readMPEGAudio reads the first MPEG audio frame of the audio between offsets start and end
+// of r, returning nil if there is none within mpegSearchLimit bytes.  A frame header is only
+// accepted if it is followed by another frame header (or the end of the audio), to avoid
+// false syncs.  If scan is true then the frames are counted (see DefaultMP3FrameScan).
+func readMPEGAudio(r io.ReadSeeker, start, end int64, scan bool) (*mpegAudio, error) {
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	n := end - start
+	if n > mpegSearchLimit+4096 {
+		n = mpegSearchLimit + 4096
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(b) && i < mpegSearchLimit; i++ {
+		h, ok := parseMPEGFrameHeader(b[i:])
+		if !ok {
+			continue
+		}
+		next := i + h.size()
+		if next > len(b) {
+			continue
+		}
+		if n, ok := parseMPEGFrameHeader(b[next:]); next < len(b) && (!ok || !n.sameStream(h)) {
+			continue
+		}
+		a := &mpegAudio{header: h, start: start + int64(i), end: end}
+		if h.layer == 3 {
+			a.vbr = parseVBRHeader(h, b[i:next])
+		}
+		if scan && (a.vbr == nil || a.vbr.Frames == 0) {
+			a.frames, err = a.countFrames(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	return nil, nil
+}
+
+// sameStream returns true if the frame headers h and x have the same version, layer and
+// sample rate (and so can be frames of the same stream).
+func (h mpegFrameHeader) sameStream(x mpegFrameHeader) bool {
+	return h.version == x.version && h.layer == x.layer && h.sampleRate == x.sampleRate
+}
+
+// countFrames returns the number of audio frames of the stream, reading the header of each
+// frame until the end of the audio or a header which is not of the stream, and records
+// whether their bitrates vary.  A frame with a VBR header is not counted.
+func (a *mpegAudio) countFrames(r io.ReadSeeker) (int64, error) {
+	var frames int64
+	var bitrate int
+	b := make([]byte, 4)
+	for pos := a.start; pos+4 <= a.end; {
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.ReadFull(r, b); err != nil {
+			if isTruncation(err) {
+				break
+			}
+			return 0, err
+		}
+		h, ok := parseMPEGFrameHeader(b)
+		if !ok || !h.sameStream(a.header) {
+			break
+		}
+		// The bitrate of the frame of a VBR header may differ from that of the audio.
+		if frames > 0 || a.vbr == nil {
+			if bitrate == 0 {
+				bitrate = h.bitrate
+			} else if h.bitrate != bitrate {
+				a.varies = true
+			}
+		}
+		frames++
+		pos += int64(h.size())
+	}
+	if a.vbr != nil && frames > 0 {
+		frames--
+	}
+	return frames, nil
+}
+
+// duration returns the duration of the audio and its accuracy.
+func (a *mpegAudio) duration() (time.Duration, DurationAccuracy) {
+	if a == nil {
+		return 0, DurationUnknown
+	}
+	frames := a.frames
+	if a.vbr != nil && a.vbr.Frames > 0 {
+		frames = int64(a.vbr.Frames)
+	}
+	if frames > 0 {
+		return time.Duration(frames * int64(a.header.samples()) * int64(time.Second) / int64(a.header.sampleRate)), DurationExact
+	}
+
+	size := a.end - a.start
+	if a.vbr != nil && a.vbr.Bytes > 0 {
+		size = int64(a.vbr.Bytes)
+	}
+	if size <= 0 {
+		return 0, DurationUnknown
+	}
+	return time.Duration(float64(size) * 8 / float64(a.header.bitrate*1000) * float64(time.Second)), DurationEstimated
+}
+
+// properties returns the properties of the audio.  The bitrate of variable bitrate audio
+// is the average bitrate, if its duration is known.
+func (a *mpegAudio) properties() *AudioProperties {
+	h := a.header
+	p := &AudioProperties{
+		Codec:       mpegLayers[h.layer-1][1],
+		SampleRate:  h.sampleRate,
+		Channels:    2,
+		Bitrate:     h.bitrate * 1000,
+		Version:     h.version.String() + " Layer " + mpegLayers[h.layer-1][0],
+		ChannelMode: mpegChannelModes[h.channelMode],
+		VBR:         a.varies || (a.vbr != nil && a.vbr.Type != "Info"),
+	}
+	if h.channelMode == 3 {
+		p.Channels = 1
+	}
+	if d, accuracy := a.duration(); p.VBR && accuracy == DurationExact && d > 0 {
+		size := a.end - a.start
+		if a.vbr != nil && a.vbr.Bytes > 0 {
+			size = int64(a.vbr.Bytes)
+		}
+		p.Bitrate = int(float64(size) * 8 / d.Seconds())
+	}
+	return p
+}
+
+// VBRHeaderInfo returns the Xing/Info or VBRI header of an MP3 file, or nil if it does not
+// have one, its audio was not read (see DefaultMP3Audio) or m is not the metadata of an MP3
+// file.
+func VBRHeaderInfo(m Metadata) *VBRHeader {
+	a := mpegAudioOf(m)
+	if a == nil || a.vbr == nil {
+		return nil
+	}
+	v := *a.vbr
+	v.TOC = append([]byte(nil), a.vbr.TOC...)
+	return &v
+}
+
+// mpegAudioOf returns the MPEG audio stream of an MP3 file, or nil if it is unknown or m is
+// not the metadata of an MP3 file (with an ID3v2 or ID3v1 tag).
+func mpegAudioOf(m Metadata) *mpegAudio {
+	switch m := m.(type) {
+	case metadataID3v2:
+		return m.audio
+	case metadataID3v1:
+		return m.audio
+	case *metadataMP3:
+		return mpegAudioOf(m.MetadataExt)
+	}
+	return nil
+}
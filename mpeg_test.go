@@ -0,0 +1,299 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testMPEGFrame returns an MPEG audio frame with the header, containing the data at offset
+// i (and otherwise zeros).
+func testMPEGFrame(t *testing.T, header []byte, i int, data []byte) []byte {
+	h, ok := parseMPEGFrameHeader(header)
+	if !ok {
+		t.Fatalf("invalid MPEG frame header %x", header)
+	}
+	b := make([]byte, h.size())
+	copy(b, header)
+	copy(b[i:], data)
+	return b
+}
+
+// testXing returns a Xing header with the frames, bytes and TOC.
+func testXing(id string, frames, size uint32) []byte {
+	b := make([]byte, 120)
+	copy(b, id)
+	binary.BigEndian.PutUint32(b[4:], 0x07)
+	binary.BigEndian.PutUint32(b[8:], frames)
+	binary.BigEndian.PutUint32(b[12:], size)
+	for i := 0; i < 100; i++ {
+		b[16+i] = byte(i * 256 / 100)
+	}
+	return b
+}
+
+func TestParseMPEGFrameHeader(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output mpegFrameHeader
+		ok     bool
+		size   int
+	}{
+		{[]byte{0xff, 0xfb, 0x90, 0x00}, mpegFrameHeader{version: mpeg1, layer: 3, bitrate: 128, sampleRate: 44100}, true, 417},
+		{[]byte{0xff, 0xfb, 0x92, 0x40}, mpegFrameHeader{version: mpeg1, layer: 3, bitrate: 128, sampleRate: 44100, padding: true, channelMode: 1}, true, 418},
+		{[]byte{0xff, 0xfa, 0xe4, 0xc0}, mpegFrameHeader{version: mpeg1, layer: 3, crc: true, bitrate: 320, sampleRate: 48000, channelMode: 3}, true, 960},
+		{[]byte{0xff, 0xf3, 0x80, 0xc0}, mpegFrameHeader{version: mpeg2, layer: 3, bitrate: 64, sampleRate: 22050, channelMode: 3}, true, 208},
+		{[]byte{0xff, 0xe3, 0x18, 0x00}, mpegFrameHeader{version: mpeg25, layer: 3, bitrate: 8, sampleRate: 8000}, true, 72},
+		{[]byte{0xff, 0xfd, 0x80, 0x00}, mpegFrameHeader{version: mpeg1, layer: 2, bitrate: 128, sampleRate: 44100}, true, 417},
+		{[]byte{0xff, 0xff, 0x80, 0x00}, mpegFrameHeader{version: mpeg1, layer: 1, bitrate: 256, sampleRate: 44100}, true, 276},
+		{[]byte{0xff, 0xeb, 0x90, 0x00}, mpegFrameHeader{}, false, 0}, // reserved version
+		{[]byte{0xff, 0xf9, 0x90, 0x00}, mpegFrameHeader{}, false, 0}, // reserved layer
+		{[]byte{0xff, 0xfb, 0x00, 0x00}, mpegFrameHeader{}, false, 0}, // free format
+		{[]byte{0xff, 0xfb, 0xf0, 0x00}, mpegFrameHeader{}, false, 0}, // invalid bitrate
+		{[]byte{0xff, 0xfb, 0x9c, 0x00}, mpegFrameHeader{}, false, 0}, // reserved sample rate
+		{[]byte{0xff, 0x1b, 0x90, 0x00}, mpegFrameHeader{}, false, 0},
+		{[]byte{0xff, 0xfb, 0x90}, mpegFrameHeader{}, false, 0},
+	}
+
+	for ii, tt := range tests {
+		got, ok := parseMPEGFrameHeader(tt.input)
+		if ok != tt.ok || got != tt.output {
+			t.Errorf("[%d] parseMPEGFrameHeader(%x) = %+v, %v, expected %+v, %v", ii, tt.input, got, ok, tt.output, tt.ok)
+			continue
+		}
+		if ok && got.size() != tt.size {
+			t.Errorf("[%d] size() = %d, expected %d", ii, got.size(), tt.size)
+		}
+	}
+}
+
+func TestMP3Duration(t *testing.T) {
+	defer func(audio bool) { DefaultMP3Audio = audio }(DefaultMP3Audio)
+	DefaultMP3Audio = true
+
+	mpeg1 := []byte{0xff, 0xfb, 0x90, 0x00}
+	mpeg2Mono := []byte{0xff, 0xf3, 0x80, 0xc0}
+	frame := testMPEGFrame(t, mpeg1, 4, nil)
+	join := func(bs ...[]byte) []byte { return bytes.Join(bs, nil) }
+	tag := testID3v23(16, testID3v23Frame("TIT2", []byte("\x00Title")))
+
+	vbri := make([]byte, 18)
+	copy(vbri, "VBRI")
+	binary.BigEndian.PutUint32(vbri[10:], 2000*417)
+	binary.BigEndian.PutUint32(vbri[14:], 2000)
+
+	xing := testXing("Xing", 1000, 1000*417)
+	toc := xing[16:116]
+
+	tests := []struct {
+		input    []byte
+		duration int
+		vbr      *VBRHeader
+	}{
+		{join(tag, testMPEGFrame(t, mpeg1, 36, xing), frame, frame), 26, &VBRHeader{Type: "Xing", Frames: 1000, Bytes: 1000 * 417, TOC: toc}},
+		{join(tag, testMPEGFrame(t, mpeg2Mono, 13, testXing("Info", 500, 500*208)), testMPEGFrame(t, mpeg2Mono, 4, nil)), 13, &VBRHeader{Type: "Info", Frames: 500, Bytes: 500 * 208, TOC: toc}},
+		{join(tag, testMPEGFrame(t, mpeg1, 36, vbri), frame), 52, &VBRHeader{Type: "VBRI", Frames: 2000, Bytes: 2000 * 417}},
+		{join(tag, []byte("junk"), testMPEGFrame(t, mpeg1, 36, xing), frame), 26, &VBRHeader{Type: "Xing", Frames: 1000, Bytes: 1000 * 417, TOC: toc}},
+		{join(tag, frame, frame), 0, nil},
+		{join(tag, []byte("\xff\xfb\x90\x00not audio")), 0, nil},
+		{tag, 0, nil},
+	}
+
+	for ii, tt := range tests {
+		for _, input := range [][]byte{tt.input, join(tt.input, testID3v1Tag())} {
+			m, err := ReadFrom(bytes.NewReader(input))
+			if err != nil {
+				t.Errorf("[%d] unexpected error: %v", ii, err)
+				continue
+			}
+			if got := m.Duration(); got != tt.duration {
+				t.Errorf("[%d] Duration() = %d, expected %d", ii, got, tt.duration)
+			}
+			if got := VBRHeaderInfo(m); !reflect.DeepEqual(got, tt.vbr) {
+				t.Errorf("[%d] VBRHeaderInfo() = %+v, expected %+v", ii, got, tt.vbr)
+			}
+		}
+	}
+}
+
+func TestDurationInfo(t *testing.T) {
+	defer func(scan bool) { DefaultMP3FrameScan = scan }(DefaultMP3FrameScan)
+	defer func(audio bool) { DefaultMP3Audio = audio }(DefaultMP3Audio)
+	DefaultMP3Audio = true
+
+	frame := testMPEGFrame(t, []byte{0xff, 0xfb, 0x90, 0x00}, 4, nil)    // 128 kbit/s
+	frame320 := testMPEGFrame(t, []byte{0xff, 0xfb, 0xe0, 0x00}, 4, nil) // 320 kbit/s
+	xing := testMPEGFrame(t, []byte{0xff, 0xfb, 0x90, 0x00}, 36, testXing("Xing", 0, 0))
+	tag := testID3v23(16, testID3v23Frame("TIT2", []byte("\x00Title")))
+	cbr := append([]byte(nil), tag...)
+	vbr := append(append([]byte(nil), tag...), xing...)
+	for i := 0; i < 100; i++ {
+		cbr = append(cbr, frame...)
+		vbr = append(vbr, frame...)
+		if i%2 == 0 {
+			vbr = append(vbr, frame320...)
+		}
+	}
+	cbr = append(cbr, testID3v1Tag()...)
+
+	// 100 frames of 1152 samples at 44.1 kHz.
+	exact := time.Duration(100 * 1152 * int64(time.Second) / 44100)
+	tests := []struct {
+		input    []byte
+		scan     bool
+		duration time.Duration
+		accuracy DurationAccuracy
+	}{
+		{cbr, false, time.Duration(100 * 417 * 8 * int64(time.Second) / 128000), DurationEstimated},
+		{cbr, true, exact, DurationExact},
+		{vbr, true, time.Duration(150 * 1152 * int64(time.Second) / 44100), DurationExact},
+		{tag, false, 0, DurationUnknown},
+		{tag, true, 0, DurationUnknown},
+	}
+
+	for ii, tt := range tests {
+		DefaultMP3FrameScan = tt.scan
+		m, err := ReadFrom(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		d, a := DurationInfo(m)
+		if d != tt.duration || a != tt.accuracy {
+			t.Errorf("[%d] DurationInfo() = %v, %v, expected %v, %v", ii, d, a, tt.duration, tt.accuracy)
+		}
+		if m.Duration() != int(tt.duration/time.Second) {
+			t.Errorf("[%d] Duration() = %d, expected %d", ii, m.Duration(), int(tt.duration/time.Second))
+		}
+	}
+
+	m, err := ReadAtoms(bytes.NewReader(testM4A(nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d, a := DurationInfo(m); d != 0 || a != DurationUnknown {
+		t.Errorf("DurationInfo() = %v, %v for MP4 file without duration, expected 0, %v", d, a, DurationUnknown)
+	}
+}
+
+func TestMP3Properties(t *testing.T) {
+	defer func(scan bool) { DefaultMP3FrameScan = scan }(DefaultMP3FrameScan)
+	defer func(audio bool) { DefaultMP3Audio = audio }(DefaultMP3Audio)
+	DefaultMP3Audio = true
+
+	mpeg1 := []byte{0xff, 0xfb, 0x90, 0x00}
+	mpeg2Mono := []byte{0xff, 0xf3, 0x80, 0xc0}
+	layer2 := []byte{0xff, 0xfd, 0x80, 0x40}
+	frame := testMPEGFrame(t, mpeg1, 4, nil)
+	frame320 := testMPEGFrame(t, []byte{0xff, 0xfb, 0xe0, 0x00}, 4, nil)
+	join := func(bs ...[]byte) []byte { return bytes.Join(bs, nil) }
+	tag := testID3v23(16, testID3v23Frame("TIT2", []byte("\x00Title")))
+
+	xingDuration := time.Duration(1000 * 1152 * int64(time.Second) / 44100)
+	scanDuration := time.Duration(3 * 1152 * int64(time.Second) / 44100)
+
+	tests := []struct {
+		input []byte
+		scan  bool
+		want  *AudioProperties
+	}{
+		{join(tag, frame, frame), false, &AudioProperties{Codec: "MP3", SampleRate: 44100, Channels: 2, Bitrate: 128000, Version: "MPEG-1 Layer III", ChannelMode: "stereo"}},
+		{join(tag, testMPEGFrame(t, mpeg1, 36, testXing("Xing", 1000, 1000*417)), frame), false, &AudioProperties{
+			Codec: "MP3", SampleRate: 44100, Channels: 2, Bitrate: int(1000 * 417 * 8 / xingDuration.Seconds()),
+			Version: "MPEG-1 Layer III", ChannelMode: "stereo", VBR: true,
+		}},
+		{join(tag, testMPEGFrame(t, mpeg2Mono, 13, testXing("Info", 500, 500*208)), testMPEGFrame(t, mpeg2Mono, 4, nil)), false, &AudioProperties{
+			Codec: "MP3", SampleRate: 22050, Channels: 1, Bitrate: 64000, Version: "MPEG-2 Layer III", ChannelMode: "mono",
+		}},
+		{join(tag, frame, frame320, frame), true, &AudioProperties{
+			Codec: "MP3", SampleRate: 44100, Channels: 2, Bitrate: int(float64(2*417+1044) * 8 / scanDuration.Seconds()),
+			Version: "MPEG-1 Layer III", ChannelMode: "stereo", VBR: true,
+		}},
+		{join(tag, testMPEGFrame(t, layer2, 4, nil), testMPEGFrame(t, layer2, 4, nil)), false, &AudioProperties{
+			Codec: "MP2", SampleRate: 44100, Channels: 2, Bitrate: 128000, Version: "MPEG-1 Layer II", ChannelMode: "joint stereo",
+		}},
+		{tag, false, nil},
+	}
+
+	for ii, tt := range tests {
+		DefaultMP3FrameScan = tt.scan
+		m, err := ReadFrom(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := Properties(m); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%d] Properties() = %+v, expected %+v", ii, got, tt.want)
+		}
+	}
+}
+
+func TestMP3ID3v1Only(t *testing.T) {
+	frame := testMPEGFrame(t, []byte{0xff, 0xfb, 0x90, 0x00}, 4, nil) // 128 kbit/s
+	input := append(bytes.Repeat(frame, 100), testID3v1Tag()...)
+	props := &AudioProperties{Codec: "MP3", SampleRate: 44100, Channels: 2, Bitrate: 128000, Version: "MPEG-1 Layer III", ChannelMode: "stereo"}
+
+	tests := []struct {
+		scan     bool
+		duration time.Duration
+		accuracy DurationAccuracy
+	}{
+		{false, time.Duration(100 * 417 * 8 * int64(time.Second) / 128000), DurationEstimated},
+		{true, time.Duration(100 * 1152 * int64(time.Second) / 44100), DurationExact},
+	}
+
+	for ii, tt := range tests {
+		o := NewReadOptions()
+		o.MP3Audio = true
+		o.MP3FrameScan = tt.scan
+		m, err := ReadFromWithOptions(bytes.NewReader(input), o)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if m.Format() != ID3v1 {
+			t.Errorf("[%d] Format() = %v, expected %v", ii, m.Format(), ID3v1)
+		}
+		if d, a := DurationInfo(m); d != tt.duration || a != tt.accuracy {
+			t.Errorf("[%d] DurationInfo() = %v, %v, expected %v, %v", ii, d, a, tt.duration, tt.accuracy)
+		}
+		if m.Duration() != int(tt.duration/time.Second) {
+			t.Errorf("[%d] Duration() = %d, expected %d", ii, m.Duration(), int(tt.duration/time.Second))
+		}
+		if got := Properties(m); !reflect.DeepEqual(got, props) {
+			t.Errorf("[%d] Properties() = %+v, expected %+v", ii, got, props)
+		}
+	}
+}
+
+func TestMP3AudioOption(t *testing.T) {
+	frame := testMPEGFrame(t, []byte{0xff, 0xfb, 0x90, 0x00}, 4, nil) // 128 kbit/s
+	tag := testID3v23(10, make([]byte, 10))
+	input := append(tag, bytes.Repeat(frame, 100)...)
+
+	o := NewReadOptions()
+	m, err := ReadFromWithOptions(bytes.NewReader(input), o)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := Properties(m); got != nil {
+		t.Errorf("Properties() = %+v, expected nil without MP3Audio", got)
+	}
+
+	// The audio is not counted towards the metadata limit.
+	o.MP3Audio = true
+	o.MetadataLimit = 1024
+	m, err = ReadFromWithOptions(bytes.NewReader(input), o)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d, a := DurationInfo(m); a != DurationEstimated || d == 0 {
+		t.Errorf("DurationInfo() = %v, %v, expected an estimated duration", d, a)
+	}
+}
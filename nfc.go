@@ -0,0 +1,137 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+//go:generate go run maketables.go
+
+// Hangul syllables are composed and decomposed algorithmically (see the Unicode
+// Standard, section 3.12).
+const (
+	hangulSBase  = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+// nfcCompositions maps pairs of characters to their primary composite.
+var nfcCompositions = make(map[[2]rune]rune)
+
+func init() {
+	for r, d := range nfcDecompositions {
+		if d[1] == 0 || nfcExclusions[r] || nfcCombiningClasses[r] != 0 || nfcCombiningClasses[d[0]] != 0 {
+			continue
+		}
+		nfcCompositions[d] = r
+	}
+}
+
+// normalizeNFC returns s in Unicode Normalization Form C.
+func normalizeNFC(s string) string {
+	quick := true
+	for _, r := range s {
+		if r >= 0x300 {
+			quick = false
+			break
+		}
+	}
+	if quick {
+		return s
+	}
+
+	rs := make([]rune, 0, len(s))
+	for _, r := range s {
+		rs = decomposeRune(rs, r)
+	}
+	reorderMarks(rs)
+	return string(composeRunes(rs))
+}
+
+// decomposeRune appends the full canonical decomposition of r to rs.
+func decomposeRune(rs []rune, r rune) []rune {
+	if r >= hangulSBase && r < hangulSBase+hangulSCount {
+		i := r - hangulSBase
+		rs = append(rs, hangulLBase+i/hangulNCount, hangulVBase+(i%hangulNCount)/hangulTCount)
+		if t := i % hangulTCount; t != 0 {
+			rs = append(rs, hangulTBase+t)
+		}
+		return rs
+	}
+
+	d, ok := nfcDecompositions[r]
+	if !ok {
+		return append(rs, r)
+	}
+	rs = decomposeRune(rs, d[0])
+	if d[1] != 0 {
+		rs = decomposeRune(rs, d[1])
+	}
+	return rs
+}
+
+// reorderMarks sorts each sequence of combining marks in rs by combining class.
+func reorderMarks(rs []rune) {
+	for i := 1; i < len(rs); i++ {
+		c := nfcCombiningClasses[rs[i]]
+		if c == 0 {
+			continue
+		}
+		for j := i; j > 0; j-- {
+			p := nfcCombiningClasses[rs[j-1]]
+			if p == 0 || p <= c {
+				break
+			}
+			rs[j-1], rs[j] = rs[j], rs[j-1]
+		}
+	}
+}
+
+// composeRunes composes the canonically decomposed and ordered rs, in place.
+func composeRunes(rs []rune) []rune {
+	if len(rs) == 0 {
+		return rs
+	}
+
+	starter := 0 // index of the last starter in the output
+	if nfcCombiningClasses[rs[0]] != 0 {
+		starter = -1
+	}
+	out := rs[:1]
+	var last uint8 = nfcCombiningClasses[rs[0]] // combining class of the last output character
+	for _, r := range rs[1:] {
+		c := nfcCombiningClasses[r]
+		if starter >= 0 && (len(out)-1 == starter || (last != 0 && last < c)) {
+			if x, ok := composePair(out[starter], r); ok {
+				out[starter] = x
+				continue
+			}
+		}
+		if c == 0 {
+			starter = len(out)
+		}
+		last = c
+		out = append(out, r)
+	}
+	return out
+}
+
+// composePair returns the primary composite of a and b, if there is one.
+func composePair(a, b rune) (rune, bool) {
+	// Hangul LV and LVT syllables.
+	if a >= hangulLBase && a < hangulLBase+hangulLCount && b >= hangulVBase && b < hangulVBase+hangulVCount {
+		return hangulSBase + ((a-hangulLBase)*hangulVCount+(b-hangulVBase))*hangulTCount, true
+	}
+	if a >= hangulSBase && a < hangulSBase+hangulSCount && (a-hangulSBase)%hangulTCount == 0 &&
+		b > hangulTBase && b < hangulTBase+hangulTCount {
+		return a + (b - hangulTBase), true
+	}
+
+	x, ok := nfcCompositions[[2]rune{a, b}]
+	return x, ok
+}
@@ -20,78 +20,92 @@ const (
 // See http://www.xiph.org/vorbis/doc/Vorbis_I_spec.html
 // and http://www.xiph.org/ogg/doc/framing.html for details.
 func ReadOGGTags(r io.ReadSeeker) (Metadata, error) {
+	rc := defaultReadContext()
+	return readOGGTags(rc.reader(r), rc)
+}
+
+func readOGGTags(r io.ReadSeeker, rc *readContext) (Metadata, error) {
+	page := tell(r)
 	oggs, err := readString(r, 4)
 	if err != nil {
-		return nil, err
+		return nil, parseError(VORBIS, "page", page, err)
 	}
 	if oggs != "OggS" {
-		return nil, errors.New("expected 'OggS'")
+		return nil, parseError(VORBIS, "page", page, errors.New("expected 'OggS'"))
 	}
 
 	// Skip 22 bytes of Page header to read page_segments length byte at position 26
 	// See http://www.xiph.org/ogg/doc/framing.html
 	_, err = r.Seek(22, io.SeekCurrent)
 	if err != nil {
-		return nil, err
+		return nil, parseError(VORBIS, "page", page, err)
 	}
 
 	nS, err := readInt(r, 1)
 	if err != nil {
-		return nil, err
+		return nil, parseError(VORBIS, "page", page, err)
 	}
 
 	// Seek and discard the segments
 	_, err = r.Seek(int64(nS), io.SeekCurrent)
 	if err != nil {
-		return nil, err
+		return nil, parseError(VORBIS, "page", page, err)
 	}
 
 	// First packet type is identification, type 1
+	ident := tell(r)
 	t, err := readInt(r, 1)
 	if err != nil {
-		return nil, err
+		return nil, parseError(VORBIS, "identification header", ident, err)
 	}
 	if t != idType {
-		return nil, errors.New("expected 'vorbis' identification type 1")
+		return nil, parseError(VORBIS, "identification header", ident, errors.New("expected 'vorbis' identification type 1"))
 	}
 
 	// Seek and discard 29 bytes from common and identification header
 	// See http://www.xiph.org/vorbis/doc/Vorbis_I_spec.html#x1-610004.2
 	_, err = r.Seek(29, io.SeekCurrent)
 	if err != nil {
-		return nil, err
+		return nil, parseError(VORBIS, "identification header", ident, err)
 	}
 
 	// Read comment header packet. May include setup header packet, if it is on the
 	// same page. First audio packet is guaranteed to be on the separate page.
 	// See https://www.xiph.org/vorbis/doc/Vorbis_I_spec.html#x1-132000A.2
+	start := tell(r)
 	ch, err := readPackets(r)
 	if err != nil {
-		return nil, err
+		if isTruncation(err) {
+			return nil, rc.truncated(VORBIS, start)
+		}
+		return nil, parseError(VORBIS, "comment header", start, err)
 	}
 	chr := bytes.NewReader(ch)
 
 	// First packet type is comment, type 3
 	t, err = readInt(chr, 1)
 	if err != nil {
-		return nil, err
+		return nil, parseError(VORBIS, "comment header", start, err)
 	}
 	if t != commentType {
-		return nil, errors.New("expected 'vorbis' comment type 3")
+		return nil, parseError(VORBIS, "comment header", start, errors.New("expected 'vorbis' comment type 3"))
 	}
 
 	// Seek and discard 6 bytes from common header
 	_, err = chr.Seek(6, io.SeekCurrent)
 	if err != nil {
-		return nil, err
+		return nil, parseError(VORBIS, "comment header", start, err)
 	}
 
 	m := &metadataOGG{
-		newMetadataVorbis(),
+		newMetadataVorbis(rc),
 	}
 
-	err = m.readVorbisComment(chr)
-	return m, err
+	err = m.readVorbisComment(chr, -1)
+	if isTruncation(err) {
+		return m, rc.truncated(VORBIS, start)
+	}
+	return m, parseError(VORBIS, "comment header", start, err)
 }
 
 // readPackets reads vorbis header packets from contiguous ogg pages in ReadSeeker.
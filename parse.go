@@ -0,0 +1,253 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrTruncated is the error returned when the data ends part way through a tag.  In
+// Lenient mode the Metadata parsed before the end of the data is returned with it.
+var ErrTruncated = errors.New("tag data is truncated")
+
+// ParseMode determines how tag data which violates its specification is handled when
+// reading tags.
+type ParseMode int
+
+// Parse modes.
+const (
+	// Lenient recovers as much of the metadata as possible: malformed frames, atoms,
+	// comments and blocks are skipped, and truncated tags return the data read so far.
+	// This is the default, and is suited to players and library managers.
+	Lenient ParseMode = iota
+
+	// Strict rejects any spec violation, returning a *ParseError which describes it.
+	// This is suited to validators.
+	Strict
+)
+
+func (m ParseMode) String() string {
+	switch m {
+	case Lenient:
+		return "lenient"
+	case Strict:
+		return "strict"
+	}
+	return fmt.Sprintf("ParseMode(%d)", int(m))
+}
+
+// DefaultParseMode is the ParseMode used when reading tags, unless another is given by
+// ReadOptions.
+var DefaultParseMode = Lenient
+
+// ErrMetadataTooLarge is the error returned when reading the tags of a file requires
+// reading (or decompressing) more than the metadata limit (see DefaultMetadataLimit).
+var ErrMetadataTooLarge = errors.New("metadata exceeds size limit")
+
+// DefaultMetadataLimit is the maximum number of bytes read from a file when reading its
+// tags (including pictures, and the decompressed size of compressed ID3v2 frames), or
+// zero for no limit, unless another is given by ReadOptions.  It prevents untrusted input
+// from exhausting memory: reading stops with ErrMetadataTooLarge when it is exceeded.
+var DefaultMetadataLimit int64 = 64 << 20
+
+// ReadOptions configures how tags are read by ReadFromWithOptions.  Each field defaults
+// to the Default variable of the same name (see NewReadOptions), which are used by
+// ReadFrom and the format specific Read functions.
+type ReadOptions struct {
+	ParseMode        ParseMode
+	MetadataLimit    int64
+	ID3Preference    ID3Preference
+	UnknownTagPolicy UnknownTagPolicy
+	DuplicatePolicy  DuplicatePolicy
+	SanitizeText     bool
+	NormalizeText    bool
+	MP3Audio         bool
+	MP3FrameScan     bool
+}
+
+// NewReadOptions returns the ReadOptions given by the Default variables (DefaultParseMode,
+// DefaultMetadataLimit, DefaultID3Preference, DefaultUnknownTagPolicy,
+// DefaultDuplicatePolicy, DefaultSanitizeText, DefaultNormalizeText, DefaultMP3Audio and
+// DefaultMP3FrameScan).
+func NewReadOptions() ReadOptions {
+	return ReadOptions{
+		ParseMode:        DefaultParseMode,
+		MetadataLimit:    DefaultMetadataLimit,
+		ID3Preference:    DefaultID3Preference,
+		UnknownTagPolicy: DefaultUnknownTagPolicy,
+		DuplicatePolicy:  DefaultDuplicatePolicy,
+		SanitizeText:     DefaultSanitizeText,
+		NormalizeText:    DefaultNormalizeText,
+		MP3Audio:         DefaultMP3Audio,
+		MP3FrameScan:     DefaultMP3FrameScan,
+	}
+}
+
+// readContext is the state of a single call which reads tags: its options, and the number
+// of bytes which may still be read before the metadata limit is exceeded.
+type readContext struct {
+	ReadOptions
+	remaining int64
+}
+
+func newReadContext(o ReadOptions) *readContext {
+	return &readContext{ReadOptions: o, remaining: o.MetadataLimit}
+}
+
+// defaultReadContext returns a readContext with the options given by the Default
+// variables, for decoding tag data outside of a call which reads tags (such as when
+// converting frames to write them).
+func defaultReadContext() *readContext {
+	return newReadContext(NewReadOptions())
+}
+
+// consume records that n more bytes of metadata have been read (or decompressed),
+// returning ErrMetadataTooLarge if this exceeds the metadata limit.
+func (rc *readContext) consume(n int64) error {
+	if rc.MetadataLimit <= 0 {
+		return nil
+	}
+	rc.remaining -= n
+	if rc.remaining < 0 {
+		return ErrMetadataTooLarge
+	}
+	return nil
+}
+
+// limitedReadSeeker is an io.ReadSeeker which returns ErrMetadataTooLarge once the
+// metadata limit of its readContext has been reached.  Seeking does not count towards
+// the limit.
+type limitedReadSeeker struct {
+	io.ReadSeeker
+	rc *readContext
+}
+
+func (l *limitedReadSeeker) Read(p []byte) (int, error) {
+	if l.rc.remaining <= 0 {
+		return 0, ErrMetadataTooLarge
+	}
+	if int64(len(p)) > l.rc.remaining {
+		p = p[:l.rc.remaining]
+	}
+	n, err := l.ReadSeeker.Read(p)
+	l.rc.remaining -= int64(n)
+	return n, err
+}
+
+// reader returns r limited to reading the metadata limit of rc, if there is one.
+func (rc *readContext) reader(r io.ReadSeeker) io.ReadSeeker {
+	if rc.MetadataLimit <= 0 {
+		return r
+	}
+	return &limitedReadSeeker{r, rc}
+}
+
+// ParseError is the error returned when tag data cannot be parsed, or in Strict mode
+// when it violates its specification.
+type ParseError struct {
+	Format    Format // format of the tag containing the violation
+	Structure string // structure being parsed, such as `atom "ilst"` or `frame "TIT2"`, or empty
+	Offset    int64  // byte offset of the offending structure in the input, or -1 if unknown
+	Err       error  // description of the violation
+}
+
+func (e *ParseError) Error() string {
+	s := e.Err.Error()
+	switch {
+	case e.Structure != "" && e.Offset >= 0:
+		s = fmt.Sprintf("%v at offset %d: %v", e.Structure, e.Offset, s)
+	case e.Structure != "":
+		s = fmt.Sprintf("%v: %v", e.Structure, s)
+	case e.Offset >= 0:
+		s = fmt.Sprintf("offset %d: %v", e.Offset, s)
+	}
+	if e.Format != UnknownFormat {
+		s = fmt.Sprintf("%v: %v", e.Format, s)
+	}
+	return s
+}
+
+// Unwrap returns the underlying error (for use with errors.Is).
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// warnings is embedded in Metadata implementations to record the spec violations which
+// were recovered from in Lenient mode.
+type warnings []error
+
+// Warnings returns the spec violations which were skipped when reading in Lenient mode.
+func (w warnings) Warnings() []error { return w }
+
+// violation reports a spec violation in tag data of format f at the given offset.  In
+// Strict mode it returns a *ParseError, in Lenient mode it adds the *ParseError to w (if
+// non-nil) and returns nil, and the caller should recover from the violation.
+func (rc *readContext) violation(w *warnings, f Format, offset int64, err error) error {
+	return rc.structureViolation(w, f, "", offset, err)
+}
+
+// structureViolation is like violation, for a violation in the named structure (see
+// ParseError.Structure).
+func (rc *readContext) structureViolation(w *warnings, f Format, structure string, offset int64, err error) error {
+	perr, ok := err.(*ParseError)
+	if !ok {
+		perr = &ParseError{Format: f, Structure: structure, Offset: offset, Err: err}
+	}
+	if rc.ParseMode == Strict {
+		return perr
+	}
+	if w != nil {
+		*w = append(*w, perr)
+	}
+	return nil
+}
+
+// skipped records that the named structure at offset in tag data of format f was skipped
+// because it cannot be decoded (as described by err).  Unlike a violation, it is added to
+// w in Strict mode too.
+func skipped(w *warnings, f Format, structure string, offset int64, err error) {
+	if w != nil {
+		*w = append(*w, &ParseError{Format: f, Structure: structure, Offset: offset, Err: err})
+	}
+}
+
+// parseError returns err, which occurred while parsing the named structure at offset in tag
+// data of format f, as a *ParseError so that it describes where the problem is.  Errors
+// which callers compare against (such as ErrTruncated) and *ParseErrors are returned
+// unchanged.
+func parseError(f Format, structure string, offset int64, err error) error {
+	switch err {
+	case nil, ErrTruncated, ErrMetadataTooLarge, ErrNotID3v1, ErrNoTagsFound:
+		return err
+	}
+	if _, ok := err.(*ParseError); ok {
+		return err
+	}
+	return &ParseError{Format: f, Structure: structure, Offset: offset, Err: err}
+}
+
+// truncated reports that tag data of format f ends part way through the structure at
+// offset.  In Strict mode it returns a *ParseError wrapping ErrTruncated, in Lenient
+// mode it returns ErrTruncated.
+func (rc *readContext) truncated(f Format, offset int64) error {
+	if err := rc.violation(nil, f, offset, ErrTruncated); err != nil {
+		return err
+	}
+	return ErrTruncated
+}
+
+// isTruncation returns true if err is the result of reading past the end of the data.
+func isTruncation(err error) bool {
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// tell returns the current offset of the io.Seeker, or -1 if it cannot be determined.
+func tell(s io.Seeker) int64 {
+	n, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,260 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// testID3v23Frame returns an ID3v2.3 frame with the given name and data.
+func testID3v23Frame(name string, data []byte) []byte {
+	l := len(data)
+	return append([]byte{name[0], name[1], name[2], name[3], byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l), 0, 0}, data...)
+}
+
+// testID3v23 returns an ID3v2.3 tag with the given declared size containing the frames.
+func testID3v23(size int, frames ...[]byte) []byte {
+	b := []byte{'I', 'D', '3', 3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	for _, f := range frames {
+		b = append(b, f...)
+	}
+	return b
+}
+
+func TestParseMode(t *testing.T) {
+	defer func(m ParseMode) { DefaultParseMode = m }(DefaultParseMode)
+
+	title := testID3v23Frame("TIT2", []byte("\x00Title"))
+	album := testID3v23Frame("TALB", []byte("\x00Album"))
+	badTitle := testID3v23Frame("TIT2", []byte("\x01\xff\xfea")) // odd length UTF-16
+
+	tests := []struct {
+		input  []byte
+		read   func([]byte) (Metadata, error)
+		offset int64 // offset of the violation
+		album  string
+		err    error // error expected in Lenient mode
+	}{
+		{
+			testID3v23(100, badTitle, album, make([]byte, 100-len(badTitle)-len(album))),
+			readID3v2Bytes, 10, "Album", nil,
+		},
+		{
+			testID3v23(100, album, title[:8]), // truncated
+			readID3v2Bytes, 10 + int64(len(album)), "Album", ErrTruncated,
+		},
+		{
+			testID3v23(len(album)+len(title), album, []byte("\x00\x01garbage!"), title),
+			readID3v2Bytes, 10 + int64(len(album)), "Album", nil,
+		},
+		{
+			testFLAC(t, []string{"ALBUM=Album", "NOEQUALS"}, 0),
+			readFLACBytes, 42 + 4 + 8 + 4 + 4 + 11, "Album", nil,
+		},
+		{
+			bytes.Join([][]byte{
+				testAtom("ftyp", []byte("M4A \x00\x00\x00\x00")),
+				testAtom("moov", testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst",
+					testAtom("\xa9nam", testAtom("data", []byte{0, 0})), // too short for its class
+					testAtom("\xa9alb", testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("Album"))),
+				)))),
+			}, nil),
+			readAtomsBytes, 52, "Album", nil,
+		},
+	}
+
+	for ii, tt := range tests {
+		DefaultParseMode = Lenient
+		m, err := tt.read(tt.input)
+		if err != tt.err {
+			t.Errorf("[%d] Lenient: error = %v, expected %v", ii, err, tt.err)
+		}
+		if m == nil {
+			t.Errorf("[%d] Lenient: expected metadata", ii)
+		} else {
+			if m.Album() != tt.album {
+				t.Errorf("[%d] Lenient: Album() = %q, expected %q", ii, m.Album(), tt.album)
+			}
+			if tt.err == nil {
+				w := Warnings(m)
+				if len(w) != 1 {
+					t.Errorf("[%d] Lenient: expected 1 warning, got %v", ii, w)
+				} else if perr, ok := w[0].(*ParseError); !ok || perr.Offset != tt.offset {
+					t.Errorf("[%d] Lenient: warning = %v, expected a *ParseError at offset %d", ii, w[0], tt.offset)
+				}
+			}
+		}
+
+		DefaultParseMode = Strict
+		_, err = tt.read(tt.input)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("[%d] Strict: expected *ParseError, got %v", ii, err)
+			continue
+		}
+		if perr.Offset != tt.offset {
+			t.Errorf("[%d] Strict: offset = %d, expected %d (%v)", ii, perr.Offset, tt.offset, perr)
+		}
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	defer func(m ParseMode) { DefaultParseMode = m }(DefaultParseMode)
+
+	album := testID3v23Frame("TALB", []byte("\x00Album"))
+	id3 := testID3v23(100, album, testID3v23Frame("TIT2", []byte("\x00Title")))
+	flac := testFLAC(t, []string{"ALBUM=Album"}, 0)
+	m4a := bytes.Join([][]byte{
+		testAtom("ftyp", []byte("M4A \x00\x00\x00\x00")),
+		testAtom("moov", testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst",
+			testAtom("\xa9alb", testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("Album"))),
+			testAtom("\xa9nam", testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("Title"))),
+		)))),
+	}, nil)
+
+	tests := []struct {
+		input []byte
+		read  func([]byte) (Metadata, error)
+		album string
+	}{
+		{id3[:len(id3)-3], readID3v2Bytes, "Album"},
+		{id3[:5], readID3v2Bytes, ""},
+		{flac[:60], readFLACBytes, ""}, // cut in the Vorbis comment block
+		{m4a[:len(m4a)-3], readAtomsBytes, "Album"},
+	}
+
+	for ii, tt := range tests {
+		DefaultParseMode = Lenient
+		m, err := tt.read(tt.input)
+		if err != ErrTruncated {
+			t.Errorf("[%d] Lenient: error = %v, expected ErrTruncated", ii, err)
+		}
+		if tt.album != "" && (m == nil || m.Album() != tt.album) {
+			t.Errorf("[%d] Lenient: expected partial metadata with album %q", ii, tt.album)
+		}
+
+		DefaultParseMode = Strict
+		if _, err := tt.read(tt.input); !errors.Is(err, ErrTruncated) {
+			t.Errorf("[%d] Strict: error = %v, expected ErrTruncated", ii, err)
+		}
+	}
+}
+
+func readID3v2Bytes(b []byte) (Metadata, error) { return ReadID3v2Tags(bytes.NewReader(b)) }
+func readFLACBytes(b []byte) (Metadata, error)  { return ReadFLACTags(bytes.NewReader(b)) }
+func readAtomsBytes(b []byte) (Metadata, error) { return ReadAtoms(bytes.NewReader(b)) }
+
+func TestMetadataLimit(t *testing.T) {
+	defer func(n int64) { DefaultMetadataLimit = n }(DefaultMetadataLimit)
+
+	title := testID3v23Frame("TIT2", append([]byte{0}, bytes.Repeat([]byte("x"), 2000)...))
+	id3 := testID3v23(len(title)+10, title, make([]byte, 10))
+	m4a := testM4A([][]byte{testTextItem("\xa9alb", "Album"), testTextItem("\xa9nam", string(bytes.Repeat([]byte("x"), 2000)))})
+	flac := testFLAC(t, []string{"ALBUM=Album", "TITLE=" + string(bytes.Repeat([]byte("x"), 2000))}, 0)
+
+	// A Vorbis comment with a corrupt vendor length (~2GB) must not be allocated up front.
+	corrupt := testFLAC(t, []string{"ALBUM=Album"}, 0)
+	copy(corrupt[46:], []byte{0xff, 0xff, 0xff, 0x7f})
+
+	tests := []struct {
+		input []byte
+		limit int64
+		err   error
+	}{
+		{id3, 0, nil},
+		{id3, 4000, nil},
+		{id3, 1000, ErrMetadataTooLarge},
+		{m4a, 1000, ErrMetadataTooLarge},
+		{flac, 1000, ErrMetadataTooLarge},
+		{corrupt, 0, nil},
+	}
+
+	for ii, tt := range tests {
+		DefaultMetadataLimit = tt.limit
+		_, err := ReadFrom(bytes.NewReader(tt.input))
+		if err != tt.err {
+			t.Errorf("[%d] ReadFrom() with limit %d error = %v, expected %v", ii, tt.limit, err, tt.err)
+		}
+	}
+}
+
+func TestParseErrorStructure(t *testing.T) {
+	defer func(m ParseMode) { DefaultParseMode = m }(DefaultParseMode)
+	DefaultParseMode = Strict
+
+	badTitle := testID3v23Frame("TIT2", []byte("\x01\xff\xfea"))
+	badData := testM4A([][]byte{testAtom("\xa9nam", testAtom("data", []byte{0, 0}))})
+	readOGGBytes := func(b []byte) (Metadata, error) { return ReadOGGTags(bytes.NewReader(b)) }
+
+	tests := []struct {
+		input     []byte
+		read      func([]byte) (Metadata, error)
+		structure string
+		offset    int64
+	}{
+		{testID3v23(100, badTitle, make([]byte, 100-len(badTitle))), readID3v2Bytes, `frame "TIT2"`, 10},
+		{badData, readAtomsBytes, `atom "\xa9nam"`, 52},
+		{testFLAC(t, []string{"NOEQUALS"}, 0), readFLACBytes, `comment "NOEQUALS"`, 58},
+		{[]byte("fLaX"), readFLACBytes, "stream marker", 0},
+		{[]byte("OggX0000000000000000000000000000"), readOGGBytes, "page", 0},
+	}
+
+	for ii, tt := range tests {
+		_, err := tt.read(tt.input)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("[%d] expected *ParseError, got %v", ii, err)
+			continue
+		}
+		if perr.Structure != tt.structure || perr.Offset != tt.offset {
+			t.Errorf("[%d] error = %v, expected %s at offset %d", ii, perr, tt.structure, tt.offset)
+		}
+	}
+
+	err := &ParseError{Format: ID3v2_3, Structure: `frame "TIT2"`, Offset: 10, Err: errors.New("short read")}
+	if s := err.Error(); s != `ID3v2.3: frame "TIT2" at offset 10: short read` {
+		t.Errorf("Error() = %q", s)
+	}
+}
+
+func TestReadFromWithOptions(t *testing.T) {
+	badTitle := testID3v23Frame("TIT2", []byte("\x01\xff\xfea"))
+	album := testID3v23Frame("TALB", []byte("\x00Album"))
+	mcdi := testID3v23Frame("MCDI", []byte("owner\x00data"))
+	id3 := testID3v23(100, badTitle, album, mcdi, make([]byte, 100-len(badTitle)-len(album)-len(mcdi)))
+
+	strict := NewReadOptions()
+	strict.ParseMode = Strict
+	if _, err := ReadFromWithOptions(bytes.NewReader(id3), strict); err == nil {
+		t.Errorf("Strict: expected error")
+	}
+
+	unknown := NewReadOptions()
+	unknown.UnknownTagPolicy = ListUnknown
+	m, err := ReadFromWithOptions(bytes.NewReader(id3), unknown)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u := UnknownTags(m); len(u) != 1 || u[0].Name != "MCDI" {
+		t.Errorf("UnknownTags() = %v, expected MCDI", u)
+	}
+
+	limited := NewReadOptions()
+	limited.MetadataLimit = 50
+	if _, err := ReadFromWithOptions(bytes.NewReader(id3), limited); !errors.Is(err, ErrMetadataTooLarge) {
+		t.Errorf("limit 50: error = %v, expected ErrMetadataTooLarge", err)
+	}
+
+	// The options of a call do not change the defaults.
+	m, err = ReadFrom(bytes.NewReader(id3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Album() != "Album" || len(UnknownTags(m)) != 0 || len(Warnings(m)) != 1 {
+		t.Errorf("ReadFrom() = %q, %v, %v, expected the defaults", m.Album(), UnknownTags(m), Warnings(m))
+	}
+}
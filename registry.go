@@ -0,0 +1,100 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"sort"
+	"strings"
+)
+
+// TagRegistry maps the field names used by this package (such as "title" and
+// "album_artist", see Edit.Fields) to the names of the tags which store them in each
+// format: MP4 atoms, ID3v2 frames, Vorbis comments and ID3v1 fields.
+type TagRegistry struct {
+	byField map[Format]map[string][]string
+	byName  map[Format]map[string]string
+}
+
+// Registry is the TagRegistry of the tags read by this package.
+var Registry = newTagRegistry()
+
+func newTagRegistry() *TagRegistry {
+	r := &TagRegistry{
+		byField: make(map[Format]map[string][]string),
+		byName:  make(map[Format]map[string]string),
+	}
+
+	// Several atoms can store the same field, so add them in a deterministic order.
+	names := make([]string, 0, len(atoms))
+	for name := range atoms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.add(MP4, atoms[name], name)
+	}
+
+	for field, names := range frames {
+		r.add(ID3v2_2, field, names[0])
+		r.add(ID3v2_3, field, names[1])
+		if field == "year" {
+			r.add(ID3v2_4, field, "TDRC")
+			continue
+		}
+		r.add(ID3v2_4, field, names[1])
+	}
+
+	for field, name := range vorbisFields {
+		r.add(VORBIS, field, name)
+	}
+
+	for _, field := range []string{"title", "artist", "album", "year", "comment", "track", "genre"} {
+		r.add(ID3v1, field, field)
+	}
+	return r
+}
+
+func (r *TagRegistry) add(f Format, field, name string) {
+	if name == "" {
+		return
+	}
+	if r.byField[f] == nil {
+		r.byField[f] = make(map[string][]string)
+		r.byName[f] = make(map[string]string)
+	}
+	r.byField[f][field] = append(r.byField[f][field], name)
+	r.byName[f][name] = field
+}
+
+// LookupByField returns the names of the tags which store the field in format f, in
+// order of preference, or nil if the format does not support the field.
+func (r *TagRegistry) LookupByField(f Format, field string) []string {
+	names := r.byField[f][field]
+	if len(names) == 0 {
+		return nil
+	}
+	return append([]string(nil), names...)
+}
+
+// LookupByAtom returns the field stored by the tag (MP4 atom, ID3v2 frame, Vorbis comment
+// or ID3v1 field) with the given name in format f.  Vorbis comment names are matched
+// case-insensitively.
+func (r *TagRegistry) LookupByAtom(f Format, name string) (field string, ok bool) {
+	if f == VORBIS {
+		name = strings.ToUpper(name)
+	}
+	field, ok = r.byName[f][name]
+	return field, ok
+}
+
+// Fields returns the fields supported by format f, sorted by name.
+func (r *TagRegistry) Fields(f Format) []string {
+	fields := make([]string, 0, len(r.byField[f]))
+	for field := range r.byField[f] {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
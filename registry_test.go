@@ -0,0 +1,58 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistryLookupByField(t *testing.T) {
+	tests := []struct {
+		format Format
+		field  string
+		names  []string
+	}{
+		{MP4, "title", []string{"\xa9nam"}},
+		{MP4, "nope", nil},
+		{ID3v2_2, "title", []string{"TT2"}},
+		{ID3v2_3, "year", []string{"TYER"}},
+		{ID3v2_4, "year", []string{"TDRC"}},
+		{VORBIS, "album_artist", []string{"ALBUMARTIST"}},
+		{ID3v1, "genre", []string{"genre"}},
+		{ID3v1, "composer", nil},
+	}
+
+	for ii, tt := range tests {
+		got := Registry.LookupByField(tt.format, tt.field)
+		if !reflect.DeepEqual(got, tt.names) {
+			t.Errorf("[%d] LookupByField(%v, %q) = %q, expected %q", ii, tt.format, tt.field, got, tt.names)
+		}
+	}
+}
+
+func TestRegistryLookupByAtom(t *testing.T) {
+	tests := []struct {
+		format Format
+		name   string
+		field  string
+		ok     bool
+	}{
+		{MP4, "\xa9ART", "artist", true},
+		{MP4, "xxxx", "", false},
+		{ID3v2_3, "TPE1", "artist", true},
+		{ID3v2_4, "TDRC", "year", true},
+		{ID3v2_2, "TPE1", "", false},
+		{VORBIS, "tracknumber", "track", true},
+		{ID3v1, "title", "title", true},
+	}
+
+	for ii, tt := range tests {
+		field, ok := Registry.LookupByAtom(tt.format, tt.name)
+		if field != tt.field || ok != tt.ok {
+			t.Errorf("[%d] LookupByAtom(%v, %q) = %q, %v, expected %q, %v", ii, tt.format, tt.name, field, ok, tt.field, tt.ok)
+		}
+	}
+}
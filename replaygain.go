@@ -0,0 +1,78 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ReplayGain is the ReplayGain loudness normalisation of a track and of its album.
+type ReplayGain struct {
+	TrackGain float64 // adjustment in dB (negative for tracks which are louder than the reference)
+	TrackPeak float64 // peak sample amplitude, relative to full scale (0 if not given)
+	AlbumGain float64
+	AlbumPeak float64
+	HasTrack  bool // the track gain is given
+	HasAlbum  bool // the album gain is given
+}
+
+// ReplayGainInfo returns the ReplayGain of the track, or nil if it has none.  It is read
+// from the REPLAYGAIN_TRACK_GAIN, REPLAYGAIN_TRACK_PEAK, REPLAYGAIN_ALBUM_GAIN and
+// REPLAYGAIN_ALBUM_PEAK values (of Vorbis comments, user defined text frames of ID3v2 tags
+// or iTunes custom atoms of MP4 files), or otherwise from the relative volume adjustment
+// (RVA2) frames of ID3v2.4 tags identified as "track" or "album".
+func ReplayGainInfo(m Metadata) *ReplayGain {
+	rg := &ReplayGain{}
+	rg.TrackGain, rg.HasTrack = parseReplayGain(replayGainText(m, "REPLAYGAIN_TRACK_GAIN"))
+	rg.TrackPeak, _ = parseReplayGain(replayGainText(m, "REPLAYGAIN_TRACK_PEAK"))
+	rg.AlbumGain, rg.HasAlbum = parseReplayGain(replayGainText(m, "REPLAYGAIN_ALBUM_GAIN"))
+	rg.AlbumPeak, _ = parseReplayGain(replayGainText(m, "REPLAYGAIN_ALBUM_PEAK"))
+
+	for _, v := range VolumeAdjustments(m) {
+		c, ok := v.Adjustment()
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.EqualFold(v.Identification, "track") && !rg.HasTrack:
+			rg.TrackGain, rg.TrackPeak, rg.HasTrack = c.Gain, c.Peak, true
+		case strings.EqualFold(v.Identification, "album") && !rg.HasAlbum:
+			rg.AlbumGain, rg.AlbumPeak, rg.HasAlbum = c.Gain, c.Peak, true
+		}
+	}
+
+	if !rg.HasTrack && !rg.HasAlbum {
+		return nil
+	}
+	return rg
+}
+
+// replayGainText returns the value of the raw tag of m with the name (ignoring case, and
+// the UserTextPrefix of ID3v2 user defined text frames).
+func replayGainText(m Metadata, name string) string {
+	var text string
+	rangeRawText(m, func(k, s string) bool {
+		if strings.EqualFold(strings.TrimPrefix(k, UserTextPrefix), name) {
+			text = s
+			return false
+		}
+		return true
+	})
+	return text
+}
+
+// parseReplayGain parses a ReplayGain value, such as "-6.52 dB" or "0.988525".
+func parseReplayGain(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) > 2 && strings.EqualFold(s[len(s)-2:], "dB") {
+		s = strings.TrimSpace(s[:len(s)-2])
+	}
+	x, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return x, true
+}
@@ -0,0 +1,74 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReplayGainInfo(t *testing.T) {
+	flac := testFLAC(t, []string{
+		"REPLAYGAIN_TRACK_GAIN=-6.52 dB",
+		"REPLAYGAIN_TRACK_PEAK=0.988525",
+		"replaygain_album_gain=-7.00 dB",
+	}, 0)
+	v23 := testID3v23(39, testID3v23Frame("TXXX", []byte("\x00REPLAYGAIN_TRACK_GAIN\x00+1.5 dB")))
+	v24 := testID3v24(
+		testID3v24Frame("RVA2", []byte("track\x00\x01\xf3\x00\x10\x40\x00")),
+		testID3v24Frame("RVA2", []byte("album\x00\x02\x04\x00\x00\x01\xf0\x00\x00")),
+	)
+	title := testID3v23(16, testID3v23Frame("TIT2", []byte("\x00Title")))
+	m4a := testM4A([][]byte{testAtom(AtomCustom,
+		testAtom("mean", make([]byte, 4), []byte("com.apple.iTunes")),
+		testAtom("name", make([]byte, 4), []byte("replaygain_album_gain")),
+		testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("-3.25 dB")),
+	)})
+
+	tests := []struct {
+		input  []byte
+		read   func([]byte) (Metadata, error)
+		output *ReplayGain
+	}{
+		{flac, readFLACBytes, &ReplayGain{TrackGain: -6.52, TrackPeak: 0.988525, AlbumGain: -7, HasTrack: true, HasAlbum: true}},
+		{v23, readID3v2Bytes, &ReplayGain{TrackGain: 1.5, HasTrack: true}},
+		{v24, readID3v2Bytes, &ReplayGain{TrackGain: -6.5, TrackPeak: 0.5, AlbumGain: -8, HasTrack: true, HasAlbum: true}},
+		{title, readID3v2Bytes, nil},
+		{m4a, readAtomsBytes, &ReplayGain{AlbumGain: -3.25, HasAlbum: true}},
+		{testM4A(nil), readAtomsBytes, nil},
+	}
+
+	for ii, tt := range tests {
+		m, err := tt.read(tt.input)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := ReplayGainInfo(m); !reflect.DeepEqual(got, tt.output) {
+			t.Errorf("[%d] ReplayGainInfo() = %+v, expected %+v", ii, got, tt.output)
+		}
+	}
+}
+
+func TestParseReplayGain(t *testing.T) {
+	tests := []struct {
+		input  string
+		output float64
+		ok     bool
+	}{
+		{"-6.52 dB", -6.52, true},
+		{"+1.00dB", 1, true},
+		{" 0.988525 ", 0.988525, true},
+		{"dB", 0, false},
+		{"", 0, false},
+	}
+
+	for ii, tt := range tests {
+		got, ok := parseReplayGain(tt.input)
+		if got != tt.output || ok != tt.ok {
+			t.Errorf("[%d] parseReplayGain(%q) = %v, %v, expected %v, %v", ii, tt.input, got, ok, tt.output, tt.ok)
+		}
+	}
+}
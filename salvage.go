@@ -0,0 +1,132 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"io"
+)
+
+// salvageChunkSize is the number of bytes read at a time when scanning for tags.
+const salvageChunkSize = 64 << 10
+
+// salvageSignatures are the byte sequences which identify the start of tag data (or, for
+// "moov", the name of the atom which contains MP4 metadata).
+var salvageSignatures = [][]byte{[]byte("ID3"), []byte("fLaC"), []byte("OggS"), []byte("moov")}
+
+// Salvage reads the metadata of r like ReadFrom, but if that fails (for instance because
+// the leading bytes of the file are damaged) it scans the whole of r for the start of ID3v2
+// tags, FLAC and OGG streams and MP4 "moov" atoms, returning the metadata of the first one
+// which can be read.  Returns ErrNoTagsFound if there is none.
+//
+// Salvage is intended for recovering metadata from corrupted files: the recovered metadata
+// may be incomplete, and its FileType may be unknown.
+func Salvage(r io.ReadSeeker) (Metadata, error) {
+	return SalvageWithOptions(r, NewReadOptions())
+}
+
+// SalvageWithOptions is Salvage with the options o in place of the defaults.
+func SalvageWithOptions(r io.ReadSeeker, o ReadOptions) (Metadata, error) {
+	start := tell(r)
+	m, err := ReadFromWithOptions(r, o)
+	if m != nil && (err == nil || err == ErrTruncated) {
+		return m, err
+	}
+	if err == ErrMetadataTooLarge {
+		return nil, err
+	}
+
+	for pos := start; ; pos++ {
+		offset, sig, err := findSignature(r, pos)
+		if err != nil {
+			return nil, err
+		}
+		if offset < 0 {
+			return nil, ErrNoTagsFound
+		}
+		pos = offset
+
+		m, err := salvageAt(r, offset, sig, o)
+		if m != nil && (err == nil || err == ErrTruncated) {
+			return m, err
+		}
+	}
+}
+
+// salvageAt reads the tags identified by the signature sig at offset, returning nil if
+// they could not be read.
+func salvageAt(r io.ReadSeeker, offset int64, sig string, o ReadOptions) (Metadata, error) {
+	if sig == "moov" {
+		offset -= 4 // the atom size precedes its name
+		if offset < 0 {
+			return nil, nil
+		}
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b, err := readBytes(r, 10)
+	if err != nil {
+		return nil, nil
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	rc := newReadContext(o)
+	switch sig {
+	case "ID3":
+		if isID3v2Header(b) {
+			return readMP3Tags(rc.reader(r), rc)
+		}
+	case "fLaC":
+		if b[4]&0x7f == byte(streamInfoBlock) {
+			return readFLACTags(rc.reader(r), rc)
+		}
+	case "OggS":
+		if b[4] == 0 { // stream structure version
+			return readOGGTags(rc.reader(r), rc)
+		}
+	case "moov":
+		if getInt(b[:4]) >= 8 {
+			return readMP4Atoms(rc.reader(r), rc)
+		}
+	}
+	return nil, nil
+}
+
+// findSignature returns the offset of the first of salvageSignatures at or after pos in
+// r, and the signature found, or -1 if there is none.
+func findSignature(r io.ReadSeeker, pos int64) (int64, string, error) {
+	if _, err := r.Seek(pos, io.SeekStart); err != nil {
+		return -1, "", err
+	}
+	buf := make([]byte, salvageChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		b := buf[:n]
+		first, sig := -1, ""
+		for _, s := range salvageSignatures {
+			if i := bytes.Index(b, s); i >= 0 && (first < 0 || i < first) {
+				first, sig = i, string(s)
+			}
+		}
+		if first >= 0 {
+			return pos + int64(first), sig, nil
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return -1, "", nil
+		}
+		if err != nil {
+			return -1, "", err
+		}
+
+		// Signatures may span chunks, so overlap them.
+		pos += int64(n) - 3
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return -1, "", err
+		}
+	}
+}
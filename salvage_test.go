@@ -0,0 +1,48 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSalvage(t *testing.T) {
+	junk := []byte("damaged leading bytes")
+	flac := testFLAC(t, []string{"TITLE=Title"}, 0)
+	m4a := testM4A([][]byte{testTextItem("\xa9nam", "Title")})
+	damagedM4A := append([]byte{}, m4a...)
+	copy(damagedM4A, "xxxxxxxx") // the ftyp atom header
+
+	tests := []struct {
+		input  []byte
+		format Format
+		err    error
+	}{
+		{flac, VORBIS, nil},
+		{append(junk, flac...), VORBIS, nil},
+		{append([]byte("ID3 not a tag"), flac...), VORBIS, nil},
+		{damagedM4A, MP4, nil},
+		{append(junk, m4a[24:]...), UnknownFormat, ErrNoTagsFound}, // the "moov" atom header is missing
+		{junk, UnknownFormat, ErrNoTagsFound},
+	}
+
+	for ii, tt := range tests {
+		m, err := Salvage(bytes.NewReader(tt.input))
+		if err != tt.err {
+			t.Errorf("[%d] Salvage() error = %v, expected %v", ii, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if m.Format() != tt.format {
+			t.Errorf("[%d] Format() = %v, expected %v", ii, m.Format(), tt.format)
+		}
+		if m.Title() != "Title" {
+			t.Errorf("[%d] Title() = %q, expected %q", ii, m.Title(), "Title")
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import "strings"
+
+// SortNames are the names used to sort a track in a library (such as "Beatles, The"),
+// where they differ from the names which are displayed.
+type SortNames struct {
+	Title       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	Composer    string
+	Show        string // name of the TV show
+}
+
+// sortNameKeys are the raw tag names of the sort names (title, artist, album, album artist,
+// composer and show) for each format.
+var sortNameKeys = map[Format][6]string{
+	MP4:     {AtomSortTitle, AtomSortArtist, AtomSortAlbum, AtomSortAlbumArt, AtomSortComposer, AtomSortShow},
+	ID3v2_2: {"TST", "TSP", "TSA", "TS2", "TSC", UserTextPrefix + "SHOWSORT"},
+	ID3v2_3: {"TSOT", "TSOP", "TSOA", "TSO2", "TSOC", UserTextPrefix + "SHOWSORT"},
+	ID3v2_4: {"TSOT", "TSOP", "TSOA", "TSO2", "TSOC", UserTextPrefix + "SHOWSORT"},
+	VORBIS:  {"titlesort", "artistsort", "albumsort", "albumartistsort", "composersort", "showsort"},
+}
+
+// SortOrder returns the sort names of the metadata: the iTunes sort atoms (sonm, soar, soal,
+// soaa, soco and sosn) of MP4 files, the sort order frames of ID3v2 tags (with the show in
+// a SHOWSORT user text frame) and the sort fields of Vorbis comments.
+func SortOrder(m Metadata) SortNames {
+	keys, ok := sortNameKeys[m.Format()]
+	if !ok {
+		return SortNames{}
+	}
+	get := func(k string) string {
+		if strings.HasPrefix(k, UserTextPrefix) {
+			if frames := id3v2FramesOf(m); frames != nil {
+				return userText(frames, k[len(UserTextPrefix):])
+			}
+		}
+		return rawText(m, k)
+	}
+	return SortNames{
+		Title:       get(keys[0]),
+		Artist:      get(keys[1]),
+		Album:       get(keys[2]),
+		AlbumArtist: get(keys[3]),
+		Composer:    get(keys[4]),
+		Show:        get(keys[5]),
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSortOrder(t *testing.T) {
+	id3Frames := bytes.Join([][]byte{
+		testID3v23Frame("TSOT", []byte("\x00Title, The")),
+		testID3v23Frame("TSOP", []byte("\x00Beatles, The")),
+		testID3v23Frame("TSO2", []byte("\x00Various")),
+		testID3v23Frame("TXXX", []byte("\x00ShowSort\x00Office, The")),
+	}, nil)
+	id3 := append(testID3v23(len(id3Frames)+10, id3Frames), make([]byte, 10)...)
+
+	mp4 := testM4A([][]byte{
+		testTextItem(AtomSortTitle, "Title, The"),
+		testTextItem(AtomSortArtist, "Beatles, The"),
+		testTextItem(AtomSortAlbum, "Album, The"),
+		testTextItem(AtomSortAlbumArt, "Various"),
+		testAtom(AtomSortComposer, testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("Lennon, John")),
+			testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("McCartney, Paul"))),
+		testTextItem(AtomSortShow, "Office, The"),
+	})
+
+	flac := testFLAC(t, []string{"TITLESORT=Title, The", "ARTISTSORT=Beatles, The", "COMPOSERSORT=Lennon, John", "SHOWSORT=Office, The"}, 0)
+
+	tests := []struct {
+		input []byte
+		read  func([]byte) (Metadata, error)
+		want  SortNames
+	}{
+		{mp4, readAtomsBytes, SortNames{"Title, The", "Beatles, The", "Album, The", "Various", "Lennon, John;McCartney, Paul", "Office, The"}},
+		{id3, readID3v2Bytes, SortNames{Title: "Title, The", Artist: "Beatles, The", AlbumArtist: "Various", Show: "Office, The"}},
+		{flac, readFLACBytes, SortNames{Title: "Title, The", Artist: "Beatles, The", Composer: "Lennon, John", Show: "Office, The"}},
+		{testM4A(nil), readAtomsBytes, SortNames{}},
+	}
+
+	for ii, tt := range tests {
+		m, err := tt.read(tt.input)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if got := SortOrder(m); got != tt.want {
+			t.Errorf("[%d] SortOrder() = %+v, expected %+v", ii, got, tt.want)
+		}
+	}
+
+	if got := SortOrder(metadataID3v1{}); got != (SortNames{}) {
+		t.Errorf("SortOrder() = %+v for ID3v1 metadata, expected no sort names", got)
+	}
+}
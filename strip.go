@@ -0,0 +1,189 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// mp3Layout describes the positions of the tag blocks in an MP3 file.
+type mp3Layout struct {
+	firstID3v2End int64 // end of the first ID3v2 tag (0 if there is none)
+	audioStart    int64 // end of all leading ID3v2 tags
+	audioEnd      int64 // start of the trailing APE/ID3v1 tags
+	apeStart      int64 // start of the APE tag (equal to apeEnd if there is none)
+	apeEnd        int64
+	id3v1         bool  // the file has a trailing ID3v1 tag
+	id3v1Start    int64 // start of the ID3v1 tag, at the end of the file or before the APE tag
+	appendedStart int64 // start of an ID3v2 tag appended to the audio (equal to appendedEnd if there is none)
+	appendedEnd   int64
+	size          int64
+}
+
+// readMP3Layout locates the ID3v2 tags at the start of the file, and the APE and ID3v1
+// tags at the end of the file.  The APE tag is usually followed by the ID3v1 tag, but some
+// taggers append it after an existing ID3v1 tag.  An ID3v2.4 tag with a footer can also
+// be appended to the audio, before the APE and ID3v1 tags.
+func readMP3Layout(r io.ReadSeeker) (*mp3Layout, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	l := &mp3Layout{size: size, audioEnd: size}
+
+	// Leading (possibly concatenated) ID3v2 tags.
+	for {
+		if _, err := r.Seek(l.audioStart, io.SeekStart); err != nil {
+			return nil, err
+		}
+		h, _, err := readID3v2Header(r, defaultReadContext())
+		if err != nil {
+			break
+		}
+		end := l.audioStart + 10 + int64(h.Size)
+		if h.Footer {
+			end += 10
+		}
+		if end > size {
+			break
+		}
+		if l.firstID3v2End == 0 {
+			l.firstID3v2End = end
+		}
+		l.audioStart = end
+	}
+
+	// Trailing ID3v1 tag.
+	if size-128 >= l.audioStart {
+		if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+			return nil, err
+		}
+		tag, err := readString(r, 3)
+		if err != nil {
+			return nil, err
+		}
+		if tag == "TAG" {
+			l.id3v1 = true
+			l.id3v1Start = size - 128
+			l.audioEnd = l.id3v1Start
+		}
+	}
+
+	// APE tag, either at the end of the file or before the ID3v1 tag.
+	l.apeStart, l.apeEnd = l.audioEnd, l.audioEnd
+	if l.audioEnd-32 >= l.audioStart {
+		if _, err := r.Seek(l.audioEnd-32, io.SeekStart); err != nil {
+			return nil, err
+		}
+		footer, err := readBytes(r, 32)
+		if err != nil {
+			return nil, err
+		}
+		if string(footer[0:8]) == "APETAGEX" {
+			// Tag size includes the footer but not the header.
+			n := int64(binary.LittleEndian.Uint32(footer[12:16]))
+			if getBit(footer[23], 7) {
+				n += 32
+			}
+			if start := l.audioEnd - n; start >= l.audioStart {
+				l.apeStart = start
+				l.audioEnd = start
+			}
+		}
+	}
+
+	// ID3v1 tag before a trailing APE tag.
+	if !l.id3v1 && l.apeEnd > l.apeStart && l.apeStart-128 >= l.audioStart {
+		if _, err := r.Seek(l.apeStart-128, io.SeekStart); err != nil {
+			return nil, err
+		}
+		tag, err := readString(r, 3)
+		if err != nil {
+			return nil, err
+		}
+		if tag == "TAG" {
+			l.id3v1 = true
+			l.id3v1Start = l.apeStart - 128
+			l.audioEnd = l.id3v1Start
+		}
+	}
+
+	// ID3v2 tag appended to the audio, found from its footer.
+	l.appendedStart, l.appendedEnd = l.audioEnd, l.audioEnd
+	if l.audioEnd-20 >= l.audioStart {
+		if _, err := r.Seek(l.audioEnd-10, io.SeekStart); err != nil {
+			return nil, err
+		}
+		footer, err := readBytes(r, 10)
+		if err != nil {
+			return nil, err
+		}
+		if isID3v2Footer(footer) {
+			if start := l.audioEnd - 20 - int64(get7BitChunkedInt(footer[6:10])); start >= l.audioStart {
+				l.appendedStart = start
+				l.audioEnd = start
+			}
+		}
+	}
+	return l, nil
+}
+
+// copyRange copies the bytes between start and end from r to w.
+func copyRange(w io.Writer, r io.ReadSeeker, start, end int64) error {
+	if end <= start {
+		return nil
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.CopyN(w, r, end-start)
+	return err
+}
+
+// StripID3Tags copies the MP3 data from r to w without any ID3v2, APE or ID3v1 tags.
+func StripID3Tags(w io.Writer, r io.ReadSeeker) error {
+	l, err := readMP3Layout(r)
+	if err != nil {
+		return err
+	}
+	return copyRange(w, r, l.audioStart, l.audioEnd)
+}
+
+// StripLegacyTags copies the MP3 data from r to w, removing tag blocks which are
+// redundant or stale: all ID3v2 tags after the first (including a tag appended to the
+// audio if there is one at the start of the file), APE tags, and the ID3v1 tag if there
+// is an ID3v2 tag.
+func StripLegacyTags(w io.Writer, r io.ReadSeeker) error {
+	l, err := readMP3Layout(r)
+	if err != nil {
+		return err
+	}
+
+	if err := copyRange(w, r, 0, l.firstID3v2End); err != nil {
+		return err
+	}
+	if err := copyRange(w, r, l.audioStart, l.audioEnd); err != nil {
+		return err
+	}
+	if l.firstID3v2End == 0 && l.appendedEnd > l.appendedStart {
+		return copyRange(w, r, l.appendedStart, l.appendedEnd)
+	}
+	if l.id3v1 && l.firstID3v2End == 0 {
+		return copyRange(w, r, l.id3v1Start, l.id3v1Start+128)
+	}
+	return nil
+}
+
+// HasLegacyTags returns true if StripLegacyTags would remove any tags from the MP3 data in r.
+func HasLegacyTags(r io.ReadSeeker) (bool, error) {
+	l, err := readMP3Layout(r)
+	if err != nil {
+		return false, err
+	}
+	id3v2 := l.firstID3v2End > 0 || l.appendedEnd > l.appendedStart
+	return l.audioStart > l.firstID3v2End || l.apeEnd > l.apeStart || (l.id3v1 && id3v2) ||
+		(l.appendedEnd > l.appendedStart && l.firstID3v2End > 0), nil
+}
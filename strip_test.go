@@ -0,0 +1,82 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testID3v2Tag returns an empty ID3v2.3 tag with the given amount of padding.
+func testID3v2Tag(padding int) []byte {
+	return append([]byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, byte(padding)}, make([]byte, padding)...)
+}
+
+// testAPETag returns an APEv2 tag (with header and footer) with no items.
+func testAPETag() []byte {
+	b := make([]byte, 64)
+	for _, off := range []int{0, 32} {
+		copy(b[off:], "APETAGEX")
+		binary.LittleEndian.PutUint32(b[off+8:], 2000)
+		binary.LittleEndian.PutUint32(b[off+12:], 32)
+		binary.LittleEndian.PutUint32(b[off+20:], 1<<31)
+	}
+	return b
+}
+
+func testID3v1Tag() []byte {
+	b := make([]byte, 128)
+	copy(b, "TAG")
+	return b
+}
+
+func TestStripTags(t *testing.T) {
+	audio := []byte("\xff\xfbaudio data")
+	v2a, v2b := testID3v2Tag(10), testID3v2Tag(20)
+	appended := testAppendedID3v24(testID3v24Frame("TIT2", []byte("\x03Title")))
+	join := func(bs ...[]byte) []byte { return bytes.Join(bs, nil) }
+
+	tests := []struct {
+		input  []byte
+		strip  []byte
+		legacy []byte
+	}{
+		{audio, audio, audio},
+		{join(v2a, audio), audio, join(v2a, audio)},
+		{join(audio, testID3v1Tag()), audio, join(audio, testID3v1Tag())},
+		{join(v2a, audio, testID3v1Tag()), audio, join(v2a, audio)},
+		{join(v2a, v2b, audio, testAPETag(), testID3v1Tag()), audio, join(v2a, audio)},
+		{join(audio, testAPETag()), audio, audio},
+		{join(audio, appended, testID3v1Tag()), audio, join(audio, appended)},
+		{join(v2a, audio, appended), audio, join(v2a, audio)},
+	}
+
+	for ii, tt := range tests {
+		out := &bytes.Buffer{}
+		if err := StripID3Tags(out, bytes.NewReader(tt.input)); err != nil {
+			t.Errorf("[%d] StripID3Tags: unexpected error: %v", ii, err)
+		}
+		if !bytes.Equal(out.Bytes(), tt.strip) {
+			t.Errorf("[%d] StripID3Tags = %q, expected %q", ii, out.Bytes(), tt.strip)
+		}
+
+		out.Reset()
+		if err := StripLegacyTags(out, bytes.NewReader(tt.input)); err != nil {
+			t.Errorf("[%d] StripLegacyTags: unexpected error: %v", ii, err)
+		}
+		if !bytes.Equal(out.Bytes(), tt.legacy) {
+			t.Errorf("[%d] StripLegacyTags = %q, expected %q", ii, out.Bytes(), tt.legacy)
+		}
+
+		has, err := HasLegacyTags(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("[%d] HasLegacyTags: unexpected error: %v", ii, err)
+		}
+		if expected := !bytes.Equal(tt.input, tt.legacy); has != expected {
+			t.Errorf("[%d] HasLegacyTags = %v, expected %v", ii, has, expected)
+		}
+	}
+}
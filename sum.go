@@ -33,6 +33,12 @@ func Sum(r io.ReadSeeker) (string, error) {
 		return SumID3v2(r)
 	}
 
+	if mp4, err := isMP4(r); err != nil {
+		return "", err
+	} else if mp4 {
+		return SumAtoms(r)
+	}
+
 	h, err := SumID3v1(r)
 	if err != nil {
 		if err == ErrNotID3v1 {
@@ -71,6 +77,23 @@ func SumAtoms(r io.ReadSeeker) (string, error) {
 			return "", err
 		}
 
+		// Size of the atom contents: size 1 means a 64-bit size follows the name, and
+		// size 0 means the atom extends to the end of the file.
+		n := int64(size) - 8
+		switch {
+		case size == 0:
+			n = -1
+		case size == 1:
+			large, err := readUint64BigEndian(r)
+			if err != nil {
+				return "", err
+			}
+			n = int64(large) - 16
+		}
+		if n < 0 && size != 0 {
+			return "", fmt.Errorf("invalid size for '%v' atom: %d", name, size)
+		}
+
 		switch name {
 		case "meta":
 			// next_item_id (int32)
@@ -85,14 +108,21 @@ func SumAtoms(r io.ReadSeeker) (string, error) {
 
 		case "mdat": // stop when we get to the data
 			h := sha1.New()
-			_, err := io.CopyN(h, r, int64(size-8))
+			if n < 0 {
+				_, err = io.Copy(h, r)
+			} else {
+				_, err = io.CopyN(h, r, n)
+			}
 			if err != nil {
 				return "", fmt.Errorf("error reading audio data: %v", err)
 			}
 			return hashSum(h), nil
 		}
 
-		_, err = r.Seek(int64(size-8), io.SeekCurrent)
+		if n < 0 {
+			return "", fmt.Errorf("reached EOF before audio data")
+		}
+		_, err = r.Seek(n, io.SeekCurrent)
 		if err != nil {
 			return "", fmt.Errorf("error reading '%v' tag: %v", name, err)
 		}
@@ -136,7 +166,7 @@ func SumID3v1(r io.ReadSeeker) (string, error) {
 // SumID3v2 constructs a checksum of MP3 audio file data (assumed to have ID3v2 tags) provided by the
 // io.ReadSeeker which is metadata invariant.
 func SumID3v2(r io.ReadSeeker) (string, error) {
-	header, _, err := readID3v2Header(r)
+	header, _, err := readID3v2Header(r, defaultReadContext())
 	if err != nil {
 		return "", fmt.Errorf("error reading ID3v2 header: %v", err)
 	}
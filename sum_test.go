@@ -0,0 +1,43 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSumAtomsSizes(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xff, 0xf1}, 32)
+	mdat := testAtom("mdat", audio)
+
+	// The same mdat atom, with size 0 (extends to the end of the file).
+	zero := append([]byte{0, 0, 0, 0}, mdat[4:]...)
+
+	// The same mdat atom, with a 64-bit size.
+	large := append([]byte{0, 0, 0, 1, 'm', 'd', 'a', 't', 0, 0, 0, 0, 0, 0, 0, byte(16 + len(audio))}, audio...)
+
+	expected, err := SumAtoms(bytes.NewReader(testM4A(nil, mdat)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := [][]byte{
+		testM4A(nil, zero),
+		testM4A(nil, large),
+		testM4A([][]byte{testTextItem("\xa9nam", "Title")}, testAtom("free", make([]byte, 8)), zero),
+	}
+
+	for ii, tt := range tests {
+		sum, err := SumAtoms(bytes.NewReader(tt))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if sum != expected {
+			t.Errorf("[%d] SumAtoms() = %v, expected %v", ii, sum, expected)
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 )
 
 // ErrNoTagsFound is the error returned by ReadFrom when the metadata format
@@ -26,8 +27,30 @@ var ErrNoTagsFound = errors.New("no tags found")
 
 // ReadFrom detects and parses audio file metadata tags (currently supports ID3v1,2.{2,3,4}, MP4, FLAC/OGG).
 // Returns non-nil error if the format of the given data could not be determined, or if there was a problem
-// parsing the data.
+// parsing the data.  If the data ends part way through the tags then the Metadata read so far is returned
+// with ErrTruncated.
+//
+// MP4 files are recognized by their atom structure, so their ftyp atom may be missing or follow other atoms.
+//
+// MP3 files are read with the following precedence: the ID3v2 tags at the start of the file (which may be
+// preceded by junk bytes, see ReadID3v2Tags) or appended to the audio, then the ID3v1 tag at the end of the
+// file (which may be followed or preceded by an APEv2 tag).
+//
+// The tags are read with the options given by the Default variables (see NewReadOptions).
 func ReadFrom(r io.ReadSeeker) (Metadata, error) {
+	return ReadFromWithOptions(r, NewReadOptions())
+}
+
+// ReadFromWithOptions is like ReadFrom, reading the tags with the options o.  Unlike
+// changing the Default variables, the options only apply to this call, so calls with
+// different options can be made concurrently.
+func ReadFromWithOptions(r io.ReadSeeker, o ReadOptions) (Metadata, error) {
+	rc := newReadContext(o)
+	return readFrom(rc.reader(r), rc)
+}
+
+func readFrom(r io.ReadSeeker, rc *readContext) (Metadata, error) {
+	start := tell(r)
 	b, err := readBytes(r, 11)
 	if err != nil {
 		return nil, err
@@ -40,22 +63,45 @@ func ReadFrom(r io.ReadSeeker) (Metadata, error) {
 
 	switch {
 	case string(b[0:4]) == "fLaC":
-		return ReadFLACTags(r)
+		return readFLACTags(r, rc)
 
 	case string(b[0:4]) == "OggS":
-		return ReadOGGTags(r)
+		return readOGGTags(r, rc)
 
 	case string(b[4:8]) == "ftyp":
-		return ReadAtoms(r)
+		return readMP4Atoms(r, rc)
 
 	case string(b[0:3]) == "ID3":
-		return ReadID3v2Tags(r)
+		return readMP3Tags(r, rc)
 
 	case string(b[0:4]) == "DSD ":
-		return ReadDSFTags(r)
+		return readDSFTags(r, rc)
+	}
+
+	mp4, err := isMP4(r)
+	if err != nil {
+		return nil, err
+	}
+	if mp4 {
+		return readMP4Atoms(r, rc)
 	}
 
-	m, err := ReadID3v1Tags(r)
+	n, err := findID3v2(r)
+	if err != nil {
+		return nil, err
+	}
+	l, err := readMP3Layout(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if n >= 0 || l.appendedEnd > l.appendedStart {
+		return readMP3Tags(r, rc)
+	}
+
+	m, err := readID3v1Tags(r, rc)
 	if err != nil {
 		if err == ErrNotID3v1 {
 			err = ErrNoTagsFound
@@ -91,13 +137,18 @@ const (
 	M4A             FileType = "M4A"  // M4A file Apple iTunes (ACC) Audio
 	M4B             FileType = "M4B"  // M4A file Apple iTunes (ACC) Audio Book
 	M4P             FileType = "M4P"  // M4A file Apple iTunes (ACC) AES Protected Audio
-	ALAC            FileType = "ALAC" // Apple Lossless file FIXME: actually detect this
+	M4V             FileType = "M4V"  // M4V file Apple iTunes Video
+	ALAC            FileType = "ALAC" // Apple Lossless file
 	FLAC            FileType = "FLAC" // FLAC file
 	OGG             FileType = "OGG"  // OGG file
 	DSF             FileType = "DSF"  // DSF file DSD Sony format see https://dsd-guide.com/sites/default/files/white-papers/DSFFileFormatSpec_E.pdf
 )
 
 // Metadata is an interface which is used to describe metadata retrieved by this package.
+// The Metadata returned by this package is not modified after it has been read, so it is
+// safe for concurrent use by multiple goroutines.  Values returned by its methods (such as
+// the *Picture returned by Picture) are shared and must not be modified, except for the
+// copy returned by Raw.
 type Metadata interface {
 	// Format returns the metadata Format used to encode the data.
 	Format() Format
@@ -146,5 +197,205 @@ type Metadata interface {
 
 	// Raw returns the raw mapping of retrieved tag names and associated values.
 	// NB: tag/atom names are not standardised between formats.
+	// Each call returns a new copy of the tags (including any byte slices, pictures and
+	// other values they refer to), which the caller may modify.
 	Raw() map[string]interface{}
 }
+
+// MetadataExt is implemented by the Metadata returned by this package, and describes the
+// metadata which is not part of Metadata.  Other implementations of Metadata may implement
+// it too; the functions BPM, InitialKey, Chapters, Warnings, Conflicts and UnknownTags
+// return zero values for those which do not.
+type MetadataExt interface {
+	Metadata
+
+	// BPM returns the tempo of the track in beats per minute, or zero if unavailable.
+	BPM() float64
+
+	// Key returns the initial musical key of the track, or UnknownKey if unavailable.
+	Key() Key
+
+	// Chapters returns the chapter markers, or nil if unavailable.
+	Chapters() []Chapter
+
+	// Warnings returns the problems with the tag data which were skipped when it was read
+	// in Lenient mode (see ParseMode), or nil if there were none.
+	Warnings() []error
+
+	// Conflicts returns the fields which have different values in coexisting tags (such as
+	// the ID3v2 and ID3v1 tags of an MP3 file), or nil if there are none.
+	Conflicts() []Conflict
+
+	// UnknownTags returns the tags which were not recognized when the metadata was read,
+	// or nil if there were none (see DefaultUnknownTagPolicy).
+	UnknownTags() []UnknownTag
+}
+
+// BPM returns the tempo of the track in beats per minute, or zero if unavailable (see
+// MetadataExt).
+func BPM(m Metadata) float64 {
+	if x, ok := m.(MetadataExt); ok {
+		return x.BPM()
+	}
+	return 0
+}
+
+// InitialKey returns the initial musical key of the track, or UnknownKey if unavailable
+// (see MetadataExt).
+func InitialKey(m Metadata) Key {
+	if x, ok := m.(MetadataExt); ok {
+		return x.Key()
+	}
+	return UnknownKey
+}
+
+// Chapters returns the chapter markers of the track, or nil if unavailable (see
+// MetadataExt).
+func Chapters(m Metadata) []Chapter {
+	if x, ok := m.(MetadataExt); ok {
+		return x.Chapters()
+	}
+	return nil
+}
+
+// Warnings returns the problems with the tag data which were skipped when it was read in
+// Lenient mode, or nil if there were none (see MetadataExt).
+func Warnings(m Metadata) []error {
+	if x, ok := m.(MetadataExt); ok {
+		return x.Warnings()
+	}
+	return nil
+}
+
+// Conflicts returns the fields which have different values in coexisting tags, or nil if
+// there are none (see MetadataExt).
+func Conflicts(m Metadata) []Conflict {
+	if x, ok := m.(MetadataExt); ok {
+		return x.Conflicts()
+	}
+	return nil
+}
+
+// UnknownTags returns the tags which were not recognized when the metadata was read, or
+// nil if there were none (see MetadataExt).
+func UnknownTags(m Metadata) []UnknownTag {
+	if x, ok := m.(MetadataExt); ok {
+		return x.UnknownTags()
+	}
+	return nil
+}
+
+// copyRaw returns a deep copy of the raw tags m, for Metadata.Raw.
+func copyRaw(m map[string]interface{}) map[string]interface{} {
+	raw := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		switch v := v.(type) {
+		case []byte:
+			raw[k] = append([]byte(nil), v...)
+		case []string:
+			raw[k] = append([]string(nil), v...)
+		case *Picture:
+			p := *v
+			p.Data = append([]byte(nil), v.Data...)
+			raw[k] = &p
+		case *UFID:
+			u := *v
+			u.Identifier = append([]byte(nil), v.Identifier...)
+			raw[k] = &u
+		case *Comm:
+			c := *v
+			raw[k] = &c
+		case *EncapsulatedObject:
+			o := *v
+			o.Data = append([]byte(nil), v.Data...)
+			raw[k] = &o
+		case *PrivateFrame:
+			p := *v
+			p.Data = append([]byte(nil), v.Data...)
+			raw[k] = &p
+		case *VolumeAdjustment:
+			a := *v
+			a.Channels = append([]ChannelAdjustment(nil), v.Channels...)
+			raw[k] = &a
+		case *Popularimeter:
+			p := *v
+			raw[k] = &p
+		case *SyncedLyrics:
+			s := *v
+			s.Lines = append([]SyncedLine(nil), v.Lines...)
+			raw[k] = &s
+		case *ChapterFrame:
+			c := *v
+			c.Frames = copyRaw(v.Frames)
+			raw[k] = &c
+		case *TableOfContents:
+			t := *v
+			t.Children = append([]string(nil), v.Children...)
+			t.Frames = copyRaw(v.Frames)
+			raw[k] = &t
+		case []Chapter:
+			raw[k] = append([]Chapter(nil), v...)
+		default:
+			raw[k] = v
+		}
+	}
+	return raw
+}
+
+// rawText returns the text of the raw tag k of m (see Metadata.Raw), reading the ID3v2
+// frames, MP4 atoms or Vorbis comments (whose names are lower case) without copying them.
+// The values of an MP4 atom with several data atoms are joined with ";".
+func rawText(m Metadata, k string) string {
+	if frames := id3v2FramesOf(m); frames != nil {
+		s, _ := frames[k].(string)
+		return s
+	}
+	if mp4, ok := m.(*metadataMP4); ok {
+		return mp4.getString([]string{k})
+	}
+	return vorbisCommentsOf(m)[k]
+}
+
+// rangeRawText calls f with the name and text of each raw tag of m which has a text value,
+// as given by rawText, until f returns false.
+func rangeRawText(m Metadata, f func(k, s string) bool) {
+	if frames := id3v2FramesOf(m); frames != nil {
+		for k, v := range frames {
+			if s, ok := v.(string); ok && !f(k, s) {
+				return
+			}
+		}
+		return
+	}
+	if mp4, ok := m.(*metadataMP4); ok {
+		for k := range mp4.data {
+			if s := mp4.getString([]string{k}); s != "" && !f(k, s) {
+				return
+			}
+		}
+		return
+	}
+	for k, s := range vorbisCommentsOf(m) {
+		if !f(k, s) {
+			return
+		}
+	}
+}
+
+// Tag is a raw tag name and its value, as returned by Metadata.Raw.
+type Tag struct {
+	Name  string
+	Value interface{}
+}
+
+// AllTags returns the raw tags of m (see Metadata.Raw) sorted by name, so that they can be
+// listed in a deterministic order.
+func AllTags(m Metadata) []Tag {
+	raw := m.Raw()
+	tags := make([]Tag, 0, len(raw))
+	for k, v := range raw {
+		tags = append(tags, Tag{Name: k, Value: v})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags
+}
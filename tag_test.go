@@ -5,7 +5,9 @@
 package audiotag
 
 import (
+	"bytes"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -145,3 +147,217 @@ func testValue(t *testing.T, expected interface{}, found interface{}) {
 		t.Errorf("expected '%v', found '%v'", expected, found)
 	}
 }
+
+func TestReadFromTagDiscovery(t *testing.T) {
+	join := func(b ...[]byte) []byte { return bytes.Join(b, nil) }
+
+	audio := bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x00}, 64)
+	id3v1 := testID3v1Tag()
+	copy(id3v1[3:], "ID3v1 Title")
+	copy(id3v1[63:], "ID3v1 Album")
+
+	newTag := testID3v23(30, testID3v23Frame("TIT2", []byte("\x00New Title")), make([]byte, 10))
+	oldTag := testID3v23(50, testID3v23Frame("TIT2", []byte("\x00Old Title")), testID3v23Frame("TALB", []byte("\x00Old Album")), make([]byte, 10))
+	moov := testAtom("moov", testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst", testTextItem("\xa9nam", "MP4 Title")))))
+
+	tests := []struct {
+		input    []byte
+		format   Format
+		title    string
+		album    string
+		warnings int
+	}{
+		{join(audio, id3v1), ID3v1, "ID3v1 Title", "ID3v1 Album", 0},
+		{join(audio, testAPETag(), id3v1), ID3v1, "ID3v1 Title", "ID3v1 Album", 0},
+		{join(audio, id3v1, testAPETag()), ID3v1, "ID3v1 Title", "ID3v1 Album", 0},
+		{join([]byte("\x00\x00junk"), newTag, audio, id3v1), ID3v2_3, "New Title", "ID3v1 Album", 1},
+		{join(newTag, oldTag, audio), ID3v2_3, "New Title", "Old Album", 0},
+		{join([]byte("junk"), newTag, testID3v2Tag(4), oldTag, audio), ID3v2_3, "New Title", "Old Album", 1},
+		{join(moov, testAtom("mdat", audio)), MP4, "MP4 Title", "", 0},
+		{join(testAtom("free", make([]byte, 8)), testAtom("mdat", audio), testAtom("ftyp", []byte("M4A \x00\x00\x00\x00")), moov), MP4, "MP4 Title", "", 0},
+	}
+
+	for ii, tt := range tests {
+		m, err := ReadFrom(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("[%d] ReadFrom() error = %v", ii, err)
+			continue
+		}
+		if m.Format() != tt.format {
+			t.Errorf("[%d] Format() = %v, expected %v", ii, m.Format(), tt.format)
+		}
+		if m.Title() != tt.title {
+			t.Errorf("[%d] Title() = %q, expected %q", ii, m.Title(), tt.title)
+		}
+		if m.Album() != tt.album {
+			t.Errorf("[%d] Album() = %q, expected %q", ii, m.Album(), tt.album)
+		}
+		if len(Warnings(m)) != tt.warnings {
+			t.Errorf("[%d] Warnings() = %v, expected %d warnings", ii, Warnings(m), tt.warnings)
+		}
+	}
+
+	defer func(m ParseMode) { DefaultParseMode = m }(DefaultParseMode)
+	DefaultParseMode = Strict
+	_, err := ReadFrom(bytes.NewReader(join([]byte("junk"), newTag, audio)))
+	if perr, ok := err.(*ParseError); !ok || perr.Offset != 0 {
+		t.Errorf("ReadFrom() in Strict mode error = %v, expected *ParseError at offset 0", err)
+	}
+}
+
+func TestReadFromConflicts(t *testing.T) {
+	defer func(p ID3Preference) { DefaultID3Preference = p }(DefaultID3Preference)
+
+	id3v1 := testID3v1Tag()
+	copy(id3v1[3:], "Short Title")
+	copy(id3v1[33:], "A Very Long Artist Name Which ") // truncated to 30 bytes
+	copy(id3v1[63:], "ID3v1 Album")
+	id3v1[126] = 7 // track
+
+	artist := "A Very Long Artist Name Which Does Not Fit"
+	id3v2 := testID3v23(84, testID3v23Frame("TIT2", []byte("\x00Long Title")), testID3v23Frame("TPE1", []byte("\x00"+artist)), make([]byte, 10))
+	data := bytes.Join([][]byte{id3v2, bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x00}, 64), id3v1}, nil)
+
+	tests := []struct {
+		pref      ID3Preference
+		format    Format
+		title     string
+		conflicts []Conflict
+	}{
+		{PreferID3v2, ID3v2_3, "Long Title", []Conflict{{"title", ID3v2_3, "Long Title", ID3v1, "Short Title"}}},
+		{PreferID3v1, ID3v2_3, "Short Title", []Conflict{{"title", ID3v1, "Short Title", ID3v2_3, "Long Title"}}},
+	}
+
+	for ii, tt := range tests {
+		DefaultID3Preference = tt.pref
+		m, err := ReadFrom(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("[%d] ReadFrom() error = %v", ii, err)
+			continue
+		}
+		if m.Format() != tt.format {
+			t.Errorf("[%d] Format() = %v, expected %v", ii, m.Format(), tt.format)
+		}
+		if m.Title() != tt.title {
+			t.Errorf("[%d] Title() = %q, expected %q", ii, m.Title(), tt.title)
+		}
+		testValue(t, "ID3v1 Album", m.Album())
+		if n, _ := m.Track(); n != 7 {
+			t.Errorf("[%d] Track() = %d, expected 7", ii, n)
+		}
+		if !reflect.DeepEqual(Conflicts(m), tt.conflicts) {
+			t.Errorf("[%d] Conflicts() = %v, expected %v", ii, Conflicts(m), tt.conflicts)
+		}
+	}
+}
+
+func TestReadFromPreferID3v1Accessors(t *testing.T) {
+	frames := bytes.Join([][]byte{
+		testID3v23Frame("TIT2", []byte("\x00Long Title")),
+		testID3v23Frame("TSOT", []byte("\x00Title, The")),
+		testID3v23Frame("TXXX", []byte("\x00WORK\x00Symphony No. 5")),
+		testID3v23Frame("MVNM", []byte("\x00Andante con moto")),
+		testID3v23Frame("TXXX", []byte("\x00REPLAYGAIN_TRACK_GAIN\x00-6.5 dB")),
+	}, nil)
+	id3v1 := testID3v1Tag()
+	copy(id3v1[3:], "Short Title")
+	data := bytes.Join([][]byte{testID3v23(len(frames), frames), bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x00}, 64), id3v1}, nil)
+
+	for _, p := range []ID3Preference{PreferID3v2, PreferID3v1} {
+		o := NewReadOptions()
+		o.ID3Preference = p
+		m, err := ReadFromWithOptions(bytes.NewReader(data), o)
+		if err != nil {
+			t.Errorf("[%v] ReadFromWithOptions() error = %v", p, err)
+			continue
+		}
+		if m.Format() != ID3v2_3 {
+			t.Errorf("[%v] Format() = %v, expected %v", p, m.Format(), ID3v2_3)
+		}
+		if got := SortOrder(m); got.Title != "Title, The" {
+			t.Errorf("[%v] SortOrder() = %+v, expected title %q", p, got, "Title, The")
+		}
+		if got := Classical(m); got.Work != "Symphony No. 5" || got.Movement != "Andante con moto" {
+			t.Errorf("[%v] Classical() = %+v, expected the work and movement", p, got)
+		}
+		if rg := ReplayGainInfo(m); rg == nil || rg.TrackGain != -6.5 {
+			t.Errorf("[%v] ReplayGainInfo() = %+v, expected track gain -6.5", p, rg)
+		}
+	}
+}
+
+func TestAllTags(t *testing.T) {
+	m, err := ReadFLACTags(bytes.NewReader(testFLAC(t, []string{"TITLE=Title", "ARTIST=Artist", "ALBUM=Album", "GENRE=Jazz"}, 0)))
+	if err != nil {
+		t.Fatalf("ReadFLACTags() error = %v", err)
+	}
+
+	expected := []Tag{{"album", "Album"}, {"artist", "Artist"}, {"genre", "Jazz"}, {"title", "Title"}, {"vendor", "test"}}
+	for i := 0; i < 10; i++ {
+		if got := AllTags(m); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("AllTags() = %v, expected %v", got, expected)
+		}
+	}
+}
+
+func TestRawCopy(t *testing.T) {
+	title := testID3v23Frame("TIT2", []byte("\x00Title"))
+	priv := testID3v23Frame("PRIV", []byte("owner\x00data"))
+	id3 := testID3v23(len(title)+len(priv)+10, title, priv, make([]byte, 10))
+	m4a := testM4A([][]byte{testTextItem("\xa9nam", "Title")})
+
+	tests := []struct {
+		input []byte
+		read  func([]byte) (Metadata, error)
+		title string // raw name of the title
+	}{
+		{id3, readID3v2Bytes, "TIT2"},
+		{m4a, readAtomsBytes, "\xa9nam"},
+		{testID3v1Tag(), func(b []byte) (Metadata, error) { return ReadID3v1Tags(bytes.NewReader(b)) }, "title"},
+	}
+
+	for ii, tt := range tests {
+		m, err := tt.read(tt.input)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		raw := m.Raw()
+		raw[tt.title] = "Changed"
+		if p, ok := raw["PRIV"].(*PrivateFrame); ok {
+			p.Data[0] = 'x'
+		}
+		if m.Title() == "Changed" || m.Raw()[tt.title] == "Changed" {
+			t.Errorf("[%d] modifying Raw() changed the metadata", ii)
+		}
+		if p, ok := m.Raw()["PRIV"].(*PrivateFrame); ok && p.Data[0] != 'd' {
+			t.Errorf("[%d] modifying Raw() changed the PRIV frame: %q", ii, p.Data)
+		}
+	}
+}
+
+func TestMetadataExt(t *testing.T) {
+	m4a := testM4A([][]byte{testAtom("tmpo", testAtom("data", []byte{0, 0, 0, 21, 0, 0, 0, 0}, []byte{0, 120}))})
+	m, err := readAtomsBytes(m4a)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := m.(MetadataExt); !ok {
+		t.Errorf("%T does not implement MetadataExt", m)
+	}
+	if got := BPM(m); got != 120 {
+		t.Errorf("BPM() = %v, expected 120", got)
+	}
+
+	// An implementation of Metadata which is not a MetadataExt.
+	other := struct{ Metadata }{m}
+	if got := BPM(other); got != 0 {
+		t.Errorf("BPM() = %v, expected 0", got)
+	}
+	if got := InitialKey(other); got != UnknownKey {
+		t.Errorf("InitialKey() = %v, expected %v", got, UnknownKey)
+	}
+	if Chapters(other) != nil || Warnings(other) != nil || Conflicts(other) != nil || UnknownTags(other) != nil {
+		t.Errorf("expected nil Chapters, Warnings, Conflicts and UnknownTags")
+	}
+}
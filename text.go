@@ -0,0 +1,50 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultSanitizeText determines whether text values read from tags are cleaned of
+// byte order marks, NUL padding and control characters (other than tab, newline and
+// carriage return), which are often left behind by broken taggers.  Set it to false
+// (or ReadOptions.SanitizeText, for a single read) to read text values exactly as they
+// are stored.
+var DefaultSanitizeText = true
+
+// DefaultNormalizeText determines whether text values read from tags are converted to
+// Unicode Normalization Form C, so that text tagged on systems which use decomposed
+// characters (such as macOS) compares equal to the same text tagged elsewhere.  It is
+// false by default, and is overridden by ReadOptions.NormalizeText.
+var DefaultNormalizeText = false
+
+// sanitizeText returns s cleaned as described by DefaultSanitizeText and normalized as
+// described by DefaultNormalizeText, or as given by the ReadOptions of rc.
+func (rc *readContext) sanitizeText(s string) string {
+	if rc.SanitizeText && strings.IndexFunc(s, isUnwantedRune) >= 0 {
+		s = strings.Map(func(r rune) rune {
+			if isUnwantedRune(r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	if rc.NormalizeText {
+		s = normalizeNFC(s)
+	}
+	return s
+}
+
+func isUnwantedRune(r rune) bool {
+	switch r {
+	case '\t', '\n', '\r':
+		return false
+	case '\ufeff':
+		return true
+	}
+	return unicode.IsControl(r)
+}
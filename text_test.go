@@ -0,0 +1,77 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSanitizeText(t *testing.T) {
+	defer func(s bool) { DefaultSanitizeText = s }(DefaultSanitizeText)
+
+	tests := []struct {
+		input []byte // TIT2 frame data
+		raw   string // title with DefaultSanitizeText = false
+		clean string // title with DefaultSanitizeText = true
+	}{
+		{[]byte("\x00Title"), "Title", "Title"},
+		{[]byte("\x00Title\x00\x00"), "Title", "Title"},
+		{[]byte("\x00Ti\x07tle\x1b"), "Ti\x07tle\x1b", "Title"},
+		{[]byte("\x03\xef\xbb\xbfTitle"), "\ufeffTitle", "Title"},
+		{[]byte("\x01\xff\xfeT\x00\xff\xfei\x00"), "T\ufeffi", "Ti"}, // BOM repeated inside the text
+		{[]byte("\x03Line 1\r\n\tLine 2"), "Line 1\r\n\tLine 2", "Line 1\r\n\tLine 2"},
+	}
+
+	for i, tt := range tests {
+		for _, sanitize := range []bool{false, true} {
+			DefaultSanitizeText = sanitize
+			m, err := ReadID3v2Tags(bytes.NewReader(testID3v23(len(tt.input)+10, testID3v23Frame("TIT2", tt.input))))
+			if err != nil {
+				t.Errorf("[%d] ReadID3v2Tags() error = %v", i, err)
+				continue
+			}
+			want := tt.raw
+			if sanitize {
+				want = tt.clean
+			}
+			if got := m.Title(); got != want {
+				t.Errorf("[%d] Title() with DefaultSanitizeText = %v is %q, expected %q", i, sanitize, got, want)
+			}
+		}
+	}
+}
+
+func TestNormalizeNFC(t *testing.T) {
+	tests := []struct {
+		input, output string
+	}{
+		{"", ""},
+		{"Beyonc\u00e9", "Beyonc\u00e9"},
+		{"Beyonce\u0301", "Beyonc\u00e9"},
+		{"Mo\u0308tley Cru\u0308e", "M\u00f6tley Cr\u00fce"},
+		{"\u212b", "\u00c5"},               // singleton decomposition
+		{"q\u0307\u0323", "q\u0323\u0307"}, // reordered marks, no composite
+		{"a\u0302\u0323", "\u1ead"},        // composed through a reordered mark
+		{"\u1100\u1161\u11a8", "\uac01"},   // Hangul
+		{"\u0958", "\u0915\u093c"},         // composition exclusion
+		{"e\u0301\u0301", "\u00e9\u0301"},  // blocked by a mark of the same class
+	}
+
+	for ii, tt := range tests {
+		if got := normalizeNFC(tt.input); got != tt.output {
+			t.Errorf("[%d] normalizeNFC(%+q) = %+q, expected %+q", ii, tt.input, got, tt.output)
+		}
+	}
+
+	defer func(n bool) { DefaultNormalizeText = n }(DefaultNormalizeText)
+	DefaultNormalizeText = true
+	title := testID3v23Frame("TIT2", []byte("\x03Beyonce\xcc\x81"))
+	m, err := ReadID3v2Tags(bytes.NewReader(testID3v23(len(title)+10, title, make([]byte, 10))))
+	if err != nil {
+		t.Fatalf("ReadID3v2Tags() error = %v", err)
+	}
+	testValue(t, "Beyoncé", m.Title())
+}
@@ -0,0 +1,74 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import "fmt"
+
+// UnknownTagPolicy determines how tags which are not recognized (MP4 metadata item atoms,
+// ID3v2 frames whose contents are not decoded, and FLAC metadata blocks) are handled.
+type UnknownTagPolicy int
+
+// Unknown tag policies.
+const (
+	// SkipUnknown ignores unknown tags.  This is the default.
+	SkipUnknown UnknownTagPolicy = iota
+
+	// ListUnknown records the name, offset and size of unknown tags.
+	ListUnknown
+
+	// CaptureUnknown records unknown tags along with a copy of their contents, for
+	// round-tripping or inspection.
+	CaptureUnknown
+)
+
+func (p UnknownTagPolicy) String() string {
+	switch p {
+	case SkipUnknown:
+		return "skip"
+	case ListUnknown:
+		return "list"
+	case CaptureUnknown:
+		return "capture"
+	}
+	return fmt.Sprintf("UnknownTagPolicy(%d)", int(p))
+}
+
+// DefaultUnknownTagPolicy is the UnknownTagPolicy used when reading tags, unless another
+// is given by ReadOptions.
+var DefaultUnknownTagPolicy = SkipUnknown
+
+// UnknownTag is a tag which was not recognized when reading metadata.
+type UnknownTag struct {
+	Format Format
+	Name   string // atom name, frame ID, or FLAC block type (such as "APPLICATION")
+	Offset int64  // byte offset of the tag (including its header) in the input
+	Size   int64  // size of the contents of the tag, excluding its header
+	Data   []byte // contents of the tag, nil unless the policy is CaptureUnknown
+}
+
+func (u UnknownTag) String() string {
+	return fmt.Sprintf("%v %q at offset %d (%d bytes)", u.Format, u.Name, u.Offset, u.Size)
+}
+
+// unknownTags is embedded in Metadata implementations to record the unknown tags (see
+// DefaultUnknownTagPolicy).
+type unknownTags []UnknownTag
+
+// UnknownTags returns the tags which were not recognized, or nil if there were none or
+// the UnknownTagPolicy is SkipUnknown.
+func (u unknownTags) UnknownTags() []UnknownTag { return u }
+
+// add records an unknown tag according to the UnknownTagPolicy p.  The data is only
+// retained by CaptureUnknown, which copies it.
+func (u *unknownTags) add(p UnknownTagPolicy, f Format, name string, offset, size int64, data []byte) {
+	t := UnknownTag{Format: f, Name: name, Offset: offset, Size: size}
+	switch p {
+	case SkipUnknown:
+		return
+	case CaptureUnknown:
+		t.Data = append([]byte{}, data...)
+	}
+	*u = append(*u, t)
+}
@@ -0,0 +1,57 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnknownTagPolicy(t *testing.T) {
+	defer func(p UnknownTagPolicy) { DefaultUnknownTagPolicy = p }(DefaultUnknownTagPolicy)
+
+	title := testID3v23Frame("TIT2", []byte("\x00Title"))
+	mcdi := testID3v23Frame("MCDI", []byte("owner\x00data"))
+	id3 := testID3v23(100, title, mcdi, make([]byte, 100-len(title)-len(mcdi)))
+
+	m4a := testM4A([][]byte{testTextItem("\xa9nam", "Title"), testAtom("xxxx", []byte("abc"))})
+
+	flac := append([]byte("fLaC\x00\x00\x00\x22"), make([]byte, 34)...)
+	flac = append(flac, 0x82, 0, 0, 8)
+	flac = append(flac, "TESTdata"...)
+
+	tests := []struct {
+		input   []byte
+		read    func([]byte) (Metadata, error)
+		unknown UnknownTag
+	}{
+		{id3, readID3v2Bytes, UnknownTag{Format: ID3v2_3, Name: "MCDI", Offset: int64(10 + len(title)), Size: 10, Data: []byte("owner\x00data")}},
+		{m4a, readAtomsBytes, UnknownTag{Format: MP4, Name: "xxxx", Offset: 81, Size: 3, Data: []byte("abc")}},
+		{flac, readFLACBytes, UnknownTag{Format: VORBIS, Name: "APPLICATION", Offset: 42, Size: 8, Data: []byte("TESTdata")}},
+	}
+
+	for ii, tt := range tests {
+		for _, p := range []UnknownTagPolicy{SkipUnknown, ListUnknown, CaptureUnknown} {
+			DefaultUnknownTagPolicy = p
+			m, err := tt.read(tt.input)
+			if err != nil {
+				t.Errorf("[%d] %v: unexpected error: %v", ii, p, err)
+				continue
+			}
+			var expected []UnknownTag
+			switch p {
+			case ListUnknown:
+				u := tt.unknown
+				u.Data = nil
+				expected = []UnknownTag{u}
+			case CaptureUnknown:
+				expected = []UnknownTag{tt.unknown}
+			}
+			if got := UnknownTags(m); !reflect.DeepEqual(got, expected) {
+				t.Errorf("[%d] %v: UnknownTags() = %v, expected %v", ii, p, got, expected)
+			}
+		}
+	}
+}
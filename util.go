@@ -5,6 +5,7 @@
 package audiotag
 
 import (
+	"bytes"
 	"encoding/binary"
 	"io"
 )
@@ -40,7 +41,23 @@ func readUint64LittleEndian(r io.Reader) (uint64, error) {
 	return binary.LittleEndian.Uint64(b), nil
 }
 
+// readChunkSize is the size above which readBytes allocates as the data is read, so that
+// a corrupt length cannot allocate more memory than the data contains.
+const readChunkSize = 1 << 20
+
 func readBytes(r io.Reader, n uint) ([]byte, error) {
+	if n > readChunkSize {
+		var buf bytes.Buffer
+		m, err := io.CopyN(&buf, r, int64(n))
+		if err != nil {
+			if err == io.EOF && m > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
 	b := make([]byte, n)
 	_, err := io.ReadFull(r, b)
 	if err != nil {
@@ -0,0 +1,768 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"unicode/utf8"
+)
+
+// ErrValidateNotSupported is the error returned by Validate when validation is not
+// supported for the format of the file.
+var ErrValidateNotSupported = errors.New("validation is not supported for this format")
+
+// Severity is the severity of a validation Finding.
+type Severity int
+
+// Severities.
+const (
+	// Warning is a deviation from the specification, or from common practice, which
+	// most readers handle.
+	Warning Severity = iota
+
+	// Error is a spec violation which readers may reject, or which causes data to be
+	// lost or misread.
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Finding is a problem found by Validate.
+type Finding struct {
+	Severity Severity
+	Format   Format // format of the tag (or container) containing the problem
+	Offset   int64  // byte offset of the offending structure in the file
+	Message  string
+}
+
+func (f Finding) String() string {
+	if f.Format == UnknownFormat {
+		return fmt.Sprintf("%v: offset %d: %v", f.Severity, f.Offset, f.Message)
+	}
+	return fmt.Sprintf("%v: %v: offset %d: %v", f.Severity, f.Format, f.Offset, f.Message)
+}
+
+// validator accumulates findings.
+type validator struct {
+	findings []Finding
+}
+
+func (v *validator) add(s Severity, f Format, offset int64, format string, args ...interface{}) {
+	v.findings = append(v.findings, Finding{
+		Severity: s,
+		Format:   f,
+		Offset:   offset,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Validate checks the structure of the tags in r against their specifications, returning
+// the problems found in the order they occur.  It checks ID3v2 tag and frame framing
+// (including synchsafe sizes), ID3v1 and APE tags in MP3 files, the atom hierarchy of MP4
+// files, and the metadata blocks and Vorbis comment framing of FLAC files.  Returns
+// ErrValidateNotSupported for other formats, and a non-nil error if r could not be read.
+//
+// Offsets within ID3v2.2 and ID3v2.3 tags which use unsynchronisation refer to the
+// decoded tag data.
+func Validate(r io.ReadSeeker) ([]Finding, error) {
+	b, err := readBytes(r, 11)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	v := &validator{}
+	switch {
+	case bytes.HasPrefix(b, []byte("fLaC")):
+		err = v.flac(r)
+
+	case len(b) >= 8 && string(b[4:8]) == "ftyp":
+		err = v.mp4(r)
+
+	case bytes.HasPrefix(b, []byte("OggS")), bytes.HasPrefix(b, []byte("DSD ")):
+		return nil, ErrValidateNotSupported
+
+	default:
+		err = v.mp3(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v.findings, nil
+}
+
+// mp3 validates the ID3v2 tags at the start of the file, and any APE and ID3v1 tags
+// at its end.
+func (v *validator) mp3(r io.ReadSeeker) error {
+	var offset int64
+	var tags int
+	for {
+		b, err := readBytes(r, 10)
+		if err != nil || string(b[:3]) != "ID3" {
+			break
+		}
+		tags++
+		n, err := v.id3v2(r, offset, b)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		offset += n
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	l, err := readMP3Layout(r)
+	if err != nil {
+		return err
+	}
+	if l.apeEnd > l.apeStart {
+		v.add(Warning, UnknownFormat, l.apeStart, "APE tag in MP3 file")
+	}
+	if l.id3v1 && tags > 0 {
+		v.add(Warning, ID3v1, l.id3v1Start, "ID3v1 tag duplicates the ID3v2 tag")
+	}
+	return nil
+}
+
+// id3v2 validates the ID3v2 tag at offset which starts with the given header, returning
+// the total size of the tag (or zero if it could not be determined).
+func (v *validator) id3v2(r io.Reader, offset int64, hb []byte) (int64, error) {
+	var vers Format
+	switch hb[3] {
+	case 2:
+		vers = ID3v2_2
+	case 3:
+		vers = ID3v2_3
+	case 4:
+		vers = ID3v2_4
+	default:
+		v.add(Error, UnknownFormat, offset+3, "unsupported ID3 version 2.%d", hb[3])
+		return 0, nil
+	}
+	if offset > 0 {
+		v.add(Warning, vers, offset, "multiple ID3v2 tags")
+	}
+	if hb[4] == 0xff {
+		v.add(Error, vers, offset+4, "invalid revision 0xff")
+	}
+
+	flags := hb[5]
+	known := byte(0xc0)
+	switch vers {
+	case ID3v2_3:
+		known = 0xe0
+	case ID3v2_4:
+		known = 0xf0
+	}
+	if flags&^known != 0 {
+		v.add(Error, vers, offset+5, "undefined header flags set: %08b", flags&^known)
+	}
+	if vers == ID3v2_2 && getBit(flags, 6) {
+		v.add(Error, vers, offset+5, "compression flag set (no compression scheme is defined for ID3v2.2)")
+	}
+	if !synchsafe(hb[6:10]) {
+		v.add(Error, vers, offset+6, "tag size is not a synchsafe integer")
+	}
+
+	size := int64(get7BitChunkedInt(hb[6:10]))
+	body, err := readBytes(r, uint(size))
+	if err != nil {
+		v.add(Error, vers, offset, "tag size %d extends beyond the end of the file", size)
+		return 0, nil
+	}
+	total := 10 + size
+
+	footer := vers == ID3v2_4 && getBit(flags, 4)
+	if footer {
+		f, err := readBytes(r, 10)
+		if err != nil || string(f[:3]) != "3DI" {
+			v.add(Error, vers, offset+total, "footer flag set but no footer found")
+		} else if !bytes.Equal(f[3:], hb[3:]) {
+			v.add(Error, vers, offset+total, "footer does not match header")
+		}
+		total += 10
+	}
+
+	if getBit(flags, 7) && vers != ID3v2_4 {
+		body = removeUnsynchronisation(body)
+	}
+
+	pos := int64(0)
+	if getBit(flags, 6) && vers != ID3v2_2 {
+		n := v.id3v2ExtendedHeader(vers, offset+10, body)
+		if n < 0 {
+			return total, nil
+		}
+		pos = n
+	}
+
+	v.id3v2Frames(vers, offset+10, body, pos)
+	return total, nil
+}
+
+// id3v2ExtendedHeader validates the extended header at the start of body, returning its
+// size, or -1 if it is invalid.  If the extended header includes a CRC-32 of the tag data
+// then it is verified.
+func (v *validator) id3v2ExtendedHeader(vers Format, offset int64, body []byte) int64 {
+	if len(body) < 6 {
+		v.add(Error, vers, offset, "truncated extended header")
+		return -1
+	}
+	var size int64
+	if vers == ID3v2_4 {
+		if !synchsafe(body[:4]) {
+			v.add(Error, vers, offset, "extended header size is not a synchsafe integer")
+		}
+		size = int64(get7BitChunkedInt(body[:4])) // includes the size bytes
+		if size < 6 {
+			v.add(Error, vers, offset, "extended header size %d is less than the minimum of 6", size)
+			return -1
+		}
+	} else {
+		size = int64(getInt(body[:4])) + 4
+		if size != 10 && size != 14 {
+			v.add(Error, vers, offset, "extended header size must be 6 or 10, got %d", size-4)
+		}
+	}
+	if size > int64(len(body)) {
+		v.add(Error, vers, offset, "extended header extends beyond the end of the tag")
+		return -1
+	}
+
+	if vers == ID3v2_4 {
+		v.id3v24ExtendedFlags(offset, body[:size], body[size:])
+		return size
+	}
+
+	// ID3v2.3: flags (2 bytes), padding size (4 bytes) and the optional CRC (4 bytes),
+	// which is calculated over the frames, excluding the padding.
+	if size < 10 || !getBit(body[4], 7) {
+		return size
+	}
+	if size < 14 {
+		v.add(Error, vers, offset+4, "CRC flag set but extended header has no CRC")
+		return size
+	}
+	padding := int64(getInt(body[6:10]))
+	if padding > int64(len(body))-size {
+		v.add(Error, vers, offset+6, "padding size %d extends beyond the end of the tag", padding)
+		return size
+	}
+	v.id3v2CRC(vers, offset+10, uint32(getInt(body[10:14])), body[size:int64(len(body))-padding])
+	return size
+}
+
+// id3v24ExtendedFlags validates the flags and flag data of the ID3v2.4 extended header ext,
+// at offset, which is followed by the frames and padding in data.
+func (v *validator) id3v24ExtendedFlags(offset int64, ext, data []byte) {
+	if ext[4] != 1 {
+		v.add(Error, ID3v2_4, offset+4, "extended header must have 1 flag byte, got %d", ext[4])
+		return
+	}
+	flags := ext[5]
+	pos := int64(6)
+	// The data of each set flag (update, CRC and restrictions, in order) is preceded by
+	// its length.
+	for _, bit := range []uint{6, 5, 4} {
+		if !getBit(flags, bit) {
+			continue
+		}
+		if pos >= int64(len(ext)) || pos+1+int64(ext[pos]) > int64(len(ext)) {
+			v.add(Error, ID3v2_4, offset+pos, "extended header flag data extends beyond the extended header")
+			return
+		}
+		n := int64(ext[pos])
+		if bit == 5 {
+			// The CRC is a 35-bit synchsafe integer, calculated over the frames and padding.
+			if n != 5 || !synchsafe(ext[pos+1:pos+6]) {
+				v.add(Error, ID3v2_4, offset+pos, "invalid CRC data in extended header")
+			} else {
+				v.id3v2CRC(ID3v2_4, offset+pos+1, uint32(get7BitChunkedInt(ext[pos+1:pos+6])), data)
+			}
+		}
+		pos += 1 + n
+	}
+}
+
+// id3v2CRC checks the CRC-32 (at offset in the extended header) of the tag data.
+func (v *validator) id3v2CRC(vers Format, offset int64, crc uint32, data []byte) {
+	if sum := crc32.ChecksumIEEE(data); sum != crc {
+		v.add(Error, vers, offset, "CRC-32 mismatch: extended header has %08x, tag data has %08x", crc, sum)
+	}
+}
+
+// id3v2Frames validates the frames in body (starting at pos), where offset is the offset
+// of body in the file.
+func (v *validator) id3v2Frames(vers Format, offset int64, body []byte, pos int64) {
+	headerSize, nameSize := int64(10), 4
+	if vers == ID3v2_2 {
+		headerSize, nameSize = 6, 3
+	}
+
+	for pos < int64(len(body)) {
+		if body[pos] == 0 {
+			// Padding: must be all zeros to the end of the tag.
+			for i := pos; i < int64(len(body)); i++ {
+				if body[i] != 0 {
+					v.add(Warning, vers, offset+i, "non-zero byte in padding")
+					break
+				}
+			}
+			return
+		}
+
+		start := offset + pos
+		if pos+headerSize > int64(len(body)) {
+			v.add(Error, vers, start, "truncated frame header")
+			return
+		}
+		h := body[pos : pos+headerSize]
+		name := string(h[:nameSize])
+
+		var size int64
+		switch vers {
+		case ID3v2_2:
+			size = int64(getInt(h[3:6]))
+		case ID3v2_3:
+			size = int64(getInt(h[4:8]))
+		case ID3v2_4:
+			size = int64(get7BitChunkedInt(h[4:8]))
+			if !synchsafe(h[4:8]) {
+				v.add(Error, vers, start+4, "frame %q size is not a synchsafe integer", name)
+				// Readers commonly fall back to a plain integer (as written by old
+				// versions of iTunes), so try that to find the next frame.
+				size = int64(getInt(h[4:8]))
+			}
+		}
+
+		if !wellFormedID3FrameName(name) {
+			v.add(Error, vers, start, "invalid frame ID %q", name)
+			return
+		}
+		if !validID3Frame(vers, name) && name[0] != 'X' && name[0] != 'Y' && name[0] != 'Z' {
+			v.add(Warning, vers, start, "non-standard frame %q", name)
+		}
+		if size == 0 {
+			v.add(Error, vers, start, "frame %q is empty", name)
+		}
+
+		pos += headerSize
+		if pos+size > int64(len(body)) {
+			v.add(Error, vers, start, "frame %q size %d extends beyond the end of the tag", name, size)
+			return
+		}
+		data := body[pos : pos+size]
+		pos += size
+
+		if vers != ID3v2_2 && h[9] != 0 {
+			// Compressed, encrypted and (in ID3v2.4) unsynchronised frame data cannot
+			// be checked further.
+			continue
+		}
+		v.id3v2FrameData(vers, start, name, data)
+	}
+}
+
+// id3v2FrameData validates the contents of the frame with the given name at offset.
+func (v *validator) id3v2FrameData(vers Format, offset int64, name string, b []byte) {
+	maxEncoding := byte(encodingUTF16)
+	if vers == ID3v2_4 {
+		maxEncoding = encodingUTF8
+	}
+
+	var err error
+	switch {
+	case name == "TXXX" || name == "TXX":
+		_, err = readTextWithDescrFrame(b, false, true, defaultReadContext())
+	case name[0] == 'T' || name == "MVNM" || name == "MVIN":
+		_, err = readTFrame(b, defaultReadContext())
+	case name == "UFID" || name == "UFI":
+		_, err = readUFID(b)
+	case name == "COMM" || name == "COM" || name == "USLT" || name == "ULT":
+		_, err = readTextWithDescrFrame(b, true, true, defaultReadContext())
+	case name == "APIC":
+		_, err = readAPICFrame(b, defaultReadContext())
+	case name == "PIC":
+		_, err = readPICFrame(b, defaultReadContext())
+	default:
+		return
+	}
+	if err != nil {
+		v.add(Error, vers, offset, "frame %q: %v", name, err)
+		return
+	}
+
+	if name[0] == 'T' || name == "COMM" || name == "COM" || name == "USLT" || name == "ULT" || name == "APIC" || name == "PIC" {
+		if len(b) > 0 && b[0] > maxEncoding {
+			v.add(Error, vers, offset, "frame %q: invalid text encoding %d", name, b[0])
+		}
+	}
+}
+
+// synchsafe returns true if none of the bytes have their most significant bit set.
+func synchsafe(b []byte) bool {
+	for _, x := range b {
+		if x&0x80 != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// removeUnsynchronisation removes the zero bytes inserted after 0xff bytes by the
+// unsynchronisation scheme.
+func removeUnsynchronisation(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		out = append(out, b[i])
+		if b[i] == 0xff && i+1 < len(b) && b[i+1] == 0 {
+			i++
+		}
+	}
+	return out
+}
+
+// mp4Containers are the MP4 atoms which contain other atoms, and their permitted parents
+// ("" for the top level).
+var mp4Containers = map[string][]string{
+	"moov": {""},
+	"trak": {"moov"},
+	"mdia": {"trak"},
+	"minf": {"mdia"},
+	"stbl": {"minf"},
+	"udta": {"moov", "trak"},
+	"meta": {"udta", "moov", "trak"},
+	"ilst": {"meta"},
+}
+
+// mp4 validates the atom hierarchy of an MP4 file.
+func (v *validator) mp4(r io.ReadSeeker) error {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	return v.mp4Atoms(r, "", 0, end, seen)
+}
+
+// mp4Atoms validates the atoms between start and end, which are the children of parent.
+func (v *validator) mp4Atoms(r io.ReadSeeker, parent string, start, end int64, seen map[string]bool) error {
+	pos := start
+	for first := true; pos < end; first = false {
+		if end-pos < 8 {
+			v.add(Error, MP4, pos, "%d trailing bytes in %s", end-pos, mp4Parent(parent))
+			return nil
+		}
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+		h, err := readBytes(r, 8)
+		if err != nil {
+			return err
+		}
+		size, name := int64(binary.BigEndian.Uint32(h)), string(h[4:])
+		headerSize := int64(8)
+
+		switch size {
+		case 0:
+			// Extends to the end of the file, only permitted at the top level.
+			if parent != "" {
+				v.add(Error, MP4, pos, "atom %q has size 0 inside %q", name, parent)
+			}
+			size = end - pos
+		case 1:
+			b, err := readBytes(r, 8)
+			if err != nil {
+				v.add(Error, MP4, pos, "truncated 64-bit size of atom %q", name)
+				return nil
+			}
+			size, headerSize = int64(binary.BigEndian.Uint64(b)), 16
+		}
+
+		if size < headerSize {
+			v.add(Error, MP4, pos, "atom %q has invalid size %d", name, size)
+			return nil
+		}
+		if pos+size > end {
+			v.add(Error, MP4, pos, "atom %q (size %d) extends beyond the end of %s", name, size, mp4Parent(parent))
+			size = end - pos
+		}
+		if parent == "" && first && name != "ftyp" {
+			v.add(Warning, MP4, pos, "first atom is %q, expected \"ftyp\"", name)
+		}
+
+		if parents, ok := mp4Containers[name]; ok {
+			if !containsString(parents, parent) {
+				v.add(Error, MP4, pos, "atom %q inside %s, expected it inside %q", name, mp4Parent(parent), parents[0])
+			}
+			if name == "moov" {
+				if seen["moov"] {
+					v.add(Error, MP4, pos, "multiple \"moov\" atoms")
+				}
+				seen["moov"] = true
+			}
+
+			childStart := pos + headerSize
+			if name == "meta" {
+				// meta is a full box: 4 bytes of version and flags precede its children.
+				// Some files (notably QuickTime) omit them, in which case the next
+				// bytes are the size of the first child.
+				b, err := readBytes(r, 8)
+				if err == nil && string(b[4:]) != "hdlr" {
+					childStart += 4
+				}
+			}
+			if err := v.mp4Atoms(r, name, childStart, pos+size, seen); err != nil {
+				return err
+			}
+		} else if parent == "ilst" {
+			if err := v.mp4Item(r, name, pos+headerSize, pos+size); err != nil {
+				return err
+			}
+		}
+		pos += size
+	}
+	return nil
+}
+
+// mp4Item validates the metadata item atom whose contents are between start and end.
+func (v *validator) mp4Item(r io.ReadSeeker, name string, start, end int64) error {
+	var data int
+	pos := start
+	for pos < end {
+		if end-pos < 8 {
+			v.add(Error, MP4, pos, "%d trailing bytes in item %q", end-pos, name)
+			return nil
+		}
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+		h, err := readBytes(r, 8)
+		if err != nil {
+			return err
+		}
+		size, child := int64(binary.BigEndian.Uint32(h)), string(h[4:])
+		if size < 8 || pos+size > end {
+			v.add(Error, MP4, pos, "atom %q in item %q has invalid size %d", child, name, size)
+			return nil
+		}
+
+		switch child {
+		case "data":
+			data++
+			if size < 16 {
+				v.add(Error, MP4, pos, "\"data\" atom in item %q is too short (%d bytes)", name, size)
+				break
+			}
+			b, err := readBytes(r, 8)
+			if err != nil {
+				return err
+			}
+			if b[0] != 0 {
+				v.add(Warning, MP4, pos+8, "\"data\" atom in item %q has unknown version %d", name, b[0])
+			}
+			if _, ok := atomTypes[getInt(b[1:4])]; !ok {
+				v.add(Warning, MP4, pos+9, "\"data\" atom in item %q has unknown type %d", name, getInt(b[1:4]))
+			}
+		case "mean", "name":
+			if name != "----" {
+				v.add(Warning, MP4, pos, "unexpected %q atom in item %q", child, name)
+			}
+		default:
+			v.add(Warning, MP4, pos, "unexpected %q atom in item %q", child, name)
+		}
+		pos += size
+	}
+	if data == 0 {
+		v.add(Error, MP4, start-8, "item %q has no \"data\" atom", name)
+	}
+	return nil
+}
+
+func mp4Parent(parent string) string {
+	if parent == "" {
+		return "the file"
+	}
+	return fmt.Sprintf("%q", parent)
+}
+
+func containsString(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// flac validates the metadata blocks of a FLAC file.
+func (v *validator) flac(r io.ReadSeeker) error {
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+
+	pos := int64(4)
+	var comments int
+	for i := 0; ; i++ {
+		h, err := readBytes(r, 4)
+		if err != nil {
+			v.add(Error, VORBIS, pos, "truncated metadata block header (no block has the last flag set)")
+			return nil
+		}
+		last := getBit(h[0], 7)
+		t := blockType(h[0] &^ (1 << 7))
+		size := int64(getInt(h[1:]))
+
+		if i == 0 && t != 0 {
+			v.add(Error, VORBIS, pos, "first metadata block is type %d, expected STREAMINFO", t)
+		}
+		if i > 0 && t == 0 {
+			v.add(Error, VORBIS, pos, "multiple STREAMINFO blocks")
+		}
+		if t == 127 {
+			v.add(Error, VORBIS, pos, "invalid metadata block type 127")
+		}
+
+		b, err := readBytes(r, uint(size))
+		if err != nil {
+			v.add(Error, VORBIS, pos, "metadata block type %d (size %d) extends beyond the end of the file", t, size)
+			return nil
+		}
+
+		switch t {
+		case 0:
+			if size != 34 {
+				v.add(Error, VORBIS, pos, "STREAMINFO block size %d, expected 34", size)
+			}
+		case vorbisCommentBlock:
+			comments++
+			if comments == 2 {
+				v.add(Error, VORBIS, pos, "multiple VORBIS_COMMENT blocks")
+			}
+			v.vorbisComment(pos+4, b)
+		case pictureBlock:
+			v.flacPicture(pos+4, b)
+		}
+
+		pos += 4 + size
+		if last {
+			return nil
+		}
+	}
+}
+
+// vorbisComment validates the framing of the Vorbis comment header in b (at offset).
+func (v *validator) vorbisComment(offset int64, b []byte) {
+	pos := int64(0)
+	next := func(what string) (int64, bool) {
+		if pos+4 > int64(len(b)) {
+			v.add(Error, VORBIS, offset+pos, "truncated %s length", what)
+			return 0, false
+		}
+		n := int64(binary.LittleEndian.Uint32(b[pos:]))
+		pos += 4
+		return n, true
+	}
+
+	n, ok := next("vendor string")
+	if !ok {
+		return
+	}
+	if pos+n > int64(len(b)) {
+		v.add(Error, VORBIS, offset, "vendor string length %d extends beyond the end of the block", n)
+		return
+	}
+	pos += n
+
+	count, ok := next("comment list")
+	if !ok {
+		return
+	}
+	for i := int64(0); i < count; i++ {
+		start := pos
+		n, ok := next("comment")
+		if !ok {
+			return
+		}
+		if pos+n > int64(len(b)) {
+			v.add(Error, VORBIS, offset+start, "comment %d length %d extends beyond the end of the block", i, n)
+			return
+		}
+		c := b[pos : pos+n]
+		pos += n
+
+		eq := bytes.IndexByte(c, '=')
+		if eq < 0 {
+			v.add(Error, VORBIS, offset+start, "comment %d does not contain '='", i)
+			continue
+		}
+		if eq == 0 {
+			v.add(Error, VORBIS, offset+start, "comment %d has an empty field name", i)
+		}
+		for _, x := range c[:eq] {
+			if x < 0x20 || x > 0x7d {
+				v.add(Error, VORBIS, offset+start, "comment %d field name %q contains invalid characters", i, c[:eq])
+				break
+			}
+		}
+		if !utf8.Valid(c[eq+1:]) {
+			v.add(Error, VORBIS, offset+start, "comment %d (%s) is not valid UTF-8", i, c[:eq])
+		}
+	}
+	if pos != int64(len(b)) {
+		v.add(Warning, VORBIS, offset+pos, "%d unused bytes after the comment list", int64(len(b))-pos)
+	}
+}
+
+// flacPicture validates the framing of the FLAC picture block in b (at offset).
+func (v *validator) flacPicture(offset int64, b []byte) {
+	if len(b) < 32 {
+		v.add(Error, VORBIS, offset, "truncated PICTURE block")
+		return
+	}
+	if t := binary.BigEndian.Uint32(b); t > 20 {
+		v.add(Error, VORBIS, offset, "invalid picture type %d", t)
+	}
+
+	pos := int64(4)
+	for _, what := range []string{"MIME type", "description"} {
+		n := int64(binary.BigEndian.Uint32(b[pos:]))
+		pos += 4 + n
+		if pos+4 > int64(len(b)) {
+			v.add(Error, VORBIS, offset, "picture %s length %d extends beyond the end of the block", what, n)
+			return
+		}
+	}
+	pos += 16 // width, height, color depth, colors used
+	if pos+4 > int64(len(b)) {
+		v.add(Error, VORBIS, offset, "truncated PICTURE block")
+		return
+	}
+	if n := int64(binary.BigEndian.Uint32(b[pos:])); pos+4+n != int64(len(b)) {
+		v.add(Error, VORBIS, offset, "picture data length %d does not match the block size", n)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"testing"
+)
+
+// testAtom returns an MP4 atom with the given name and contents.
+func testAtom(name string, contents ...[]byte) []byte {
+	b := bytes.Join(contents, nil)
+	h := make([]byte, 8)
+	binary.BigEndian.PutUint32(h, uint32(8+len(b)))
+	copy(h[4:], name)
+	return append(h, b...)
+}
+
+func TestValidate(t *testing.T) {
+	audio := []byte("\xff\xfbaudio data")
+	title := testID3v23Frame("TIT2", []byte("\x00Title"))
+	join := func(bs ...[]byte) []byte { return bytes.Join(bs, nil) }
+
+	textData := testAtom("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte("Title"))
+	ftyp := testAtom("ftyp", []byte("M4A \x00\x00\x00\x00"))
+
+	// ID3v2.3 and ID3v2.4 tags with the CRC-32 of their frames in the extended header.
+	crc := crc32.ChecksumIEEE(title)
+	crcPadding := crc32.ChecksumIEEE(join(title, make([]byte, 4)))
+	ext23 := []byte{0, 0, 0, 10, 0x80, 0, 0, 0, 0, 4, byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)}
+	ext24 := []byte{0, 0, 0, 12, 1, 0x20, 5, byte(crcPadding >> 28), byte(crcPadding >> 21 & 0x7f),
+		byte(crcPadding >> 14 & 0x7f), byte(crcPadding >> 7 & 0x7f), byte(crcPadding & 0x7f)}
+	crcTag := func(vers byte, ext []byte, corrupt bool) []byte {
+		b := testID3v23(len(ext)+len(title)+4, ext, title, make([]byte, 4))
+		b[3], b[5] = vers, 0x40
+		if corrupt {
+			b[len(b)-5] = 'x' // the last byte of the title
+		}
+		return join(b, audio)
+	}
+
+	tests := []struct {
+		input    []byte
+		expected []Finding
+	}{
+		{join(testID3v23(len(title)+10, title, make([]byte, 10)), audio), nil},
+		{crcTag(3, ext23, false), nil},
+		{
+			crcTag(3, ext23, true),
+			[]Finding{{Error, ID3v2_3, 20, fmt.Sprintf("CRC-32 mismatch: extended header has %08x, tag data has %08x", crc, crc32.ChecksumIEEE(join(title[:len(title)-1], []byte("x"))))}},
+		},
+		{crcTag(4, ext24, false), nil},
+		{
+			crcTag(4, ext24, true),
+			[]Finding{{Error, ID3v2_4, 17, fmt.Sprintf("CRC-32 mismatch: extended header has %08x, tag data has %08x", crcPadding, crc32.ChecksumIEEE(join(title[:len(title)-1], []byte("x"), make([]byte, 4))))}},
+		},
+		{
+			join(testID3v23(len(title)+3, title, []byte{0, 0, 1}), audio),
+			[]Finding{{Warning, ID3v2_3, 10 + int64(len(title)) + 2, "non-zero byte in padding"}},
+		},
+		{
+			join(testID3v23(12, testID3v23Frame("TIT2", []byte("\x05x"))), audio),
+			[]Finding{{Error, ID3v2_3, 10, `frame "TIT2": invalid text encoding 5`}},
+		},
+		{
+			join(testID3v23(len(title), testID3v23Frame("TIT2", []byte("\x00Titlexxxxxxxxxx")))),
+			[]Finding{{Error, ID3v2_3, 10, `frame "TIT2" size 16 extends beyond the end of the tag`}},
+		},
+		{
+			join(testID3v2Tag(0), testID3v2Tag(0), audio, testAPETag(), testID3v1Tag()),
+			[]Finding{
+				{Warning, ID3v2_3, 10, "multiple ID3v2 tags"},
+				{Warning, UnknownFormat, 20 + int64(len(audio)), "APE tag in MP3 file"},
+				{Warning, ID3v1, 84 + int64(len(audio)), "ID3v1 tag duplicates the ID3v2 tag"},
+			},
+		},
+		{
+			[]byte("ID3\x04\x00\x00\x00\x00\x00\x90"),
+			[]Finding{
+				{Error, ID3v2_4, 6, "tag size is not a synchsafe integer"},
+				{Error, ID3v2_4, 0, "tag size 144 extends beyond the end of the file"},
+			},
+		},
+		{
+			join(ftyp, testAtom("moov", testAtom("udta", testAtom("meta", make([]byte, 4), testAtom("ilst", testAtom("\xa9nam", textData)))))),
+			nil,
+		},
+		{
+			join(ftyp, testAtom("moov", testAtom("ilst", testAtom("\xa9nam", testAtom("name", []byte("x")))))),
+			[]Finding{
+				{Error, MP4, 24, `atom "ilst" inside "moov", expected it inside "meta"`},
+				{Warning, MP4, 40, `unexpected "name" atom in item "\xa9nam"`},
+				{Error, MP4, 32, `item "\xa9nam" has no "data" atom`},
+			},
+		},
+		{
+			join(ftyp, testAtom("moov"), testAtom("moov")),
+			[]Finding{{Error, MP4, 24, `multiple "moov" atoms`}},
+		},
+		{
+			testFLAC(t, []string{"TITLE=Title", "NOEQUALS", "=x"}, 0),
+			[]Finding{
+				{Error, VORBIS, 73, "comment 1 does not contain '='"},
+				{Error, VORBIS, 85, "comment 2 has an empty field name"},
+			},
+		},
+	}
+
+	for ii, tt := range tests {
+		findings, err := Validate(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %v", ii, err)
+			continue
+		}
+		if len(findings) != len(tt.expected) {
+			t.Errorf("[%d] Validate = %v, expected %v", ii, findings, tt.expected)
+			continue
+		}
+		for i := range findings {
+			if findings[i] != tt.expected[i] {
+				t.Errorf("[%d] finding %d = %v, expected %v", ii, i, findings[i], tt.expected[i])
+			}
+		}
+	}
+}
@@ -5,56 +5,73 @@
 package audiotag
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 )
 
-func newMetadataVorbis() *metadataVorbis {
+func newMetadataVorbis(rc *readContext) *metadataVorbis {
 	return &metadataVorbis{
-		c: make(map[string]string),
+		c:  make(map[string]string),
+		rc: rc,
 	}
 }
 
-type metadataVorbis struct {
-	c map[string]string // the vorbis comments
-	p *Picture
+// vorbisCommentsOf returns the Vorbis comments of m (the metadata of a FLAC or OGG file),
+// or nil if m has none.
+func vorbisCommentsOf(m Metadata) map[string]string {
+	var v *metadataVorbis
+	switch m := m.(type) {
+	case *metadataFLAC:
+		v = m.metadataVorbis
+	case *metadataOGG:
+		v = m.metadataVorbis
+	}
+	if v == nil {
+		return nil
+	}
+	return v.c
 }
 
-func (m *metadataVorbis) readVorbisComment(r io.Reader) error {
-	vendorLen, err := readUint32LittleEndian(r)
-	if err != nil {
-		return err
-	}
+type metadataVorbis struct {
+	warnings
+	unknownTags
+	c  map[string]string // the vorbis comments
+	p  *Picture
+	rc *readContext // the options the comments are read with
+}
 
-	vendor, err := readString(r, uint(vendorLen))
+// readVorbisComment reads the Vorbis comment header from r, which is at the given offset
+// in the input (or -1 if unknown).
+func (m *metadataVorbis) readVorbisComment(r io.Reader, offset int64) error {
+	vendor, comments, err := readVorbisCommentList(r)
 	if err != nil {
 		return err
 	}
 	m.c["vendor"] = vendor
 
-	commentsLen, err := readUint32LittleEndian(r)
-	if err != nil {
-		return err
-	}
-
-	for i := uint32(0); i < commentsLen; i++ {
-		l, err := readUint32LittleEndian(r)
+	pos := offset + 4 + int64(len(vendor)) + 4
+	for _, s := range comments {
+		k, v, err := parseComment(s, m.rc)
 		if err != nil {
-			return err
+			if offset < 0 {
+				pos = -1
+			}
+			// Lenient: skip the malformed comment.
+			if err := m.rc.structureViolation(&m.warnings, VORBIS, fmt.Sprintf("comment %q", s), pos, err); err != nil {
+				return err
+			}
+		} else if old, ok := m.c[strings.ToLower(k)]; ok {
+			m.c[strings.ToLower(k)] = m.rc.mergeDuplicate(old, v).(string)
+		} else {
+			m.c[strings.ToLower(k)] = v
 		}
-		s, err := readString(r, uint(l))
-		if err != nil {
-			return err
-		}
-		k, v, err := parseComment(s)
-		if err != nil {
-			return err
-		}
-		m.c[strings.ToLower(k)] = v
+		pos += 4 + int64(len(s))
 	}
 	return nil
 }
@@ -134,14 +151,14 @@ func (m *metadataVorbis) readPictureBlock(r io.Reader) error {
 	return nil
 }
 
-func parseComment(c string) (k, v string, err error) {
+func parseComment(c string, rc *readContext) (k, v string, err error) {
 	kv := strings.SplitN(c, "=", 2)
 	if len(kv) != 2 {
 		err = errors.New("vorbis comment must contain '='")
 		return
 	}
 	k = kv[0]
-	v = kv[1]
+	v = rc.sanitizeText(kv[1])
 	return
 }
 
@@ -202,23 +219,9 @@ func (m *metadataVorbis) Genre() string {
 }
 
 func (m *metadataVorbis) Year() int {
-	var dateFormat string
-
-	// The date need to follow the international standard https://en.wikipedia.org/wiki/ISO_8601
-	// and obviously the VorbisComment standard https://wiki.xiph.org/VorbisComment#Date_and_time
-	switch len(m.c["date"]) {
-	case 0:
-		return 0
-	case 4:
-		dateFormat = "2006"
-	case 7:
-		dateFormat = "2006-01"
-	case 10:
-		dateFormat = "2006-01-02"
-	}
-
-	t, _ := time.Parse(dateFormat, m.c["date"])
-	return t.Year()
+	// The date should follow ISO 8601 (see https://wiki.xiph.org/VorbisComment#Date_and_time),
+	// but often does not.
+	return parseYear(m.c["date"])
 }
 
 func (m *metadataVorbis) Track() (int, int) {
@@ -253,3 +256,210 @@ func (m *metadataVorbis) Picture() *Picture {
 func (m metadataVorbis) Duration() int {
 	return 0
 }
+
+// Chapters returns the chapters given by the Vorbis chapter extension comments
+// (CHAPTER001=00:00:00.000, CHAPTER001NAME=Title).
+// See https://wiki.xiph.org/Chapter_Extension.
+func (m *metadataVorbis) Chapters() []Chapter {
+	var chapters []Chapter
+	for i := 0; i < 1000; i++ {
+		k := fmt.Sprintf("chapter%03d", i)
+		start, ok := m.c[k]
+		if !ok {
+			if i == 0 {
+				continue // numbering may start from 0 or 1
+			}
+			break
+		}
+		d, err := parseChapterTime(start)
+		if err != nil {
+			continue
+		}
+		if len(chapters) > 0 {
+			chapters[len(chapters)-1].EndTime = formatChapterSeconds(d)
+		}
+		chapters = append(chapters, Chapter{
+			id:        uint8(len(chapters)),
+			StartTime: formatChapterSeconds(d),
+			Title:     m.c[k+"name"],
+		})
+	}
+	return chapters
+}
+
+func (m *metadataVorbis) Conflicts() []Conflict {
+	return nil
+}
+
+func (m *metadataVorbis) BPM() float64 {
+	return parseBPM(m.c["bpm"])
+}
+
+func (m *metadataVorbis) Key() Key {
+	if m.c["initialkey"] != "" {
+		return ParseKey(m.c["initialkey"])
+	}
+	return ParseKey(m.c["key"])
+}
+
+// vorbisFields maps field names (as used by Edit) to Vorbis comment field names.
+// See https://wiki.xiph.org/Field_names.
+var vorbisFields = map[string]string{
+	"title":        CommentTitle,
+	"album":        CommentAlbum,
+	"artist":       CommentArtist,
+	"album_artist": CommentAlbumArtist,
+	"composer":     CommentComposer,
+	"genre":        CommentGenre,
+	"year":         CommentDate,
+	"track":        CommentTrack,
+	"track_total":  CommentTrackTotal,
+	"disc":         CommentDisc,
+	"disc_total":   CommentDiscTotal,
+	"comment":      CommentComment,
+	"lyrics":       CommentLyrics,
+	"bpm":          CommentBPM,
+	"key":          CommentKey,
+}
+
+// vorbisChapterRe matches the field names of the Vorbis chapter extension.
+var vorbisChapterRe = regexp.MustCompile(`(?i)^CHAPTER[0-9]{3}(NAME|URL)?$`)
+
+// vorbisFieldName returns the Vorbis comment field name for the Edit field name.
+func vorbisFieldName(name string) string {
+	if n, ok := vorbisFields[name]; ok {
+		return n
+	}
+	return strings.ToUpper(name)
+}
+
+// editVorbisComments applies the edit to the list of "KEY=value" comments, preserving
+// the order of the existing comments which are not changed.
+func editVorbisComments(comments []string, e *Edit) ([]string, error) {
+	set := make(map[string]string, len(e.Fields))
+	for k, v := range e.Fields {
+		set[vorbisFieldName(k)] = v
+	}
+
+	var result []string
+	if !e.Clear {
+		for _, c := range comments {
+			k, _, err := parseComment(c, defaultReadContext())
+			if err != nil {
+				continue
+			}
+			if _, ok := set[strings.ToUpper(k)]; ok {
+				continue
+			}
+			if e.Chapters != nil && vorbisChapterRe.MatchString(k) {
+				continue
+			}
+			result = append(result, c)
+		}
+	}
+
+	for i, c := range e.Chapters {
+		d, err := parseChapterTime(c.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		k := fmt.Sprintf("CHAPTER%03d", i+1)
+		result = append(result, k+"="+formatChapterTime(d))
+		if c.Title != "" {
+			result = append(result, k+"NAME="+c.Title)
+		}
+	}
+
+	// Add new fields in a stable order: known fields first, then custom ones.
+	var names []string
+	for _, n := range []string{"title", "album", "artist", "album_artist", "composer", "genre", "year",
+		"track", "track_total", "disc", "disc_total", "comment", "lyrics", "bpm", "key"} {
+		if _, ok := e.Fields[n]; ok {
+			names = append(names, n)
+		}
+	}
+	var custom []string
+	for n := range e.Fields {
+		if _, ok := vorbisFields[n]; !ok {
+			custom = append(custom, n)
+		}
+	}
+	sort.Strings(custom)
+	for _, n := range append(names, custom...) {
+		if v := e.Fields[n]; v != "" {
+			result = append(result, vorbisFieldName(n)+"="+v)
+		}
+	}
+	return result, nil
+}
+
+// writeVorbisComment writes a Vorbis comment header (without framing bit) to w.
+func writeVorbisComment(w io.Writer, vendor string, comments []string) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(vendor))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, vendor); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(comments))); err != nil {
+		return err
+	}
+	for _, c := range comments {
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(c))); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readVorbisCommentList reads a Vorbis comment header, returning the vendor string and
+// the comments in the order they are given.
+func readVorbisCommentList(r io.Reader) (vendor string, comments []string, err error) {
+	vendorLen, err := readUint32LittleEndian(r)
+	if err != nil {
+		return
+	}
+	vendor, err = readString(r, uint(vendorLen))
+	if err != nil {
+		return
+	}
+
+	commentsLen, err := readUint32LittleEndian(r)
+	if err != nil {
+		return
+	}
+	for i := uint32(0); i < commentsLen; i++ {
+		var l uint32
+		l, err = readUint32LittleEndian(r)
+		if err != nil {
+			return
+		}
+		var s string
+		s, err = readString(r, uint(l))
+		if err != nil {
+			return
+		}
+		comments = append(comments, s)
+	}
+	return
+}
+
+// writePictureBlock writes the picture in the FLAC picture block format (which is
+// also used for METADATA_BLOCK_PICTURE Vorbis comments).
+func writePictureBlock(w io.Writer, p *Picture) error {
+	for _, x := range []interface{}{
+		uint32(pictureTypeIndex(p.Type)),
+		uint32(len(p.MIMEType)), []byte(p.MIMEType),
+		uint32(len(p.Description)), []byte(p.Description),
+		uint32(0), uint32(0), uint32(0), uint32(0), // width, height, color depth, colors used
+		uint32(len(p.Data)), p.Data,
+	} {
+		if err := binary.Write(w, binary.BigEndian, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2015, David Howden
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audiotag
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrWriteNotSupported is the error returned by WriteTags when writing tags is not
+// supported for the format of the file.
+var ErrWriteNotSupported = errors.New("writing tags is not supported for this format")
+
+// Edit is a set of changes to make to the tags of a file.
+type Edit struct {
+	// Fields to set, keyed by field name ("title", "album", "artist", "album_artist",
+	// "composer", "genre", "year", "track", "track_total", "disc", "disc_total",
+	// "comment", "lyrics", "bpm" and "key").  An empty value removes the field.  Other
+	// names are written as format-specific custom fields where the format supports them.
+	Fields map[string]string
+
+	// Pictures replaces all of the attached pictures if non-nil (so an empty non-nil
+	// slice removes them).
+	Pictures []*Picture
+
+	// Chapters replaces all of the chapter markers if non-nil.
+	Chapters []Chapter
+
+	// Clear removes all existing tags before the edit is applied.
+	Clear bool
+
+	// Scrub removes the metadata which identifies the buyer of a purchased file (the
+	// AccountAtoms of MP4 files) before the edit is applied, so that the file can be
+	// shared.
+	Scrub bool
+
+	// ID3v2Version is the version (ID3v2_3 or ID3v2_4) of the ID3v2 tags written to MP3
+	// files, if non-empty.  Existing tags of the other version are converted to it.
+	ID3v2Version Format
+}
+
+// WriteTags copies the file data from r to w, updating its tags with the Edit.  Returns
+// ErrWriteNotSupported if writing tags is not supported for the format of the data.
+func WriteTags(w io.Writer, r io.ReadSeeker, e *Edit) error {
+	b, err := readBytes(r, 11)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Seek(-11, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("could not seek back to original position: %v", err)
+	}
+
+	switch {
+	case string(b[0:4]) == "fLaC":
+		return WriteFLACTags(w, r, e)
+
+	case string(b[4:8]) == "ftyp":
+		return WriteMP4Tags(w, r, e)
+
+	case string(b[0:3]) == "ID3", b[0] == 0xff && b[1]&0xe0 == 0xe0:
+		return WriteID3v2Tags(w, r, e)
+	}
+
+	mp4, err := isMP4(r)
+	if err != nil {
+		return err
+	}
+	if mp4 {
+		return WriteMP4Tags(w, r, e)
+	}
+	return ErrWriteNotSupported
+}
+
+// pictureTypeIndex returns the ID3v2/FLAC picture type index for the picture type
+// description, defaulting to the front cover.
+func pictureTypeIndex(t string) byte {
+	for k, v := range pictureTypes {
+		if v == t {
+			return k
+		}
+	}
+	return 0x03
+}